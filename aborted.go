@@ -0,0 +1,30 @@
+package wedge
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// StatusClientClosed is the status a view returns when it stopped
+// because its client went away, which is what nginx logs such requests
+// with. Nothing is sent for it, and the request is counted as aborted.
+const StatusClientClosed = 499
+
+// clientGone reports whether the client of req went away before its
+// response was finished, which net/http notices when the connection is
+// closed. Views see the same through req.Context(), and should stop
+// working once it's done.
+func clientGone(req *http.Request) bool {
+	return errors.Is(req.Context().Err(), context.Canceled)
+}
+
+// handleAborted records a request whose client went away, counting it
+// in the statistics under "Aborted".
+func (App *AppServer) handleAborted(req *http.Request) {
+	log.Println("Aborted:", req.URL.Path)
+	if App.stat_map != nil {
+		App.incrementStats("Aborted => " + App.statPath(req))
+	}
+}
@@ -0,0 +1,65 @@
+package wedge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAborted(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.EnableStatTracking()
+	App.Handler500(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		t.Errorf("%s: an aborted request got the 500 handler", req.URL.Path)
+		return "", http.StatusInternalServerError
+	})
+	waiting := make(chan bool)
+	App.AddURLs(
+		Handle("^/wait/$", func(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+			waiting <- true
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		URL("^/legacy/$", "Legacy", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "", StatusClientClosed
+		}, HTML),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-waiting
+		cancel()
+	}()
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/wait/", nil).WithContext(ctx),
+		httptest.NewRequest("GET", "/legacy/", nil),
+	} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		if w.Body.Len() != 0 {
+			t.Errorf("%s: got %q", req.URL.Path, w.Body)
+		}
+	}
+	App.flushStats(context.Background())
+	for _, path := range []string{"/wait/", "/legacy/"} {
+		if n, _ := App.stat_map.Find("Aborted =&gt; " + path).(int); n != 1 {
+			t.Errorf("%s: counted %d aborts", path, n)
+		}
+	}
+}
+
+func TestCoalesceOutlivesClient(t *testing.T) {
+	route := Route("^/slow/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		if req.Context().Err() != nil {
+			return "", StatusClientClosed
+		}
+		return "slow page", http.StatusOK
+	}, Coalesce())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/slow/", nil).WithContext(ctx)
+	if resp, status := route.coalesce.do(httptest.NewRecorder(), req, route); status != http.StatusOK {
+		t.Errorf("got (%q, %d) after the first client left", resp, status)
+	}
+}
@@ -0,0 +1,45 @@
+package wedge
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// acmePrefix is where ACME servers look for HTTP-01 challenge responses.
+const acmePrefix = "/.well-known/acme-challenge/"
+
+// ACMEChallenge serves the HTTP-01 challenge responses which an ACME
+// client such as certbot writes into dir, for example with
+// "certbot certonly --webroot -w /var/www/acme", which writes them into
+// /var/www/acme/.well-known/acme-challenge.
+//
+// Example:
+//
+//	App.AddURLs(wedge.ACMEChallenge("/var/www/acme/.well-known/acme-challenge"))
+func ACMEChallenge(dir string) *Rule {
+	return ACMEChallengeFunc(func(token string) (string, bool) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, token))
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	})
+}
+
+// ACMEChallengeFunc serves HTTP-01 challenge responses from fn, which is
+// given the token from the URL and returns the key authorization for it,
+// for ACME clients which keep them somewhere other than in files.
+func ACMEChallengeFunc(fn func(token string) (string, bool)) *Rule {
+	return makeurl("^"+strings.Replace(acmePrefix, ".", `\.`, -1)+"[A-Za-z0-9_-]+$", "ACME Challenge",
+		func(w http.ResponseWriter, req *http.Request) (string, int) {
+			token := req.URL.Path[len(acmePrefix):]
+			keyAuth, ok := fn(token)
+			if !ok {
+				return "", http.StatusNotFound
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			return strings.TrimSpace(keyAuth), http.StatusOK
+		}, HTML, 0).SetMeta(wellKnownMeta, "acme-challenge")
+}
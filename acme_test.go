@@ -0,0 +1,41 @@
+package wedge
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestACMEChallenge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "tok-EN_1"), []byte("tok-EN_1.thumbprint\n"), 0644)
+
+	App := NewAppServer("8080", 30)
+	App.AddURLs(ACMEChallenge(dir))
+
+	tests := map[string]int{
+		"/.well-known/acme-challenge/tok-EN_1":     200,
+		"/.well-known/acme-challenge/missing":      404,
+		"/.well-known/acme-challenge/../acme_test": 404,
+		"/.well-known/acme-challenge/":             404,
+	}
+	for path, status := range tests {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != status {
+			t.Errorf("%s: got %d, want %d", path, w.Code, status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/acme-challenge/tok-EN_1", nil))
+	if body := w.Body.String(); body != "tok-EN_1.thumbprint" {
+		t.Errorf("got %q", body)
+	}
+}
@@ -0,0 +1,130 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// adaptive is the configuration of AdaptiveCache.
+type adaptive struct {
+	threshold time.Duration
+	ttl       time.Duration
+}
+
+// AdaptiveCache caches the route's responses for ttl only when they take
+// longer than threshold to generate. Fast pages are always fresh, while a
+// page which becomes slow, say because the database is struggling, is
+// protected until it speeds up again. Only GET and HEAD requests are
+// cached, and only successful responses.
+//
+// How long the view takes is shown on the statistics page, if stat
+// tracking is enabled.
+//
+// Example:
+//
+//	wedge.URL("^/reports/$", "Reports", Reports, wedge.HTML).
+//		AdaptiveCache(200*time.Millisecond, time.Minute)
+func (u *Rule) AdaptiveCache(threshold, ttl time.Duration) *Rule {
+	u.adaptive = &adaptive{threshold, ttl}
+	return u
+}
+
+// AdaptiveCache is the Option form of Rule.AdaptiveCache.
+func AdaptiveCache(threshold, ttl time.Duration) Option {
+	return func(u *Rule) {
+		u.AdaptiveCache(threshold, ttl)
+	}
+}
+
+// adaptiveResponse returns a cached response for req if a slow one was
+// stored, and otherwise calls the view and times it.
+func (App *AppServer) adaptiveResponse(w http.ResponseWriter, req *http.Request, route *Rule) (string, int) {
+	cacheable := safeMethod(req)
+	key := "adaptive\x00" + cacheKey(req, route)
+	if cacheable {
+		if resp, ok := App.cachedResponse(key, time.Now()); ok {
+			App.replayHeaders(w, key)
+			return resp, http.StatusOK
+		}
+	}
+
+	start := time.Now()
+	cookies := cookieCount(w)
+	before := w.Header().Clone()
+	resp, status := App.callView(w, req, route)
+	elapsed := time.Since(start)
+	slow := elapsed >= route.adaptive.threshold
+	if App.timings != nil {
+		App.timings.record(route.name, elapsed, slow)
+	}
+	if cacheable && slow && status == http.StatusOK && route.shareable(w, cookies) {
+		App.cacheResponse(key, resp, time.Now().Add(route.adaptive.ttl), requestTags(req, route))
+		App.cacheHeaders(key, before, w.Header(), requestTags(req, route))
+	}
+	return resp, status
+}
+
+// timings holds how long the views of adaptively cached routes take.
+type timings struct {
+	sync.Mutex
+	routes map[string]*timing
+}
+
+type timing struct {
+	count int
+	slow  int
+	total time.Duration
+	max   time.Duration
+}
+
+func newTimings() *timings {
+	return &timings{routes: make(map[string]*timing)}
+}
+
+func (t *timings) record(route string, elapsed time.Duration, slow bool) {
+	t.Lock()
+	defer t.Unlock()
+	r, ok := t.routes[route]
+	if !ok {
+		r = &timing{}
+		t.routes[route] = r
+	}
+	r.count++
+	r.total += elapsed
+	if elapsed > r.max {
+		r.max = elapsed
+	}
+	if slow {
+		r.slow++
+	}
+}
+
+// html renders the timings as a table, or nothing if there are none.
+func (t *timings) html() string {
+	t.Lock()
+	defer t.Unlock()
+	if len(t.routes) == 0 {
+		return ""
+	}
+	var names []string
+	for name := range t.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(`<p>Response times</p><table border="2"><tr><th>Route</th><th>Calls</th>` +
+		`<th>Mean</th><th>Max</th><th>Cached as slow</th></tr>`)
+	for _, name := range names {
+		r := t.routes[name]
+		fmt.Fprintf(buf, "<tr><td>%s</td><td>%d</td><td>%s</td><td>%s</td><td>%d</td></tr>",
+			template.HTMLEscapeString(name), r.count, r.total/time.Duration(r.count), r.max, r.slow)
+	}
+	buf.WriteString(`</table>`)
+	return buf.String()
+}
@@ -0,0 +1,460 @@
+// Package admin generates pages for listing, creating, editing and
+// deleting objects from struct definitions, using the forms package for
+// validation, in the manner of a small Django admin.
+//
+// Models are structs whose fields are described by "admin" tags, and are
+// kept in a Store. The Admin answers every page below its Prefix from a
+// single view:
+//
+//	type Post struct {
+//		ID    string `admin:"id,list"`
+//		Title string `admin:"label=Title,max=200,list,search"`
+//		Draft bool   `admin:"list"`
+//	}
+//
+//	site := &admin.Admin{
+//		Authenticate: func(req *http.Request) (string, bool) {
+//			return currentUser(req)
+//		},
+//	}
+//	site.Register("posts", Post{}, admin.NewMemoryStore())
+//	App.AddURLs(wedge.URL("^/admin/", "admin", site.Serve, wedge.HTML))
+package admin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wedge"
+	"wedge/forms"
+)
+
+// Action is something a user can do to the objects of a model.
+type Action string
+
+const (
+	View   Action = "view"
+	Create Action = "create"
+	Edit   Action = "edit"
+	Delete Action = "delete"
+)
+
+// PerPage is how many objects the list pages show at once, unless the
+// Admin sets its own.
+const PerPage = 25
+
+// MaxFileSize is the largest file which can be uploaded to a field tagged
+// file.
+var MaxFileSize int64 = 10 << 20
+
+// ErrNotFound is returned by a Store which has no object with an id.
+var ErrNotFound = errors.New("admin: object not found")
+
+// Store keeps the objects of a model. Objects are pointers to the model's
+// struct.
+type Store interface {
+	// List returns the objects from offset up to limit which match query,
+	// found with Model.Matches, along with how many match in total.
+	List(m *Model, query string, offset, limit int) ([]interface{}, int, error)
+	Get(m *Model, id string) (interface{}, error)
+	// Save stores obj, which is new if its id is the zero value. The Store
+	// should then give it an id.
+	Save(m *Model, obj interface{}) error
+	Delete(m *Model, id string) error
+}
+
+// Admin serves the admin pages for the models registered with it.
+type Admin struct {
+	// Prefix is the path the Admin is mounted at, "/admin/" if it's empty.
+	Prefix string
+	// Authenticate returns the user making req, if there is one. Every
+	// page is refused until it's set.
+	Authenticate func(req *http.Request) (user string, ok bool)
+	// LoginURL is where users who aren't authenticated are sent. Without
+	// it they're told the page doesn't exist.
+	LoginURL string
+	// Allow reports whether user may do action to the objects of model.
+	// Authenticated users may do anything if it isn't set.
+	Allow func(user, model string, action Action) bool
+	// PerPage overrides the package's PerPage.
+	PerPage int
+	// Storage keeps the files uploaded to fields tagged file, under the
+	// model's name. Models with such fields can't be registered without
+	// it.
+	Storage wedge.Storage
+
+	mu     sync.RWMutex
+	models map[string]*Model
+	names  []string
+}
+
+// Register adds a model named name, whose objects are structs of the
+// same type as proto and are kept in store. It panics if the struct's tags
+// can't be understood, as it's expected to be called at start up.
+func (a *Admin) Register(name string, proto interface{}, store Store) *Model {
+	m, err := parseModel(name, proto)
+	if err != nil {
+		panic(err)
+	}
+	if len(m.files()) > 0 && a.Storage == nil {
+		panic("admin: " + name + " has files but the Admin has no Storage")
+	}
+	m.Store = store
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.models == nil {
+		a.models = make(map[string]*Model)
+	}
+	if _, ok := a.models[name]; !ok {
+		a.names = append(a.names, name)
+		sort.Strings(a.names)
+	}
+	a.models[name] = m
+	return m
+}
+
+// Model returns the model registered as name, or nil.
+func (a *Admin) Model(name string) *Model {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.models[name]
+}
+
+func (a *Admin) prefix() string {
+	if a.Prefix == "" {
+		return "/admin/"
+	}
+	return a.Prefix
+}
+
+func (a *Admin) perPage() int {
+	if a.PerPage > 0 {
+		return a.PerPage
+	}
+	return PerPage
+}
+
+func (a *Admin) allowed(user, model string, action Action) bool {
+	return a.Allow == nil || a.Allow(user, model, action)
+}
+
+// Serve is a wedge view answering every admin page, to be mounted at the
+// Prefix. The pages are:
+//
+//	Prefix                  the registered models
+//	Prefix/model/           the model's objects, taking page and q parameters
+//	Prefix/model/new/       a form for a new object
+//	Prefix/model/id/        a form editing the object
+//	Prefix/model/id/delete/ confirming the object's deletion
+//
+// Pages the user isn't allowed to see are answered as not found, as are
+// all of them if Authenticate isn't set.
+func (a *Admin) Serve(w http.ResponseWriter, req *http.Request) (string, int) {
+	if a.Authenticate == nil {
+		log.Println("admin: refusing request, Authenticate isn't set")
+		return "", http.StatusNotFound
+	}
+	user, ok := a.Authenticate(req)
+	if !ok {
+		if a.LoginURL != "" {
+			return forms.SeeOther(a.LoginURL + "?next=" + url.QueryEscape(req.URL.Path))
+		}
+		return "", http.StatusNotFound
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, a.prefix())
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if parts[0] == "" {
+		return a.index(user)
+	}
+	m := a.Model(parts[0])
+	if m == nil {
+		return "", http.StatusNotFound
+	}
+	switch {
+	case len(parts) == 1:
+		return a.list(m, user, req)
+	case len(parts) == 2 && parts[1] == "new":
+		return a.edit(m, user, "", req)
+	case len(parts) == 2:
+		return a.edit(m, user, parts[1], req)
+	case len(parts) == 3 && parts[2] == "delete":
+		return a.remove(m, user, parts[1], req)
+	}
+	return "", http.StatusNotFound
+}
+
+func (a *Admin) index(user string) (string, int) {
+	a.mu.RLock()
+	var names []string
+	for _, name := range a.names {
+		if a.allowed(user, name, View) {
+			names = append(names, name)
+		}
+	}
+	a.mu.RUnlock()
+	return execute(indexTemplate, map[string]interface{}{
+		"Prefix": a.prefix(),
+		"Models": names,
+	})
+}
+
+func (a *Admin) list(m *Model, user string, req *http.Request) (string, int) {
+	if !a.allowed(user, m.Name, View) {
+		return "", http.StatusNotFound
+	}
+	query := req.URL.Query().Get("q")
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	per := a.perPage()
+	objects, total, err := m.Store.List(m, query, (page-1)*per, per)
+	if err != nil {
+		log.Println("Error listing", m.Name+":", err)
+		return "", http.StatusInternalServerError
+	}
+
+	var columns []*field
+	for _, f := range append([]*field{m.id}, m.fields...) {
+		if f.list {
+			columns = append(columns, f)
+		}
+	}
+	if len(columns) == 0 {
+		columns = []*field{m.id}
+	}
+	var headers []string
+	for _, f := range columns {
+		headers = append(headers, f.label)
+	}
+	var rows [][]string
+	var ids []string
+	for _, obj := range objects {
+		var row []string
+		for _, f := range columns {
+			row = append(row, m.text(obj, f))
+		}
+		rows = append(rows, row)
+		ids = append(ids, m.ID(obj))
+	}
+
+	data := map[string]interface{}{
+		"Prefix":  a.prefix(),
+		"Model":   m.Name,
+		"Query":   query,
+		"Headers": headers,
+		"Rows":    rows,
+		"IDs":     ids,
+		"Page":    page,
+		"Total":   total,
+		"Create":  a.allowed(user, m.Name, Create),
+		"Edit":    a.allowed(user, m.Name, Edit),
+		"Delete":  a.allowed(user, m.Name, Delete),
+	}
+	if page > 1 {
+		data["Previous"] = page - 1
+	}
+	if page*per < total {
+		data["Next"] = page + 1
+	}
+	return execute(listTemplate, data)
+}
+
+// edit shows and saves the form for the object id, or for a new object
+// if id is empty.
+func (a *Admin) edit(m *Model, user, id string, req *http.Request) (string, int) {
+	action := Edit
+	if id == "" {
+		action = Create
+	}
+	if !a.allowed(user, m.Name, action) {
+		return "", http.StatusNotFound
+	}
+	obj := m.New()
+	if id != "" {
+		existing, err := m.Store.Get(m, id)
+		if err != nil {
+			return "", http.StatusNotFound
+		}
+		// edit a copy, so the stored object is only changed by Save.
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(existing).Elem())
+	}
+	values := make(map[string]string)
+	for _, f := range m.fields {
+		values[f.name] = m.text(obj, f)
+	}
+	if req.Method != "POST" {
+		return a.editor(m, id, values, nil)
+	}
+	files := m.files()
+	if len(files) > 0 {
+		// the form's fields are in the same multipart body as the files.
+		if err := req.ParseMultipartForm(forms.MaxMemory); err != nil {
+			return a.editor(m, id, values, map[string]string{"": "the form couldn't be read"})
+		}
+	}
+
+	form := m.form.Validate(req)
+	for name, value := range form.Convert() {
+		values[name] = fmt.Sprint(value)
+	}
+	if !form.Valid() {
+		return a.editor(m, id, values, form.Errors())
+	}
+	// files are only stored once the rest of the form is valid, and
+	// the ones they replace are only deleted once the object is saved.
+	var stored, replaced []string
+	for _, f := range files {
+		key, err := a.upload(m, f, req)
+		if err != nil {
+			a.deleteFiles(stored)
+			return a.editor(m, id, values, map[string]string{f.name: err.Error()})
+		}
+		if key != "" {
+			stored = append(stored, key)
+			if values[f.name] != "" {
+				replaced = append(replaced, values[f.name])
+			}
+			values[f.name] = key
+		}
+	}
+	for _, f := range m.fields {
+		if err := m.set(obj, f, values[f.name]); err != nil {
+			a.deleteFiles(stored)
+			return a.editor(m, id, values, map[string]string{f.name: "invalid"})
+		}
+	}
+	if err := m.Store.Save(m, obj); err != nil {
+		log.Println("Error saving", m.Name+":", err)
+		a.deleteFiles(stored)
+		return "", http.StatusInternalServerError
+	}
+	a.deleteFiles(replaced)
+	return forms.SeeOther(a.prefix() + m.Name + "/")
+}
+
+// upload stores the file sent for the field f, returning its key, which
+// is empty if none was sent.
+func (a *Admin) upload(m *Model, f *field, req *http.Request) (string, error) {
+	file, header, err := req.FormFile(f.name)
+	if err == http.ErrMissingFile {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.New("the file couldn't be read")
+	}
+	defer file.Close()
+	if header.Size > MaxFileSize {
+		return "", fmt.Errorf("the file is larger than %d bytes", MaxFileSize)
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := m.Name + "/" + hex.EncodeToString(b) + strings.ToLower(filepath.Ext(header.Filename))
+	if err := a.Storage.Put(key, file); err != nil {
+		log.Println("Error storing file for", m.Name+":", err)
+		return "", errors.New("the file couldn't be stored")
+	}
+	return key, nil
+}
+
+// deleteFiles removes files from the Storage, logging any which can't
+// be.
+func (a *Admin) deleteFiles(keys []string) {
+	for _, key := range keys {
+		if err := a.Storage.Delete(key); err != nil {
+			log.Println("Error deleting file", key+":", err)
+		}
+	}
+}
+
+// editor renders the form for an object, filled in with values. It
+// carries a one-time token so that refreshing after saving doesn't save
+// again.
+func (a *Admin) editor(m *Model, id string, values, errors map[string]string) (string, int) {
+	var inputs []map[string]interface{}
+	for _, f := range m.fields {
+		input := map[string]interface{}{
+			"Name":  f.name,
+			"Label": f.label,
+			"Value": values[f.name],
+		}
+		switch {
+		case f.file:
+			input["File"] = true
+			if values[f.name] != "" {
+				input["URL"] = a.Storage.URL(values[f.name])
+			}
+		case len(f.choices) > 0:
+			input["Choices"] = f.choices
+		case f.kind == reflect.Bool:
+			input["Choices"] = []string{"true", "false"}
+		}
+		inputs = append(inputs, input)
+	}
+	return execute(editTemplate, map[string]interface{}{
+		"Prefix":     a.prefix(),
+		"Model":      m.Name,
+		"ID":         id,
+		"Inputs":     inputs,
+		"Multipart":  len(m.files()) > 0,
+		"Errors":     errors,
+		"TokenField": forms.OnceTokenField,
+		"Token":      m.form.Token(),
+	})
+}
+
+// remove asks for confirmation on a GET and deletes the object id on a
+// POST.
+func (a *Admin) remove(m *Model, user, id string, req *http.Request) (string, int) {
+	if !a.allowed(user, m.Name, Delete) {
+		return "", http.StatusNotFound
+	}
+	obj, err := m.Store.Get(m, id)
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	if req.Method != "POST" {
+		return execute(deleteTemplate, map[string]interface{}{
+			"Prefix": a.prefix(),
+			"Model":  m.Name,
+			"ID":     id,
+		})
+	}
+	if err := m.Store.Delete(m, id); err != nil {
+		log.Println("Error deleting from", m.Name+":", err)
+		return "", http.StatusInternalServerError
+	}
+	var keys []string
+	for _, f := range m.files() {
+		if key := m.text(obj, f); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	a.deleteFiles(keys)
+	return forms.SeeOther(a.prefix() + m.Name + "/")
+}
+
+func execute(t *template.Template, data interface{}) (string, int) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		log.Println("Error rendering admin page:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
@@ -0,0 +1,324 @@
+package admin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"wedge"
+	"wedge/forms"
+)
+
+type post struct {
+	ID     int    `admin:"id,list"`
+	Title  string `admin:"label=Title,max=20,list,search"`
+	Status string `admin:"choices=draft|published,list"`
+	Views  int
+	Pinned bool
+	notes  string
+}
+
+var tokenre = regexp.MustCompile(`name="` + forms.OnceTokenField + `" value="([^"]+)"`)
+
+// users are the users of the test Admin by their session cookie.
+var users = map[string]string{"alice": "alice", "bob": "bob"}
+
+func newAdmin() *Admin {
+	a := &Admin{
+		Authenticate: func(req *http.Request) (string, bool) {
+			c, err := req.Cookie("session")
+			if err != nil {
+				return "", false
+			}
+			user, ok := users[c.Value]
+			return user, ok
+		},
+	}
+	a.Register("posts", post{}, NewMemoryStore())
+	return a
+}
+
+func request(a *Admin, user, method, target string, form url.Values) (string, int) {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	if user != "" {
+		req.AddCookie(&http.Cookie{Name: "session", Value: user})
+	}
+	return a.Serve(httptest.NewRecorder(), req)
+}
+
+// create saves a post through the editor, returning how it was answered.
+func create(t *testing.T, a *Admin, user string, values url.Values) (string, int) {
+	editor, status := request(a, user, "GET", "/admin/posts/new/", nil)
+	m := tokenre.FindStringSubmatch(editor)
+	if status != http.StatusOK || m == nil {
+		t.Fatalf("the editor got %d %q", status, editor)
+	}
+	values.Set(forms.OnceTokenField, m[1])
+	return request(a, user, "POST", "/admin/posts/new/", values)
+}
+
+func postValues(title string) url.Values {
+	return url.Values{"title": {title}, "status": {"draft"}, "views": {"3"}, "pinned": {"false"}}
+}
+
+func TestAuthentication(t *testing.T) {
+	a := &Admin{}
+	a.Register("posts", post{}, NewMemoryStore())
+	// nothing is served until there's a way to authenticate.
+	if _, status := request(a, "alice", "GET", "/admin/", nil); status != http.StatusNotFound {
+		t.Errorf("without Authenticate got %d", status)
+	}
+
+	a = newAdmin()
+	for _, user := range []string{"", "mallory"} {
+		for _, target := range []string{"/admin/", "/admin/posts/", "/admin/posts/new/", "/admin/posts/1/delete/"} {
+			if _, status := request(a, user, "GET", target, nil); status != http.StatusNotFound {
+				t.Errorf("%q got %d for %s", user, status, target)
+			}
+		}
+		if _, status := request(a, user, "POST", "/admin/posts/new/", postValues("sneaky")); status != http.StatusNotFound {
+			t.Errorf("%q got %d creating a post", user, status)
+		}
+	}
+	if objects, total, _ := a.Model("posts").Store.List(a.Model("posts"), "", 0, 0); total != 0 {
+		t.Errorf("unauthenticated requests made %v", objects)
+	}
+
+	a.LoginURL = "/login/"
+	if to, status := request(a, "", "GET", "/admin/posts/?q=x", nil); status != http.StatusSeeOther || to != "/login/?next=%2Fadmin%2Fposts%2F" {
+		t.Errorf("got %d to %q", status, to)
+	}
+	if body, status := request(a, "alice", "GET", "/admin/", nil); status != http.StatusOK || !strings.Contains(body, `href="/admin/posts/"`) {
+		t.Errorf("alice got %d %q", status, body)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	a := newAdmin()
+	a.Register("users", struct {
+		Name string `admin:"id"`
+	}{}, NewMemoryStore())
+	// bob may only view posts, and alice may do anything but delete them.
+	a.Allow = func(user, model string, action Action) bool {
+		if user == "bob" {
+			return model == "posts" && action == View
+		}
+		return action != Delete
+	}
+	if _, status := create(t, a, "alice", postValues("Hello")); status != http.StatusSeeOther {
+		t.Fatalf("alice got %d creating a post", status)
+	}
+
+	index, _ := request(a, "bob", "GET", "/admin/", nil)
+	if !strings.Contains(index, "posts") || strings.Contains(index, "users") {
+		t.Errorf("bob's index is %q", index)
+	}
+	list, status := request(a, "bob", "GET", "/admin/posts/", nil)
+	if status != http.StatusOK || !strings.Contains(list, "Hello") || strings.Contains(list, "/new/") || strings.Contains(list, "Edit") || strings.Contains(list, "Delete") {
+		t.Errorf("bob's list got %d %q", status, list)
+	}
+	for _, test := range []struct{ user, method, target string }{
+		{"bob", "GET", "/admin/users/"},
+		{"bob", "GET", "/admin/posts/new/"},
+		{"bob", "GET", "/admin/posts/1/"},
+		{"bob", "POST", "/admin/posts/1/"},
+		{"bob", "POST", "/admin/posts/1/delete/"},
+		{"alice", "GET", "/admin/posts/1/delete/"},
+		{"alice", "POST", "/admin/posts/1/delete/"},
+	} {
+		if _, status := request(a, test.user, test.method, test.target, url.Values{"title": {"changed"}}); status != http.StatusNotFound {
+			t.Errorf("%s got %d for %s %s", test.user, status, test.method, test.target)
+		}
+	}
+	obj, err := a.Model("posts").Store.Get(a.Model("posts"), "1")
+	if err != nil || obj.(*post).Title != "Hello" {
+		t.Errorf("got %+v, %v after refused changes", obj, err)
+	}
+	list, _ = request(a, "alice", "GET", "/admin/posts/", nil)
+	if !strings.Contains(list, `href="/admin/posts/1/"`) || strings.Contains(list, "/delete/") {
+		t.Errorf("alice's list is %q", list)
+	}
+}
+
+func TestEditAndDelete(t *testing.T) {
+	a := newAdmin()
+	m := a.Model("posts")
+	if to, status := create(t, a, "alice", postValues("Hello")); status != http.StatusSeeOther || to != "/admin/posts/" {
+		t.Fatalf("creating got %d to %q", status, to)
+	}
+	obj, err := m.Store.Get(m, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := obj.(*post); p.Title != "Hello" || p.Status != "draft" || p.Views != 3 || p.Pinned {
+		t.Errorf("created %+v", p)
+	}
+
+	editor, _ := request(a, "alice", "GET", "/admin/posts/1/", nil)
+	if !strings.Contains(editor, `value="Hello"`) {
+		t.Errorf("the editor isn't filled in: %q", editor)
+	}
+	token := tokenre.FindStringSubmatch(editor)[1]
+	bad := url.Values{forms.OnceTokenField: {token}, "title": {"Changed"}, "status": {"secret"}, "views": {"many"}, "pinned": {"true"}}
+	body, status := request(a, "alice", "POST", "/admin/posts/1/", bad)
+	if status != http.StatusOK || !strings.Contains(body, `class="error">status`) || !strings.Contains(body, `class="error">views`) {
+		t.Errorf("invalid edit got %d %q", status, body)
+	}
+	if obj, _ := m.Store.Get(m, "1"); obj.(*post).Title != "Hello" {
+		t.Errorf("an invalid edit changed the title to %q", obj.(*post).Title)
+	}
+	// an edit changes a copy, so a failed one leaves the stored object.
+	good := postValues("Changed")
+	good.Set(forms.OnceTokenField, tokenre.FindStringSubmatch(body)[1])
+	good.Set("pinned", "true")
+	if _, status := request(a, "alice", "POST", "/admin/posts/1/", good); status != http.StatusSeeOther {
+		t.Errorf("editing got %d", status)
+	}
+	if obj, _ := m.Store.Get(m, "1"); obj.(*post).Title != "Changed" || !obj.(*post).Pinned {
+		t.Errorf("edited %+v", obj)
+	}
+	// the same copy of the form can't be submitted twice.
+	good.Set("title", "Again")
+	if body, _ := request(a, "alice", "POST", "/admin/posts/1/", good); !strings.Contains(body, forms.AlreadySubmittedMessage) {
+		t.Errorf("resubmitting got %q", body)
+	}
+
+	if body, status := request(a, "alice", "GET", "/admin/posts/1/delete/", nil); status != http.StatusOK || !strings.Contains(body, "<form") {
+		t.Errorf("confirming got %d %q", status, body)
+	}
+	if _, err := m.Store.Get(m, "1"); err != nil {
+		t.Error("a GET deleted the post")
+	}
+	if _, status := request(a, "alice", "POST", "/admin/posts/1/delete/", nil); status != http.StatusSeeOther {
+		t.Errorf("deleting got %d", status)
+	}
+	if _, err := m.Store.Get(m, "1"); err != ErrNotFound {
+		t.Errorf("got %v for a deleted post", err)
+	}
+	for _, target := range []string{"/admin/posts/1/", "/admin/posts/1/delete/", "/admin/pages/", "/admin/posts/1/delete/now/"} {
+		if _, status := request(a, "alice", "GET", target, nil); status != http.StatusNotFound {
+			t.Errorf("%s got %d", target, status)
+		}
+	}
+}
+
+func TestListPages(t *testing.T) {
+	a := newAdmin()
+	a.PerPage = 2
+	for _, title := range []string{"Apples", "Bananas", "Cherries", "Green apples"} {
+		if _, status := create(t, a, "alice", postValues(title)); status != http.StatusSeeOther {
+			t.Fatalf("creating %s got %d", title, status)
+		}
+	}
+	for _, test := range []struct {
+		query       string
+		found, want []string
+	}{
+		{"", []string{"Apples", "Bananas"}, []string{"4 found", `href="?q=&amp;page=2">Next`}},
+		{"page=2", []string{"Cherries", "Green apples"}, []string{`href="?q=&amp;page=1">Previous`}},
+		{"q=APPLE", []string{"Apples", "Green apples"}, []string{"2 found"}},
+		{"q=apple&page=9", nil, []string{"2 found"}},
+	} {
+		list, status := request(a, "alice", "GET", "/admin/posts/?"+test.query, nil)
+		if status != http.StatusOK {
+			t.Errorf("%s: got %d", test.query, status)
+		}
+		for _, want := range append(test.found, test.want...) {
+			if !strings.Contains(list, want) {
+				t.Errorf("%s: %q isn't in %q", test.query, want, list)
+			}
+		}
+		if strings.Count(list, "<tr>")-1 != len(test.found) {
+			t.Errorf("%s: got %d rows, want %d", test.query, strings.Count(list, "<tr>")-1, len(test.found))
+		}
+	}
+}
+
+type photo struct {
+	ID    int    `admin:"id"`
+	Title string `admin:"list"`
+	Image string `admin:"file"`
+}
+
+func TestFiles(t *testing.T) {
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("a model with files was registered without a Storage")
+			}
+		}()
+		newAdmin().Register("photos", photo{}, NewMemoryStore())
+	}()
+
+	store := &wedge.DiskStorage{Dir: t.TempDir(), BaseURL: "/media/"}
+	a := newAdmin()
+	a.Storage = store
+	m := a.Register("photos", photo{}, NewMemoryStore())
+	upload := func(target, image string) (string, int) {
+		editor, _ := request(a, "alice", "GET", target, nil)
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField(forms.OnceTokenField, tokenre.FindStringSubmatch(editor)[1])
+		mw.WriteField("title", "Cat")
+		if image != "" {
+			fw, _ := mw.CreateFormFile("image", "Cat.JPG")
+			fw.Write([]byte(image))
+		}
+		mw.Close()
+		req := httptest.NewRequest("POST", target, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: "session", Value: "alice"})
+		return a.Serve(httptest.NewRecorder(), req)
+	}
+	read := func(key string) string {
+		r, err := store.Get(key)
+		if err != nil {
+			return ""
+		}
+		defer r.Close()
+		data, _ := ioutil.ReadAll(r)
+		return string(data)
+	}
+
+	if _, status := upload("/admin/photos/new/", "first"); status != http.StatusSeeOther {
+		t.Fatalf("uploading got %d", status)
+	}
+	obj, _ := m.Store.Get(m, "1")
+	first := obj.(*photo).Image
+	if !strings.HasPrefix(first, "photos/") || !strings.HasSuffix(first, ".jpg") || read(first) != "first" {
+		t.Fatalf("stored %q holding %q", first, read(first))
+	}
+	if editor, _ := request(a, "alice", "GET", "/admin/photos/1/", nil); !strings.Contains(editor, "/media/"+first) {
+		t.Errorf("the editor doesn't link to the file: %q", editor)
+	}
+
+	// saving without a file keeps the one there is.
+	upload("/admin/photos/1/", "")
+	if obj, _ := m.Store.Get(m, "1"); obj.(*photo).Image != first {
+		t.Errorf("saving without a file changed it to %q", obj.(*photo).Image)
+	}
+	// replacing it deletes the old one.
+	upload("/admin/photos/1/", "second")
+	obj, _ = m.Store.Get(m, "1")
+	second := obj.(*photo).Image
+	if second == first || read(second) != "second" || read(first) != "" {
+		t.Errorf("replacing %q with %q left %q and %q", first, second, read(first), read(second))
+	}
+	// as does deleting the object.
+	request(a, "alice", "POST", "/admin/photos/1/delete/", nil)
+	if read(second) != "" {
+		t.Error("deleting the photo left its file")
+	}
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MemoryStore is a Store which keeps objects in memory, for development
+// and tests. New objects are given increasing numeric ids.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]interface{}
+	next    int
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]interface{})}
+}
+
+// List returns the matching objects ordered by id.
+func (s *MemoryStore) List(m *Model, query string, offset, limit int) ([]interface{}, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id, obj := range s.objects {
+		if m.Matches(obj, query) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, errA := strconv.Atoi(ids[i])
+		b, errB := strconv.Atoi(ids[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+	total := len(ids)
+	if offset > total {
+		offset = total
+	}
+	ids = ids[offset:]
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	objects := make([]interface{}, len(ids))
+	for i, id := range ids {
+		objects[i] = s.objects[id]
+	}
+	return objects, total, nil
+}
+
+func (s *MemoryStore) Get(m *Model, id string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return obj, nil
+}
+
+func (s *MemoryStore) Save(m *Model, obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := reflect.ValueOf(obj).Elem().Field(m.id.index)
+	if id.IsZero() {
+		s.next++
+		if err := m.set(obj, m.id, strconv.Itoa(s.next)); err != nil {
+			return err
+		}
+	}
+	s.objects[m.ID(obj)] = obj
+	return nil
+}
+
+func (s *MemoryStore) Delete(m *Model, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.objects, id)
+	return nil
+}
@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"wedge/forms"
+)
+
+// field describes one field of a model, from its struct tag.
+type field struct {
+	index   int
+	name    string
+	label   string
+	kind    reflect.Kind
+	max     int
+	id      bool
+	list    bool
+	search  bool
+	choices []string
+	file    bool
+}
+
+// Model is a type registered with an Admin.
+type Model struct {
+	Name  string
+	Store Store
+	typ   reflect.Type
+	id    *field
+	// fields are the editable fields, in the order they're declared.
+	fields []*field
+	form   *forms.Form
+}
+
+// parseModel reads the admin tags of the struct type of proto. Fields are
+// tagged with a comma separated list of:
+//
+//	id            the field identifying the object, which isn't editable
+//	label=Title   how the field is labelled, its name if it's not given
+//	max=200       the maximum length of a string, 256 if it's not given
+//	list          show the field on the list page
+//	search        match the field against searches
+//	choices=a|b   only allow the given values
+//	file          a string holding the key of a file uploaded to the
+//	              Admin's Storage
+//
+// Untagged fields of a supported kind are editable with the defaults, and
+// fields tagged "-" are ignored. Strings, bools, ints and floats are
+// supported.
+func parseModel(name string, proto interface{}) (*Model, error) {
+	t := reflect.TypeOf(proto)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("admin: %s is not a struct", t)
+	}
+	m := &Model{Name: name, typ: t}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("admin")
+		if tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		f := &field{index: i, name: strings.ToLower(sf.Name), label: sf.Name, kind: sf.Type.Kind(), max: 256}
+		switch f.kind {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int32, reflect.Int64, reflect.Float64:
+		default:
+			if tag != "" {
+				return nil, fmt.Errorf("admin: %s.%s has unsupported type %s", t, sf.Name, sf.Type)
+			}
+			continue
+		}
+		for _, opt := range strings.Split(tag, ",") {
+			key, value := opt, ""
+			if i := strings.Index(opt, "="); i >= 0 {
+				key, value = opt[:i], opt[i+1:]
+			}
+			switch key {
+			case "":
+			case "id":
+				f.id = true
+			case "label":
+				f.label = value
+			case "max":
+				max, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("admin: %s.%s: bad max %q", t, sf.Name, value)
+				}
+				f.max = max
+			case "list":
+				f.list = true
+			case "search":
+				f.search = true
+			case "choices":
+				f.choices = strings.Split(value, "|")
+			case "file":
+				if f.kind != reflect.String {
+					return nil, fmt.Errorf("admin: %s.%s: only strings can hold files", t, sf.Name)
+				}
+				f.file = true
+			default:
+				return nil, fmt.Errorf("admin: %s.%s: unknown option %q", t, sf.Name, key)
+			}
+		}
+		if f.id {
+			m.id = f
+		} else {
+			m.fields = append(m.fields, f)
+		}
+	}
+	if m.id == nil {
+		return nil, fmt.Errorf("admin: %s has no field tagged id", t)
+	}
+	m.form = m.buildForm()
+	return m, nil
+}
+
+// buildForm makes the form which validates the model's editor.
+func (m *Model) buildForm() *forms.Form {
+	var fields []forms.Field
+	for _, f := range m.fields {
+		switch {
+		case f.file:
+			// files are optional, keeping the one there is, so they're
+			// handled apart from the form.
+		case len(f.choices) > 0:
+			options := forms.ChoicesFrom(f.choices,
+				func(i int) interface{} { return f.choices[i] },
+				func(i int) string { return f.choices[i] },
+			)
+			fields = append(fields, forms.ComboField(f.name, f.label, options...))
+		case f.kind == reflect.Bool:
+			fields = append(fields, forms.RadioField(f.name,
+				forms.Choice("Yes", "true", false),
+				forms.Choice("No", "false", true),
+			))
+		case f.kind == reflect.String:
+			fields = append(fields, forms.TextField(f.name, f.label, f.max+1))
+		case f.kind == reflect.Float64:
+			fields = append(fields, forms.TextField(f.name, f.label, 64,
+				forms.WithValidator(forms.Matches(`^-?[0-9]+(\.[0-9]+)?$`))))
+		default:
+			fields = append(fields, forms.TextField(f.name, f.label, 32,
+				forms.WithValidator(forms.Matches(`^-?[0-9]+$`))))
+		}
+	}
+	return forms.NewForm(forms.NewFormMetadata(m.Name, "", "POST", true), fields...).Once()
+}
+
+// files returns the fields which hold files.
+func (m *Model) files() []*field {
+	var files []*field
+	for _, f := range m.fields {
+		if f.file {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// ID returns the id of obj, as a string.
+func (m *Model) ID(obj interface{}) string {
+	return m.text(obj, m.id)
+}
+
+// text returns the value of f in obj as a string.
+func (m *Model) text(obj interface{}, f *field) string {
+	return fmt.Sprint(reflect.Indirect(reflect.ValueOf(obj)).Field(f.index).Interface())
+}
+
+// set parses value into the field f of obj, which must be a pointer.
+func (m *Model) set(obj interface{}, f *field, value string) error {
+	v := reflect.ValueOf(obj).Elem().Field(f.index)
+	switch f.kind {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	}
+	return nil
+}
+
+// New returns a pointer to a new, empty object of the model.
+func (m *Model) New() interface{} {
+	return reflect.New(m.typ).Interface()
+}
+
+// Matches reports whether any of the searchable fields of obj contain
+// query, ignoring case. Every object matches an empty query.
+func (m *Model) Matches(obj interface{}, query string) bool {
+	if query == "" {
+		return true
+	}
+	query = strings.ToLower(query)
+	for _, f := range append([]*field{m.id}, m.fields...) {
+		if f.search && strings.Contains(strings.ToLower(m.text(obj, f)), query) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package admin
+
+import "html/template"
+
+var indexTemplate = template.Must(template.New("index.html").Parse(
+	`<!DOCTYPE html><html><body><h1>Administration</h1><ul>` +
+		`{{range .Models}}<li><a href="{{$.Prefix}}{{.}}/">{{.}}</a></li>{{end}}` +
+		`</ul></body></html>`))
+
+var listTemplate = template.Must(template.New("list.html").Parse(
+	`<!DOCTYPE html><html><body><h1><a href="{{.Prefix}}">Administration</a> / {{.Model}}</h1>` +
+		`<form method="GET" action="{{.Prefix}}{{.Model}}/">` +
+		`<input type="search" name="q" value="{{.Query}}" /> <input type="submit" value="Search"></form>` +
+		`{{if .Create}}<p><a href="{{.Prefix}}{{.Model}}/new/">New</a></p>{{end}}` +
+		`<p>{{.Total}} found</p>` +
+		`<table border="1"><tr>{{range .Headers}}<th>{{.}}</th>{{end}}<th></th></tr>` +
+		`{{range $i, $row := .Rows}}<tr>{{range $row}}<td>{{.}}</td>{{end}}<td>` +
+		`{{$id := index $.IDs $i}}` +
+		`{{if $.Edit}}<a href="{{$.Prefix}}{{$.Model}}/{{$id}}/">Edit</a> {{end}}` +
+		`{{if $.Delete}}<a href="{{$.Prefix}}{{$.Model}}/{{$id}}/delete/">Delete</a>{{end}}` +
+		`</td></tr>{{end}}</table>` +
+		`<p>{{with .Previous}}<a href="?q={{$.Query}}&amp;page={{.}}">Previous</a> {{end}}` +
+		`Page {{.Page}}` +
+		`{{with .Next}} <a href="?q={{$.Query}}&amp;page={{.}}">Next</a>{{end}}</p>` +
+		`</body></html>`))
+
+var editTemplate = template.Must(template.New("edit.html").Parse(
+	`<!DOCTYPE html><html><body><h1><a href="{{.Prefix}}">Administration</a> / ` +
+		`<a href="{{.Prefix}}{{.Model}}/">{{.Model}}</a> / {{if .ID}}{{.ID}}{{else}}New{{end}}</h1>` +
+		`{{range $field, $err := .Errors}}<p class="error">{{if $field}}{{$field}}: {{end}}{{$err}}</p>{{end}}` +
+		`<form method="POST" action=""{{if .Multipart}} enctype="multipart/form-data"{{end}}>` +
+		`<input type="hidden" name="{{.TokenField}}" value="{{.Token}}" />` +
+		`{{range .Inputs}}<p>{{.Label}}: {{$input := .}}` +
+		`{{if .File}}{{with .URL}}<a href="{{.}}">{{$input.Value}}</a> {{end}}<input type="file" name="{{.Name}}" />` +
+		`{{else if .Choices}}<select name="{{.Name}}">{{range .Choices}}` +
+		`<option value="{{.}}"{{if eq . $input.Value}} selected{{end}}>{{.}}</option>{{end}}</select>` +
+		`{{else}}<input type="text" name="{{.Name}}" value="{{.Value}}" />{{end}}</p>{{end}}` +
+		`<input type="submit" value="Save"></form></body></html>`))
+
+var deleteTemplate = template.Must(template.New("delete.html").Parse(
+	`<!DOCTYPE html><html><body><h1>Delete {{.ID}} from {{.Model}}?</h1>` +
+		`<form method="POST" action="">` +
+		`<input type="submit" value="Delete"></form></body></html>`))
@@ -0,0 +1,153 @@
+package wedge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIError is the body of an error response to an API request, sent
+// under "error" as JSON in place of the 404, 500 and other error pages:
+//
+//	{"error": {"code": "not_found", "message": "No such order", "request_id": "9f86d081884c7d65"}}
+//
+// Requests to JSON routes are API requests, as are any which prefer
+// application/json to text/html in their Accept header, so that clients
+// get one for paths which match no route. Error templates and the 404 and
+// 500 handlers are only used for the others.
+type APIError struct {
+	// Status is the HTTP status the error is sent with.
+	Status int `json:"-"`
+	// Code is a short, stable name for the error, such as "not_found",
+	// which clients can switch on. It defaults to the status text in
+	// snake case.
+	Code string `json:"code"`
+	// Message describes the error. It defaults to the status text.
+	Message string `json:"message"`
+	// Details are anything more the client may need, such as the fields
+	// which failed validation.
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+func (e APIError) Error() string {
+	return e.Message
+}
+
+// Fail answers an API request with e, returning its message and status for
+// the view to return. On other routes the message and status are handled
+// as any other view's are.
+//
+// Example:
+//
+//	func CreateOrder(w http.ResponseWriter, req *http.Request) (string, int) {
+//		if problems := validate(req); len(problems) > 0 {
+//			return wedge.Fail(req, wedge.APIError{
+//				Status:  http.StatusUnprocessableEntity,
+//				Code:    "invalid_order",
+//				Message: "The order is invalid",
+//				Details: problems,
+//			})
+//		}
+//		...
+//	}
+//
+// Without Fail, an API route's error responses are sent with the body the
+// view returned as their message, apart from 404s and 500s, whose message
+// is their status text so that internal errors aren't shown to clients.
+func Fail(req *http.Request, e APIError) (string, int) {
+	if slot, ok := req.Context().Value(apiKey).(*APIError); ok {
+		*slot = e
+	}
+	if e.Status == 0 {
+		e.Status = http.StatusInternalServerError
+	}
+	return e.Message, e.Status
+}
+
+// withAPI returns a shallow copy of req which can hold the APIError its
+// view fails with.
+func withAPI(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), apiKey, &APIError{}))
+}
+
+// sendAPIError answers req with an APIError for status if it's an API
+// request, reporting whether it was. message is used if the view didn't
+// fail with an error of its own.
+func (App *AppServer) sendAPIError(w http.ResponseWriter, req *http.Request, status int, message string) bool {
+	slot, ok := req.Context().Value(apiKey).(*APIError)
+	if !ok && !prefersJSON(req.Header.Get("Accept")) {
+		return false
+	}
+	var e APIError
+	if ok && slot.Status == status {
+		e = *slot
+	}
+	e.Status = status
+	if e.Message == "" {
+		e.Message = message
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+	if e.Code == "" {
+		e.Code = errorCode(status)
+	}
+	e.RequestID = RequestID(req)
+	body, err := json.Marshal(map[string]APIError{"error": e})
+	if err != nil {
+		// the details couldn't be encoded.
+		e.Details = nil
+		body, _ = json.Marshal(map[string]APIError{"error": e})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+	return true
+}
+
+// errorCode is the default Code of an APIError with status, such as
+// "method_not_allowed" for 405.
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error_" + strconv.Itoa(status)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, text)
+}
+
+// prefersJSON reports whether an Accept header asks for application/json
+// over text/html.
+func prefersJSON(accept string) bool {
+	var jsonQ, htmlQ float64
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(fields[0])) {
+		case "application/json":
+			jsonQ = weight
+		case "text/html":
+			htmlQ = weight
+		}
+	}
+	return jsonQ > htmlQ
+}
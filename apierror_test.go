@@ -0,0 +1,103 @@
+package wedge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefersJSON(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                                      false,
+		"*/*":                                   false,
+		"application/json":                      true,
+		"text/html, application/json":           false,
+		"text/html;q=0.5, application/json":     true,
+		"Application/JSON;q=0.9, text/html;q=0": true,
+	} {
+		if got := prefersJSON(accept); got != want {
+			t.Errorf("%q: got %v", accept, got)
+		}
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.Handler404(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "<h1>Not here</h1>", http.StatusNotFound
+	})
+	App.AddURLs(
+		Route("^/api/orders/(?P<id>[0-9]+)$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			switch Params(req)["id"] {
+			case "1":
+				return Fail(req, APIError{
+					Status:  http.StatusUnprocessableEntity,
+					Code:    "invalid_order",
+					Message: "The order is invalid",
+					Details: map[string]string{"quantity": "must be positive"},
+				})
+			case "2":
+				return "quantity is required", http.StatusBadRequest
+			case "3":
+				return "connection refused", http.StatusInternalServerError
+			}
+			return "", http.StatusNotFound
+		}, ContentType(JSON), Methods("GET")),
+		Route("^/page$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "", http.StatusNotFound
+		}),
+	)
+	type envelope struct {
+		Error struct {
+			Code      string
+			Message   string
+			Details   map[string]string
+			RequestID string `json:"request_id"`
+		}
+	}
+	get := func(method, path, accept string) (*httptest.ResponseRecorder, envelope) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		var e envelope
+		if w.Header().Get("Content-Type") == "application/json" {
+			if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+				t.Errorf("%s %s: %v", method, path, err)
+			}
+		}
+		return w, e
+	}
+
+	for _, test := range []struct {
+		method, path, accept string
+		status               int
+		code, message        string
+	}{
+		{"GET", "/api/orders/1", "", 422, "invalid_order", "The order is invalid"},
+		{"GET", "/api/orders/2", "", 400, "bad_request", "quantity is required"},
+		{"GET", "/api/orders/3", "", 500, "internal_server_error", "Internal Server Error"},
+		{"GET", "/api/orders/4", "", 404, "not_found", "Not Found"},
+		{"POST", "/api/orders/4", "application/json", 405, "method_not_allowed", "Method Not Allowed"},
+		{"GET", "/missing", "application/json", 404, "not_found", "Not Found"},
+	} {
+		w, e := get(test.method, test.path, test.accept)
+		if w.Code != test.status || e.Error.Code != test.code || e.Error.Message != test.message {
+			t.Errorf("%s %s: got %d %q", test.method, test.path, w.Code, w.Body)
+		}
+		if e.Error.RequestID == "" || e.Error.RequestID != w.Header().Get("X-Request-ID") {
+			t.Errorf("%s %s: got request ID %q", test.method, test.path, e.Error.RequestID)
+		}
+	}
+	if _, e := get("GET", "/api/orders/1", ""); e.Error.Details["quantity"] != "must be positive" {
+		t.Errorf("got details %v", e.Error.Details)
+	}
+
+	// pages still get the 404 handler.
+	for _, path := range []string{"/page", "/missing"} {
+		if w, _ := get("GET", path, "text/html"); w.Body.String() != "<h1>Not here</h1>" {
+			t.Errorf("%s: got %q", path, w.Body)
+		}
+	}
+}
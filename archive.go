@@ -0,0 +1,282 @@
+package wedge
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ZipEntry is a file in an archive written by StreamZip. Open is only
+// called when the entry is written, so a single file is open at a time
+// however many there are.
+type ZipEntry struct {
+	// Name is the path of the file within the archive.
+	Name     string
+	Modified time.Time
+	Open     func() (io.ReadCloser, error)
+}
+
+// ZipFile returns a ZipEntry for the file at path, named name in the
+// archive, or after the file itself if name is empty.
+func ZipFile(path, name string) ZipEntry {
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	entry := ZipEntry{Name: name, Open: func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}}
+	if info, err := os.Stat(path); err == nil {
+		entry.Modified = info.ModTime()
+	}
+	return entry
+}
+
+// ZipReader returns a ZipEntry for the contents of r.
+func ZipReader(name string, modified time.Time, r io.Reader) ZipEntry {
+	return ZipEntry{Name: name, Modified: modified, Open: func() (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	}}
+}
+
+// errBadEntryName is returned for entries whose names could escape the
+// directory the archive is extracted into.
+var errBadEntryName = errors.New("wedge: archive entry name escapes the archive")
+
+// entryName cleans name into a relative path within an archive.
+func entryName(name string) (string, error) {
+	name = strings.TrimLeft(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if name == "" || name == "." {
+		return "", errBadEntryName
+	}
+	return name, nil
+}
+
+// attachment sets the headers for a download named filename.
+func attachment(w http.ResponseWriter, ctype, filename string) {
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+}
+
+// StreamZip writes a zip archive of entries to w as it's assembled, so the
+// archive is never held in memory, and returns the response for the view
+// to return. If an entry fails once the response has started, the
+// connection is aborted so the client doesn't save a truncated archive.
+//
+// The route mustn't be cached, as the archive bypasses the response.
+//
+// Example:
+//
+//	func Attachments(w http.ResponseWriter, req *http.Request) (string, int) {
+//		var entries []wedge.ZipEntry
+//		for _, a := range attachmentsOf(req) {
+//			entries = append(entries, wedge.ZipFile(a.Path, a.Name))
+//		}
+//		return wedge.StreamZip(w, "attachments.zip", entries...)
+//	}
+func StreamZip(w http.ResponseWriter, filename string, entries ...ZipEntry) (string, int) {
+	attachment(w, "application/zip", filename)
+	archive := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := writeZipEntry(archive, entry); err != nil {
+			log.Println("Error streaming zip entry", entry.Name+":", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Println("Error finishing zip:", err)
+		panic(http.ErrAbortHandler)
+	}
+	return "", http.StatusOK
+}
+
+func writeZipEntry(archive *zip.Writer, entry ZipEntry) error {
+	name, err := entryName(entry.Name)
+	if err != nil {
+		return err
+	}
+	r, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	modified := entry.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	f, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// ZipDownload is a route serving a zip archive streamed with StreamZip.
+// entries returns the archive's filename and entries for each request,
+// and an error if there's nothing to serve, which is answered with a 404.
+func ZipDownload(re, name string, entries func(req *http.Request) (string, []ZipEntry, error)) *Rule {
+	return Route(re, func(w http.ResponseWriter, req *http.Request) (string, int) {
+		filename, list, err := entries(req)
+		if err != nil {
+			log.Println("No archive for", req.URL.Path+":", err)
+			return "", http.StatusNotFound
+		}
+		return StreamZip(w, filename, list...)
+	}, Name(name), ContentType(DOWNLOAD), Cache(0))
+}
+
+// TarOptions chooses which files TarDirectory exports.
+type TarOptions struct {
+	// Include, if it's given, limits the export to files matching one of
+	// its globs, as for path.Match. Globs are matched against both the
+	// file's path relative to the directory, with forward slashes, and its
+	// name alone.
+	Include []string
+	// Exclude leaves out files and directories matching any of its globs,
+	// in the same manner as Include.
+	Exclude []string
+	// MaxSize refuses exports whose files add up to more than MaxSize
+	// bytes, before anything is sent. Zero means no limit.
+	MaxSize int64
+}
+
+func matchAny(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// tarFile is a file found by collect, to be written by TarDirectory.
+type tarFile struct {
+	rel  string
+	path string
+	info fs.FileInfo
+}
+
+// collect finds the regular files under dir which opts selects, along
+// with their total size. Symlinks are skipped, so an export can't reach
+// outside dir.
+func (opts TarOptions) collect(dir string) ([]tarFile, int64, error) {
+	var files []tarFile
+	var total int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchAny(opts.Exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchAny(opts.Include, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		if opts.MaxSize > 0 && total > opts.MaxSize {
+			return fmt.Errorf("export of %s is over %d bytes", dir, opts.MaxSize)
+		}
+		files = append(files, tarFile{rel, p, info})
+		return nil
+	})
+	return files, total, err
+}
+
+// TarDirectory is a route streaming a gzipped tar of the files in dir,
+// chosen by opts, for export and backup endpoints. The archive is named
+// after dir and is assembled as it's sent. Exports over opts.MaxSize are
+// answered with a 500 without sending anything.
+//
+// The route does no authentication of its own, so it mustn't be added
+// where anyone who shouldn't have the files can reach it.
+//
+// Example:
+//
+//	wedge.TarDirectory("^/export/uploads.tar.gz$", "Export uploads", "/srv/uploads", wedge.TarOptions{
+//		Exclude: []string{"*.tmp", ".git"},
+//		MaxSize: 1 << 30,
+//	})
+func TarDirectory(re, name, dir string, opts TarOptions) *Rule {
+	return Route(re, func(w http.ResponseWriter, req *http.Request) (string, int) {
+		files, _, err := opts.collect(dir)
+		if err != nil {
+			log.Println("Error exporting directory:", err)
+			return "", http.StatusInternalServerError
+		}
+		attachment(w, "application/gzip", filepath.Base(dir)+".tar.gz")
+		gz := gzip.NewWriter(w)
+		archive := tar.NewWriter(gz)
+		for _, f := range files {
+			if err := writeTarFile(archive, f); err != nil {
+				log.Println("Error streaming", f.path+":", err)
+				panic(http.ErrAbortHandler)
+			}
+		}
+		if err := archive.Close(); err != nil {
+			log.Println("Error finishing tar:", err)
+			panic(http.ErrAbortHandler)
+		}
+		if err := gz.Close(); err != nil {
+			log.Println("Error finishing gzip:", err)
+			panic(http.ErrAbortHandler)
+		}
+		return "", http.StatusOK
+	}, Name(name), ContentType(DOWNLOAD), Cache(0))
+}
+
+// writeTarFile writes f into archive. Exactly the size it had when it was
+// found is written, so a file which changes meanwhile can't corrupt the
+// archive, and one which shrinks fails it.
+func writeTarFile(archive *tar.Writer, f tarFile) error {
+	header, err := tar.FileInfoHeader(f.info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = f.rel
+	r, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(archive, r, f.info.Size())
+	return err
+}
@@ -0,0 +1,113 @@
+package wedge
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZipDownload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	os.WriteFile(file, []byte("file a"), 0644)
+	modified := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+
+	App := NewAppServer("0", 30)
+	App.AddURLs(ZipDownload("^/all.zip$", "All", func(req *http.Request) (string, []ZipEntry, error) {
+		return "all files.zip", []ZipEntry{
+			ZipFile(file, ""),
+			ZipReader("../../etc/b.txt", modified, strings.NewReader("reader b")),
+		}, nil
+	}))
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/all.zip", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/zip" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="all files.zip"` {
+		t.Errorf("got Content-Disposition %q", got)
+	}
+
+	body := w.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a.txt": "file a", "etc/b.txt": "reader b"}
+	if len(archive.File) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(archive.File), len(want))
+	}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != want[f.Name] {
+			t.Errorf("%s: got %q, want %q", f.Name, data, want[f.Name])
+		}
+		if f.Name == "etc/b.txt" && !f.Modified.Equal(modified) {
+			t.Errorf("%s: got modified %v, want %v", f.Name, f.Modified, modified)
+		}
+	}
+}
+
+func TestTarDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.tmp"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("c"), 0644)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0644)
+	os.Symlink("/etc/passwd", filepath.Join(dir, "link.txt"))
+
+	App := NewAppServer("0", 30)
+	App.AddURLs(
+		TarDirectory("^/export$", "Export", dir, TarOptions{Exclude: []string{"*.tmp", ".git"}}),
+		TarDirectory("^/small$", "Small", dir, TarOptions{MaxSize: 2}),
+	)
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d", w.Code)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	if strings.Join(names, ",") != "a.txt,sub/c.txt" {
+		t.Errorf("got entries %v, want [a.txt sub/c.txt]", names)
+	}
+
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/small", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("export over MaxSize: got status %d", w.Code)
+	}
+}
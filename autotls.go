@@ -0,0 +1,762 @@
+package wedge
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The directories of Let's Encrypt's ACME servers. The staging server
+// has far higher rate limits, for trying things out, but issues
+// certificates which browsers don't trust.
+const (
+	LetsEncrypt        = "https://acme-v02.api.letsencrypt.org/directory"
+	LetsEncryptStaging = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+// AutoTLS configures RunAutoTLS.
+type AutoTLS struct {
+	// Directory is the directory URL of the ACME server certificates
+	// are obtained from. It's LetsEncrypt if it's empty.
+	Directory string
+	// CacheDir is where the account key and certificates are kept, so
+	// they aren't obtained again on every restart. It's "certs" if
+	// it's empty.
+	CacheDir string
+	// Email is given to the certificate authority, which uses it to
+	// warn of certificates which are about to expire.
+	Email string
+	// HTTPPort is the port HTTP requests are answered on, with the ACME
+	// challenges and redirects to HTTPS. It's 80 if it's empty.
+	HTTPPort string
+	// RenewBefore is how long before they expire certificates are
+	// renewed. It's 30 days if it's zero.
+	RenewBefore time.Duration
+	// AcceptTOS agrees to the terms of service of the certificate
+	// authority, which are linked from its directory. No certificates
+	// are obtained unless it's set.
+	AcceptTOS bool
+}
+
+// SetAutoTLS configures how RunAutoTLS obtains certificates.
+func (App *AppServer) SetAutoTLS(c AutoTLS) {
+	App.autoTLS = c
+}
+
+// RunAutoTLS is RunTLS with certificates for domains which are obtained
+// from an ACME certificate authority, such as Let's Encrypt, when they're
+// first needed and renewed when they're close to expiring. The ACME
+// HTTP-01 challenges are answered on the HTTP port, where every other
+// request is redirected to HTTPS. Certificates are only obtained for
+// domains, which have to resolve to the server. The AppServer's port is
+// usually 443.
+//
+// Example:
+//
+//	App := wedge.NewAppServer("443", 30)
+//	App.SetAutoTLS(wedge.AutoTLS{
+//		CacheDir:  "/var/lib/site/certs",
+//		Email:     "admin@example.com",
+//		AcceptTOS: true,
+//	})
+//	App.RunAutoTLS("example.com", "www.example.com")
+func (App *AppServer) RunAutoTLS(domains ...string) {
+	ctx, stop := SignalContext(context.Background())
+	defer stop()
+	err := App.run(ctx, func() error {
+		return App.StartAutoTLS(domains...)
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// StartAutoTLS is StartTLS with certificates obtained automatically, see
+// RunAutoTLS.
+func (App *AppServer) StartAutoTLS(domains ...string) error {
+	m, err := newCertManager(App.autoTLS, domains)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if App.tlsConfig != nil {
+		config = App.tlsConfig.Clone()
+	}
+	config.GetCertificate = m.getCertificate
+
+	listener, err := net.Listen("tcp", ":"+App.port)
+	if err != nil {
+		return err
+	}
+	plain, err := net.Listen("tcp", ":"+m.HTTPPort)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	App.redirector = &http.Server{
+		Handler:     m.httpHandler(App.port),
+		ReadTimeout: App.timeout * time.Second,
+	}
+	go func() {
+		if err := App.redirector.Serve(plain); err != http.ErrServerClosed {
+			log.Println("HTTP server for ACME challenges stopped:", err)
+		}
+	}()
+	App.serve(listener, config)
+	done := App.done
+	go func() {
+		<-done
+		m.stop()
+	}()
+	return nil
+}
+
+// certManager obtains, caches and renews the certificates of the domains
+// given to RunAutoTLS.
+type certManager struct {
+	AutoTLS
+	client  *acmeClient
+	domains map[string]bool
+	// ctx is cancelled by stop, abandoning the orders in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sync.Mutex
+	certs map[string]*tls.Certificate
+	// pending holds a channel for each domain whose certificate is being
+	// obtained, closed once it's done, so each domain has one order at a
+	// time and orders for different domains don't wait on each other.
+	pending map[string]chan struct{}
+	failed  map[string]time.Time
+	// renewals are the timers which renew each domain's certificate.
+	renewals map[string]*time.Timer
+	// tokens are the key authorizations of the challenges in progress.
+	tokens map[string]string
+}
+
+// acmeRetry is how long a domain whose certificate couldn't be obtained
+// waits before it's tried again, so as not to run into the certificate
+// authority's rate limits.
+var acmeRetry = time.Minute
+
+// acmeTimeout bounds each request to the ACME server, and
+// acmeHandshakeWait how long a handshake waits for a certificate to be
+// obtained before it fails, leaving the order to carry on without it.
+var (
+	acmeTimeout       = 30 * time.Second
+	acmeHandshakeWait = time.Minute
+)
+
+func newCertManager(c AutoTLS, domains []string) (*certManager, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("wedge: RunAutoTLS needs at least one domain")
+	}
+	if !c.AcceptTOS {
+		return nil, errors.New("wedge: RunAutoTLS needs AcceptTOS, agreeing to the certificate authority's terms of service")
+	}
+	if c.Directory == "" {
+		c.Directory = LetsEncrypt
+	}
+	if c.CacheDir == "" {
+		c.CacheDir = "certs"
+	}
+	if c.HTTPPort == "" {
+		c.HTTPPort = "80"
+	}
+	if c.RenewBefore <= 0 {
+		c.RenewBefore = 30 * 24 * time.Hour
+	}
+	if err := os.MkdirAll(c.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+	key, err := loadAccountKey(filepath.Join(c.CacheDir, "acme-account.key"))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &certManager{
+		AutoTLS: c,
+		client: &acmeClient{
+			directory: c.Directory,
+			key:       key,
+			email:     c.Email,
+			acceptTOS: c.AcceptTOS,
+			http:      &http.Client{Timeout: acmeTimeout},
+		},
+		ctx:      ctx,
+		cancel:   cancel,
+		domains:  make(map[string]bool),
+		certs:    make(map[string]*tls.Certificate),
+		pending:  make(map[string]chan struct{}),
+		failed:   make(map[string]time.Time),
+		renewals: make(map[string]*time.Timer),
+		tokens:   make(map[string]string),
+	}
+	for _, domain := range domains {
+		m.domains[strings.ToLower(domain)] = true
+	}
+	return m, nil
+}
+
+// loadAccountKey reads the ACME account key from path, creating it if
+// there isn't one yet.
+func loadAccountKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("wedge: no key in %s", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	return key, ioutil.WriteFile(path, pemKey, 0600)
+}
+
+// getCertificate is the GetCertificate of the tls.Config. A domain's
+// first handshake waits, for up to acmeHandshakeWait, while its
+// certificate is obtained. Certificates are renewed by their timers,
+// see scheduleRenewal, rather than by handshakes.
+func (m *certManager) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if !m.domains[name] {
+		return nil, fmt.Errorf("wedge: no certificate for %q", hello.ServerName)
+	}
+	m.Lock()
+	cert := m.certs[name]
+	m.Unlock()
+	if cert == nil {
+		cert = m.load(name)
+	}
+	if cert != nil && time.Now().Before(cert.Leaf.NotAfter) {
+		return cert, nil
+	}
+	type obtained struct {
+		cert *tls.Certificate
+		err  error
+	}
+	result := make(chan obtained, 1)
+	go func() {
+		cert, err := m.obtain(name)
+		result <- obtained{cert, err}
+	}()
+	timeout := time.NewTimer(acmeHandshakeWait)
+	defer timeout.Stop()
+	ctx := context.Background()
+	if hello.Conn != nil {
+		ctx = hello.Context()
+	}
+	select {
+	case r := <-result:
+		return r.cert, r.err
+	case <-timeout.C:
+	case <-ctx.Done():
+	}
+	return nil, fmt.Errorf("wedge: still obtaining a certificate for %s", name)
+}
+
+// certPath is where the key and certificate chain of domain are kept.
+func (m *certManager) certPath(domain string) string {
+	return filepath.Join(m.CacheDir, domain+".pem")
+}
+
+// load returns the certificate of domain from the cache directory, or nil.
+func (m *certManager) load(domain string) *tls.Certificate {
+	data, err := ioutil.ReadFile(m.certPath(domain))
+	if err != nil {
+		return nil
+	}
+	cert, err := parseCertificate(data)
+	if err != nil {
+		log.Println("Ignoring the cached certificate of", domain+":", err)
+		return nil
+	}
+	m.Lock()
+	m.certs[domain] = cert
+	m.scheduleRenewal(domain, cert)
+	m.Unlock()
+	return cert
+}
+
+// parseCertificate parses a key and certificate chain in PEM.
+func parseCertificate(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &cert, nil
+}
+
+// obtain gets a new certificate for domain from the certificate
+// authority, or waits for the one already being obtained.
+func (m *certManager) obtain(domain string) (*tls.Certificate, error) {
+	m.Lock()
+	if wait, ok := m.pending[domain]; ok {
+		m.Unlock()
+		<-wait
+		m.Lock()
+		defer m.Unlock()
+		if cert := m.certs[domain]; cert != nil && time.Now().Before(cert.Leaf.NotAfter) {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("wedge: couldn't obtain a certificate for %s", domain)
+	}
+	if time.Since(m.failed[domain]) < acmeRetry {
+		m.Unlock()
+		return nil, fmt.Errorf("wedge: couldn't obtain a certificate for %s, retrying later", domain)
+	}
+	done := make(chan struct{})
+	m.pending[domain] = done
+	m.Unlock()
+	defer func() {
+		m.Lock()
+		delete(m.pending, domain)
+		m.Unlock()
+		close(done)
+	}()
+
+	log.Println("Obtaining a certificate for", domain)
+	data, err := m.client.obtain(m.ctx, domain, m.respond)
+	var cert *tls.Certificate
+	if err == nil {
+		cert, err = parseCertificate(data)
+	}
+	if err != nil {
+		log.Println("Error obtaining a certificate for", domain+":", err)
+		m.Lock()
+		m.failed[domain] = time.Now()
+		if m.certs[domain] != nil {
+			// a renewal which failed is tried again later.
+			m.renewAt(domain, time.Now().Add(acmeRetry))
+		}
+		m.Unlock()
+		return nil, err
+	}
+	if err := ioutil.WriteFile(m.certPath(domain), data, 0600); err != nil {
+		log.Println("Error caching the certificate of", domain+":", err)
+	}
+	m.Lock()
+	m.certs[domain] = cert
+	m.scheduleRenewal(domain, cert)
+	m.Unlock()
+	return cert, nil
+}
+
+// scheduleRenewal sets the timer which renews cert, RenewBefore it
+// expires, or half way through its life if that's later, so that a
+// certificate which is shorter lived than RenewBefore isn't renewed over
+// and over. It must be called with m locked.
+func (m *certManager) scheduleRenewal(domain string, cert *tls.Certificate) {
+	leaf := cert.Leaf
+	at := leaf.NotAfter.Add(-m.RenewBefore)
+	if half := leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2); half.After(at) {
+		at = half
+	}
+	m.renewAt(domain, at)
+}
+
+// renewAt replaces the renewal timer of domain with one which fires at
+// at. It must be called with m locked.
+func (m *certManager) renewAt(domain string, at time.Time) {
+	if timer := m.renewals[domain]; timer != nil {
+		timer.Stop()
+	}
+	m.renewals[domain] = time.AfterFunc(time.Until(at), func() {
+		m.obtain(domain)
+	})
+}
+
+// stop stops the renewal timers and the orders in progress.
+func (m *certManager) stop() {
+	m.cancel()
+	m.Lock()
+	defer m.Unlock()
+	for _, timer := range m.renewals {
+		timer.Stop()
+	}
+}
+
+// respond sets the key authorization served for the challenge token, or
+// removes it if keyAuth is empty.
+func (m *certManager) respond(token, keyAuth string) {
+	m.Lock()
+	defer m.Unlock()
+	if keyAuth == "" {
+		delete(m.tokens, token)
+		return
+	}
+	m.tokens[token] = keyAuth
+}
+
+// httpHandler answers the ACME challenges, and redirects every other
+// request to HTTPS on port.
+func (m *certManager) httpHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, acmePrefix) {
+			m.Lock()
+			keyAuth, ok := m.tokens[req.URL.Path[len(acmePrefix):]]
+			m.Unlock()
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, keyAuth)
+			return
+		}
+		if req.Method != "GET" && req.Method != "HEAD" {
+			http.Error(w, "Use HTTPS", http.StatusBadRequest)
+			return
+		}
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// acmeClient is just enough of an ACME (RFC 8555) client to obtain
+// certificates with HTTP-01 challenges.
+type acmeClient struct {
+	directory string
+	key       *ecdsa.PrivateKey
+	email     string
+	acceptTOS bool
+	http      *http.Client
+
+	// mu guards registering the account, and nonceMu the nonce kept
+	// from the last response for the next request. Neither is held for
+	// an order, so orders for different domains run side by side.
+	mu      sync.Mutex
+	urls    acmeDirectory
+	kid     string
+	nonceMu sync.Mutex
+	nonce   string
+}
+
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+type acmeAuthorization struct {
+	Status     string          `json:"status"`
+	Challenges []acmeChallenge `json:"challenges"`
+}
+
+type acmeChallenge struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// acmePoll is how often the status of challenges and orders is checked,
+// and acmeAttempts how many times before giving up.
+var (
+	acmePoll     = time.Second
+	acmeAttempts = 60
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwk is the account's public key as a JSON Web Key.
+func (c *acmeClient) jwk() map[string]string {
+	x, y := make([]byte, 32), make([]byte, 32)
+	c.key.X.FillBytes(x)
+	c.key.Y.FillBytes(y)
+	return map[string]string{"crv": "P-256", "kty": "EC", "x": b64(x), "y": b64(y)}
+}
+
+// thumbprint is the RFC 7638 thumbprint of the account key, which is part
+// of every key authorization.
+func (c *acmeClient) thumbprint() string {
+	jwk := c.jwk()
+	canonical := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, jwk["crv"], jwk["kty"], jwk["x"], jwk["y"])
+	sum := sha256.Sum256([]byte(canonical))
+	return b64(sum[:])
+}
+
+// post sends payload to url signed with the account key, decoding the
+// response into out, which may be a *[]byte for the raw body. A nil
+// payload is a POST-as-GET.
+func (c *acmeClient) post(url string, payload, out interface{}) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		nonce, err := c.getNonce()
+		if err != nil {
+			return nil, err
+		}
+		protected := map[string]interface{}{"alg": "ES256", "nonce": nonce, "url": url}
+		if c.kid == "" {
+			protected["jwk"] = c.jwk()
+		} else {
+			protected["kid"] = c.kid
+		}
+		header, _ := json.Marshal(protected)
+		body := ""
+		if payload != nil {
+			b, err := json.Marshal(payload)
+			if err != nil {
+				return nil, err
+			}
+			body = b64(b)
+		}
+		signed := b64(header) + "." + body
+		hash := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, c.key, hash[:])
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		jws, _ := json.Marshal(map[string]string{"protected": b64(header), "payload": body, "signature": b64(sig)})
+
+		resp, err := c.http.Post(url, "application/jose+json", bytes.NewReader(jws))
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+			c.nonceMu.Lock()
+			c.nonce = nonce
+			c.nonceMu.Unlock()
+		}
+		if resp.StatusCode >= 400 {
+			var problem struct {
+				Type   string `json:"type"`
+				Detail string `json:"detail"`
+			}
+			json.Unmarshal(data, &problem)
+			// nonces can expire, so one rejected is tried again with
+			// the fresh one sent back.
+			if problem.Type == "urn:ietf:params:acme:error:badNonce" && attempt < 3 {
+				continue
+			}
+			return resp, fmt.Errorf("wedge: ACME request to %s failed with %s: %s %s", url, resp.Status, problem.Type, problem.Detail)
+		}
+		if raw, ok := out.(*[]byte); ok {
+			*raw = data
+		} else if out != nil {
+			if err := json.Unmarshal(data, out); err != nil {
+				return resp, err
+			}
+		}
+		return resp, nil
+	}
+}
+
+// getNonce returns the nonce from the last response, or a new one.
+func (c *acmeClient) getNonce() (string, error) {
+	c.nonceMu.Lock()
+	nonce := c.nonce
+	c.nonce = ""
+	c.nonceMu.Unlock()
+	if nonce != "" {
+		return nonce, nil
+	}
+	resp, err := c.http.Head(c.urls.NewNonce)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	nonce = resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("wedge: ACME server sent no nonce")
+	}
+	return nonce, nil
+}
+
+// register looks up the ACME server's directory and creates the account,
+// or finds the one the key already has.
+func (c *acmeClient) register() error {
+	if c.kid != "" {
+		return nil
+	}
+	resp, err := c.http.Get(c.directory)
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&c.urls)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	account := map[string]interface{}{"termsOfServiceAgreed": c.acceptTOS}
+	if c.email != "" {
+		account["contact"] = []string{"mailto:" + c.email}
+	}
+	resp, err = c.post(c.urls.NewAccount, account, nil)
+	if err != nil {
+		return err
+	}
+	c.kid = resp.Header.Get("Location")
+	if c.kid == "" {
+		return errors.New("wedge: ACME server sent no account URL")
+	}
+	return nil
+}
+
+// poll fetches url into out every acmePoll until status returns something
+// other than pending or processing, or ctx is done.
+func (c *acmeClient) poll(ctx context.Context, url string, out interface{}, status func() string) error {
+	ticker := time.NewTicker(acmePoll)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		if s := status(); s != "pending" && s != "processing" {
+			return nil
+		}
+		if i == acmeAttempts {
+			return fmt.Errorf("wedge: gave up waiting for %s", url)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if _, err := c.post(url, nil, out); err != nil {
+			return err
+		}
+	}
+}
+
+// obtain orders a certificate for domain, answering its challenges with
+// respond, and returns the new key and certificate chain in PEM.
+func (c *acmeClient) obtain(ctx context.Context, domain string, respond func(token, keyAuth string)) ([]byte, error) {
+	c.mu.Lock()
+	err := c.register()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var order acmeOrder
+	identifiers := []map[string]string{{"type": "dns", "value": domain}}
+	resp, err := c.post(c.urls.NewOrder, map[string]interface{}{"identifiers": identifiers}, &order)
+	if err != nil {
+		return nil, err
+	}
+	orderURL := resp.Header.Get("Location")
+	for _, url := range order.Authorizations {
+		if err := c.authorize(ctx, url, respond); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.post(order.Finalize, map[string]string{"csr": b64(csr)}, &order); err != nil {
+		return nil, err
+	}
+	if err := c.poll(ctx, orderURL, &order, func() string { return order.Status }); err != nil {
+		return nil, err
+	}
+	if order.Status != "valid" {
+		return nil, fmt.Errorf("wedge: ACME order for %s is %s", domain, order.Status)
+	}
+	var chain []byte
+	if _, err := c.post(order.Certificate, nil, &chain); err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), chain...), nil
+}
+
+// authorize proves control of the domain of the authorization at url with
+// its HTTP-01 challenge.
+func (c *acmeClient) authorize(ctx context.Context, url string, respond func(token, keyAuth string)) error {
+	var authz acmeAuthorization
+	if _, err := c.post(url, nil, &authz); err != nil {
+		return err
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	var challenge *acmeChallenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "http-01" {
+			challenge = &authz.Challenges[i]
+		}
+	}
+	if challenge == nil {
+		return errors.New("wedge: ACME server offered no http-01 challenge")
+	}
+	respond(challenge.Token, challenge.Token+"."+c.thumbprint())
+	defer respond(challenge.Token, "")
+	if _, err := c.post(challenge.URL, struct{}{}, nil); err != nil {
+		return err
+	}
+	authz.Status = "pending"
+	if err := c.poll(ctx, url, &authz, func() string { return authz.Status }); err != nil {
+		return err
+	}
+	if authz.Status != "valid" {
+		return fmt.Errorf("wedge: ACME authorization is %s", authz.Status)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package wedge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeACME is an ACME server which checks the signatures of requests and
+// the HTTP-01 challenge responses, and issues certificates from its own
+// CA.
+type fakeACME struct {
+	t        *testing.T
+	httpPort string
+	ca       *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+
+	sync.Mutex
+	url       string
+	account   *ecdsa.PublicKey
+	jwk       map[string]string
+	nonce     int
+	badNonce  bool
+	token     string
+	authz     string
+	order     acmeOrder
+	requests  int
+	validated bool
+}
+
+func newFakeACME(t *testing.T, httpPort string) (*fakeACME, *httptest.Server) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+	f := &fakeACME{t: t, httpPort: httpPort, ca: ca, caKey: caKey, badNonce: true, authz: "pending"}
+	srv := httptest.NewServer(f)
+	f.url = srv.URL
+	return f, srv
+}
+
+func (f *fakeACME) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests++
+	f.nonce++
+	w.Header().Set("Replay-Nonce", fmt.Sprint("n", f.nonce))
+	switch req.URL.Path {
+	case "/dir":
+		json.NewEncoder(w).Encode(acmeDirectory{f.url + "/nonce", f.url + "/account", f.url + "/order"})
+		return
+	case "/nonce":
+		return
+	}
+
+	var jws struct{ Protected, Payload, Signature string }
+	json.NewDecoder(req.Body).Decode(&jws)
+	var protected struct {
+		Nonce, URL, Kid string
+		JWK             map[string]string
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	json.Unmarshal(header, &protected)
+	if protected.URL != f.url+req.URL.Path {
+		f.t.Errorf("signed URL %q sent to %s", protected.URL, req.URL.Path)
+	}
+	key := f.account
+	if req.URL.Path == "/account" {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.JWK["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(protected.JWK["y"])
+		key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		f.account, f.jwk = key, protected.JWK
+	} else if protected.Kid != f.url+"/account/1" {
+		f.t.Errorf("request to %s signed by %q", req.URL.Path, protected.Kid)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	hash := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if len(sig) != 64 || !ecdsa.Verify(key, hash[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		f.t.Errorf("bad signature on request to %s", req.URL.Path)
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+
+	switch req.URL.Path {
+	case "/account":
+		var account struct{ TermsOfServiceAgreed bool }
+		json.Unmarshal(payload, &account)
+		if !account.TermsOfServiceAgreed {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Location", f.url+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+	case "/order":
+		if f.badNonce {
+			f.badNonce = false
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type":"urn:ietf:params:acme:error:badNonce"}`)
+			return
+		}
+		f.order = acmeOrder{Status: "pending", Authorizations: []string{f.url + "/authz/1"}, Finalize: f.url + "/finalize/1"}
+		f.token = "tok-" + fmt.Sprint(f.nonce)
+		f.authz = "pending"
+		w.Header().Set("Location", f.url+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(f.order)
+	case "/authz/1":
+		json.NewEncoder(w).Encode(acmeAuthorization{
+			Status:     f.authz,
+			Challenges: []acmeChallenge{{"dns-01", f.url + "/dns", f.token}, {"http-01", f.url + "/chal/1", f.token}},
+		})
+	case "/chal/1":
+		// the client is waiting on this request, so the check has to
+		// be made without holding the lock.
+		token, jwk := f.token, f.jwk
+		f.Unlock()
+		resp, err := http.Get("http://127.0.0.1:" + f.httpPort + acmePrefix + token)
+		f.Lock()
+		want := token + "." + (&acmeClient{key: &ecdsa.PrivateKey{PublicKey: *jwkKey(jwk)}}).thumbprint()
+		f.authz = "invalid"
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) == want {
+				f.authz = "valid"
+				f.validated = true
+			}
+		}
+		fmt.Fprint(w, `{}`)
+	case "/finalize/1":
+		var finalize struct{ CSR string }
+		json.Unmarshal(payload, &finalize)
+		der, _ := base64.RawURLEncoding.DecodeString(finalize.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || f.authz != "valid" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		cert, _ := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(int64(f.nonce)),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, f.ca, csr.PublicKey, f.caKey)
+		f.order.Status = "processing"
+		f.order.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))
+		json.NewEncoder(w).Encode(f.order)
+	case "/order/1":
+		order := f.order
+		order.Status, order.Certificate = "valid", f.url+"/cert/1"
+		json.NewEncoder(w).Encode(order)
+	case "/cert/1":
+		fmt.Fprint(w, f.order.Certificate)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func jwkKey(jwk map[string]string) *ecdsa.PublicKey {
+	x, _ := base64.RawURLEncoding.DecodeString(jwk["x"])
+	y, _ := base64.RawURLEncoding.DecodeString(jwk["y"])
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+}
+
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func TestAutoTLS(t *testing.T) {
+	defer func(poll time.Duration) { acmePoll = poll }(acmePoll)
+	acmePoll = 10 * time.Millisecond
+	dir, err := ioutil.TempDir("", "autotls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	port, httpPort := freePort(t), freePort(t)
+	fake, srv := newFakeACME(t, httpPort)
+	defer srv.Close()
+
+	App := NewAppServer(port, 30)
+	App.SetVerbosity(Quiet)
+	App.AddURLs(URL("^/$", "Index", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "secure", http.StatusOK
+	}, HTML))
+	App.SetAutoTLS(AutoTLS{Directory: srv.URL + "/dir", CacheDir: dir, HTTPPort: httpPort, AcceptTOS: true})
+	if err := App.StartAutoTLS("Example.test"); err != nil {
+		t.Fatal(err)
+	}
+	defer App.Shutdown(context.Background())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(fake.ca)
+	client := func(name string) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial(network, "127.0.0.1:"+port)
+			},
+			TLSClientConfig: &tls.Config{ServerName: name, RootCAs: roots},
+		}}
+	}
+	resp, err := client("example.test").Get("https://example.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secure" || !fake.validated {
+		t.Errorf("got %q, challenge validated: %v", body, fake.validated)
+	}
+	if _, err := client("other.test").Get("https://other.test/"); err == nil {
+		t.Error("got a certificate for a domain which wasn't given")
+	}
+
+	plain := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err = plain.Get("http://127.0.0.1:" + httpPort + "/about/?a=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "https://127.0.0.1:" + port + "/about/?a=1"; resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != want {
+		t.Errorf("got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// a restart uses the cached certificate without asking for a new one.
+	fake.Lock()
+	requests := fake.requests
+	fake.Unlock()
+	m, err := newCertManager(AutoTLS{Directory: srv.URL + "/dir", CacheDir: dir, AcceptTOS: true}, []string{"example.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := m.getCertificate(&tls.ClientHelloInfo{ServerName: "example.test"})
+	if err != nil || cert.Leaf.DNSNames[0] != "example.test" {
+		t.Fatalf("got %v from the cache", err)
+	}
+	fake.Lock()
+	if fake.requests != requests {
+		t.Errorf("the cached certificate made %d requests", fake.requests-requests)
+	}
+	fake.Unlock()
+	if _, err := os.Stat(filepath.Join(dir, "acme-account.key")); err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(cert.Leaf.Issuer.CommonName, "Fake ACME") {
+		t.Errorf("got issuer %q", cert.Leaf.Issuer.CommonName)
+	}
+	// handshakes don't renew it, its timer does.
+	m.Lock()
+	timer := m.renewals["example.test"]
+	m.Unlock()
+	if timer == nil {
+		t.Error("no renewal was scheduled for the cached certificate")
+	}
+	m.stop()
+}
+
+func TestAutoTLSHandshakeWait(t *testing.T) {
+	defer func(wait time.Duration) { acmeHandshakeWait = wait }(acmeHandshakeWait)
+	acmeHandshakeWait = 50 * time.Millisecond
+	dir, err := ioutil.TempDir("", "autotls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// an ACME server which never answers.
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-hung
+	}))
+	defer srv.Close()
+	defer close(hung)
+
+	m, err := newCertManager(AutoTLS{Directory: srv.URL + "/dir", CacheDir: dir, AcceptTOS: true}, []string{"example.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := m.getCertificate(&tls.ClientHelloInfo{ServerName: "example.test"}); err == nil {
+		t.Error("got a certificate from a server which never answered")
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("the handshake waited %v", waited)
+	}
+}
+
+func TestAutoTLSTerms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autotls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := newCertManager(AutoTLS{CacheDir: dir}, []string{"example.test"}); err == nil {
+		t.Error("got no error without AcceptTOS")
+	}
+}
+
+func TestACMEPollCancel(t *testing.T) {
+	c := &acmeClient{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := c.poll(ctx, "http://example.test/order/1", nil, func() string { return "pending" })
+	if err != context.Canceled || time.Since(start) > acmePoll/2 {
+		t.Errorf("got %v after %v", err, time.Since(start))
+	}
+	if err := c.poll(ctx, "http://example.test/order/1", nil, func() string { return "valid" }); err != nil {
+		t.Errorf("got %v for a finished order", err)
+	}
+}
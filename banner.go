@@ -0,0 +1,91 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"text/tabwriter"
+)
+
+// Verbosity is how much the server reports about itself when it starts.
+type Verbosity int
+
+const (
+	// Quiet prints nothing on startup.
+	Quiet Verbosity = iota
+	// Summary prints the address being listened on, whether TLS is in
+	// use and how many routes there are.
+	Summary
+	// RouteTable prints the summary followed by every route.
+	RouteTable
+)
+
+var handlertypeNames = map[handlertype]string{
+	HTML:     "HTML",
+	JSON:     "JSON",
+	STATIC:   "STATIC",
+	ICON:     "ICON",
+	REDIRECT: "REDIRECT",
+	DOWNLOAD: "DOWNLOAD",
+	IMAGE:    "IMAGE",
+	FEED:     "FEED",
+	NDJSON:   "NDJSON",
+}
+
+func (t handlertype) String() string {
+	if name, ok := handlertypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("handlertype(%d)", int(t))
+}
+
+// SetVerbosity sets what Run reports when the server starts. It defaults
+// to Summary. Everything is written with the log package, so it ends up
+// wherever the rest of the server's logging goes.
+func (App *AppServer) SetVerbosity(v Verbosity) {
+	App.verbosity = v
+}
+
+// banner reports the server's configuration according to its verbosity.
+func (App *AppServer) banner() {
+	if App.verbosity == Quiet {
+		return
+	}
+	secure := "off"
+	if App.server != nil && App.server.TLSConfig != nil {
+		secure = "on"
+	}
+	log.Printf("Serving on PORT: %s (TLS: %s, %d routes)\n", App.port, secure, len(App.routes))
+	if App.base != "" {
+		log.Println("Mounted under:", App.base)
+	}
+	if App.verbosity < RouteTable {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(App.routeTable(), "\n"), "\n") {
+		log.Println(line)
+	}
+}
+
+// routeTable lays out the routes in aligned columns.
+func (App *AppServer) routeTable() string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATTERN\tMETHODS\tTYPE\tCACHE")
+	for _, route := range App.routes {
+		methods := strings.Join(route.methods, ",")
+		if methods == "" {
+			methods = "*"
+		}
+		cache := "-"
+		if ttl := route.CacheTTL(); ttl < 0 {
+			cache = "forever"
+		} else if ttl > 0 {
+			cache = ttl.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", route.name, route.rawre, methods, route.viewtype, cache)
+	}
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,53 @@
+package wedge
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestBanner(t *testing.T) {
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "ok", http.StatusOK
+	}
+	App := NewAppServer("8080", 0)
+	App.AddURLs(
+		GET("^/posts/$", "Posts", view, JSON),
+		CacheURL("^/about/$", "About", view, HTML, 60),
+		Route("^/logo$", view, Name("Logo"), ContentType(IMAGE), Cache(-1)),
+	)
+	App.SetBasePath("/blog")
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	log.SetFlags(0)
+	defer log.SetFlags(log.LstdFlags)
+
+	for _, test := range []struct {
+		verbosity Verbosity
+		want      string
+	}{
+		{Quiet, ""},
+		{Summary, "Serving on PORT: 8080 (TLS: off, 3 routes)\nMounted under: /blog\n"},
+		{RouteTable, "Serving on PORT: 8080 (TLS: off, 3 routes)\n" +
+			"Mounted under: /blog\n" +
+			"NAME   PATTERN    METHODS  TYPE   CACHE\n" +
+			"Posts  ^/posts/$  GET      JSON   -\n" +
+			"About  ^/about/$  *        HTML   1m0s\n" +
+			"Logo   ^/logo$    *        IMAGE  forever\n"},
+	} {
+		buf.Reset()
+		App.SetVerbosity(test.verbosity)
+		App.banner()
+		if buf.String() != test.want {
+			t.Errorf("%d: got\n%s\nwant\n%s", test.verbosity, buf, test.want)
+		}
+	}
+
+	if got := handlertype(99).String(); got != "handlertype(99)" {
+		t.Errorf("got %q", got)
+	}
+}
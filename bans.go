@@ -0,0 +1,378 @@
+package wedge
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"wedge/forms"
+)
+
+// BanPolicy configures automatic banning of abusive clients, see
+// EnableBans.
+type BanPolicy struct {
+	// MaxEvents is how many abuse events a client may cause within
+	// Window before it's banned.
+	MaxEvents int
+	Window    time.Duration
+	// BanTime is how long a client's first ban lasts. Each further ban
+	// lasts twice as long as the one before, up to MaxBanTime.
+	BanTime    time.Duration
+	MaxBanTime time.Duration
+	// Allow lists the CIDRs and IPs which are never banned, such as the
+	// site's own monitoring.
+	Allow []string
+	// File is where bans are kept so they survive restarts, with the
+	// clients' addresses as they are, see Ban. They are only kept in
+	// memory if it's empty.
+	File string
+	// Count404s makes every 404 an abuse event.
+	Count404s bool
+}
+
+// Ban is a client which has been banned.
+//
+// Its IP is the client's address as it is, whatever SetPrivacy says, as
+// that's what has to be refused, including after a restart. It's only
+// kept for MaxBanTime after the ban ends, so that a client which comes
+// back is banned for longer, and bans are logged with the address
+// anonymised.
+type Ban struct {
+	IP     string
+	Reason string
+	Until  time.Time
+	// Count is how many times the client has been banned, which sets
+	// how long its next ban lasts.
+	Count int
+}
+
+// BanSweep is how often the events of clients which have gone quiet and
+// bans which are over are forgotten.
+var BanSweep = time.Minute
+
+// MaxBanClients caps how many clients' events, bans and denials are held
+// at once, so a flood of addresses can't exhaust memory.
+var MaxBanClients = 100000
+
+// bans holds the events and bans of each client.
+type bans struct {
+	sync.Mutex
+	BanPolicy
+	allow  []*net.IPNet
+	events map[string][]time.Time
+	bans   map[string]*Ban
+	// saving is held while the bans are written, so that an older copy
+	// can't replace a newer one.
+	saving sync.Mutex
+}
+
+// EnableBans bans clients which cause too many abuse events, which are
+// reported with ReportAbuse and, if the policy counts them, 404s. Banned
+// clients are refused with a 403, see DenyIP. Bans already in p.File are
+// restored.
+//
+// Example:
+//
+//	err := App.EnableBans(wedge.BanPolicy{
+//		MaxEvents:  20,
+//		Window:     time.Minute,
+//		BanTime:    10 * time.Minute,
+//		MaxBanTime: 7 * 24 * time.Hour,
+//		Allow:      []string{"10.0.0.0/8"},
+//		File:       "/var/lib/site/bans.json",
+//		Count404s:  true,
+//	})
+func (App *AppServer) EnableBans(p BanPolicy) error {
+	allow, err := parseNets(p.Allow, "allowed")
+	if err != nil {
+		return err
+	}
+	if p.MaxEvents < 1 {
+		p.MaxEvents = 1
+	}
+	App.initDenied()
+	b := &bans{
+		BanPolicy: p,
+		allow:     allow,
+		events:    make(map[string][]time.Time),
+		bans:      make(map[string]*Ban),
+	}
+	if p.File != "" {
+		data, err := ioutil.ReadFile(p.File)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(data) > 0 {
+			var saved []*Ban
+			if err := json.Unmarshal(data, &saved); err != nil {
+				return err
+			}
+			for _, ban := range saved {
+				b.bans[ban.IP] = ban
+				if time.Now().Before(ban.Until) {
+					App.DenyIP(ban.IP, time.Until(ban.Until))
+				}
+			}
+		}
+	}
+	App.bans = b
+	go b.sweep(App, App.quit)
+	return nil
+}
+
+// sweep prunes the bans and the deny list every BanSweep, until quit is
+// closed.
+func (b *bans) sweep(App *AppServer, quit <-chan struct{}) {
+	tick := time.NewTicker(BanSweep)
+	defer tick.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-tick.C:
+		}
+		if b.prune(time.Now()) {
+			b.save()
+		}
+		App.denied.prune(time.Now())
+	}
+}
+
+// prune forgets the events of clients which have been quiet for a
+// Window, and bans which ended longer than MaxBanTime ago, reporting
+// whether any bans were.
+func (b *bans) prune(now time.Time) bool {
+	keep := b.MaxBanTime
+	if keep < b.BanTime {
+		keep = b.BanTime
+	}
+	b.Lock()
+	defer b.Unlock()
+	for ip, times := range b.events {
+		if now.Sub(times[len(times)-1]) > b.Window {
+			delete(b.events, ip)
+		}
+	}
+	pruned := false
+	for ip, ban := range b.bans {
+		if now.Sub(ban.Until) > keep {
+			delete(b.bans, ip)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// ReportAbuse records an abuse event, such as a failed login, from the
+// client making req, banning it if it has caused too many.
+func (App *AppServer) ReportAbuse(req *http.Request, reason string) {
+	if App.bans == nil {
+		return
+	}
+	App.reportAbuse(App.forwardedFor(req), reason)
+}
+
+func (App *AppServer) reportAbuse(ip, reason string) {
+	b := App.bans
+	if inNets(b.allow, ip) {
+		return
+	}
+	now := time.Now()
+	b.Lock()
+	var recent []time.Time
+	for _, t := range b.events[ip] {
+		if now.Sub(t) <= b.Window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) < b.MaxEvents {
+		if _, ok := b.events[ip]; !ok && len(b.events) >= MaxBanClients {
+			// make room by forgetting some other client's events.
+			for key := range b.events {
+				delete(b.events, key)
+				break
+			}
+		}
+		b.events[ip] = recent
+		b.Unlock()
+		return
+	}
+	delete(b.events, ip)
+	ban, ok := b.bans[ip]
+	if !ok {
+		if len(b.bans) >= MaxBanClients {
+			b.evict(now)
+		}
+		ban = &Ban{IP: ip}
+		b.bans[ip] = ban
+	}
+	ban.Count++
+	ban.Reason = reason
+	d := b.banTime(ban.Count)
+	ban.Until = now.Add(d)
+	b.Unlock()
+
+	log.Println("Banning", App.anonymise(ip), "for", d, "after", reason)
+	App.DenyIP(ip, d)
+	b.save()
+}
+
+// evict forgets a ban to make room for another, one which is over if
+// there is one. It must be called with b locked.
+func (b *bans) evict(now time.Time) {
+	victim := ""
+	for ip, ban := range b.bans {
+		victim = ip
+		if now.After(ban.Until) {
+			break
+		}
+	}
+	delete(b.bans, victim)
+}
+
+// banTime is how long the count'th ban of a client lasts.
+func (b *bans) banTime(count int) time.Duration {
+	d := b.BanTime
+	for i := 1; i < count; i++ {
+		d *= 2
+		if b.MaxBanTime > 0 && d >= b.MaxBanTime {
+			return b.MaxBanTime
+		}
+	}
+	return d
+}
+
+// save writes the bans to the policy's File, if it has one. Expired bans
+// are kept so that a client banned again still gets a longer ban.
+func (b *bans) save() {
+	if b.File == "" {
+		return
+	}
+	b.saving.Lock()
+	defer b.saving.Unlock()
+	b.Lock()
+	var saved []*Ban
+	for _, ban := range b.bans {
+		saved = append(saved, ban)
+	}
+	data, err := json.MarshalIndent(saved, "", "\t")
+	b.Unlock()
+	if err != nil {
+		log.Println("Error saving bans:", err)
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(b.File), ".bans")
+	if err != nil {
+		log.Println("Error saving bans:", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), b.File)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Println("Error saving bans:", err)
+	}
+}
+
+// Bans returns the clients which are currently banned, those whose bans
+// end soonest first.
+func (App *AppServer) Bans() []Ban {
+	if App.bans == nil {
+		return nil
+	}
+	App.bans.Lock()
+	defer App.bans.Unlock()
+	now := time.Now()
+	var current []Ban
+	for _, ban := range App.bans.bans {
+		if now.Before(ban.Until) {
+			current = append(current, *ban)
+		}
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].Until.Before(current[j].Until)
+	})
+	return current
+}
+
+// Unban lifts the ban on ip, if it has one.
+func (App *AppServer) Unban(ip string) {
+	if App.bans == nil {
+		return
+	}
+	App.bans.Lock()
+	if ban, ok := App.bans.bans[ip]; ok {
+		ban.Until = time.Time{}
+	}
+	App.bans.Unlock()
+	App.AllowIP(ip)
+	App.bans.save()
+}
+
+// unbanForm is submitted by each button of BansView. It needs the
+// one-time token displayed with it, so other sites can't lift bans through
+// an administrator's browser.
+var unbanForm = forms.NewForm(forms.NewFormMetadata("unban", "", "POST", false),
+	forms.TextField("unban", "IP", 64),
+).Once()
+
+var bansTemplate = template.Must(template.New("bans").Parse(
+	`<!DOCTYPE html><html><body><h1>Bans</h1>` +
+		`<table border="2"><tr><th>IP</th><th>Reason</th><th>Until</th><th>Bans</th><th></th></tr>` +
+		`{{range .Bans}}<tr><td>{{.IP}}</td><td>{{.Reason}}</td><td>{{.Until.Format "2006-01-02 15:04:05"}}</td>` +
+		`<td>{{.Count}}</td><td><form method="POST"><input type="hidden" name="unban" value="{{.IP}}" />` +
+		`<input type="hidden" name="{{$.TokenField}}" value="{{.Token}}" />` +
+		`<input type="submit" value="Unban"></form></td></tr>{{end}}` +
+		`</table></body></html>`))
+
+// banRow is a Ban as it's shown by BansView, with the token for its form.
+type banRow struct {
+	Ban
+	Token string
+}
+
+// BansView is a view listing the current bans, each with a button to lift
+// it. It has to be mounted behind authentication.
+//
+// Example:
+//
+//	wedge.URL("^/admin/bans/$", "Bans", RequireAdmin(App.BansView), wedge.HTML)
+func (App *AppServer) BansView(w http.ResponseWriter, req *http.Request) (string, int) {
+	if req.Method == "POST" {
+		form := unbanForm.Validate(req)
+		if !form.Valid() {
+			return "", http.StatusForbidden
+		}
+		App.Unban(form.ConvertTyped().String("unban"))
+		return req.URL.Path, http.StatusSeeOther
+	}
+	var rows []banRow
+	for _, ban := range App.Bans() {
+		rows = append(rows, banRow{ban, unbanForm.Token()})
+	}
+	buf := new(bytes.Buffer)
+	err := bansTemplate.Execute(buf, map[string]interface{}{
+		"Bans":       rows,
+		"TokenField": forms.OnceTokenField,
+	})
+	if err != nil {
+		log.Println("Error rendering bans:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
@@ -0,0 +1,203 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bans.json")
+	policy := BanPolicy{
+		MaxEvents:  3,
+		Window:     time.Minute,
+		BanTime:    time.Minute,
+		MaxBanTime: 3 * time.Minute,
+		Allow:      []string{"192.0.2.0/24"},
+		File:       file,
+		Count404s:  true,
+	}
+	App := NewAppServer("0", 0)
+	if err := App.EnableBans(policy); err != nil {
+		t.Fatal(err)
+	}
+	App.AddURLs(URL("^/admin/bans/$", "Bans", App.BansView, HTML))
+
+	get := func(ip, path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i := 0; i < 3; i++ {
+		get("203.0.113.5", "/missing")
+		get("192.0.2.7", "/missing")
+	}
+	if code := get("203.0.113.5", "/admin/bans/"); code != http.StatusForbidden {
+		t.Errorf("client not banned after 3 404s: %d", code)
+	}
+	if code := get("192.0.2.7", "/admin/bans/"); code != http.StatusOK {
+		t.Errorf("allowed client was banned: %d", code)
+	}
+
+	bans := App.Bans()
+	if len(bans) != 1 || bans[0].IP != "203.0.113.5" || bans[0].Count != 1 {
+		t.Fatalf("unexpected bans: %+v", bans)
+	}
+	if d := time.Until(bans[0].Until); d < 50*time.Second || d > time.Minute {
+		t.Errorf("first ban lasts %s, want a minute", d)
+	}
+
+	// bans are restored from the file.
+	restored := NewAppServer("0", 0)
+	if err := restored.EnableBans(policy); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Denied("203.0.113.5") {
+		t.Error("ban wasn't restored")
+	}
+
+	// the view lists and lifts bans.
+	req := httptest.NewRequest("GET", "/admin/bans/", nil)
+	req.RemoteAddr = "192.0.2.7:1234"
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "203.0.113.5") {
+		t.Errorf("ban not listed:\n%s", w.Body)
+	}
+	token := regexp.MustCompile(`name="_once" value="([0-9a-f]+)"`).FindStringSubmatch(w.Body.String())
+	if token == nil {
+		t.Fatalf("no token in:\n%s", w.Body)
+	}
+	unban := func(values url.Values) int {
+		req := httptest.NewRequest("POST", "/admin/bans/", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "192.0.2.7:1234"
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w.Code
+	}
+	// a form posted from elsewhere doesn't have the page's token.
+	if code := unban(url.Values{"unban": {"203.0.113.5"}}); code != http.StatusForbidden || !App.Denied("203.0.113.5") {
+		t.Errorf("unbanning without a token: got %d", code)
+	}
+	if code := unban(url.Values{"unban": {"203.0.113.5"}, "_once": {token[1]}}); code != http.StatusSeeOther {
+		t.Errorf("unbanning: got %d", code)
+	}
+	if code := get("203.0.113.5", "/admin/bans/"); code != http.StatusOK {
+		t.Errorf("client still banned after unbanning: %d", code)
+	}
+
+	// the next ban is twice as long, and later ones are capped.
+	req = httptest.NewRequest("POST", "/login/", nil)
+	req.RemoteAddr = "198.51.100.8:1234"
+	App.bans.bans["198.51.100.8"] = &Ban{IP: "198.51.100.8", Count: 1}
+	for _, want := range []time.Duration{2 * time.Minute, 3 * time.Minute} {
+		for i := 0; i < 3; i++ {
+			App.ReportAbuse(req, "login")
+		}
+		bans := App.Bans()
+		if len(bans) != 1 {
+			t.Fatalf("unexpected bans: %+v", bans)
+		}
+		if d := time.Until(bans[0].Until); d < want-10*time.Second || d > want {
+			t.Errorf("ban %d lasts %s, want %s", bans[0].Count, d, want)
+		}
+		App.Unban("198.51.100.8")
+	}
+}
+
+func TestBansPrune(t *testing.T) {
+	defer func(max int) { MaxBanClients = max }(MaxBanClients)
+	MaxBanClients = 2
+	App := NewAppServer("0", 0)
+	if err := App.EnableBans(BanPolicy{MaxEvents: 2, Window: time.Minute, BanTime: time.Minute, MaxBanTime: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	b := App.bans
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		App.reportAbuse(ip, "test")
+	}
+	if len(b.events) != 2 {
+		t.Errorf("holding the events of %d clients, want at most 2", len(b.events))
+	}
+	if b.prune(time.Now().Add(2 * time.Minute)); len(b.events) != 0 {
+		t.Errorf("%d quiet clients' events weren't forgotten", len(b.events))
+	}
+
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		App.reportAbuse(ip, "test")
+		App.reportAbuse(ip, "test")
+	}
+	if len(b.bans) != 2 || len(App.denied.until) != 2 {
+		t.Errorf("holding %d bans and %d denials, want at most 2", len(b.bans), len(App.denied.until))
+	}
+	if !App.Denied("198.51.100.3") {
+		t.Error("the latest ban made no room for itself")
+	}
+	// bans are remembered for MaxBanTime after they end.
+	if b.prune(time.Now().Add(time.Hour)); len(b.bans) != 2 {
+		t.Errorf("%d bans remembered within MaxBanTime, want 2", len(b.bans))
+	}
+	if b.prune(time.Now().Add(2 * time.Hour)); len(b.bans) != 0 {
+		t.Errorf("%d bans remembered after MaxBanTime", len(b.bans))
+	}
+	App.denied.prune(time.Now().Add(2 * time.Minute))
+	if len(App.denied.until) != 0 {
+		t.Errorf("%d expired denials kept", len(App.denied.until))
+	}
+}
+
+func TestBansPrivacy(t *testing.T) {
+	App := NewAppServer("0", 0)
+	App.SetPrivacy(Privacy{IPs: TruncateIPs})
+	if err := App.EnableBans(BanPolicy{MaxEvents: 1, BanTime: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	App.reportAbuse("203.0.113.77", "test")
+	if strings.Contains(logged.String(), "203.0.113.77") || !strings.Contains(logged.String(), "203.0.113.0") {
+		t.Errorf("logged %q", logged.String())
+	}
+	if !App.Denied("203.0.113.77") {
+		t.Error("the client's own address wasn't denied")
+	}
+}
+
+func TestBansSave(t *testing.T) {
+	policy := BanPolicy{MaxEvents: 1, BanTime: time.Minute, File: filepath.Join(t.TempDir(), "bans.json")}
+	App := NewAppServer("0", 0)
+	if err := App.EnableBans(policy); err != nil {
+		t.Fatal(err)
+	}
+	// saves racing each other leave the file with the last of them.
+	done := make(chan bool)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			App.reportAbuse(fmt.Sprintf("203.0.113.%d", i), "test")
+			done <- true
+		}(i)
+	}
+	for i := 0; i < 20; i++ {
+		<-done
+	}
+	restored := NewAppServer("0", 0)
+	if err := restored.EnableBans(policy); err != nil {
+		t.Fatal(err)
+	}
+	if bans := restored.Bans(); len(bans) != 20 {
+		t.Errorf("restored %d bans, want 20", len(bans))
+	}
+}
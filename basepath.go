@@ -0,0 +1,92 @@
+package wedge
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// SetBasePath mounts the AppServer under path, for when it's served behind
+// a reverse proxy which forwards a subpath such as "/app" to it. Requests
+// outside of path are answered with a 404, and routes are matched against
+// the rest of the path, so they're written as if the app were at the root.
+//
+// Redirects, the paths of assets given to Preload and AddPreload, and
+// anything passed through Link are prefixed with path. Links written into
+// pages by hand have to use Link, or BasePath, themselves.
+//
+// Example:
+//
+//	App.SetBasePath("/app")
+//	// "/app/about/" is routed as "/about/"
+func (App *AppServer) SetBasePath(path string) {
+	path = strings.TrimRight(path, "/")
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	App.base = path
+}
+
+// BasePath returns the path the AppServer is mounted under, or "" if it
+// is mounted at the root.
+func (App *AppServer) BasePath() string {
+	return App.base
+}
+
+// BasePath returns the path the AppServer serving req is mounted under,
+// or "" if it is mounted at the root.
+func BasePath(req *http.Request) string {
+	base, _ := req.Context().Value(basePathKey).(string)
+	return base
+}
+
+// Link returns path as it must be written for a client, with the base path
+// of the AppServer serving req added to it. Only paths starting with a
+// single "/" are changed, so relative paths and full URLs are returned as
+// they are.
+//
+// Example:
+//
+//	`<a href="` + wedge.Link(req, "/about/") + `">About</a>`
+func Link(req *http.Request, path string) string {
+	return prefixPath(BasePath(req), path)
+}
+
+func prefixPath(base, path string) string {
+	if base == "" || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return path
+	}
+	return base + path
+}
+
+// stripBase returns a copy of req whose path has the base path removed,
+// and reports whether req was under the base path at all.
+func (App *AppServer) stripBase(req *http.Request) (*http.Request, bool) {
+	path := req.URL.Path
+	if path != App.base && !strings.HasPrefix(path, App.base+"/") {
+		return req, false
+	}
+	u := *req.URL
+	u.Path = strings.TrimPrefix(path, App.base)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	req = req.WithContext(context.WithValue(req.Context(), basePathKey, App.base))
+	req.URL = &u
+	return req, true
+}
+
+// sendRedirect sends the client to to, which is prefixed with the base path
+// if it is rooted. Relative paths are resolved against the path the client
+// asked for rather than the one the routes see.
+func sendRedirect(w http.ResponseWriter, req *http.Request, to string, status int) {
+	if base := BasePath(req); base != "" {
+		u := *req.URL
+		u.Path = base + u.Path
+		req = req.WithContext(req.Context())
+		req.URL = &u
+		to = prefixPath(base, to)
+	}
+	http.Redirect(w, req, to, status)
+}
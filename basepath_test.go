@@ -0,0 +1,52 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.SetBasePath("app/")
+	App.AddURLs(
+		URL("^/about/$", "About", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return Link(req, "/contact/"), http.StatusOK
+		}, HTML),
+		URL("^/old/$", "Old", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "/about/", http.StatusSeeOther
+		}, HTML),
+		URL("^/rel/x$", "Relative", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "y", http.StatusSeeOther
+		}, HTML),
+	)
+	if App.BasePath() != "/app" {
+		t.Errorf("BasePath: got %q", App.BasePath())
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"/app/about/", 200, "/app/contact/", ""},
+		{"/about/", 404, "", ""},
+		{"/application/about/", 404, "", ""},
+		{"/app/old/", 303, "", "/app/about/"},
+		{"/app/rel/x", 303, "", "/app/rel/y"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.path, w.Code, test.status)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: got body %q, want %q", test.path, w.Body.String(), test.body)
+		}
+		if got := w.Header().Get("Location"); got != test.location {
+			t.Errorf("%s: got Location %q, want %q", test.path, got, test.location)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// topN keeps approximate counts of the most frequent keys in a bounded
+// amount of memory. Counts decay exponentially with the given half life,
+// so keys which were popular a long time ago give way to current ones.
+type topN struct {
+	size     int
+	halfLife time.Duration
+	entries  map[string]*decayed
+}
+
+type decayed struct {
+	count float64
+	at    time.Time
+}
+
+// value is the count of d, decayed to now.
+func (d *decayed) value(now time.Time, halfLife time.Duration) float64 {
+	if halfLife <= 0 {
+		return d.count
+	}
+	return d.count * math.Exp2(-float64(now.Sub(d.at))/float64(halfLife))
+}
+
+func newTopN(size int, halfLife time.Duration) *topN {
+	return &topN{size: size, halfLife: halfLife, entries: make(map[string]*decayed)}
+}
+
+// add counts a hit to key. Up to four times size keys are tracked, so
+// that newcomers have a chance to climb into the top before the least
+// frequent key is evicted to make room.
+func (t *topN) add(key string, now time.Time) {
+	if d, ok := t.entries[key]; ok {
+		d.count = d.value(now, t.halfLife) + 1
+		d.at = now
+		return
+	}
+	if len(t.entries) >= t.size*4 {
+		var min string
+		minValue := math.Inf(1)
+		for k, d := range t.entries {
+			if v := d.value(now, t.halfLife); v < minValue {
+				min, minValue = k, v
+			}
+		}
+		delete(t.entries, min)
+	}
+	t.entries[key] = &decayed{1, now}
+}
+
+// StatCount is a key and its decayed count, as shown in the breakdowns.
+type StatCount struct {
+	Key   string
+	Count float64
+}
+
+// top returns the size most frequent keys, most frequent first.
+func (t *topN) top(now time.Time) []StatCount {
+	var counts []StatCount
+	for k, d := range t.entries {
+		counts = append(counts, StatCount{k, d.value(now, t.halfLife)})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Key < counts[j].Key
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	if len(counts) > t.size {
+		counts = counts[:t.size]
+	}
+	return counts
+}
+
+// breakdown holds the top referrers, user agents and, if a GeoResolver
+// is set, countries for each route.
+type breakdown struct {
+	sync.Mutex
+	size      int
+	halfLife  time.Duration
+	referrers map[string]*topN
+	agents    map[string]*topN
+	countries map[string]*topN
+}
+
+// EnableStatBreakdown extends stat tracking with the top n referring sites
+// and browser families for each route, shown on the statistics page. Older
+// hits count for half as much every halfLife, or never fade if it's zero.
+// EnableStatTracking must be called as well.
+func (App *AppServer) EnableStatBreakdown(n int, halfLife time.Duration) {
+	App.breakdown = &breakdown{
+		size:      n,
+		halfLife:  halfLife,
+		referrers: make(map[string]*topN),
+		agents:    make(map[string]*topN),
+		countries: make(map[string]*topN),
+	}
+}
+
+// record counts the referrer and user agent of req against route.
+func (b *breakdown) record(route string, req *http.Request, now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+	if b.referrers[route] == nil {
+		b.referrers[route] = newTopN(b.size, b.halfLife)
+		b.agents[route] = newTopN(b.size, b.halfLife)
+		b.countries[route] = newTopN(b.size, b.halfLife)
+	}
+	b.referrers[route].add(referrer(req), now)
+	b.agents[route].add(AgentFamily(req.UserAgent()), now)
+	if country := Country(req); country != "" {
+		b.countries[route].add(country, now)
+	}
+}
+
+// referrer returns the site which referred req, or "(direct)" if there
+// isn't one, and "(internal)" for links within the site itself.
+func referrer(req *http.Request) string {
+	ref, err := url.Parse(req.Referer())
+	if err != nil || ref.Host == "" {
+		return "(direct)"
+	}
+	if strings.EqualFold(ref.Host, req.Host) {
+		return "(internal)"
+	}
+	return strings.ToLower(ref.Host)
+}
+
+// agentFamilies maps substrings of a User-Agent to its family. Order
+// matters, as most browsers claim to be several of the others.
+var agentFamilies = []struct {
+	match  string
+	family string
+}{
+	{"bot", "Bot"},
+	{"spider", "Bot"},
+	{"crawl", "Bot"},
+	{"edg/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"firefox", "Firefox"},
+	{"chrome", "Chrome"},
+	{"crios", "Chrome"},
+	{"safari", "Safari"},
+	{"msie", "Internet Explorer"},
+	{"trident", "Internet Explorer"},
+	{"curl", "curl"},
+	{"wget", "Wget"},
+	{"go-http-client", "Go"},
+	{"python", "Python"},
+}
+
+// AgentFamily returns the browser family of a User-Agent, such as
+// "Firefox" or "Bot", which is how user agents are grouped in the
+// statistics.
+func AgentFamily(agent string) string {
+	if agent == "" {
+		return "(none)"
+	}
+	agent = strings.ToLower(agent)
+	for _, f := range agentFamilies {
+		if strings.Contains(agent, f.match) {
+			return f.family
+		}
+	}
+	return "Other"
+}
+
+// html renders the breakdowns for each route.
+func (b *breakdown) html() string {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	var routes []string
+	for route := range b.referrers {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	buf := new(bytes.Buffer)
+	for _, route := range routes {
+		fmt.Fprintf(buf, `<p>%s</p><table border="2"><tr><th>Referrer</th><th>Hits</th>`+
+			`<th>Browser</th><th>Hits</th>`, template.HTMLEscapeString(route))
+		columns := [][]StatCount{b.referrers[route].top(now), b.agents[route].top(now)}
+		if countries := b.countries[route].top(now); len(countries) > 0 {
+			buf.WriteString("<th>Country</th><th>Hits</th>")
+			columns = append(columns, countries)
+		}
+		buf.WriteString("</tr>")
+		rows := 0
+		for _, counts := range columns {
+			if len(counts) > rows {
+				rows = len(counts)
+			}
+		}
+		for i := 0; i < rows; i++ {
+			buf.WriteString("<tr>")
+			for _, counts := range columns {
+				if i < len(counts) {
+					fmt.Fprintf(buf, "<td>%s</td><td>%.1f</td>",
+						template.HTMLEscapeString(counts[i].Key), counts[i].Count)
+				} else {
+					buf.WriteString("<td></td><td></td>")
+				}
+			}
+			buf.WriteString("</tr>")
+		}
+		buf.WriteString("</table>")
+	}
+	return buf.String()
+}
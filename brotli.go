@@ -0,0 +1,748 @@
+package wedge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// brotliWindowBits is the log2 of the window brotliWriter matches in.
+	brotliWindowBits  = 18
+	brotliMaxDistance = 1<<brotliWindowBits - 16
+	// brotliBlockSize is the most input compressed as one meta-block.
+	brotliBlockSize = 1 << 16
+	brotliHashBits  = 15
+	brotliMinMatch  = 4
+	// brotliDefaultQuality is used for a quality of -1.
+	brotliDefaultQuality = 4
+)
+
+// brotliLevels are, by quality, how many earlier positions with the same
+// hash are tried for a match, the length of match good enough to stop
+// looking, and the length below which a match is put off in case there's
+// a longer one starting at the next byte.
+var brotliLevels = [12]struct{ depth, nice, lazy int }{
+	{1, 16, 0}, {2, 24, 0}, {4, 32, 0}, {8, 48, 0},
+	{8, 64, 16}, {16, 96, 32}, {32, 128, 64}, {64, 192, 128},
+	{128, 258, 258}, {256, 512, 512}, {1024, 1024, 1024}, {4096, 4096, 4096},
+}
+
+// brotliWriter compresses to Brotli (RFC 7932) at a quality from 0, the
+// fastest, to 11, the smallest. Each block of input is a meta-block of
+// its own with prefix codes fitted to it, and matches are found with hash
+// chains. It doesn't use Brotli's dictionary, context modelling or block
+// splitting, so it compresses less than the reference encoder does at the
+// same quality. From quality 3 on it compresses better than gzip's default
+// level, though more slowly.
+type brotliWriter struct {
+	w                 io.Writer
+	depth, nice, lazy int
+
+	// hist is the window, followed by the input from pending on which
+	// hasn't been compressed yet.
+	hist    []byte
+	pending int
+	// hashed is how much of hist is in the hash chains. head has the
+	// index in hist, plus one, of the latest position with each hash, and
+	// prev how far back from each position the one before it with the
+	// same hash is, or 0.
+	hashed int
+	head   []int32
+	prev   []int32
+	// last is the distance of the last match, which is the cheapest to
+	// use again.
+	last int
+
+	bits   brotliBits
+	closed bool
+	err    error
+}
+
+// newBrotliWriter returns a brotliWriter compressing to w at quality, or
+// at brotliDefaultQuality if it's -1.
+func newBrotliWriter(w io.Writer, quality int) (*brotliWriter, error) {
+	if quality == -1 {
+		quality = brotliDefaultQuality
+	}
+	if quality < 0 || quality >= len(brotliLevels) {
+		return nil, fmt.Errorf("wedge: invalid Brotli quality %d", quality)
+	}
+	level := brotliLevels[quality]
+	b := &brotliWriter{
+		w:     w,
+		depth: level.depth,
+		nice:  level.nice,
+		lazy:  level.lazy,
+		head:  make([]int32, 1<<brotliHashBits),
+		last:  4,
+	}
+	// WBITS: a 1 and then WBITS-17 in 3 bits.
+	b.bits.write(4, 1|(brotliWindowBits-17)<<1)
+	return b, nil
+}
+
+func (b *brotliWriter) Write(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n := len(p)
+	for len(p) > 0 {
+		room := brotliBlockSize - (len(b.hist) - b.pending)
+		if room > len(p) {
+			room = len(p)
+		}
+		b.hist = append(b.hist, p[:room]...)
+		p = p[room:]
+		if cap(b.prev) < cap(b.hist) {
+			prev := make([]int32, len(b.prev), cap(b.hist))
+			copy(prev, b.prev)
+			b.prev = prev
+		}
+		if len(b.hist)-b.pending == brotliBlockSize {
+			if err := b.block(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush compresses and sends everything written so far.
+func (b *brotliWriter) Flush() error {
+	if b.err != nil || b.closed {
+		return b.err
+	}
+	if err := b.block(); err != nil {
+		return err
+	}
+	// an empty metadata block brings the stream to a byte boundary, so
+	// the last byte can go out too.
+	if b.bits.n%8 != 0 {
+		b.bits.write(6, 3<<1)
+		b.bits.align()
+	}
+	return b.output()
+}
+
+// Close compresses what's left and ends the stream, without closing the
+// underlying writer.
+func (b *brotliWriter) Close() error {
+	if b.err != nil || b.closed {
+		return b.err
+	}
+	if err := b.block(); err != nil {
+		return err
+	}
+	b.closed = true
+	// ISLAST and ISLASTEMPTY.
+	b.bits.write(2, 3)
+	b.bits.align()
+	return b.output()
+}
+
+// output sends the whole bytes of the stream written so far.
+func (b *brotliWriter) output() error {
+	b.bits.bytes()
+	if len(b.bits.out) == 0 {
+		return nil
+	}
+	_, b.err = b.w.Write(b.bits.out)
+	b.bits.out = b.bits.out[:0]
+	return b.err
+}
+
+// block compresses the pending input as a meta-block, and sends it, then
+// slides the window along if it's full.
+func (b *brotliWriter) block() error {
+	start, end := b.pending, len(b.hist)
+	if start == end {
+		return nil
+	}
+	var cmds []brotliCommand
+	lit, last := start, b.last
+	for i := start; i+brotliMinMatch <= end; {
+		b.hashTo(i)
+		length, dist := b.match(i, end)
+		if length == 0 {
+			i++
+			continue
+		}
+		for length < b.lazy && i+1+brotliMinMatch <= end {
+			b.hashTo(i + 1)
+			next, nextDist := b.match(i+1, end)
+			if next <= length {
+				break
+			}
+			i++
+			length, dist = next, nextDist
+		}
+		cmds = append(cmds, brotliCommand{insert: i - lit, copy: length, dist: dist})
+		b.last = dist
+		i += length
+		lit = i
+	}
+	if lit < end {
+		cmds = append(cmds, brotliCommand{insert: end - lit})
+	}
+	b.hashTo(end)
+
+	// compressed works out the last distance again as it codes them.
+	b.last = last
+	mark := b.bits.mark()
+	b.compressed(b.hist[start:end], cmds)
+	// incompressible input is sent as it is.
+	if b.bits.since(mark) > 8*(end-start)+64 {
+		b.bits.reset(mark)
+		b.last = last
+		b.bits.header(end-start, true)
+		b.bits.align()
+		b.bits.out = append(b.bits.out, b.hist[start:end]...)
+	}
+	b.pending = end
+
+	// sliding it along copies the whole window, so it waits until it's
+	// twice as big as it needs to be.
+	if drop := len(b.hist) - brotliMaxDistance; drop >= brotliMaxDistance {
+		n := copy(b.hist, b.hist[drop:])
+		b.hist = b.hist[:n]
+		n = copy(b.prev, b.prev[drop:])
+		b.prev = b.prev[:n]
+		b.pending -= drop
+		b.hashed -= drop
+		for h, pos := range b.head {
+			if int(pos) > drop {
+				b.head[h] = pos - int32(drop)
+			} else {
+				b.head[h] = 0
+			}
+		}
+	}
+	return b.output()
+}
+
+// hashTo adds the positions in hist before i to the hash chains.
+func (b *brotliWriter) hashTo(i int) {
+	for ; b.hashed < i && b.hashed+brotliMinMatch <= len(b.hist); b.hashed++ {
+		h := brotliHash(b.hist[b.hashed:])
+		var back int32
+		if prev := int(b.head[h]) - 1; prev >= 0 && b.hashed-prev <= brotliMaxDistance {
+			back = int32(b.hashed - prev)
+		}
+		b.prev = append(b.prev, back)
+		b.head[h] = int32(b.hashed + 1)
+	}
+}
+
+func brotliHash(p []byte) uint32 {
+	return binary.LittleEndian.Uint32(p) * 0x1e35a7bd >> (32 - brotliHashBits)
+}
+
+// match returns the longest match found for hist[i:end], and its
+// distance, or a length of 0 if there's none of at least brotliMinMatch.
+func (b *brotliWriter) match(i, end int) (length, dist int) {
+	s := b.hist[:end]
+	best := brotliMinMatch - 1
+	// the last distance is the cheapest to code, so it's tried first.
+	if d := b.last; d <= i {
+		if n := brotliMatchLen(s[i-d:], s[i:]); n > best {
+			best, dist = n, d
+		}
+	}
+	cand := int(b.head[brotliHash(s[i:])]) - 1
+	for depth := b.depth; depth > 0 && cand >= 0 && best < b.nice; depth-- {
+		if i-cand > brotliMaxDistance {
+			break
+		}
+		if best < len(s)-i && s[cand+best] == s[i+best] {
+			if n := brotliMatchLen(s[cand:], s[i:]); n > best {
+				best, dist = n, i-cand
+			}
+		}
+		back := b.prev[cand]
+		if back == 0 {
+			break
+		}
+		cand -= int(back)
+	}
+	if best < brotliMinMatch {
+		return 0, 0
+	}
+	return best, dist
+}
+
+// brotliMatchLen returns how many bytes at the start of a and b match.
+func brotliMatchLen(a, b []byte) int {
+	n := 0
+	for len(b)-n >= 8 {
+		if x := binary.LittleEndian.Uint64(a[n:]) ^ binary.LittleEndian.Uint64(b[n:]); x != 0 {
+			return n + bits.TrailingZeros64(x)/8
+		}
+		n += 8
+	}
+	for n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// brotliCommand is some literals followed by a copy of copy bytes from
+// dist back. The last command of a meta-block may have no copy.
+type brotliCommand struct {
+	insert, copy, dist int
+}
+
+var (
+	brotliInsertBase  = [24]int{0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98, 130, 194, 322, 578, 1090, 2114, 6210, 22594}
+	brotliInsertExtra = [24]uint{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 12, 14, 24}
+	brotliCopyBase    = [24]int{2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54, 70, 102, 134, 198, 326, 582, 1094, 2118}
+	brotliCopyExtra   = [24]uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 24}
+	// brotliCells are the first insert-and-copy codes which use an explicit
+	// distance, by the insert length code / 8 and the copy length code / 8.
+	brotliCells = [3][3]int{{128, 192, 384}, {256, 320, 512}, {448, 576, 640}}
+)
+
+// brotliLengthCode returns the code in base for n.
+func brotliLengthCode(base *[24]int, n int) int {
+	code := 0
+	for code < len(base)-1 && base[code+1] <= n {
+		code++
+	}
+	return code
+}
+
+// brotliSymbol is a command, coded.
+type brotliSymbol struct {
+	cmd, dist                   int
+	insert, copy, distExtra     uint64
+	insertBits, copyBits, dBits uint
+}
+
+// compressed writes data as a compressed meta-block of cmds.
+func (b *brotliWriter) compressed(data []byte, cmds []brotliCommand) {
+	var litFreq [256]uint32
+	var cmdFreq [704]uint32
+	var distFreq [64]uint32
+	for _, c := range data {
+		litFreq[c]++
+	}
+	syms := make([]brotliSymbol, len(cmds))
+	for i, c := range cmds {
+		s := &syms[i]
+		ins := brotliLengthCode(&brotliInsertBase, c.insert)
+		s.insert, s.insertBits = uint64(c.insert-brotliInsertBase[ins]), brotliInsertExtra[ins]
+		cp := 0
+		if c.copy > 0 {
+			cp = brotliLengthCode(&brotliCopyBase, c.copy)
+			s.copy, s.copyBits = uint64(c.copy-brotliCopyBase[cp]), brotliCopyExtra[cp]
+		}
+		// the meta-block ends before a distance would be read for a
+		// command with no copy.
+		s.dist = -1
+		if ins < 8 && cp < 16 && (c.copy == 0 || c.dist == b.last) {
+			// codes below 128 mean the last distance again.
+			s.cmd = (cp>>3)<<6 | (ins&7)<<3 | cp&7
+		} else {
+			s.cmd = brotliCells[ins>>3][cp>>3] | (ins&7)<<3 | cp&7
+		}
+		switch {
+		case s.cmd < 128 || c.copy == 0:
+		case c.dist == b.last:
+			s.dist = 0
+		default:
+			// NPOSTFIX and NDIRECT are 0, so distance codes from 16
+			// on are a bit for the top of d and how many bits
+			// there are below it.
+			d := c.dist + 3
+			n := uint(bits.Len(uint(d)) - 2)
+			top := d >> n & 1
+			s.dist = 16 + 2*int(n-1) + top
+			s.distExtra, s.dBits = uint64(d-(2+top)<<n), n
+			b.last = c.dist
+		}
+		cmdFreq[s.cmd]++
+		if s.dist >= 0 {
+			distFreq[s.dist]++
+		}
+	}
+	lits := newBrotliCode(litFreq[:])
+	commands := newBrotliCode(cmdFreq[:])
+	dists := newBrotliCode(distFreq[:])
+
+	w := &b.bits
+	w.header(len(data), false)
+	// one block type of each kind, no NPOSTFIX or NDIRECT, a context
+	// mode and one prefix code for literals and distances.
+	w.write(13, 0)
+	lits.store(w, 8)
+	commands.store(w, 10)
+	dists.store(w, 6)
+	for i, s := range syms {
+		commands.put(w, s.cmd)
+		w.write(s.insertBits, s.insert)
+		w.write(s.copyBits, s.copy)
+		for _, c := range data[:cmds[i].insert] {
+			lits.put(w, int(c))
+		}
+		data = data[cmds[i].insert+cmds[i].copy:]
+		if s.dist >= 0 {
+			dists.put(w, s.dist)
+			w.write(s.dBits, s.distExtra)
+		}
+	}
+}
+
+// brotliBits writes a bit stream, least significant bit first, up to 32
+// bits at a time. The bits are kept in acc until there are 32 of them.
+type brotliBits struct {
+	out []byte
+	acc uint64
+	n   uint
+}
+
+func (w *brotliBits) write(n uint, v uint64) {
+	w.acc |= v << w.n
+	w.n += n
+	if w.n >= 32 {
+		w.out = append(w.out, byte(w.acc), byte(w.acc>>8), byte(w.acc>>16), byte(w.acc>>24))
+		w.acc >>= 32
+		w.n -= 32
+	}
+}
+
+// bytes moves the whole bytes in acc to out.
+func (w *brotliBits) bytes() {
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+// align pads the stream with zeros to a byte boundary, and moves it all
+// to out.
+func (w *brotliBits) align() {
+	if w.n%8 != 0 {
+		w.write(8-w.n%8, 0)
+	}
+	w.bytes()
+}
+
+// brotliMark is a position in a brotliBits to go back to.
+type brotliMark struct {
+	len int
+	acc uint64
+	n   uint
+}
+
+func (w *brotliBits) mark() brotliMark {
+	return brotliMark{len(w.out), w.acc, w.n}
+}
+
+// since returns how many bits have been written since m.
+func (w *brotliBits) since(m brotliMark) int {
+	return 8*(len(w.out)-m.len) + int(w.n) - int(m.n)
+}
+
+func (w *brotliBits) reset(m brotliMark) {
+	w.out, w.acc, w.n = w.out[:m.len], m.acc, m.n
+}
+
+// header starts a meta-block which isn't the last, of n bytes.
+func (w *brotliBits) header(n int, uncompressed bool) {
+	nibbles := uint(4)
+	for n-1 >= 1<<(4*nibbles) {
+		nibbles++
+	}
+	w.write(1, 0)
+	w.write(2, uint64(nibbles-4))
+	w.write(4*nibbles, uint64(n-1))
+	if uncompressed {
+		w.write(1, 1)
+	} else {
+		w.write(1, 0)
+	}
+}
+
+// brotliCode is a prefix code, with the length and the bit reversed code
+// of each symbol. A code for one symbol has no bits at all.
+type brotliCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  int
+}
+
+func newBrotliCode(freq []uint32) *brotliCode {
+	c := &brotliCode{lengths: brotliLengths(freq, 15)}
+	used := 0
+	for s, l := range c.lengths {
+		if l > 0 {
+			used++
+			c.single = s
+		}
+	}
+	if used == 1 {
+		c.lengths[c.single] = 0
+	}
+	c.codes = brotliCodes(c.lengths)
+	return c
+}
+
+func (c *brotliCode) put(w *brotliBits, sym int) {
+	w.write(uint(c.lengths[sym]), uint64(c.codes[sym]))
+}
+
+// brotliOrder is the order the code lengths of the code for code lengths
+// are stored in.
+var brotliOrder = [18]int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// store writes the code to w for an alphabet whose symbols take
+// alphabetBits, as a simple code if it has four symbols or fewer and
+// otherwise as code lengths, themselves prefix coded.
+func (c *brotliCode) store(w *brotliBits, alphabetBits uint) {
+	var syms []int
+	for s, l := range c.lengths {
+		if l > 0 {
+			syms = append(syms, s)
+		}
+	}
+	if len(syms) <= 4 {
+		if len(syms) == 0 {
+			syms = []int{c.single}
+		}
+		sort.Slice(syms, func(i, j int) bool {
+			if c.lengths[syms[i]] != c.lengths[syms[j]] {
+				return c.lengths[syms[i]] < c.lengths[syms[j]]
+			}
+			return syms[i] < syms[j]
+		})
+		w.write(2, 1)
+		w.write(2, uint64(len(syms)-1))
+		for _, s := range syms {
+			w.write(alphabetBits, uint64(s))
+		}
+		if len(syms) == 4 {
+			if c.lengths[syms[0]] == 1 {
+				w.write(1, 1)
+			} else {
+				w.write(1, 0)
+			}
+		}
+		return
+	}
+
+	codes, extra := brotliRLE(c.lengths)
+	var freq [18]uint32
+	for _, code := range codes {
+		freq[code]++
+	}
+	lengths := brotliLengths(freq[:], 5)
+	used, single := 0, 0
+	for s, l := range lengths {
+		if l > 0 {
+			used++
+			single = s
+		}
+	}
+	stored := len(brotliOrder)
+	if used > 1 {
+		for lengths[brotliOrder[stored-1]] == 0 {
+			stored--
+		}
+	}
+	skip := 0
+	if lengths[brotliOrder[0]] == 0 && lengths[brotliOrder[1]] == 0 {
+		skip = 2
+		if lengths[brotliOrder[2]] == 0 {
+			skip = 3
+		}
+	}
+	w.write(2, uint64(skip))
+	for _, s := range brotliOrder[skip:stored] {
+		// a fixed code for the lengths 0 to 5.
+		l := lengths[s]
+		w.write(uint([6]uint8{2, 4, 3, 2, 2, 4}[l]), uint64([6]uint8{0, 7, 3, 2, 1, 15}[l]))
+	}
+	if used == 1 {
+		lengths[single] = 0
+	}
+	lc := brotliCodes(lengths)
+	for i, code := range codes {
+		w.write(uint(lengths[code]), uint64(lc[code]))
+		switch code {
+		case 16:
+			w.write(2, uint64(extra[i]))
+		case 17:
+			w.write(3, uint64(extra[i]))
+		}
+	}
+}
+
+// brotliRLE codes lengths, leaving out the zeros at the end, with 16 to
+// repeat the last length other than zero and 17 to repeat zero. Repeats
+// which follow each other multiply, so a run is coded as several of them
+// with the most significant first.
+func brotliRLE(lengths []uint8) (codes, extra []uint8) {
+	n := len(lengths)
+	for n > 0 && lengths[n-1] == 0 {
+		n--
+	}
+	push := func(code, bits uint8) {
+		codes = append(codes, code)
+		extra = append(extra, bits)
+	}
+	repeat := func(code uint8, reps int, mask, shift uint) {
+		start := len(codes)
+		reps -= 3
+		for {
+			push(code, uint8(reps&int(mask)))
+			reps >>= shift
+			if reps == 0 {
+				break
+			}
+			reps--
+		}
+		for i, j := start, len(codes)-1; i < j; i, j = i+1, j-1 {
+			codes[i], codes[j] = codes[j], codes[i]
+			extra[i], extra[j] = extra[j], extra[i]
+		}
+	}
+	prev := uint8(8)
+	for i := 0; i < n; {
+		v, reps := lengths[i], 1
+		for i+reps < n && lengths[i+reps] == v {
+			reps++
+		}
+		i += reps
+		if v == 0 {
+			if reps == 11 {
+				push(0, 0)
+				reps--
+			}
+			if reps < 3 {
+				for ; reps > 0; reps-- {
+					push(0, 0)
+				}
+			} else {
+				repeat(17, reps, 7, 3)
+			}
+			continue
+		}
+		if v != prev {
+			push(v, 0)
+			reps--
+		}
+		if reps == 7 {
+			push(v, 0)
+			reps--
+		}
+		if reps < 3 {
+			for ; reps > 0; reps-- {
+				push(v, 0)
+			}
+		} else {
+			repeat(16, reps, 3, 2)
+		}
+		prev = v
+	}
+	return codes, extra
+}
+
+// brotliLengths returns the lengths of a Huffman code for freq, none of
+// which are longer than limit. Symbols which don't occur have none, and
+// if only one does its length is 1.
+func brotliLengths(freq []uint32, limit uint8) []uint8 {
+	lengths := make([]uint8, len(freq))
+	var syms []int
+	for s, f := range freq {
+		if f > 0 {
+			syms = append(syms, s)
+		}
+	}
+	if len(syms) == 1 {
+		lengths[syms[0]] = 1
+	}
+	if len(syms) < 2 {
+		return lengths
+	}
+	// rarer symbols are made less rare until the code is short enough.
+	for floor := uint32(1); ; floor *= 2 {
+		weight := func(s int) uint32 {
+			if freq[s] < floor {
+				return floor
+			}
+			return freq[s]
+		}
+		sort.Slice(syms, func(i, j int) bool {
+			wi, wj := weight(syms[i]), weight(syms[j])
+			return wi < wj || wi == wj && syms[i] < syms[j]
+		})
+		// leaves are taken in order from syms and the nodes joining
+		// them from after them in weights, as they're made in order of
+		// weight too.
+		n := len(syms)
+		weights := make([]uint32, 2*n-1)
+		parent := make([]int, 2*n-1)
+		for i, s := range syms {
+			weights[i] = weight(s)
+		}
+		leaf, node := 0, n
+		smallest := func(made int) int {
+			if leaf < n && (node == made || weights[leaf] <= weights[node]) {
+				leaf++
+				return leaf - 1
+			}
+			node++
+			return node - 1
+		}
+		for made := n; made < 2*n-1; made++ {
+			a := smallest(made)
+			b := smallest(made)
+			weights[made] = weights[a] + weights[b]
+			parent[a], parent[b] = made, made
+		}
+		depth := make([]uint8, 2*n-1)
+		longest := uint8(0)
+		for i := 2*n - 3; i >= 0; i-- {
+			depth[i] = depth[parent[i]] + 1
+			if i < n && depth[i] > longest {
+				longest = depth[i]
+			}
+		}
+		if longest <= limit {
+			for i, s := range syms {
+				lengths[s] = depth[i]
+			}
+			return lengths
+		}
+	}
+}
+
+// brotliCodes returns the canonical codes for lengths, bit reversed as
+// they're written least significant bit first.
+func brotliCodes(lengths []uint8) []uint16 {
+	var count, next [16]int
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+	code := 0
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l > 0 {
+			codes[s] = bits.Reverse16(uint16(next[l])) >> (16 - l)
+			next[l]++
+		}
+	}
+	return codes
+}
@@ -0,0 +1,329 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// brotliReader reads a bit stream, least significant bit first.
+type brotliReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *brotliReader) bits(n uint) int {
+	v := 0
+	for i := uint(0); i < n; i++ {
+		if r.pos/8 >= len(r.data) {
+			panic("unexpected end of stream")
+		}
+		v |= int(r.data[r.pos/8]>>(r.pos%8)&1) << i
+		r.pos++
+	}
+	return v
+}
+
+func (r *brotliReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+// brotliTestCode maps the length and code of each symbol to the symbol.
+type brotliTestCode map[[2]int]int
+
+func newBrotliTestCode(lengths []int) brotliTestCode {
+	c := make(brotliTestCode)
+	var count, next [16]int
+	used, single := 0, 0
+	for s, l := range lengths {
+		if l > 0 {
+			count[l]++
+			used++
+			single = s
+		}
+	}
+	if used == 1 {
+		c[[2]int{0, 0}] = single
+		return c
+	}
+	code := 0
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l > 0 {
+			c[[2]int{l, next[l]}] = s
+			next[l]++
+		}
+	}
+	return c
+}
+
+func (r *brotliReader) symbol(c brotliTestCode) int {
+	code := 0
+	for l := 0; l <= 15; l++ {
+		if s, ok := c[[2]int{l, code}]; ok {
+			return s
+		}
+		code = code<<1 | r.bits(1)
+	}
+	panic("invalid prefix code")
+}
+
+// code reads a prefix code for an alphabet of size symbols.
+func (r *brotliReader) code(size int) brotliTestCode {
+	lengths := make([]int, size)
+	hskip := r.bits(2)
+	if hskip == 1 {
+		syms := make([]int, r.bits(2)+1)
+		for i := range syms {
+			syms[i] = r.bits(uint(bits.Len(uint(size - 1))))
+		}
+		shape := [][]int{{1}, {1, 1}, {1, 2, 2}, {2, 2, 2, 2}}[len(syms)-1]
+		if len(syms) == 4 && r.bits(1) == 1 {
+			shape = []int{1, 2, 3, 3}
+		}
+		for i, s := range syms {
+			lengths[s] = shape[i]
+		}
+		return newBrotliTestCode(lengths)
+	}
+
+	var codeLengths [18]int
+	space := 32
+	for _, s := range brotliOrder[hskip:] {
+		// the fixed code for code lengths.
+		v := [4]int{0, 4, 3, -1}[r.bits(2)]
+		if v < 0 {
+			switch {
+			case r.bits(1) == 0:
+				v = 2
+			case r.bits(1) == 0:
+				v = 1
+			default:
+				v = 5
+			}
+		}
+		codeLengths[s] = v
+		if v > 0 {
+			if space -= 32 >> uint(v); space <= 0 {
+				break
+			}
+		}
+	}
+	lengthCode := newBrotliTestCode(codeLengths[:])
+
+	prev, repeat, repeatLength := 8, 0, 0
+	space = 1 << 15
+	for i := 0; i < size && space > 0; {
+		c := r.symbol(lengthCode)
+		if c < 16 {
+			lengths[i] = c
+			i++
+			repeat = 0
+			if c > 0 {
+				prev = c
+				space -= 1 << 15 >> uint(c)
+			}
+			continue
+		}
+		extra, length := uint(2), prev
+		if c == 17 {
+			extra, length = 3, 0
+		}
+		if repeatLength != length {
+			repeat, repeatLength = 0, length
+		}
+		old := repeat
+		if repeat > 0 {
+			repeat = (repeat - 2) << extra
+		}
+		repeat += r.bits(extra) + 3
+		for n := repeat - old; n > 0; n-- {
+			lengths[i] = length
+			i++
+			if length > 0 {
+				space -= 1 << 15 >> uint(length)
+			}
+		}
+	}
+	if space != 0 {
+		panic("incomplete prefix code")
+	}
+	return newBrotliTestCode(lengths)
+}
+
+// brotliDecode decodes the subset of Brotli which brotliWriter writes:
+// one block type of each kind, no context modelling, and no distance
+// codes below 16 but the last distance again.
+func brotliDecode(data []byte) (out []byte, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	r := &brotliReader{data: data}
+	if r.bits(1) == 1 && r.bits(3) == 0 {
+		panic("unsupported WBITS")
+	}
+	// the insert and copy length codes each cell of commands starts at.
+	insertCells := [11]int{0, 0, 0, 0, 8, 8, 0, 16, 8, 16, 16}
+	copyCells := [11]int{0, 8, 0, 8, 0, 8, 16, 0, 16, 8, 16}
+	last := 4
+	for {
+		isLast := r.bits(1) == 1
+		if isLast && r.bits(1) == 1 {
+			return out, nil
+		}
+		nibbles := r.bits(2)
+		if nibbles == 3 {
+			if r.bits(1) != 0 || r.bits(2) != 0 {
+				panic("unsupported metadata")
+			}
+			r.align()
+			continue
+		}
+		mlen := r.bits(uint(4*(nibbles+4))) + 1
+		if !isLast && r.bits(1) == 1 {
+			r.align()
+			out = append(out, data[r.pos/8:r.pos/8+mlen]...)
+			r.pos += 8 * mlen
+			continue
+		}
+		if r.bits(13) != 0 {
+			panic("unsupported block types, distance parameters, context mode or context maps")
+		}
+		lits, cmds, dists := r.code(256), r.code(704), r.code(64)
+		end := len(out) + mlen
+		for len(out) < end {
+			cmd := r.symbol(cmds)
+			ins := insertCells[cmd>>6] + cmd>>3&7
+			cp := copyCells[cmd>>6] + cmd&7
+			insert := brotliInsertBase[ins] + r.bits(brotliInsertExtra[ins])
+			length := brotliCopyBase[cp] + r.bits(brotliCopyExtra[cp])
+			for ; insert > 0; insert-- {
+				out = append(out, byte(r.symbol(lits)))
+			}
+			if len(out) >= end {
+				break
+			}
+			dist := last
+			if cmd >= 128 {
+				switch code := r.symbol(dists); {
+				case code >= 16:
+					n := uint(1 + (code-16)>>1)
+					dist = (2+(code-16)&1)<<n - 4 + r.bits(n) + 1
+					last = dist
+				case code != 0:
+					panic("unsupported distance code")
+				}
+			}
+			if dist > len(out) {
+				panic("distance before the start of the stream")
+			}
+			for ; length > 0; length-- {
+				out = append(out, out[len(out)-dist])
+			}
+		}
+		if len(out) != end {
+			panic("meta-block overran")
+		}
+		if isLast {
+			return out, nil
+		}
+	}
+}
+
+func TestBrotli(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	r.Read(random)
+	var text strings.Builder
+	words := []string{"<p>", "</p>", "wedge", "route", "the", "a", "request", "\n", "héllo"}
+	for text.Len() < 100000 {
+		text.WriteString(words[r.Intn(len(words))])
+		text.WriteByte(' ')
+	}
+	// enough to slide the window along, with matches across blocks.
+	big := bytes.Repeat(append([]byte(text.String()[:70000]), random[:30000]...), 7)
+
+	compress := func(data []byte, quality int) []byte {
+		var buf bytes.Buffer
+		w, err := newBrotliWriter(&buf, quality)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	sizes := make(map[int]int)
+	for quality := -1; quality <= 11; quality++ {
+		for _, test := range []struct {
+			name string
+			data []byte
+		}{
+			{"empty", nil},
+			{"byte", []byte("a")},
+			{"text", []byte(text.String())},
+			{"random", random},
+			{"zeros", make([]byte, 300000)},
+		} {
+			out := compress(test.data, quality)
+			got, err := brotliDecode(out)
+			if err != nil || !bytes.Equal(got, test.data) {
+				t.Errorf("%s at %d: got %d bytes back and %v, want %d", test.name, quality, len(got), err, len(test.data))
+			}
+			if test.name == "text" {
+				sizes[quality] = len(out)
+			}
+			// incompressible input is sent as it is, not bigger.
+			if test.name == "random" && len(out) > len(random)+16 {
+				t.Errorf("random at %d: got %d bytes", quality, len(out))
+			}
+		}
+	}
+	if sizes[11] >= sizes[0] || sizes[-1] != sizes[brotliDefaultQuality] {
+		t.Errorf("got sizes %v", sizes)
+	}
+	for _, quality := range []int{0, 11} {
+		if got, err := brotliDecode(compress(big, quality)); err != nil || !bytes.Equal(got, big) {
+			t.Errorf("big at %d: got %d bytes back and %v, want %d", quality, len(got), err, len(big))
+		}
+	}
+
+	for _, quality := range []int{-2, 12} {
+		if _, err := newBrotliWriter(&bytes.Buffer{}, quality); err == nil {
+			t.Errorf("quality %d: got no error", quality)
+		}
+	}
+}
+
+func TestBrotliFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := newBrotliWriter(&buf, 5)
+	var written []byte
+	for _, part := range []string{"<html>", "<p>hello hello hello</p>", strings.Repeat("<li>item</li>", 100)} {
+		w.Write([]byte(part))
+		written = append(written, part...)
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// a flushed stream ends on a byte boundary, so it can be ended
+		// with an empty last meta-block.
+		got, err := brotliDecode(append(buf.Bytes()[:buf.Len():buf.Len()], 3))
+		if err != nil || string(got) != string(written) {
+			t.Errorf("after %q: got %q and %v", part, got, err)
+		}
+	}
+	w.Close()
+	if got, err := brotliDecode(buf.Bytes()); err != nil || string(got) != string(written) {
+		t.Errorf("got %q and %v", got, err)
+	}
+}
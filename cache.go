@@ -0,0 +1,436 @@
+package wedge
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tagKey is used for the entries in the cache_map which hold the set of
+// cache keys associated with a tag. Using a distinct type means they
+// cannot collide with the paths which are used as keys for responses.
+type tagKey string
+
+// entryTags is used for the entries in the cache_map which hold the tags
+// a cache key was stored with, so that evicting the key can also remove
+// it from every tag which refers to it.
+type entryTags string
+
+// pathKeys is used for the entries in the cache_map which hold the set of
+// response keys stored for a path, so evictPath only touches those.
+type pathKeys string
+
+// cacheOrder is the key of the cacheLRU in the cache_map.
+type cacheOrder struct{}
+
+// cacheLRU holds the keys of the cached responses, from the most recently
+// used to the least.
+type cacheLRU struct {
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// CacheSize is how many responses each AppServer keeps cached at most.
+// When there are more the least recently used are evicted, along with
+// everything cached with them.
+var CacheSize = 10000
+
+// keyPath returns the path a response key was made for by cacheKey, and
+// false for keys which aren't for responses.
+func keyPath(key string) (string, bool) {
+	i := strings.IndexByte(key, 0)
+	if i <= 0 {
+		return "", false
+	}
+	return key[:i], true
+}
+
+// serialized is a cached JSON encoding of a view's response. source is
+// kept so that a stale encoding is never served for a fresh response.
+type serialized struct {
+	source string
+	body   string
+}
+
+// TagResponse associates tags with the response which is being generated
+// for req. If the route is cached, then the cached entry can later be
+// evicted with App.InvalidateTag using any of the tags.
+//
+// Calling TagResponse on a route which is not cached does nothing.
+//
+// Example:
+//
+//	wedge.TagResponse(req, "user:42")
+func TagResponse(req *http.Request, tags ...string) {
+	current, ok := req.Context().Value(tagsKey).(*[]string)
+	if !ok {
+		return
+	}
+	*current = append(*current, tags...)
+}
+
+// withTags returns a shallow copy of req which can collect tags.
+func withTags(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), tagsKey, &[]string{}))
+}
+
+// requestTags returns the tags for the cache entry of req, being those
+// which were set on the route followed by any added with TagResponse.
+func requestTags(req *http.Request, route *Rule) []string {
+	tags := append([]string{}, route.cache_tags...)
+	current, ok := req.Context().Value(tagsKey).(*[]string)
+	if ok {
+		tags = append(tags, *current...)
+	}
+	return tags
+}
+
+// cacheKey builds the key which a response for req is stored under. This
+// is the path, query string and method of the request, the route which
+// matched it and the values of any headers the route varies on. The path
+// comes first so evictPath can find every key for it, and HEADs share
+// the responses of GETs.
+func cacheKey(req *http.Request, route *Rule) string {
+	method := req.Method
+	if method == "HEAD" {
+		method = "GET"
+	}
+	key := []string{req.URL.Path, req.URL.RawQuery, method, fmt.Sprintf("%p", route)}
+	for _, header := range route.vary {
+		key = append(key, req.Header.Get(header))
+	}
+	return strings.Join(key, "\x00")
+}
+
+// safeMethod reports whether req is a GET or HEAD, the only requests
+// whose responses are cached.
+func safeMethod(req *http.Request) bool {
+	return req.Method == "GET" || req.Method == "HEAD"
+}
+
+// takesGET reports whether the route answers GETs, which it does unless
+// it's limited to other methods.
+func (u *Rule) takesGET() bool {
+	if len(u.methods) == 0 {
+		return true
+	}
+	for _, method := range u.methods {
+		if method == "GET" || method == "HEAD" {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheInsert stores value under key+suffix, to go with the response
+// cached under key, and records it against each of the tags. Both happen
+// in a single job so an invalidation can't see the value without also
+// seeing its tags. Nothing is stored if the response isn't cached, so
+// evicting responses evicts everything stored with them.
+func (App *AppServer) cacheInsert(key, suffix string, value interface{}, tags []string) {
+	App.cache_map.Do(func(m freemap) interface{} {
+		if _, ok := m[key].(expiring); ok {
+			store(m, key+suffix, value, tags)
+		}
+		return true
+	})
+}
+
+// storeTagged does the work of cacheInsert for any safeMap.
+func storeTagged(cache *safeMap, key string, value interface{}, tags []string) {
+	_, ok := cache.Do(func(m freemap) interface{} {
+		store(m, key, value, tags)
+		return true
+	})
+	if !ok {
+		panic("Inserting into cache failure!")
+	}
+}
+
+// store stores value under key, recording it against its path and each
+// of the tags. It must only be called from within a job on the cache.
+func store(m freemap, key string, value interface{}, tags []string) {
+	untag(m, key)
+	m[key] = value
+	if path, ok := keyPath(key); ok {
+		keys, ok := m[pathKeys(path)].(map[string]bool)
+		if !ok {
+			keys = make(map[string]bool)
+			m[pathKeys(path)] = keys
+		}
+		keys[key] = true
+	}
+	for _, tag := range tags {
+		keys, ok := m[tagKey(tag)].(map[string]bool)
+		if !ok {
+			keys = make(map[string]bool)
+			m[tagKey(tag)] = keys
+		}
+		keys[key] = true
+	}
+	if len(tags) > 0 {
+		m[entryTags(key)] = tags
+	}
+}
+
+// cacheResponse stores the response resp under key until expires, or for
+// good if expires is zero. If that makes more than CacheSize responses,
+// the least recently used is evicted.
+func (App *AppServer) cacheResponse(key, resp string, expires time.Time, tags []string) {
+	App.cache_map.Do(func(m freemap) interface{} {
+		store(m, key, expiring{resp, expires}, tags)
+		lru, ok := m[cacheOrder{}].(*cacheLRU)
+		if !ok {
+			lru = &cacheLRU{order: list.New(), elems: make(map[string]*list.Element)}
+			m[cacheOrder{}] = lru
+		}
+		if e, ok := lru.elems[key]; ok {
+			lru.order.MoveToFront(e)
+		} else {
+			lru.elems[key] = lru.order.PushFront(key)
+		}
+		for lru.order.Len() > CacheSize {
+			dropResponse(m, lru.order.Back().Value.(string))
+		}
+		return true
+	})
+}
+
+// cachedResponse returns the response cached under key, unless it's
+// expired by now, and makes it the most recently used.
+func (App *AppServer) cachedResponse(key string, now time.Time) (string, bool) {
+	resp, _ := App.cache_map.Do(func(m freemap) interface{} {
+		cached, ok := m[key].(expiring)
+		if !ok {
+			return nil
+		}
+		if !cached.fresh(now) {
+			dropResponse(m, key)
+			return nil
+		}
+		if lru, ok := m[cacheOrder{}].(*cacheLRU); ok {
+			if e, ok := lru.elems[key]; ok {
+				lru.order.MoveToFront(e)
+			}
+		}
+		return cached.value
+	})
+	s, ok := resp.(string)
+	return s, ok
+}
+
+// dropResponse removes the response cached under key and everything
+// cached with it. It must only be called from within a job on the cache.
+func dropResponse(m freemap, key string) {
+	for _, k := range []string{key, key + "\x00json", key + "\x00sum", key + "\x00hdr", key + "\x00etag"} {
+		untag(m, k)
+		delete(m, k)
+	}
+	if lru, ok := m[cacheOrder{}].(*cacheLRU); ok {
+		if e, ok := lru.elems[key]; ok {
+			lru.order.Remove(e)
+			delete(lru.elems, key)
+		}
+	}
+}
+
+// untag removes key from every tag it was stored with, and from the keys
+// of its path. It must only be called from within a job on the cache.
+func untag(m freemap, key string) {
+	if path, ok := keyPath(key); ok {
+		keys, _ := m[pathKeys(path)].(map[string]bool)
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(m, pathKeys(path))
+		}
+	}
+	tags, _ := m[entryTags(key)].([]string)
+	for _, tag := range tags {
+		keys, _ := m[tagKey(tag)].(map[string]bool)
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(m, tagKey(tag))
+		}
+	}
+	delete(m, entryTags(key))
+}
+
+// InvalidateTag evicts every cached entry which was tagged with any of
+// tags, so that the next request for each of them runs the view again.
+//
+// Entries are tagged either by the view calling TagResponse, by the route
+// having been given tags with CacheTags, or by being stored with Cached.
+func (App *AppServer) InvalidateTag(tags ...string) {
+	evictTagged(App.cache_map, tags)
+	evictTagged(dataCache, tags)
+}
+
+func evictTagged(cache *safeMap, tags []string) {
+	cache.Do(func(m freemap) interface{} {
+		for _, tag := range tags {
+			keys, _ := m[tagKey(tag)].(map[string]bool)
+			for key := range keys {
+				dropResponse(m, key)
+			}
+		}
+		return true
+	})
+}
+
+// evictPath evicts the responses cached for path, whichever query,
+// method and headers they were for, along with everything cached with
+// them.
+func evictPath(cache *safeMap, path string) {
+	cache.Do(func(m freemap) interface{} {
+		keys, _ := m[pathKeys(path)].(map[string]bool)
+		for key := range keys {
+			dropResponse(m, key)
+		}
+		return true
+	})
+}
+
+// jsonBody returns the JSON encoding of resp. For cached routes the
+// encoding is itself cached, so identical responses aren't re-marshalled
+// on every request.
+func (App *AppServer) jsonBody(req *http.Request, route *Rule, resp string) string {
+	if route.cache_duration == 0 || !safeMethod(req) || App.personal(req, route) {
+		return encodeJSON(resp)
+	}
+	key := cacheKey(req, route)
+	if cached, ok := App.cache_map.Find(key + "\x00json").(serialized); ok && cached.source == resp {
+		return cached.body
+	}
+	body := encodeJSON(resp)
+	App.cacheInsert(key, "\x00json", serialized{resp, body}, requestTags(req, route))
+	return body
+}
+
+func encodeJSON(resp string) string {
+	b, _ := json.Marshal(map[string]string{
+		"message": resp,
+	})
+	return string(b) + "\n"
+}
+
+// dataCache holds the values stored by Cached. It is global, shared by
+// every AppServer in the process, as views have no way of referring to
+// the one serving them: keys should be unique across them all, and any
+// AppServer's InvalidateTag evicts matching values for every one.
+var dataCache = NewSafeMap()
+
+// CachedSweep is how often expired values are removed from the cache
+// kept by Cached, once it's been used.
+var CachedSweep = time.Minute
+
+// dataSweeper starts sweepCached the first time Cached is called.
+var dataSweeper sync.Once
+
+// sweepCached runs purgeExpired on dataCache every CachedSweep.
+func sweepCached() {
+	tick := time.NewTicker(CachedSweep)
+	defer tick.Stop()
+	for now := range tick.C {
+		purgeExpired(dataCache, now)
+	}
+}
+
+// purgeExpired removes the values in cache which expired by now, along
+// with their tags.
+func purgeExpired(cache *safeMap, now time.Time) {
+	cache.Do(func(m freemap) interface{} {
+		for key, value := range m {
+			if cached, ok := value.(expiring); ok && !now.Before(cached.expires) {
+				untag(m, key.(string))
+				delete(m, key)
+			}
+		}
+		return true
+	})
+}
+
+// expiring is a cached value along with when it goes stale.
+type expiring struct {
+	value   interface{}
+	expires time.Time
+}
+
+// fresh reports whether the value hasn't gone stale by now. Values with
+// no expiry never do.
+func (e expiring) fresh(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+// Cached is a read-through cache for arbitrary data. If a value which
+// hasn't expired is stored under key it is returned, otherwise fn is
+// called and its result is stored for ttl. Errors from fn are returned
+// to the caller and are never cached. Expired values are removed every
+// CachedSweep.
+//
+// The cache is global rather than belonging to an AppServer, so keys
+// are shared by every AppServer in the process.
+//
+// The value is tagged with key and any extra tags, so it can be evicted
+// early with App.InvalidateTag in the same way as a cached response.
+//
+// Example:
+//
+//	posts, err := wedge.Cached("posts:recent", time.Minute, func() (interface{}, error) {
+//		return db.RecentPosts()
+//	}, "posts")
+func Cached(key string, ttl time.Duration, fn func() (interface{}, error), tags ...string) (interface{}, error) {
+	dataSweeper.Do(func() { go sweepCached() })
+	if cached, ok := dataCache.Find(key).(expiring); ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	storeTagged(dataCache, key, expiring{value, time.Now().Add(ttl)}, append([]string{key}, tags...))
+	return value, nil
+}
+
+// SetCachePolicy caches responses from every route of handler type t for
+// d, unless the route was given its own duration with CacheURL or Cache.
+// Routes which only take methods such as POST are left alone, and only
+// the GETs and HEADs of the others are cached.
+// A negative duration caches forever and zero turns caching off. It
+// applies to routes added both before and after it's called.
+//
+// Example:
+//
+//	App.SetCachePolicy(wedge.JSON, 30*time.Second)
+//	App.SetCachePolicy(wedge.HTML, 0)
+func (App *AppServer) SetCachePolicy(t handlertype, d time.Duration) {
+	if App.policies == nil {
+		App.policies = make(map[handlertype]time.Duration)
+	}
+	App.policies[t] = d
+	for _, route := range App.routes {
+		App.applyCachePolicy(route)
+	}
+}
+
+// applyCachePolicy sets the cache duration of route from the policy for
+// its tags or handler type, if it hasn't one of its own. Policies only
+// apply to routes which take GETs, as no other requests are cached.
+func (App *AppServer) applyCachePolicy(route *Rule) {
+	if route.cache_set || !route.takesGET() {
+		return
+	}
+	d, ok := App.tagPolicy(route)
+	if !ok {
+		d, ok = App.policies[route.viewtype]
+	}
+	if !ok {
+		return
+	}
+	route.setCache(cacheUnits(d))
+	route.cache_set = false
+}
@@ -0,0 +1,313 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	calls := 0
+	route := CacheURL("^/user/$", "User", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls++
+		TagResponse(req, "user:42")
+		return "Alice", http.StatusOK
+	}, JSON, -1)
+
+	App := NewAppServer("0", 0)
+	App.AddURLs(route)
+	get := func() string {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/user/", nil))
+		return w.Body.String()
+	}
+
+	first, second := get(), get()
+	if calls != 1 {
+		t.Fatalf("expected the view to be called once, got %d", calls)
+	}
+	if first != second || first != "{\"message\":\"Alice\"}\n" {
+		t.Fatalf("unexpected bodies: %q %q", first, second)
+	}
+
+	App.InvalidateTag("user:42")
+	get()
+	if calls != 2 {
+		t.Fatalf("expected the view to run again after invalidation, got %d calls", calls)
+	}
+}
+
+func TestInvalidateRouteTag(t *testing.T) {
+	calls := 0
+	route := CacheURL("^/posts/", "Posts", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls++
+		return req.URL.Path, http.StatusOK
+	}, HTML, -1).CacheTags("posts")
+
+	App := NewAppServer("0", 0)
+	App.AddURLs(route)
+	for _, path := range []string{"/posts/1", "/posts/2", "/posts/1", "/posts/2"} {
+		App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if calls != 2 {
+		t.Fatalf("expected one call per path, got %d", calls)
+	}
+
+	App.InvalidateTag("posts")
+	for _, path := range []string{"/posts/1", "/posts/2"} {
+		App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if calls != 4 {
+		t.Fatalf("expected both paths to be evicted, got %d calls", calls)
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	fetch := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+	App := NewAppServer("0", 0)
+
+	a, _ := Cached("test:cached", time.Hour, fetch, "numbers")
+	b, _ := Cached("test:cached", time.Hour, fetch, "numbers")
+	if a != 1 || b != 1 {
+		t.Fatalf("expected the cached value to be reused, got %v and %v", a, b)
+	}
+
+	App.InvalidateTag("numbers")
+	if c, _ := Cached("test:cached", -time.Second, fetch); c != 2 {
+		t.Fatalf("expected a fresh value after invalidation, got %v", c)
+	}
+	if d, _ := Cached("test:cached", time.Hour, fetch); d != 3 {
+		t.Fatalf("expected the expired value to be replaced, got %v", d)
+	}
+}
+
+func TestCachedPurge(t *testing.T) {
+	fetch := func() (interface{}, error) { return "value", nil }
+	Cached("test:stale", time.Millisecond, fetch, "purged")
+	Cached("test:fresh", time.Hour, fetch, "purged")
+
+	purgeExpired(dataCache, time.Now().Add(time.Second))
+	if dataCache.Find("test:stale") != nil || dataCache.Find(entryTags("test:stale")) != nil || dataCache.Find(tagKey("test:stale")) != nil {
+		t.Error("an expired value is still cached")
+	}
+	keys, _ := dataCache.Find(tagKey("purged")).(map[string]bool)
+	if len(keys) != 1 || !keys["test:fresh"] {
+		t.Errorf("got %v tagged after the purge", keys)
+	}
+	if _, ok := dataCache.Find("test:fresh").(expiring); !ok {
+		t.Error("a fresh value was purged")
+	}
+	NewAppServer("0", 0).InvalidateTag("purged")
+}
+
+func TestCachePolicy(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "", http.StatusOK
+	}
+	api := URL("^/api/$", "API", view, JSON)
+	own := Route("^/api/own/$", view, ContentType(JSON), Cache(0))
+	page := URL("^/$", "Index", view, HTML)
+	App.AddURLs(api, own)
+	App.SetCachePolicy(JSON, 30*time.Second)
+	App.SetCachePolicy(STATIC, -1)
+	App.AddURLs(page)
+
+	if ttl := api.CacheTTL(); ttl != 30*time.Second {
+		t.Errorf("API: got %v, want 30s", ttl)
+	}
+	if ttl := own.CacheTTL(); ttl != 0 {
+		t.Errorf("route with its own duration: got %v, want 0", ttl)
+	}
+	if ttl := page.CacheTTL(); ttl != 0 {
+		t.Errorf("HTML without a policy: got %v, want 0", ttl)
+	}
+
+	App.SetCachePolicy(HTML, -1)
+	if ttl := page.CacheTTL(); ttl != -1 {
+		t.Errorf("HTML forever: got %v, want -1", ttl)
+	}
+}
+
+func TestAdaptiveCache(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	delay := time.Duration(0)
+	calls := 0
+	route := Route("^/report/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls++
+		time.Sleep(delay)
+		return "report", http.StatusOK
+	}, AdaptiveCache(20*time.Millisecond, time.Minute))
+	App.AddURLs(route)
+
+	get := func() {
+		App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/report/", nil))
+	}
+	get()
+	get()
+	if calls != 2 {
+		t.Fatalf("fast responses: view called %d times, want 2", calls)
+	}
+	delay = 30 * time.Millisecond
+	get()
+	get()
+	if calls != 3 {
+		t.Errorf("slow response: view called %d times, want 3", calls)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	calls := 0
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls++
+		return req.Method + " " + req.URL.Query().Get("q"), http.StatusOK
+	}
+	search := Route("^/search$", view, Cache(-1), Methods("GET", "POST"))
+	posts := Route("^/posts/$", view, Cache(-1), Methods("GET"))
+	create := Route("^/posts/$", view, Cache(-1), Methods("PUT"))
+
+	App := NewAppServer("0", 0)
+	App.AddURLs(search, posts, create)
+	do := func(method, target string) string {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w.Body.String()
+	}
+
+	for _, test := range []struct{ method, target, want string }{
+		{"GET", "/search?q=a", "GET a"},
+		{"GET", "/search?q=b", "GET b"},
+		{"GET", "/search?q=a", "GET a"},
+		{"POST", "/search?q=a", "POST a"},
+		{"POST", "/search?q=a", "POST a"},
+		{"GET", "/posts/", "GET "},
+		{"PUT", "/posts/", "PUT "},
+		{"PUT", "/posts/", "PUT "},
+	} {
+		if got := do(test.method, test.target); got != test.want {
+			t.Errorf("%s %s: got %q, want %q", test.method, test.target, got, test.want)
+		}
+	}
+	// the second GET of ?q=a was cached, and nothing else was.
+	if calls != 7 {
+		t.Errorf("the views were called %d times, want 7", calls)
+	}
+}
+
+func TestCachePolicyMethods(t *testing.T) {
+	posted := 0
+	list := Route("^/posts/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "list", http.StatusOK
+	}, Methods("GET"))
+	create := Route("^/posts/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		posted++
+		return "created", http.StatusOK
+	}, Methods("POST"))
+	App := NewAppServer("0", 0)
+	App.AddURLs(list, create)
+	App.SetCachePolicy(HTML, time.Minute)
+
+	if list.CacheTTL() != time.Minute || create.CacheTTL() != 0 {
+		t.Errorf("got TTLs %v and %v", list.CacheTTL(), create.CacheTTL())
+	}
+	for _, method := range []string{"GET", "POST", "GET", "POST"} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest(method, "/posts/", nil))
+		if want := map[string]string{"GET": "list", "POST": "created"}[method]; w.Body.String() != want {
+			t.Errorf("%s: got %q, want %q", method, w.Body, want)
+		}
+	}
+	if posted != 2 {
+		t.Errorf("the POST view ran %d times, want 2", posted)
+	}
+}
+
+func TestEvictPath(t *testing.T) {
+	App := NewAppServer("0", 0)
+	route := Route("^/", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "", http.StatusOK
+	})
+	keys := map[string]string{}
+	for _, target := range []string{"/a", "/a?page=2", "/ab"} {
+		key := cacheKey(httptest.NewRequest("GET", target, nil), route)
+		App.cacheResponse(key, target, time.Time{}, []string{"page"})
+		App.cacheInsert(key, "\x00etag", target, nil)
+		keys[target] = key
+	}
+	evictPath(App.cache_map, "/a")
+	for target, key := range keys {
+		_, cached := App.cache_map.Find(key).(expiring)
+		_, etag := App.cache_map.Find(key + "\x00etag").(string)
+		if want := target == "/ab"; cached != want || etag != want {
+			t.Errorf("%s: cached %v with its ETag %v, want %v", target, cached, etag, want)
+		}
+	}
+	if App.cache_map.Find(pathKeys("/a")) != nil {
+		t.Error("the keys of the evicted path are still indexed")
+	}
+	if evictTagged(App.cache_map, []string{"page"}); App.cache_map.Find(pathKeys("/ab")) != nil {
+		t.Error("the keys of a path evicted by tag are still indexed")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	App := NewAppServer("0", 0)
+	now := time.Now()
+	App.cacheResponse("a", "A", now.Add(time.Minute), nil)
+	App.cacheResponse("b", "B", now.Add(2*time.Minute), nil)
+	App.cacheResponse("c", "C", time.Time{}, nil)
+	// each response expires on its own, and ones with no expiry never do.
+	later := now.Add(90 * time.Second)
+	for _, test := range []struct {
+		key  string
+		want bool
+	}{{"a", false}, {"b", true}, {"c", true}} {
+		if _, ok := App.cachedResponse(test.key, later); ok != test.want {
+			t.Errorf("%s: got %v, want %v", test.key, ok, test.want)
+		}
+	}
+	if App.cache_map.Find("a") != nil {
+		t.Error("the expired response is still stored")
+	}
+
+	forever := Route("^/$", nil, Cache(-1))
+	minute := Route("^/$", nil, Cache(time.Minute))
+	if !forever.cacheExpiry(now).IsZero() || !minute.cacheExpiry(now).Equal(now.Add(time.Minute)) {
+		t.Errorf("got expiries %v and %v", forever.cacheExpiry(now), minute.cacheExpiry(now))
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	defer func(size int) { CacheSize = size }(CacheSize)
+	CacheSize = 2
+	calls := make(map[string]int)
+	route := CacheURL("^/page$", "Page", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls[req.URL.RawQuery]++
+		return req.URL.RawQuery, http.StatusOK
+	}, HTML, -1)
+	App := NewAppServer("0", 0)
+	App.AddURLs(route)
+	get := func(query string) {
+		App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/page?"+query, nil))
+	}
+
+	// p=3 evicts p=2, which was used less recently than p=1.
+	for _, query := range []string{"p=1", "p=2", "p=1", "p=3"} {
+		get(query)
+	}
+	evicted := cacheKey(httptest.NewRequest("GET", "/page?p=2", nil), route)
+	if App.cache_map.Find(evicted) != nil || App.cache_map.Find(evicted+"\x00hdr") != nil {
+		t.Error("the evicted response, or its headers, are still stored")
+	}
+	get("p=1")
+	get("p=2")
+	if want := map[string]int{"p=1": 1, "p=2": 2, "p=3": 1}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v, want %v", calls, want)
+	}
+}
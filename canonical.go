@@ -0,0 +1,125 @@
+package wedge
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WWWMode is what Canonical does with the "www." prefix of hostnames.
+type WWWMode int
+
+const (
+	// KeepWWW leaves hostnames as they are.
+	KeepWWW WWWMode = iota
+	// StripWWW redirects www.example.com to example.com.
+	StripWWW
+	// AddWWW redirects example.com to www.example.com.
+	AddWWW
+)
+
+// HSTSPreloadAge is the shortest max-age browsers accept for their HSTS
+// preload lists.
+const HSTSPreloadAge = 365 * 24 * time.Hour
+
+// Canonical describes the one origin a site should be reached at.
+type Canonical struct {
+	// HTTPS redirects plain HTTP requests to HTTPS.
+	HTTPS bool
+	WWW   WWWMode
+	// HSTS is the max-age of the Strict-Transport-Security header sent
+	// on HTTPS responses. It isn't sent if HSTS is zero.
+	HSTS              time.Duration
+	IncludeSubdomains bool
+	// Preload asks to be included in browsers' HSTS preload lists, which
+	// needs IncludeSubdomains and an HSTS of at least HSTSPreloadAge.
+	Preload bool
+	// Internal hostnames are left alone, such as those health checks and
+	// other services use to reach the AppServer directly.
+	Internal []string
+}
+
+// SetCanonical makes the AppServer send every request to the canonical
+// origin with a 301, before it's routed, and add HSTS to its responses.
+// The scheme and host are read from the proxy headers of proxies given to
+// TrustProxies, so it works behind a proxy terminating TLS.
+//
+// Example:
+//
+//	App.SetCanonical(wedge.Canonical{
+//		HTTPS:    true,
+//		WWW:      wedge.StripWWW,
+//		HSTS:     wedge.HSTSPreloadAge,
+//		Internal: []string{"localhost", "app.internal"},
+//	})
+func (App *AppServer) SetCanonical(c Canonical) {
+	if c.Preload && (!c.IncludeSubdomains || c.HSTS < HSTSPreloadAge) {
+		log.Println("HSTS preload needs IncludeSubdomains and an HSTS of at least", HSTSPreloadAge)
+	}
+	App.canonical = &c
+}
+
+// hstsHeader is the Strict-Transport-Security header for c.
+func (c *Canonical) hstsHeader() string {
+	header := fmt.Sprintf("max-age=%d", int64(c.HSTS/time.Second))
+	if c.IncludeSubdomains {
+		header += "; includeSubDomains"
+	}
+	if c.Preload {
+		header += "; preload"
+	}
+	return header
+}
+
+func (c *Canonical) internal(host string) bool {
+	for _, h := range c.Internal {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalize redirects req to the canonical origin, reporting whether it
+// did, and otherwise adds the HSTS header if the request is secure.
+func (App *AppServer) canonicalize(w http.ResponseWriter, req *http.Request) bool {
+	c := App.canonical
+	scheme, host := App.forwarded(req)
+	hostname, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+	if hostname == "" || c.internal(hostname) || net.ParseIP(hostname) != nil {
+		return false
+	}
+
+	target, targetHost := scheme, strings.ToLower(hostname)
+	if c.HTTPS {
+		target = "https"
+	}
+	www := strings.HasPrefix(targetHost, "www.")
+	switch {
+	case c.WWW == StripWWW && www:
+		targetHost = strings.TrimPrefix(targetHost, "www.")
+	case c.WWW == AddWWW && !www:
+		targetHost = "www." + targetHost
+	}
+	// the port only means something for the scheme it was used with.
+	if port != "" && target == scheme {
+		targetHost = net.JoinHostPort(targetHost, port)
+	}
+
+	if target != scheme || targetHost != strings.ToLower(host) {
+		to := target + "://" + targetHost + req.URL.RequestURI()
+		log.Println("Canonical redirect:", scheme+"://"+host+req.URL.RequestURI(), "=>", to)
+		http.Redirect(w, req, to, http.StatusMovedPermanently)
+		return true
+	}
+	if scheme == "https" && c.HSTS > 0 {
+		w.Header().Set("Strict-Transport-Security", c.hstsHeader())
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package wedge
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.SetVerbosity(Quiet)
+	App.SetCanonical(Canonical{
+		HTTPS:             true,
+		WWW:               StripWWW,
+		HSTS:              HSTSPreloadAge,
+		IncludeSubdomains: true,
+		Preload:           true,
+		Internal:          []string{"localhost"},
+	})
+	App.AddURLs(URL("^/x$", "X", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "x", http.StatusOK
+	}, HTML))
+
+	tests := []struct {
+		url      string
+		secure   bool
+		status   int
+		location string
+		hsts     bool
+	}{
+		{"http://example.com/x?a=1", false, 301, "https://example.com/x?a=1", false},
+		{"https://www.example.com/x", true, 301, "https://example.com/x", false},
+		{"http://www.example.com:8080/x", false, 301, "https://example.com/x", false},
+		{"https://example.com/x", true, 200, "", true},
+		{"http://localhost/x", false, 200, "", false},
+		{"http://127.0.0.1/x", false, 200, "", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.url, nil)
+		if test.secure {
+			req.TLS = &tls.ConnectionState{}
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.url, w.Code, test.status)
+		}
+		if got := w.Header().Get("Location"); got != test.location {
+			t.Errorf("%s: got Location %q, want %q", test.url, got, test.location)
+		}
+		hsts := w.Header().Get("Strict-Transport-Security")
+		if test.hsts && hsts != "max-age=31536000; includeSubDomains; preload" {
+			t.Errorf("%s: got HSTS %q", test.url, hsts)
+		}
+		if !test.hsts && hsts != "" {
+			t.Errorf("%s: got HSTS %q on a response which shouldn't have it", test.url, hsts)
+		}
+	}
+}
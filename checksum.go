@@ -0,0 +1,119 @@
+package wedge
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// checksums are the digests of a download. For cached routes they are
+// cached along with it, in the same way as JSON encodings are, so a
+// large file is only hashed when it changes. source is kept so that
+// stale digests are never sent for a fresh response, and name because
+// the headers the view set aren't when the file comes from the cache.
+type checksums struct {
+	source string
+	name   string
+	md5    [md5.Size]byte
+	sha256 [sha256.Size]byte
+}
+
+// Checksums makes a Download route send the MD5 and SHA-256 digests of
+// the file in Content-MD5 and Digest headers, so clients can check it
+// arrived intact. A HEAD request fetches them without the file.
+//
+// The digests can also be fetched on their own by adding ?checksum=sha256
+// or ?checksum=md5 to the route's URL, which answers in the format
+// sha256sum and md5sum check:
+//
+//	$ curl -O https://example.com/files/release.tar.gz
+//	$ curl https://example.com/files/release.tar.gz?checksum=sha256 | sha256sum -c
+//	release.tar.gz: OK
+//
+// Example:
+//
+//	wedge.Download(`^/files/(?P<name>[\w.-]+)$`, "File", File).Checksums()
+func (u *Rule) Checksums() *Rule {
+	u.checksums = true
+	return u
+}
+
+// Checksums is the Option form of Rule.Checksums.
+func Checksums() Option {
+	return func(u *Rule) {
+		u.Checksums()
+	}
+}
+
+// checksumsOf returns the digests of resp, taking them from the cache for
+// cached routes.
+func (App *AppServer) checksumsOf(w http.ResponseWriter, req *http.Request, route *Rule, resp string) checksums {
+	name := downloadName(req, w.Header().Get("Content-Disposition"))
+	if route.cache_duration == 0 || !safeMethod(req) || App.personal(req, route) {
+		return sum(resp, name)
+	}
+	key := cacheKey(req, route)
+	if cached, ok := App.cache_map.Find(key + "\x00sum").(checksums); ok && cached.source == resp {
+		return cached
+	}
+	sums := sum(resp, name)
+	App.cacheInsert(key, "\x00sum", sums, requestTags(req, route))
+	return sums
+}
+
+func sum(resp, name string) checksums {
+	return checksums{
+		source: resp,
+		name:   name,
+		md5:    md5.Sum([]byte(resp)),
+		sha256: sha256.Sum256([]byte(resp)),
+	}
+}
+
+// serveDownload writes a download, with its digests if the route asks
+// for them, or answers a request for its checksum.
+func (App *AppServer) serveDownload(w http.ResponseWriter, req *http.Request, resp string, route *Rule) {
+	if !route.checksums {
+		serveRanges(w, req, resp)
+		return
+	}
+	sums := App.checksumsOf(w, req, route, resp)
+	if algorithm, ok := req.URL.Query()["checksum"]; ok {
+		var digest []byte
+		switch algorithm[0] {
+		case "sha256", "":
+			digest = sums.sha256[:]
+		case "md5":
+			digest = sums.md5[:]
+		default:
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Unknown checksum "+algorithm[0], http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Del("Content-Disposition")
+		fmt.Fprintf(w, "%x  %s\n", digest, sums.name)
+		return
+	}
+	md5sum := base64.StdEncoding.EncodeToString(sums.md5[:])
+	// Content-MD5 is of the body sent, which for a Range is only part
+	// of the download, whereas Digest is of all of it.
+	if req.Header.Get("Range") == "" {
+		w.Header().Set("Content-MD5", md5sum)
+	}
+	w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sums.sha256[:])+",md5="+md5sum)
+	serveRanges(w, req, resp)
+}
+
+// downloadName is the name a download is saved under: the filename in
+// its Content-Disposition, or else the last segment of its path.
+func downloadName(req *http.Request, disposition string) string {
+	if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return path.Base(req.URL.Path)
+}
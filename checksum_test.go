@@ -0,0 +1,64 @@
+package wedge
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	file := "release contents\n"
+	route := Route("^/files/release.tar.gz$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		w.Header().Set("Content-Disposition", `attachment; filename="release-1.0.tar.gz"`)
+		return file, http.StatusOK
+	}, Name("File"), ContentType(DOWNLOAD), Cache(-1), Checksums())
+
+	App := NewAppServer("0", 0)
+	App.AddURLs(route, Download("^/plain$", "Plain", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return file, http.StatusOK
+	}))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	md5sum, sha := md5.Sum([]byte(file)), sha256.Sum256([]byte(file))
+	w := get("/files/release.tar.gz")
+	if want := base64.StdEncoding.EncodeToString(md5sum[:]); w.Header().Get("Content-MD5") != want {
+		t.Errorf("got Content-MD5 %q, want %q", w.Header().Get("Content-MD5"), want)
+	}
+	if want := "sha-256=" + base64.StdEncoding.EncodeToString(sha[:]) + ",md5=" + base64.StdEncoding.EncodeToString(md5sum[:]); w.Header().Get("Digest") != want {
+		t.Errorf("got Digest %q, want %q", w.Header().Get("Digest"), want)
+	}
+	if w.Body.String() != file {
+		t.Errorf("got body %q", w.Body)
+	}
+	if _, ok := App.cache_map.Find(cacheKey(httptest.NewRequest("GET", "/files/release.tar.gz", nil), route) + "\x00sum").(checksums); !ok {
+		t.Error("the checksums weren't cached with the file")
+	}
+
+	for query, want := range map[string]string{
+		"checksum":        fmt.Sprintf("%x  release-1.0.tar.gz\n", sha),
+		"checksum=sha256": fmt.Sprintf("%x  release-1.0.tar.gz\n", sha),
+		"checksum=md5":    fmt.Sprintf("%x  release-1.0.tar.gz\n", md5sum),
+	} {
+		w = get("/files/release.tar.gz?" + query)
+		if w.Body.String() != want || w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("%s: got %q with %v", query, w.Body, w.Header())
+		}
+	}
+	if w = get("/files/release.tar.gz?checksum=crc32"); w.Code != http.StatusBadRequest {
+		t.Errorf("got %d for an unknown checksum", w.Code)
+	}
+
+	// routes which don't ask for checksums are sent as they were.
+	w = get("/plain?checksum")
+	if w.Body.String() != file || w.Header().Get("Digest") != "" {
+		t.Errorf("got %q with %v", w.Body, w.Header())
+	}
+}
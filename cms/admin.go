@@ -0,0 +1,169 @@
+package cms
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"wedge/forms"
+)
+
+// pageForm validates the page editor. It's only used for validation and
+// conversion, the editor itself is rendered by editTemplate so that it
+// can be filled in with the page being edited.
+var pageForm = forms.NewForm(
+	forms.NewFormMetadata("page", "", "POST", true),
+	forms.TextField("path", "Path", 256, forms.WithValidator(forms.Matches(`^/[^\s?#]*$`))),
+	forms.TextField("title", "Title", 256, forms.WithValidator(forms.NotBlank())),
+	forms.RadioField("format",
+		forms.Choice("HTML", FormatHTML, false),
+		forms.Choice("Markdown", FormatMarkdown, true),
+	),
+	forms.TextField("template", "Template", 128),
+	forms.TextField("body", "Body", 1<<20),
+).Once()
+
+var adminTemplates = template.Must(template.New("list.html").Parse(
+	`<!DOCTYPE html><html><body><h1>Pages</h1>` +
+		`<p><a href="{{.Prefix}}edit/">New page</a></p>` +
+		`<table border="1"><tr><th>Path</th><th>Title</th><th>Updated</th><th></th></tr>` +
+		`{{range .Pages}}<tr><td><a href="{{.Path}}">{{.Path}}</a></td><td>{{.Title}}</td>` +
+		`<td>{{.Updated.Format "2006-01-02 15:04"}}</td>` +
+		`<td><a href="{{$.Prefix}}edit/?path={{.Path}}">Edit</a> ` +
+		`<a href="{{$.Prefix}}delete/?path={{.Path}}">Delete</a></td></tr>{{end}}` +
+		`</table></body></html>`))
+
+var editTemplate = template.Must(template.New("edit.html").Parse(
+	`<!DOCTYPE html><html><body><h1>{{if .Page.Path}}Edit {{.Page.Path}}{{else}}New page{{end}}</h1>` +
+		`{{range $field, $err := .Errors}}<p class="error">{{if $field}}{{$field}}: {{end}}{{$err}}</p>{{end}}` +
+		`<form method="POST" action="{{.Prefix}}edit/">` +
+		`<input type="hidden" name="{{.TokenField}}" value="{{.Token}}" />` +
+		`<p>Path: <input type="text" name="path" value="{{.Page.Path}}" /></p>` +
+		`<p>Title: <input type="text" name="title" value="{{.Page.Title}}" /></p>` +
+		`<p>Format: <label><input type="radio" name="format" value="html"` +
+		`{{if eq .Page.Format "html"}} checked{{end}} /> HTML</label> ` +
+		`<label><input type="radio" name="format" value="markdown"` +
+		`{{if ne .Page.Format "html"}} checked{{end}} /> Markdown</label></p>` +
+		`<p>Template: <input type="text" name="template" value="{{.Page.Template}}" /></p>` +
+		`<p><textarea name="body" rows="20" cols="80">{{.Page.Body}}</textarea></p>` +
+		`<input type="submit" value="Save"></form></body></html>`))
+
+var deleteTemplate = template.Must(template.New("delete.html").Parse(
+	`<!DOCTYPE html><html><body><h1>Delete {{.Page.Path}}?</h1>` +
+		`<form method="POST" action="{{.Prefix}}delete/?path={{.Page.Path}}">` +
+		`<input type="submit" value="Delete"></form></body></html>`))
+
+func (s *Site) adminPrefix() string {
+	if s.AdminPrefix == "" {
+		return "/admin/pages/"
+	}
+	return s.AdminPrefix
+}
+
+func execute(t *template.Template, data interface{}) (string, int) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		log.Println("Error rendering admin page:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
+
+// AdminList is a wedge view listing every page, with links to edit and
+// delete them, to be mounted at the AdminPrefix.
+func (s *Site) AdminList(w http.ResponseWriter, req *http.Request) (string, int) {
+	pages, err := s.Store.List()
+	if err != nil {
+		log.Println("Error listing pages:", err)
+		return "", http.StatusInternalServerError
+	}
+	return execute(adminTemplates, map[string]interface{}{
+		"Prefix": s.adminPrefix(),
+		"Pages":  pages,
+	})
+}
+
+// AdminEdit is a wedge view for creating and editing pages, to be
+// mounted at "edit/" below the AdminPrefix. A GET shows the editor for the
+// page given by the "path" query parameter, or an empty one for a new
+// page, and a POST saves it.
+func (s *Site) AdminEdit(w http.ResponseWriter, req *http.Request) (string, int) {
+	if req.Method != "POST" {
+		page := &Page{Format: FormatMarkdown}
+		if path := req.URL.Query().Get("path"); path != "" {
+			existing, err := s.Store.Get(path)
+			if err != nil {
+				return "", http.StatusNotFound
+			}
+			page = existing
+		}
+		return s.editor(page, nil)
+	}
+
+	form := pageForm.Validate(req)
+	values := form.Convert()
+	page := &Page{Format: FormatMarkdown}
+	if path, ok := values["path"].(string); ok {
+		page.Path = path
+	}
+	if title, ok := values["title"].(string); ok {
+		page.Title = title
+	}
+	if format, ok := values["format"].(string); ok {
+		page.Format = format
+	}
+	if tmpl, ok := values["template"].(string); ok {
+		page.Template = tmpl
+	}
+	if body, ok := values["body"].(string); ok {
+		page.Body = body
+	}
+	if !form.Valid() {
+		return s.editor(page, form.Errors())
+	}
+
+	page.Updated = time.Now()
+	if err := s.Store.Put(page); err != nil {
+		log.Println("Error saving page:", err)
+		return "", http.StatusInternalServerError
+	}
+	s.changed()
+	return forms.SeeOther(s.adminPrefix())
+}
+
+// editor renders the page editor, which carries a one-time token so that
+// refreshing after saving doesn't save again.
+func (s *Site) editor(page *Page, errors map[string]string) (string, int) {
+	return execute(editTemplate, map[string]interface{}{
+		"Prefix":     s.adminPrefix(),
+		"Page":       page,
+		"Errors":     errors,
+		"TokenField": forms.OnceTokenField,
+		"Token":      pageForm.Token(),
+	})
+}
+
+// AdminDelete is a wedge view for deleting the page given by the "path"
+// query parameter, to be mounted at "delete/" below the AdminPrefix. A GET
+// asks for confirmation and a POST deletes the page.
+func (s *Site) AdminDelete(w http.ResponseWriter, req *http.Request) (string, int) {
+	path := req.URL.Query().Get("path")
+	page, err := s.Store.Get(path)
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	if req.Method != "POST" {
+		return execute(deleteTemplate, map[string]interface{}{
+			"Prefix": s.adminPrefix(),
+			"Page":   page,
+		})
+	}
+	if err := s.Store.Delete(path); err != nil {
+		log.Println("Error deleting page:", err)
+		return "", http.StatusInternalServerError
+	}
+	s.changed()
+	return forms.SeeOther(s.adminPrefix())
+}
@@ -0,0 +1,118 @@
+package cms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"wedge/forms"
+)
+
+var tokenre = regexp.MustCompile(`name="` + forms.OnceTokenField + `" value="([^"]+)"`)
+
+func post(view func(http.ResponseWriter, *http.Request) (string, int), target string, values url.Values) (string, int) {
+	req := httptest.NewRequest("POST", target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return view(httptest.NewRecorder(), req)
+}
+
+func TestAdminEdit(t *testing.T) {
+	site := newSite()
+	var invalidated []string
+	site.Invalidate = func(tags ...string) {
+		invalidated = append(invalidated, tags...)
+	}
+
+	editor, status := site.AdminEdit(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/pages/edit/", nil))
+	m := tokenre.FindStringSubmatch(editor)
+	if status != http.StatusOK || m == nil || !strings.Contains(editor, "New page") {
+		t.Fatalf("got %d %q", status, editor)
+	}
+	page := url.Values{
+		forms.OnceTokenField: {m[1]},
+		"path":               {"/faq/"},
+		"title":              {"FAQ"},
+		"format":             {FormatMarkdown},
+		"template":           {""},
+		"body":               {"# Questions"},
+	}
+	if to, status := post(site.AdminEdit, "/admin/pages/edit/", page); status != http.StatusSeeOther || to != "/admin/pages/" {
+		t.Fatalf("saving got %d to %q", status, to)
+	}
+	saved, err := site.Store.Get("/faq/")
+	if err != nil || saved.Title != "FAQ" || saved.Body != "# Questions" || saved.Updated.IsZero() {
+		t.Fatalf("got %+v, %v", saved, err)
+	}
+	if len(invalidated) != 1 || invalidated[0] != CacheTag {
+		t.Errorf("invalidated %v", invalidated)
+	}
+
+	// the same copy of the form can't be saved twice.
+	page.Set("title", "FAQ again")
+	if body, status := post(site.AdminEdit, "/admin/pages/edit/", page); status != http.StatusOK || !strings.Contains(body, forms.AlreadySubmittedMessage) {
+		t.Errorf("resubmitting got %d %q", status, body)
+	}
+	if saved, _ := site.Store.Get("/faq/"); saved.Title != "FAQ" {
+		t.Errorf("resubmitting saved %q", saved.Title)
+	}
+
+	// invalid pages are shown again with their errors, and not saved.
+	editor, _ = site.AdminEdit(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/pages/edit/?path=/faq/", nil))
+	if !strings.Contains(editor, `value="FAQ"`) || !strings.Contains(editor, "# Questions") {
+		t.Errorf("the editor wasn't filled in: %q", editor)
+	}
+	bad := url.Values{
+		forms.OnceTokenField: {tokenre.FindStringSubmatch(editor)[1]},
+		"path":               {"no slash"},
+		"title":              {"   "},
+		"format":             {FormatHTML},
+		"template":           {""},
+		"body":               {""},
+	}
+	body, status := post(site.AdminEdit, "/admin/pages/edit/", bad)
+	if status != http.StatusOK || !strings.Contains(body, `class="error">path`) || !strings.Contains(body, `class="error">title`) {
+		t.Errorf("invalid page got %d %q", status, body)
+	}
+	if pages, _ := site.Store.List(); len(pages) != 6 {
+		t.Errorf("got %d pages after an invalid edit", len(pages))
+	}
+	if len(invalidated) != 1 {
+		t.Errorf("invalidated %v without a change", invalidated)
+	}
+
+	if _, status := site.AdminEdit(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/pages/edit/?path=/missing/", nil)); status != http.StatusNotFound {
+		t.Errorf("editing a missing page got %d", status)
+	}
+}
+
+func TestAdminListAndDelete(t *testing.T) {
+	site := newSite()
+	site.AdminPrefix = "/manage/"
+	invalidated := 0
+	site.Invalidate = func(tags ...string) { invalidated++ }
+
+	list, status := site.AdminList(httptest.NewRecorder(), httptest.NewRequest("GET", "/manage/", nil))
+	if status != http.StatusOK || !strings.Contains(list, `href="/manage/edit/?path=%2fcontact%2f"`) || strings.Count(list, "<tr>") != 6 {
+		t.Errorf("got %d %q", status, list)
+	}
+
+	confirm, status := site.AdminDelete(httptest.NewRecorder(), httptest.NewRequest("GET", "/manage/delete/?path=/contact/", nil))
+	if status != http.StatusOK || !strings.Contains(confirm, "Delete /contact/?") {
+		t.Errorf("got %d %q", status, confirm)
+	}
+	if _, err := site.Store.Get("/contact/"); err != nil || invalidated != 0 {
+		t.Error("asking to confirm deleted the page")
+	}
+	if to, status := post(site.AdminDelete, "/manage/delete/?path=/contact/", nil); status != http.StatusSeeOther || to != "/manage/" {
+		t.Errorf("deleting got %d to %q", status, to)
+	}
+	if _, err := site.Store.Get("/contact/"); err != ErrNotFound || invalidated != 1 {
+		t.Errorf("got %v after deleting, invalidated %d times", err, invalidated)
+	}
+	if _, status := post(site.AdminDelete, "/manage/delete/?path=/contact/", nil); status != http.StatusNotFound {
+		t.Errorf("deleting a missing page got %d", status)
+	}
+}
@@ -0,0 +1,225 @@
+// Package cms is an extension to wedge which serves a tree of pages kept
+// in a Store, for the mostly static parts of a site such as "about" and
+// "contact" pages, along with views for editing them.
+//
+// A Site serves the pages from a catch-all route, added after every other
+// route so that it only sees the paths nothing else wants:
+//
+//	site := &cms.Site{Store: cms.NewMemoryStore()}
+//	App.AddURLs(
+//		wedge.URL("^/admin/pages/$", "Pages", site.AdminList, wedge.HTML),
+//		wedge.URL("^/admin/pages/edit/$", "Edit page", site.AdminEdit, wedge.HTML),
+//		wedge.URL("^/admin/pages/delete/$", "Delete page", site.AdminDelete, wedge.HTML),
+//		wedge.Route("^/", site.Serve, wedge.Cache(time.Hour), wedge.CacheTags(cms.CacheTag)),
+//	)
+//	site.Invalidate = App.InvalidateTag
+//
+// The admin views do no authentication of their own, so they have to be
+// protected by whatever protects the rest of the site's administration.
+package cms
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats of a Page's Body.
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// CacheTag is the tag Site invalidates when a page is changed, which the
+// route serving the pages should be cached under.
+const CacheTag = "cms"
+
+// Page is a page of the site.
+type Page struct {
+	// Path is the URL path the page is served at, e.g. "/about/".
+	Path  string
+	Title string
+	// Body is the content of the page, in the Format it was written in.
+	Body   string
+	Format string
+	// Template is the name of the template the page is rendered with,
+	// or "" for the Site's default.
+	Template string
+	Updated  time.Time
+}
+
+// HTML returns the body of the page as HTML.
+func (p *Page) HTML() template.HTML {
+	if p.Format == FormatMarkdown {
+		return Markdown(p.Body)
+	}
+	return template.HTML(p.Body)
+}
+
+// ErrNotFound is returned by a Store which doesn't have the page asked for.
+var ErrNotFound = errors.New("cms: page not found")
+
+// Store keeps the pages of a Site. Implementations must be safe to use
+// from multiple goroutines.
+type Store interface {
+	Get(path string) (*Page, error)
+	Put(page *Page) error
+	Delete(path string) error
+	// List returns every page, ordered by path.
+	List() ([]*Page, error)
+}
+
+// MemoryStore is a Store which keeps pages in memory, for small sites
+// which load their pages at startup, and for testing.
+type MemoryStore struct {
+	sync.RWMutex
+	pages map[string]*Page
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{pages: make(map[string]*Page)}
+}
+
+func (m *MemoryStore) Get(path string) (*Page, error) {
+	m.RLock()
+	defer m.RUnlock()
+	page, ok := m.pages[path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *page
+	return &copied, nil
+}
+
+func (m *MemoryStore) Put(page *Page) error {
+	copied := *page
+	m.Lock()
+	defer m.Unlock()
+	m.pages[page.Path] = &copied
+	return nil
+}
+
+func (m *MemoryStore) Delete(path string) error {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.pages[path]; !ok {
+		return ErrNotFound
+	}
+	delete(m.pages, path)
+	return nil
+}
+
+func (m *MemoryStore) List() ([]*Page, error) {
+	m.RLock()
+	defer m.RUnlock()
+	pages := make([]*Page, 0, len(m.pages))
+	for _, page := range m.pages {
+		copied := *page
+		pages = append(pages, &copied)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Path < pages[j].Path
+	})
+	return pages, nil
+}
+
+// defaultTemplate is used for pages when the Site has no Templates.
+var defaultTemplate = template.Must(template.New("page.html").Parse(
+	`<!DOCTYPE html><html><head><title>{{.Page.Title}}</title></head><body>` +
+		`<h1>{{.Page.Title}}</h1>{{.Body}}` +
+		`{{if .Children}}<ul>{{range .Children}}<li><a href="{{.Path}}">{{.Title}}</a></li>{{end}}</ul>{{end}}` +
+		`</body></html>`))
+
+// Site serves the pages in a Store.
+type Site struct {
+	Store Store
+	// Templates holds the templates pages are rendered with. They are
+	// executed with a PageData. If it's nil a plain built-in template
+	// is used.
+	Templates *template.Template
+	// DefaultTemplate is the template used for pages which don't name
+	// one, "page.html" if it's empty.
+	DefaultTemplate string
+	// AdminPrefix is where the admin views are mounted, "/admin/pages/"
+	// if it's empty.
+	AdminPrefix string
+	// Invalidate, if set, is called with CacheTag whenever a page is
+	// changed through the admin views. It's usually App.InvalidateTag.
+	Invalidate func(tags ...string)
+}
+
+// PageData is what page templates are executed with.
+type PageData struct {
+	Page *Page
+	// Body is the page's body as HTML.
+	Body template.HTML
+	// Children are the pages directly below this one in the tree.
+	Children []*Page
+}
+
+// Serve is a wedge view which renders the page at the request's path.
+func (s *Site) Serve(w http.ResponseWriter, req *http.Request) (string, int) {
+	page, err := s.Store.Get(req.URL.Path)
+	if err == ErrNotFound {
+		return "", http.StatusNotFound
+	}
+	if err != nil {
+		log.Println("Error loading page:", err)
+		return "", http.StatusInternalServerError
+	}
+	children, err := s.Children(page.Path)
+	if err != nil {
+		log.Println("Error loading child pages:", err)
+	}
+
+	t, name := defaultTemplate, "page.html"
+	if s.Templates != nil {
+		t, name = s.Templates, s.DefaultTemplate
+		if name == "" {
+			name = "page.html"
+		}
+	}
+	if page.Template != "" {
+		name = page.Template
+	}
+	buf := new(bytes.Buffer)
+	if err := t.ExecuteTemplate(buf, name, PageData{page, page.HTML(), children}); err != nil {
+		log.Println("Error rendering page:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
+
+// Children returns the pages directly below path, so "/about/team/" is a
+// child of "/about/" but "/about/team/alice/" isn't.
+func (s *Site) Children(path string) ([]*Page, error) {
+	pages, err := s.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	var children []*Page
+	for _, page := range pages {
+		if page.Path == path || !strings.HasPrefix(page.Path, prefix) {
+			continue
+		}
+		rest := strings.TrimSuffix(page.Path[len(prefix):], "/")
+		if rest != "" && !strings.Contains(rest, "/") {
+			children = append(children, page)
+		}
+	}
+	return children, nil
+}
+
+// changed invalidates any cached pages.
+func (s *Site) changed() {
+	if s.Invalidate != nil {
+		s.Invalidate(CacheTag)
+	}
+}
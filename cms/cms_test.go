@@ -0,0 +1,118 @@
+package cms
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put(&Page{Path: "/b/", Title: "B"})
+	store.Put(&Page{Path: "/a/", Title: "A"})
+
+	page, err := store.Get("/a/")
+	if err != nil || page.Title != "A" {
+		t.Fatalf("got %+v, %v", page, err)
+	}
+	// pages are copied in and out, so callers can't change the store's.
+	page.Title = "changed"
+	if page, _ := store.Get("/a/"); page.Title != "A" {
+		t.Errorf("changing a page changed the stored one to %q", page.Title)
+	}
+
+	pages, _ := store.List()
+	if len(pages) != 2 || pages[0].Path != "/a/" || pages[1].Path != "/b/" {
+		t.Errorf("got %+v", pages)
+	}
+	if err := store.Delete("/a/"); err != nil {
+		t.Error(err)
+	}
+	if _, err := store.Get("/a/"); err != ErrNotFound {
+		t.Errorf("got %v for a deleted page", err)
+	}
+	if err := store.Delete("/a/"); err != ErrNotFound {
+		t.Errorf("got %v deleting a missing page", err)
+	}
+}
+
+func newSite() *Site {
+	store := NewMemoryStore()
+	for _, page := range []*Page{
+		{Path: "/about/", Title: "About", Body: "We make **things**.", Format: FormatMarkdown},
+		{Path: "/about/team/", Title: "Team", Body: "<p>Us</p>", Format: FormatHTML},
+		{Path: "/about/team/alice/", Title: "Alice", Format: FormatHTML},
+		{Path: "/about/history/", Title: "History", Format: FormatHTML},
+		{Path: "/contact/", Title: "Contact", Format: FormatHTML, Template: "plain.html"},
+	} {
+		store.Put(page)
+	}
+	return &Site{Store: store}
+}
+
+func serve(s *Site, path string) (string, int) {
+	return s.Serve(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+}
+
+func TestServe(t *testing.T) {
+	site := newSite()
+	body, status := serve(site, "/about/")
+	if status != http.StatusOK {
+		t.Fatalf("got %d", status)
+	}
+	for _, want := range []string{
+		"<title>About</title>",
+		"<p>We make <strong>things</strong>.</p>",
+		// direct children only, in order.
+		`<li><a href="/about/history/">History</a></li><li><a href="/about/team/">Team</a></li></ul>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%q isn't in %q", want, body)
+		}
+	}
+	if strings.Contains(body, "Alice") {
+		t.Errorf("a grandchild is listed in %q", body)
+	}
+	if _, status := serve(site, "/missing/"); status != http.StatusNotFound {
+		t.Errorf("got %d for a missing page", status)
+	}
+
+	site.Templates = template.Must(template.New("page.html").Parse(`page {{.Page.Title}}: {{.Body}}`))
+	template.Must(site.Templates.New("plain.html").Parse(`plain {{.Page.Title}}`))
+	if body, _ := serve(site, "/about/team/"); body != "page Team: <p>Us</p>" {
+		t.Errorf("got %q with the default template", body)
+	}
+	if body, _ := serve(site, "/contact/"); body != "plain Contact" {
+		t.Errorf("got %q with the page's own template", body)
+	}
+	site.DefaultTemplate = "missing.html"
+	if _, status := serve(site, "/about/team/"); status != http.StatusInternalServerError {
+		t.Errorf("got %d with a missing template", status)
+	}
+}
+
+func TestChildren(t *testing.T) {
+	site := newSite()
+	for path, want := range map[string]string{
+		"/":             "/about/ /contact/",
+		"/about/":       "/about/history/ /about/team/",
+		"/about/team/":  "/about/team/alice/",
+		"/about/team":   "/about/team/alice/",
+		"/contact/":     "",
+		"/nonexistent/": "",
+	} {
+		children, err := site.Children(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, child := range children {
+			paths = append(paths, child.Path)
+		}
+		if got := strings.Join(paths, " "); got != want {
+			t.Errorf("%s: got children %q, want %q", path, got, want)
+		}
+	}
+}
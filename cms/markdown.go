@@ -0,0 +1,126 @@
+package cms
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Markdown converts a page body written in Markdown into HTML. It can be
+// replaced with a full implementation, the built-in one handling only
+// the common parts: headings, paragraphs, lists, block quotes, fenced
+// code, emphasis, inline code and links.
+var Markdown = basicMarkdown
+
+var (
+	headingre = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletre  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	numberre  = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	codere    = regexp.MustCompile("`([^`]+)`")
+	strongre  = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emre      = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	linkre    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	schemere  = regexp.MustCompile(`^(?i)(https?:|mailto:|/|#|\.)`)
+)
+
+func basicMarkdown(source string) template.HTML {
+	var out, para strings.Builder
+	var list string // the tag of the list being written, if any
+	var fence bool
+
+	flush := func() {
+		if para.Len() > 0 {
+			out.WriteString("<p>" + inline(strings.TrimSpace(para.String())) + "</p>\n")
+			para.Reset()
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(tag string) {
+		if list != tag {
+			closeList()
+			out.WriteString("<" + tag + ">\n")
+			list = tag
+		}
+	}
+
+	for _, line := range strings.Split(strings.Replace(source, "\r\n", "\n", -1), "\n") {
+		if fence {
+			if strings.HasPrefix(line, "```") {
+				out.WriteString("</code></pre>\n")
+				fence = false
+			} else {
+				out.WriteString(html.EscapeString(line) + "\n")
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			closeList()
+			out.WriteString("<pre><code>")
+			fence = true
+		case trimmed == "":
+			flush()
+			closeList()
+		case headingre.MatchString(trimmed):
+			flush()
+			closeList()
+			m := headingre.FindStringSubmatch(trimmed)
+			level := string('0' + rune(len(m[1])))
+			out.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+		case bulletre.MatchString(trimmed):
+			flush()
+			openList("ul")
+			out.WriteString("<li>" + inline(bulletre.FindStringSubmatch(trimmed)[1]) + "</li>\n")
+		case numberre.MatchString(trimmed):
+			flush()
+			openList("ol")
+			out.WriteString("<li>" + inline(numberre.FindStringSubmatch(trimmed)[1]) + "</li>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			closeList()
+			out.WriteString("<blockquote>" + inline(strings.TrimSpace(trimmed[1:])) + "</blockquote>\n")
+		default:
+			closeList()
+			para.WriteString(trimmed + " ")
+		}
+	}
+	flush()
+	closeList()
+	if fence {
+		out.WriteString("</code></pre>\n")
+	}
+	return template.HTML(out.String())
+}
+
+// inline escapes text and converts its inline Markdown. Code spans are
+// set aside first so that nothing inside them is converted.
+func inline(text string) string {
+	var spans []string
+	text = codere.ReplaceAllStringFunc(text, func(m string) string {
+		spans = append(spans, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return "\x00"
+	})
+	text = html.EscapeString(text)
+	text = linkre.ReplaceAllStringFunc(text, func(m string) string {
+		parts := linkre.FindStringSubmatch(m)
+		// only allow links which can't run script
+		if !schemere.MatchString(html.UnescapeString(parts[2])) {
+			return parts[1]
+		}
+		return `<a href="` + parts[2] + `">` + parts[1] + `</a>`
+	})
+	text = strongre.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emre.ReplaceAllString(text, "<em>$1$2</em>")
+	for _, span := range spans {
+		text = strings.Replace(text, "\x00", span, 1)
+	}
+	return text
+}
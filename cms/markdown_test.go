@@ -0,0 +1,32 @@
+package cms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	for _, test := range []struct{ source, want string }{
+		{"# Title #", "<h1>Title</h1>\n"},
+		{"### A *small* heading", "<h3>A <em>small</em> heading</h3>\n"},
+		{"one\ntwo\n\nthree", "<p>one two</p>\n<p>three</p>\n"},
+		{"- a\n- b\n1. c", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>\n"},
+		{"> quoted", "<blockquote>quoted</blockquote>\n"},
+		{"```\n<b>**x**</b>\n```", "<pre><code>&lt;b&gt;**x**&lt;/b&gt;\n</code></pre>\n"},
+		{"use `<br>` and __bold__", "<p>use <code>&lt;br&gt;</code> and <strong>bold</strong></p>\n"},
+		{"[home](/) [mail](mailto:a@example.com)", `<p><a href="/">home</a> <a href="mailto:a@example.com">mail</a></p>` + "\n"},
+		// markup is escaped and only links which can't run script are kept.
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"[click](javascript:alert)", "<p>click</p>\n"},
+		{"[click](JavaScript:alert)", "<p>click</p>\n"},
+		{`[x](" onclick="a)`, "<p>[x](&#34; onclick=&#34;a)</p>\n"},
+		{"snake_case_name", "<p>snake_case_name</p>\n"},
+	} {
+		if got := string(Markdown(test.source)); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+	if got := string(Markdown("```\nunclosed")); !strings.HasSuffix(got, "</code></pre>\n") {
+		t.Errorf("an unclosed fence got %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package wedge
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// coalescer runs a route's view once for all identical requests which
+// arrive while it's running, in the manner of a single flight.
+type coalescer struct {
+	sync.Mutex
+	calls map[string]*call
+}
+
+// call is a view in progress and, once it's done, its response.
+type call struct {
+	done      sync.WaitGroup
+	resp      string
+	status    int
+	header    http.Header
+	shareable bool
+}
+
+// Coalesce makes identical concurrent GET and HEAD requests to the route
+// share a single call of the view, so a sudden burst of visitors to an
+// uncached page only renders it once. Requests are identical if they
+// have the same path and query, and the same values for any Vary headers.
+//
+// It's only safe for views whose response depends on nothing else, so
+// none which read cookies or sessions. Cached routes don't need it, as
+// their responses are already shared.
+func (u *Rule) Coalesce() *Rule {
+	u.coalesce = &coalescer{calls: make(map[string]*call)}
+	return u
+}
+
+// Coalesce is the Option form of Rule.Coalesce.
+func Coalesce() Option {
+	return func(u *Rule) {
+		u.Coalesce()
+	}
+}
+
+// do calls the route's view for req, or waits for the call already
+// running for an identical request. The headers the view sets are copied
+// to the responses of every request which shared the call.
+func (c *coalescer) do(w http.ResponseWriter, req *http.Request, route *Rule) (string, int) {
+	key := cacheKey(req, route)
+
+	c.Lock()
+	if running, ok := c.calls[key]; ok {
+		c.Unlock()
+		running.done.Wait()
+		// a response which set cookies belongs to the client it was
+		// made for.
+		if !running.shareable {
+			return route.handler(w, req)
+		}
+		for k, v := range running.header {
+			w.Header()[k] = append([]string(nil), v...)
+		}
+		return running.resp, running.status
+	}
+	running := &call{status: http.StatusInternalServerError}
+	running.done.Add(1)
+	c.calls[key] = running
+	c.Unlock()
+
+	// a view which panics still has to release the waiting requests,
+	// which then answer with a 500.
+	defer func() {
+		c.Lock()
+		delete(c.calls, key)
+		c.Unlock()
+		running.done.Done()
+	}()
+	// the call carries on if its client goes away, as others are
+	// waiting for it, though not past the route's deadline.
+	ctx := context.WithoutCancel(req.Context())
+	if route.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, route.deadline)
+		defer cancel()
+	}
+	cookies := cookieCount(w)
+	running.resp, running.status = route.handler(w, req.WithContext(ctx))
+	running.header = w.Header().Clone()
+	running.header.Del("Set-Cookie")
+	running.shareable = route.shareable(w, cookies)
+	return running.resp, running.status
+}
@@ -0,0 +1,62 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+	var calls int32
+	started := make(chan bool)
+	release := make(chan bool)
+	route := Route("^/slow/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		atomic.AddInt32(&calls, 1)
+		started <- true
+		<-release
+		w.Header().Set("X-Rendered", "yes")
+		return "slow page", http.StatusOK
+	}, Coalesce())
+
+	var wg sync.WaitGroup
+	get := func(w *httptest.ResponseRecorder) {
+		defer wg.Done()
+		resp, status := route.coalesce.do(w, httptest.NewRequest("GET", "/slow/", nil), route)
+		if resp != "slow page" || status != http.StatusOK {
+			t.Errorf("got (%q, %d)", resp, status)
+		}
+	}
+	recorders := make([]*httptest.ResponseRecorder, 5)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go get(recorders[i])
+		if i == 0 {
+			<-started
+		}
+	}
+	// give the others time to start waiting on the first
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("view called %d times, want 1", calls)
+	}
+	for _, w := range recorders {
+		if w.Header().Get("X-Rendered") != "yes" {
+			t.Error("headers weren't copied to a coalesced response")
+		}
+	}
+
+	// once finished, the next request calls the view again
+	go func() { <-started }()
+	wg.Add(1)
+	get(httptest.NewRecorder())
+	if calls != 2 {
+		t.Errorf("view called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,152 @@
+// Package comments is an extension to wedge which adds comment threads,
+// or a guestbook, to any page, keyed by the page's path.
+//
+// A Thread is embedded in a page with the "comments" template function,
+// and submitted to the Post view. New comments wait in a moderation
+// queue until they're approved, unless AutoApprove is set:
+//
+//	c := &comments.Comments{Store: comments.NewMemoryStore()}
+//	tmpl := template.New("page.html").Funcs(c.FuncMap())
+//	// in page.html: {{comments "/about/"}}
+//	App.AddURLs(
+//		wedge.URL("^/comments/$", "Comment", c.Post, wedge.HTML),
+//		wedge.URL("^/admin/comments/$", "Comment queue", c.Queue, wedge.HTML),
+//		wedge.URL("^/admin/comments/moderate/$", "Moderate", c.Moderate, wedge.HTML),
+//	)
+//	c.Invalidate = App.InvalidateTag
+//
+// Submissions are protected by a one-time token, a per-client rate limit,
+// a hidden field which only bots fill in and a limit on the number of
+// links. Like cms, the moderation views do no authentication of their own.
+package comments
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Comment is a comment on the page at Path.
+type Comment struct {
+	ID   string
+	Path string
+	Name string
+	// Email is never shown on the site, only in the moderation queue.
+	Email    string
+	Body     string
+	Approved bool
+	Created  time.Time
+}
+
+// ErrNotFound is returned by a Store which doesn't have the comment asked
+// for.
+var ErrNotFound = errors.New("comments: comment not found")
+
+// Store keeps comments. Implementations must be safe to use from multiple
+// goroutines.
+type Store interface {
+	// Add stores a new comment, giving it an ID.
+	Add(c *Comment) error
+	Get(id string) (*Comment, error)
+	// Thread returns the approved comments on path, oldest first.
+	Thread(path string) ([]*Comment, error)
+	// Pending returns the comments awaiting approval, oldest first.
+	Pending() ([]*Comment, error)
+	Approve(id string) error
+	Delete(id string) error
+}
+
+// Tag returns the cache tag which is invalidated when the thread on path
+// changes. Cached pages which show a thread should be tagged with it.
+func Tag(path string) string {
+	return "comments:" + path
+}
+
+// MemoryStore is a Store which keeps comments in memory, for testing and
+// for sites which don't mind losing them on restart.
+type MemoryStore struct {
+	sync.RWMutex
+	comments map[string]*Comment
+	next     int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{comments: make(map[string]*Comment)}
+}
+
+func (m *MemoryStore) Add(c *Comment) error {
+	m.Lock()
+	defer m.Unlock()
+	m.next++
+	c.ID = strconv.Itoa(m.next)
+	copied := *c
+	m.comments[c.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) Get(id string) (*Comment, error) {
+	m.RLock()
+	defer m.RUnlock()
+	c, ok := m.comments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (m *MemoryStore) Thread(path string) ([]*Comment, error) {
+	return m.filter(func(c *Comment) bool {
+		return c.Approved && c.Path == path
+	}), nil
+}
+
+func (m *MemoryStore) Pending() ([]*Comment, error) {
+	return m.filter(func(c *Comment) bool {
+		return !c.Approved
+	}), nil
+}
+
+func (m *MemoryStore) filter(keep func(*Comment) bool) []*Comment {
+	m.RLock()
+	defer m.RUnlock()
+	var found []*Comment
+	for _, c := range m.comments {
+		if keep(c) {
+			copied := *c
+			found = append(found, &copied)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].Created.Equal(found[j].Created) {
+			return found[i].Created.Before(found[j].Created)
+		}
+		a, _ := strconv.Atoi(found[i].ID)
+		b, _ := strconv.Atoi(found[j].ID)
+		return a < b
+	})
+	return found
+}
+
+func (m *MemoryStore) Approve(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.comments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	c.Approved = true
+	return nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.comments[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.comments, id)
+	return nil
+}
@@ -0,0 +1,66 @@
+package comments
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	for _, c := range []*Comment{
+		{Path: "/a/", Name: "late", Created: now.Add(time.Minute)},
+		{Path: "/a/", Name: "early", Created: now},
+		{Path: "/b/", Name: "other", Created: now},
+		{Path: "/a/", Name: "tied", Created: now},
+	} {
+		if err := store.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(comments []*Comment) (got []string) {
+		for _, c := range comments {
+			got = append(got, c.Name)
+		}
+		return got
+	}
+
+	// nothing is shown until it's approved.
+	if thread, _ := store.Thread("/a/"); len(thread) != 0 {
+		t.Errorf("unapproved comments are shown: %v", names(thread))
+	}
+	pending, _ := store.Pending()
+	if got := names(pending); len(got) != 4 || got[0] != "early" || got[1] != "other" || got[2] != "tied" || got[3] != "late" {
+		t.Errorf("pending %v", got)
+	}
+	for _, id := range []string{"1", "2", "4"} {
+		if err := store.Approve(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	thread, _ := store.Thread("/a/")
+	if got := names(thread); len(got) != 3 || got[0] != "early" || got[1] != "tied" || got[2] != "late" {
+		t.Errorf("thread %v", got)
+	}
+	if pending, _ := store.Pending(); len(pending) != 1 || pending[0].Name != "other" {
+		t.Errorf("pending %v after approving", names(pending))
+	}
+
+	// comments are copied in and out, so callers can't change the store's.
+	thread[0].Body = "changed"
+	if c, _ := store.Get(thread[0].ID); c.Body != "" {
+		t.Errorf("changing a comment changed the stored one to %q", c.Body)
+	}
+
+	if err := store.Delete("1"); err != nil {
+		t.Error(err)
+	}
+	for _, err := range []error{store.Delete("1"), store.Approve("1"), store.Approve("9")} {
+		if err != ErrNotFound {
+			t.Errorf("got %v for a missing comment", err)
+		}
+	}
+	if _, err := store.Get("1"); err != ErrNotFound {
+		t.Errorf("got %v for a deleted comment", err)
+	}
+}
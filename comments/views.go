@@ -0,0 +1,218 @@
+package comments
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"wedge/forms"
+)
+
+// MaxLinks is how many links a comment may contain before it's rejected
+// as spam.
+var MaxLinks = 2
+
+// maxLinks rejects bodies with more than MaxLinks links.
+func maxLinks(value interface{}, req *http.Request) error {
+	body, _ := value.(string)
+	if strings.Count(strings.ToLower(body), "http") > MaxLinks {
+		return errors.New("has too many links")
+	}
+	return nil
+}
+
+// commentForm validates submitted comments. "website" is a honeypot: it's
+// hidden from people, so a value in it means a bot filled in the form.
+var commentForm = forms.NewForm(
+	forms.NewFormMetadata("comment", "", "POST", true),
+	forms.TextField("path", "Path", 512, forms.WithValidator(forms.Matches(`^/([^/\s?#][^\s?#]*)?$`))),
+	forms.TextField("name", "Name", 65, forms.WithValidator(forms.NotBlank())),
+	forms.TextField("email", "Email", 255, forms.WithValidator(forms.Matches(`^([^@\s]+@[^@\s]+)?$`))),
+	forms.TextField("body", "Comment", 4097, forms.WithValidator(forms.NotBlank(), maxLinks)),
+	forms.TextField("website", "Website", 1),
+).Once().RateLimit(5, 10*time.Minute, nil)
+
+var threadTemplate = template.Must(template.New("thread.html").Parse(
+	`<section id="comments">{{range .Comments}}` +
+		`<article class="comment"><p><strong>{{.Name}}</strong> ` +
+		`<time>{{.Created.Format "2006-01-02 15:04"}}</time></p><p>{{.Body}}</p></article>{{end}}` +
+		`{{range $field, $err := .Errors}}<p class="error">{{if $field}}{{$field}}: {{end}}{{$err}}</p>{{end}}` +
+		`<form method="POST" action="{{.Action}}">` +
+		`<input type="hidden" name="{{.TokenField}}" value="{{.Token}}" />` +
+		`<input type="hidden" name="path" value="{{.Path}}" />` +
+		`<p style="display:none"><input type="text" name="website" value="" tabindex="-1" autocomplete="off" /></p>` +
+		`<p>Name: <input type="text" name="name" value="{{.Comment.Name}}" /></p>` +
+		`<p>Email (not shown): <input type="email" name="email" value="{{.Comment.Email}}" /></p>` +
+		`<p><textarea name="body" rows="6" cols="60">{{.Comment.Body}}</textarea></p>` +
+		`<input type="submit" value="Comment"></form></section>`))
+
+var pageTemplate = template.Must(template.New("page.html").Parse(
+	`<!DOCTYPE html><html><body>{{.}}</body></html>`))
+
+var queueTemplate = template.Must(template.New("queue.html").Parse(
+	`<!DOCTYPE html><html><body><h1>Comments awaiting approval</h1>` +
+		`{{range .Comments}}<article><p><strong>{{.Name}}</strong> &lt;{{.Email}}&gt; on ` +
+		`<a href="{{.Path}}">{{.Path}}</a> at {{.Created.Format "2006-01-02 15:04"}}</p><p>{{.Body}}</p>` +
+		`<form method="POST" action="{{$.Action}}"><input type="hidden" name="id" value="{{.ID}}" />` +
+		`<button name="action" value="approve">Approve</button> ` +
+		`<button name="action" value="delete">Delete</button></form></article>` +
+		`{{else}}<p>Nothing to moderate.</p>{{end}}</body></html>`))
+
+// Comments serves the comment threads kept in a Store.
+type Comments struct {
+	Store Store
+	// PostURL is where the Post view is mounted, "/comments/" if it's
+	// empty.
+	PostURL string
+	// ModerateURL is where the Moderate view is mounted,
+	// "/admin/comments/moderate/" if it's empty.
+	ModerateURL string
+	// AutoApprove publishes comments without waiting for moderation.
+	AutoApprove bool
+	// Invalidate is called with the Tag of a thread whenever it changes,
+	// usually App.InvalidateTag, so that cached pages showing it are
+	// evicted.
+	Invalidate func(tags ...string)
+}
+
+func (c *Comments) postURL() string {
+	if c.PostURL == "" {
+		return "/comments/"
+	}
+	return c.PostURL
+}
+
+func (c *Comments) moderateURL() string {
+	if c.ModerateURL == "" {
+		return "/admin/comments/moderate/"
+	}
+	return c.ModerateURL
+}
+
+func (c *Comments) changed(path string) {
+	if c.Invalidate != nil {
+		c.Invalidate(Tag(path))
+	}
+}
+
+// Thread renders the approved comments on path followed by a form for
+// adding another.
+func (c *Comments) Thread(path string) template.HTML {
+	return c.thread(&Comment{Path: path}, nil)
+}
+
+// FuncMap returns template functions for embedding threads, so that
+// {{comments .Path}} in a template renders the Thread for the path.
+func (c *Comments) FuncMap() template.FuncMap {
+	return template.FuncMap{"comments": c.Thread}
+}
+
+// thread renders the thread on draft.Path, with the form filled in from
+// draft and showing errors.
+func (c *Comments) thread(draft *Comment, errors map[string]string) template.HTML {
+	thread, err := c.Store.Thread(draft.Path)
+	if err != nil {
+		log.Println("Error loading comments:", err)
+	}
+	buf := new(bytes.Buffer)
+	err = threadTemplate.Execute(buf, map[string]interface{}{
+		"Comments":   thread,
+		"Comment":    draft,
+		"Path":       draft.Path,
+		"Errors":     errors,
+		"Action":     c.postURL(),
+		"TokenField": forms.OnceTokenField,
+		"Token":      commentForm.Token(),
+	})
+	if err != nil {
+		log.Println("Error rendering comments:", err)
+		return ""
+	}
+	return template.HTML(buf.String())
+}
+
+// Post is a wedge view which adds a submitted comment, to be mounted at
+// the PostURL. A valid comment sends the client back to the page it was
+// left on, and an invalid one shows the thread again with the errors.
+func (c *Comments) Post(w http.ResponseWriter, req *http.Request) (string, int) {
+	if req.Method != "POST" {
+		return "", http.StatusNotFound
+	}
+	form := commentForm.Validate(req)
+	values := form.Convert()
+	draft := &Comment{Created: time.Now(), Approved: c.AutoApprove}
+	draft.Path, _ = values["path"].(string)
+	draft.Name, _ = values["name"].(string)
+	draft.Email, _ = values["email"].(string)
+	draft.Body, _ = values["body"].(string)
+	if !form.Valid() {
+		if draft.Path == "" || strings.HasPrefix(draft.Path, "//") {
+			draft.Path = "/"
+		}
+		return c.render(pageTemplate, c.thread(draft, form.Errors()))
+	}
+
+	if err := c.Store.Add(draft); err != nil {
+		log.Println("Error saving comment:", err)
+		return "", http.StatusInternalServerError
+	}
+	if draft.Approved {
+		c.changed(draft.Path)
+	}
+	return forms.SeeOther(draft.Path + "#comments")
+}
+
+// Queue is a wedge view listing the comments awaiting approval, with
+// buttons to approve or delete each of them.
+func (c *Comments) Queue(w http.ResponseWriter, req *http.Request) (string, int) {
+	pending, err := c.Store.Pending()
+	if err != nil {
+		log.Println("Error listing comments:", err)
+		return "", http.StatusInternalServerError
+	}
+	return c.render(queueTemplate, map[string]interface{}{
+		"Comments": pending,
+		"Action":   c.moderateURL(),
+	})
+}
+
+// Moderate is a wedge view which approves or deletes the comment given by
+// the "id" and "action" form values, to be mounted at the ModerateURL.
+// It sends the client back to the path above ModerateURL afterwards, where
+// Queue is expected to be mounted.
+func (c *Comments) Moderate(w http.ResponseWriter, req *http.Request) (string, int) {
+	if req.Method != "POST" {
+		return "", http.StatusNotFound
+	}
+	comment, err := c.Store.Get(req.PostFormValue("id"))
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	switch req.PostFormValue("action") {
+	case "approve":
+		err = c.Store.Approve(comment.ID)
+	case "delete":
+		err = c.Store.Delete(comment.ID)
+	default:
+		return "", http.StatusNotFound
+	}
+	if err != nil {
+		log.Println("Error moderating comment:", err)
+		return "", http.StatusInternalServerError
+	}
+	c.changed(comment.Path)
+	return forms.SeeOther(strings.TrimSuffix(c.moderateURL(), "moderate/"))
+}
+
+func (c *Comments) render(t *template.Template, data interface{}) (string, int) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		log.Println("Error rendering comments page:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
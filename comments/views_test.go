@@ -0,0 +1,195 @@
+package comments
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"wedge/forms"
+)
+
+var tokenre = regexp.MustCompile(`name="` + forms.OnceTokenField + `" value="([^"]+)"`)
+
+// clients numbers the clients submitting comments, so that tests don't
+// run into commentForm's rate limit unless they mean to.
+var clients int32
+
+func post(view func(http.ResponseWriter, *http.Request) (string, int), target, client string, values url.Values) (string, int) {
+	req := httptest.NewRequest("POST", target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = client + ":1234"
+	return view(httptest.NewRecorder(), req)
+}
+
+func newClient() string {
+	return fmt.Sprintf("192.0.2.%d", atomic.AddInt32(&clients, 1))
+}
+
+// comment fills in the form in the thread on path, as a browser would.
+func comment(c *Comments, path, name, body string) url.Values {
+	m := tokenre.FindStringSubmatch(string(c.Thread(path)))
+	return url.Values{
+		forms.OnceTokenField: {m[1]},
+		"path":               {path},
+		"website":            {""},
+		"name":               {name},
+		"email":              {name + "@example.com"},
+		"body":               {body},
+	}
+}
+
+func TestPost(t *testing.T) {
+	var invalidated []string
+	c := &Comments{Store: NewMemoryStore(), Invalidate: func(tags ...string) {
+		invalidated = append(invalidated, tags...)
+	}}
+	to, status := post(c.Post, "/comments/", newClient(), comment(c, "/about/", "Ann", "Hello <b>there</b>"))
+	if status != http.StatusSeeOther || to != "/about/#comments" {
+		t.Fatalf("got %d to %q", status, to)
+	}
+	// it waits to be approved.
+	if thread := string(c.Thread("/about/")); strings.Contains(thread, "Hello") {
+		t.Errorf("an unapproved comment is shown: %q", thread)
+	}
+	pending, _ := c.Store.Pending()
+	if len(pending) != 1 || pending[0].Name != "Ann" || pending[0].Email != "Ann@example.com" || pending[0].Created.IsZero() {
+		t.Fatalf("pending %+v", pending)
+	}
+	if len(invalidated) != 0 {
+		t.Errorf("invalidated %v for a comment awaiting approval", invalidated)
+	}
+
+	c.AutoApprove = true
+	if _, status := post(c.Post, "/comments/", newClient(), comment(c, "/about/", "Bob", "Hi")); status != http.StatusSeeOther {
+		t.Fatalf("got %d", status)
+	}
+	thread := string(c.Thread("/about/"))
+	if !strings.Contains(thread, "<strong>Bob</strong>") || strings.Contains(thread, "Bob@example.com") {
+		t.Errorf("got thread %q", thread)
+	}
+	if len(invalidated) != 1 || invalidated[0] != Tag("/about/") {
+		t.Errorf("invalidated %v", invalidated)
+	}
+	if _, status := c.Post(httptest.NewRecorder(), httptest.NewRequest("GET", "/comments/", nil)); status != http.StatusNotFound {
+		t.Errorf("a GET got %d", status)
+	}
+}
+
+func TestPostRejected(t *testing.T) {
+	c := &Comments{Store: NewMemoryStore(), AutoApprove: true}
+	for name, change := range map[string]func(url.Values){
+		"honeypot":   func(v url.Values) { v.Set("website", "http://spam.example.com/") },
+		"links":      func(v url.Values) { v.Set("body", "http://a http://b http://c") },
+		"blank":      func(v url.Values) { v.Set("body", "  ") },
+		"no name":    func(v url.Values) { v.Set("name", "") },
+		"email":      func(v url.Values) { v.Set("email", "nobody") },
+		"no token":   func(v url.Values) { v.Del(forms.OnceTokenField) },
+		"offsite":    func(v url.Values) { v.Set("path", "//evil.example.com/") },
+		"relative":   func(v url.Values) { v.Set("path", "about/") },
+		"whitespace": func(v url.Values) { v.Set("path", "/a b/") },
+	} {
+		values := comment(c, "/about/", "Ann", "Hello")
+		change(values)
+		body, status := post(c.Post, "/comments/", newClient(), values)
+		if status != http.StatusOK || !strings.Contains(body, `class="error"`) {
+			t.Errorf("%s: got %d %q", name, status, body)
+		}
+		// the form is shown again with a fresh token, and never sends the
+		// client off the site.
+		if !tokenre.MatchString(body) || strings.Contains(body, "evil.example.com") {
+			t.Errorf("%s: got %q", name, body)
+		}
+	}
+	if pending, _ := c.Store.Pending(); len(pending) != 0 {
+		t.Errorf("saved %d rejected comments", len(pending))
+	}
+	if thread, _ := c.Store.Thread("/about/"); len(thread) != 0 {
+		t.Errorf("saved %d rejected comments", len(thread))
+	}
+
+	// a token can only be used once.
+	values := comment(c, "/about/", "Ann", "Hello")
+	if _, status := post(c.Post, "/comments/", newClient(), values); status != http.StatusSeeOther {
+		t.Fatalf("got %d", status)
+	}
+	if body, _ := post(c.Post, "/comments/", newClient(), values); !strings.Contains(body, forms.AlreadySubmittedMessage) {
+		t.Errorf("resubmitting got %q", body)
+	}
+
+	client := newClient()
+	for i := 0; i < 5; i++ {
+		if _, status := post(c.Post, "/comments/", client, comment(c, "/about/", "Ann", "Again")); status != http.StatusSeeOther {
+			t.Fatalf("comment %d got %d", i, status)
+		}
+	}
+	if body, _ := post(c.Post, "/comments/", client, comment(c, "/about/", "Ann", "Again")); !strings.Contains(body, forms.TooManySubmissionsMessage) {
+		t.Errorf("the sixth comment got %q", body)
+	}
+	if thread, _ := c.Store.Thread("/about/"); len(thread) != 6 {
+		t.Errorf("got %d comments", len(thread))
+	}
+}
+
+func TestModerate(t *testing.T) {
+	var invalidated []string
+	c := &Comments{Store: NewMemoryStore(), Invalidate: func(tags ...string) {
+		invalidated = append(invalidated, tags...)
+	}}
+	for _, body := range []string{"First", "Second <script>"} {
+		if _, status := post(c.Post, "/comments/", newClient(), comment(c, "/about/", "Ann", body)); status != http.StatusSeeOther {
+			t.Fatalf("got %d", status)
+		}
+	}
+
+	queue, status := c.Queue(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/comments/", nil))
+	if status != http.StatusOK || !strings.Contains(queue, "Ann@example.com") || !strings.Contains(queue, "Second &lt;script&gt;") || !strings.Contains(queue, `action="/admin/comments/moderate/"`) {
+		t.Errorf("got %d %q", status, queue)
+	}
+
+	moderate := func(id, action string) (string, int) {
+		return post(c.Moderate, "/admin/comments/moderate/", newClient(), url.Values{"id": {id}, "action": {action}})
+	}
+	if to, status := moderate("1", "approve"); status != http.StatusSeeOther || to != "/admin/comments/" {
+		t.Errorf("approving got %d to %q", status, to)
+	}
+	if thread := string(c.Thread("/about/")); !strings.Contains(thread, "First") || strings.Contains(thread, "Second") {
+		t.Errorf("got thread %q", thread)
+	}
+	if _, status := moderate("2", "delete"); status != http.StatusSeeOther {
+		t.Errorf("deleting got %d", status)
+	}
+	if _, err := c.Store.Get("2"); err != ErrNotFound {
+		t.Errorf("got %v for a deleted comment", err)
+	}
+	if len(invalidated) != 2 || invalidated[0] != Tag("/about/") || invalidated[1] != Tag("/about/") {
+		t.Errorf("invalidated %v", invalidated)
+	}
+	if queue, _ := c.Queue(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/comments/", nil)); !strings.Contains(queue, "Nothing to moderate.") {
+		t.Errorf("got %q", queue)
+	}
+
+	for _, test := range []struct{ id, action string }{{"2", "approve"}, {"9", "delete"}, {"1", "publish"}, {"", ""}} {
+		if _, status := moderate(test.id, test.action); status != http.StatusNotFound {
+			t.Errorf("%s %q got %d", test.action, test.id, status)
+		}
+	}
+	if _, status := c.Moderate(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/comments/moderate/?id=1&action=delete", nil)); status != http.StatusNotFound {
+		t.Errorf("a GET got %d", status)
+	}
+	if _, err := c.Store.Get("1"); err != nil {
+		t.Error("a GET deleted the comment")
+	}
+	if len(invalidated) != 2 {
+		t.Errorf("invalidated %v without a change", invalidated)
+	}
+
+	c.ModerateURL = "/manage/comments/moderate/"
+	if to, _ := moderate("1", "delete"); to != "/manage/comments/" {
+		t.Errorf("got sent to %q", to)
+	}
+}
@@ -0,0 +1,322 @@
+package wedge
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder returns a writer compressing to w at quality, whose range
+// depends on the encoding.
+type Encoder func(w io.Writer, quality int) (io.WriteCloser, error)
+
+// Encoders are the content codings Compress can use, by their names in
+// Accept-Encoding. Brotli and gzip are built in. Brotli's qualities go
+// from 0 to 11: its 4 compresses text around 5% better than gzip's 6, but
+// takes two or three times as long, while 10 and 11 are only worth it
+// for responses which are cached. Other encoders can be added, along with
+// their place in EncodingPreference and their DefaultQualities.
+//
+// Example:
+//
+//	// with github.com/klauspost/compress/zstd
+//	wedge.Encoders["zstd"] = func(w io.Writer, quality int) (io.WriteCloser, error) {
+//		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevel(quality)))
+//	}
+//	wedge.EncodingPreference = []string{"zstd", "br", "gzip"}
+//	wedge.DefaultQualities["zstd"] = 2
+var Encoders = map[string]Encoder{
+	"br": func(w io.Writer, quality int) (io.WriteCloser, error) {
+		return newBrotliWriter(w, quality)
+	},
+	"gzip": func(w io.Writer, quality int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, quality)
+	},
+}
+
+// EncodingPreference is the order encodings are chosen in when a client
+// accepts several equally. Encoders it doesn't list come after those it
+// does.
+var EncodingPreference = []string{"br", "gzip"}
+
+// Qualities are the qualities, or levels, a content type is compressed
+// at, by encoding.
+type Qualities map[string]int
+
+// DefaultQualities are used for the encodings a content type doesn't
+// give a quality for.
+var DefaultQualities = Qualities{"br": brotliDefaultQuality, "gzip": 6}
+
+// CompressibleTypes are the content types Compress compresses if it
+// isn't given any. Types ending in /* match every subtype.
+var CompressibleTypes = map[string]Qualities{
+	"text/*":                    nil,
+	"application/javascript":    nil,
+	"application/json":          nil,
+	"application/xml":           nil,
+	"application/rss+xml":       nil,
+	"application/atom+xml":      nil,
+	"application/manifest+json": nil,
+	"image/svg+xml":             nil,
+}
+
+// Compression configures Compress.
+type Compression struct {
+	// MinSize is the smallest response compressed, as small ones can
+	// grow. It's 1024 bytes if it's zero.
+	MinSize int
+	// Types are the content types compressed, with the qualities they're
+	// compressed at, which are taken from DefaultQualities if they're
+	// nil. It's CompressibleTypes if it's nil.
+	Types map[string]Qualities
+}
+
+// Compress is http.Handler middleware, for UseHandler, which compresses
+// responses with the best encoding the client accepts. Responses are only
+// compressed if they're of one of c.Types and at least c.MinSize bytes,
+// or are flushed before they get that far, and if they aren't already
+// encoded or partial. Every response which could be compressed is sent
+// with "Vary: Accept-Encoding", so caches keep the versions apart.
+//
+// Example:
+//
+//	App.UseHandler(wedge.Compress(wedge.Compression{
+//		MinSize: 512,
+//		Types: map[string]wedge.Qualities{
+//			"text/html":        {"br": 4, "gzip": 5},
+//			"text/css":         {"br": 11, "gzip": 9},
+//			"application/json": nil,
+//		},
+//	}))
+func Compress(c Compression) func(http.Handler) http.Handler {
+	if c.MinSize <= 0 {
+		c.MinSize = 1024
+	}
+	if c.Types == nil {
+		c.Types = CompressibleTypes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			cw := &compressWriter{
+				ResponseWriter: w,
+				c:              &c,
+				encoding:       negotiateEncoding(req.Header.Get("Accept-Encoding")),
+				status:         http.StatusOK,
+			}
+			defer cw.close()
+			next.ServeHTTP(WrapWriter(w, cw), req)
+		})
+	}
+}
+
+// negotiateEncoding picks the encoding to use from an Accept-Encoding
+// header, or "" if the client accepts none of Encoders.
+func negotiateEncoding(accept string) string {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		q[name] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, name := range preferredEncodings() {
+		weight, ok := q[name]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = name, weight
+		}
+	}
+	return best
+}
+
+// preferredEncodings lists Encoders in EncodingPreference order, followed
+// by any others by name.
+func preferredEncodings() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range EncodingPreference {
+		if _, ok := Encoders[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	var rest []string
+	for name := range Encoders {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// qualities returns the qualities for the content type ctype, and whether
+// it's compressed at all.
+func (c *Compression) qualities(ctype string) (Qualities, bool) {
+	media, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return nil, false
+	}
+	if q, ok := c.Types[media]; ok {
+		return q, true
+	}
+	if i := strings.IndexByte(media, '/'); i > 0 {
+		if q, ok := c.Types[media[:i]+"/*"]; ok {
+			return q, true
+		}
+	}
+	return nil, false
+}
+
+// compressWriter holds back the start of a response until it knows
+// whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *Compression
+	encoding string
+	status   int
+	header   bool
+	decided  bool
+	buf      []byte
+	enc      io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.header {
+		return
+	}
+	cw.header = true
+	cw.status = status
+	// informational and bodiless responses go straight out.
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	cw.header = true
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.c.MinSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what's been written so far, deciding whether to compress
+// the response if it's in a compressible type, however small it is.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide chooses whether to compress the response, big being whether
+// it's long enough to, and sends the headers and what's been held back.
+func (cw *compressWriter) decide(big bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	qualities, compressible := cw.c.qualities(h.Get("Content-Type"))
+	if compressible && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		cw.status != http.StatusPartialContent && big {
+		addVary(h, "Accept-Encoding")
+		if cw.encoding != "" {
+			quality, ok := qualities[cw.encoding]
+			if !ok {
+				quality, ok = DefaultQualities[cw.encoding]
+			}
+			if !ok {
+				quality = -1
+			}
+			enc, err := Encoders[cw.encoding](cw.ResponseWriter, quality)
+			if err == nil {
+				h.Set("Content-Encoding", cw.encoding)
+				h.Del("Content-Length")
+				// digests are of the body as it's sent, which
+				// this is about to change.
+				h.Del("Content-MD5")
+				h.Del("Digest")
+				// the encoded body isn't byte for byte the one
+				// its ETag was strong for.
+				if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+					h.Set("ETag", "W/"+etag)
+				}
+				cw.enc = enc
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close finishes the response once the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if !cw.header {
+			// nothing was written, so leave the response to net/http.
+			return
+		}
+		cw.decide(len(cw.buf) >= cw.c.MinSize)
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
+
+// addVary adds name to the Vary header unless it's already there.
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v == "*" || strings.EqualFold(v, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
@@ -0,0 +1,103 @@
+package wedge
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	Encoders["test"] = func(w io.Writer, quality int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, quality)
+	}
+	defer delete(Encoders, "test")
+	for accept, want := range map[string]string{
+		"":                     "",
+		"identity":             "",
+		"gzip, deflate":        "gzip",
+		"test, gzip":           "gzip",
+		"test;q=1, gzip;q=0.5": "test",
+		"*":                    "br",
+		"*;q=0.1, br;q=0":      "gzip",
+		"gzip, deflate, br":    "br",
+		"GZIP;q=0.9, br;q=0.8": "gzip",
+	} {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("%q: got %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	big := strings.Repeat("<p>hello</p>", 200)
+	App := NewAppServer("0", 30)
+	App.AddURLs(
+		URL("^/big/$", "Big", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return big, http.StatusOK
+		}, HTML),
+		URL("^/small/$", "Small", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "<p>hi</p>", http.StatusOK
+		}, HTML),
+		URL("^/image/$", "Image", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			w.Header().Set("Content-Type", "image/png")
+			return big, http.StatusOK
+		}, IMAGE),
+	)
+	App.UseHandler(Compress(Compression{
+		MinSize: 100,
+		Types:   map[string]Qualities{"text/html": {"gzip": gzip.BestCompression}},
+	}))
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		App.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/big/", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("got headers %v", w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(gz); string(body) != big {
+		t.Errorf("got %d bytes back, want %d", len(body), len(big))
+	}
+
+	// Brotli is preferred.
+	w = get("/big/", "gzip, br")
+	if w.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("got headers %v", w.Header())
+	}
+	if body, err := brotliDecode(w.Body.Bytes()); err != nil || string(body) != big {
+		t.Errorf("got %d bytes back and %v, want %d", len(body), err, len(big))
+	}
+
+	// clients which don't accept gzip get the same response uncompressed,
+	// which still varies.
+	w = get("/big/", "")
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "Accept-Encoding" || w.Body.String() != big {
+		t.Errorf("got %v and %d bytes", w.Header(), w.Body.Len())
+	}
+
+	for _, path := range []string{"/small/", "/image/"} {
+		w = get(path, "gzip")
+		if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" || w.Code != http.StatusOK {
+			t.Errorf("%s: got %d %v", path, w.Code, w.Header())
+		}
+	}
+
+	w = get("/missing/", "gzip")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("got %d %v", w.Code, w.Header())
+	}
+}
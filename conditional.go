@@ -0,0 +1,94 @@
+package wedge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// validator is what a cached response is validated against by conditional
+// requests. Like checksums it's cached along with the response, so a
+// response is only hashed when it changes, and so that its Last-Modified
+// time is when it last did.
+type validator struct {
+	source   string
+	etag     string
+	modified time.Time
+}
+
+// validatorOf returns the validator of resp, taking it from the cache if
+// it's still the response it was made for.
+func (App *AppServer) validatorOf(req *http.Request, route *Rule, resp string) validator {
+	key := cacheKey(req, route)
+	if cached, ok := App.cache_map.Find(key + "\x00etag").(validator); ok && cached.source == resp {
+		return cached
+	}
+	sum := sha256.Sum256([]byte(resp))
+	v := validator{
+		source:   resp,
+		etag:     `"` + hex.EncodeToString(sum[:12]) + `"`,
+		modified: time.Now().UTC().Truncate(time.Second),
+	}
+	App.cacheInsert(key, "\x00etag", v, requestTags(req, route))
+	return v
+}
+
+// conditional sets the ETag and Last-Modified headers of a response from
+// a cached route, unless its view set its own, and reports whether req
+// already has it, in which case it has been answered with a 304.
+func (App *AppServer) conditional(w http.ResponseWriter, req *http.Request, route *Rule, resp string) bool {
+	if !safeMethod(req) ||
+		route.cache_duration == 0 && route.adaptive == nil || App.personal(req, route) {
+		return false
+	}
+	h := w.Header()
+	var modified time.Time
+	if h.Get("ETag") == "" {
+		v := App.validatorOf(req, route, resp)
+		h.Set("ETag", v.etag)
+		modified = v.modified
+	}
+	if h.Get("Last-Modified") == "" && !modified.IsZero() {
+		h.Set("Last-Modified", modified.Format(http.TimeFormat))
+	} else if t, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		modified = t
+	}
+	if !notModified(req, h.Get("ETag"), modified) {
+		return false
+	}
+	// as for http.ServeContent, the headers of the body are dropped.
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Disposition")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified reports whether req's If-None-Match matches etag or, if it
+// hasn't one, whether its If-Modified-Since is no older than modified.
+func notModified(req *http.Request, etag string, modified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			// GETs are compared weakly, see RFC 7232 section 3.2.
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.IsZero() && !modified.Truncate(time.Second).After(since)
+}
+
+// staticETag is the ETag of a static file, made from when it was
+// modified and its size as nginx's are, so files aren't hashed.
+func staticETag(modified time.Time, size int) string {
+	return fmt.Sprintf(`"%x-%x"`, modified.Unix(), size)
+}
@@ -0,0 +1,149 @@
+package wedge
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalGET(t *testing.T) {
+	App := NewAppServer("0", 30)
+	page := "<p>page</p>"
+	cached := CacheURL("^/cached/$", "Cached", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return page, http.StatusOK
+	}, HTML, -1).CacheTags("page")
+	App.AddURLs(
+		cached,
+		CacheURL("^/tagged/$", "Tagged", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			w.Header().Set("ETag", `"v1"`)
+			return "tagged", http.StatusOK
+		}, HTML, time.Hour),
+		URL("^/uncached/$", "Uncached", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "uncached", http.StatusOK
+		}, HTML),
+	)
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/cached/")
+	etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) || modified == "" {
+		t.Fatalf("got %d with ETag %q and Last-Modified %q", w.Code, etag, modified)
+	}
+	// the cached response keeps its validators.
+	if w = get("/cached/"); w.Header().Get("ETag") != etag || w.Header().Get("Last-Modified") != modified {
+		t.Errorf("got ETag %q and Last-Modified %q from the cache", w.Header().Get("ETag"), w.Header().Get("Last-Modified"))
+	}
+	for _, header := range [][]string{
+		{"If-None-Match", etag},
+		{"If-None-Match", `"other", W/` + etag},
+		{"If-None-Match", "*"},
+		{"If-Modified-Since", modified},
+	} {
+		w = get("/cached/", header...)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+			t.Errorf("%v: got %d %q %v", header, w.Code, w.Body, w.Header())
+		}
+	}
+	// If-None-Match takes precedence over If-Modified-Since.
+	w = get("/cached/", "If-None-Match", `"other"`, "If-Modified-Since", modified)
+	if w.Code != http.StatusOK || w.Body.String() != page {
+		t.Errorf("got %d %q for another ETag", w.Code, w.Body)
+	}
+
+	w = get("/tagged/", "If-None-Match", `"v1"`)
+	if w.Code != http.StatusNotModified || w.Header().Get("ETag") != `"v1"` {
+		t.Errorf("got %d with ETag %q for the view's own", w.Code, w.Header().Get("ETag"))
+	}
+	if w = get("/uncached/"); w.Header().Get("ETag") != "" || w.Header().Get("Last-Modified") != "" {
+		t.Errorf("uncached response got %v", w.Header())
+	}
+
+	// a changed response gets a new ETag once it's cached again.
+	page = "<p>changed</p>"
+	App.InvalidateTag("page")
+	if w = get("/cached/", "If-None-Match", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("changed response got %d with ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestConditionalCompressed(t *testing.T) {
+	big := strings.Repeat("<p>hello</p>", 200)
+	App := NewAppServer("0", 30)
+	App.AddURLs(CacheURL("^/$", "Index", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return big, http.StatusOK
+	}, HTML, time.Hour))
+	App.UseHandler(Compress(Compression{MinSize: 100}))
+	get := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		App.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := get()
+	etag := w.Header().Get("ETag")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("got %v", w.Header())
+	}
+	if _, err := gzip.NewReader(w.Body); err != nil {
+		t.Fatal(err)
+	}
+	if w = get("If-None-Match", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("got %d and %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestStaticETag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.js")
+	if err := ioutil.WriteFile(path, []byte("app()"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	os.Chtimes(path, modified, modified)
+
+	App := NewAppServer("0", 30)
+	App.AddURLs(StaticFiles("/static/", dir))
+	get := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/static/app.js", nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get()
+	if etag := w.Header().Get("ETag"); etag != `"5eac0f40-5"` {
+		t.Fatalf("got ETag %q", etag)
+	}
+	if w = get("If-None-Match", `"5eac0f40-5"`); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("got %d %q", w.Code, w.Body)
+	}
+	if w = get("If-None-Match", `"5eac0f40-4"`); w.Code != http.StatusOK || w.Body.String() != "app()" {
+		t.Errorf("got %d %q for another ETag", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,236 @@
+package wedge
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PreflightCacheSize is how many preflight decisions each route keeps.
+// When it's full the oldest decisions are dropped.
+var PreflightCacheSize = 1024
+
+// DefaultPreflightTTL is how long preflight decisions are kept for routes
+// whose CORSPolicy has no MaxAge.
+var DefaultPreflightTTL = time.Minute
+
+// CORSPolicy is which cross-origin requests a route allows.
+type CORSPolicy struct {
+	// Origins are the origins allowed to make requests, such as
+	// "https://example.com", or "*" for any.
+	Origins []string
+	// AllowOrigin, if it's set, is asked about origins which aren't in
+	// Origins.
+	AllowOrigin func(origin string) bool
+	// Methods allowed in addition to the simple ones, GET, HEAD and POST.
+	Methods []string
+	// Headers the client may send in addition to the simple ones.
+	Headers []string
+	// Expose are the response headers scripts may read.
+	Expose []string
+	// Credentials lets requests carry cookies and HTTP authentication.
+	// Browsers refuse credentials with an Allow-Origin of "*", so it can't
+	// be combined with "*" in Origins.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight response, and how
+	// long the route keeps its decision for the same preflight.
+	MaxAge time.Duration
+}
+
+// cors is a CORSPolicy in use by a route, with its preflight decisions.
+type cors struct {
+	CORSPolicy
+	sync.Mutex
+	decisions map[string]preflight
+	order     []string
+
+	preflights uint64
+	hits       uint64
+	rejected   uint64
+}
+
+// preflight is the outcome of a preflight request.
+type preflight struct {
+	header  http.Header
+	expires time.Time
+}
+
+// CORS answers cross-origin requests to the route according to policy,
+// including the preflight OPTIONS requests browsers send first for those
+// which aren't simple. Preflights are answered before the route's Methods
+// are checked, and never reach the view.
+//
+// Example:
+//
+//	wedge.Route("^/api/", API, wedge.CORS(wedge.CORSPolicy{
+//		Origins: []string{"https://app.example.com"},
+//		Methods: []string{"PUT", "DELETE"},
+//		Headers: []string{"Content-Type", "Authorization"},
+//		MaxAge:  10 * time.Minute,
+//	}))
+func (u *Rule) CORS(policy CORSPolicy) *Rule {
+	if policy.Credentials {
+		for _, o := range policy.Origins {
+			if o == "*" {
+				panic("wedge: a CORS policy with Credentials can't allow any origin; list them or use AllowOrigin")
+			}
+		}
+	}
+	u.cors = &cors{CORSPolicy: policy, decisions: make(map[string]preflight)}
+	return u
+}
+
+// CORS is the Option form of Rule.CORS.
+func CORS(policy CORSPolicy) Option {
+	return func(u *Rule) {
+		u.CORS(policy)
+	}
+}
+
+// PreflightStats returns how many preflight requests the route has had,
+// how many of them were answered from its cache of decisions, and how
+// many were refused.
+func (u *Rule) PreflightStats() (preflights, hits, rejected uint64) {
+	if u.cors == nil {
+		return 0, 0, 0
+	}
+	return atomic.LoadUint64(&u.cors.preflights),
+		atomic.LoadUint64(&u.cors.hits),
+		atomic.LoadUint64(&u.cors.rejected)
+}
+
+// isPreflight reports whether req is a CORS preflight request.
+func isPreflight(req *http.Request) bool {
+	return req.Method == "OPTIONS" && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// allowedOrigin returns the value of Access-Control-Allow-Origin for
+// origin, or "" if it isn't allowed.
+func (c *cors) allowedOrigin(origin string) string {
+	for _, o := range c.Origins {
+		if o == "*" {
+			return "*"
+		}
+		if strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	if c.AllowOrigin != nil && c.AllowOrigin(origin) {
+		return origin
+	}
+	return ""
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide works out the response headers for a preflight, which are nil if
+// it's refused.
+func (c *cors) decide(origin, method, headers string) http.Header {
+	allowed := c.allowedOrigin(origin)
+	if allowed == "" {
+		return nil
+	}
+	switch method {
+	case "GET", "HEAD", "POST":
+	default:
+		if !containsFold(c.Methods, method) {
+			return nil
+		}
+	}
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" && !containsFold(c.Headers, h) {
+			return nil
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("Access-Control-Allow-Origin", allowed)
+	header.Set("Access-Control-Allow-Methods", strings.Join(append([]string{"GET", "HEAD", "POST"}, c.Methods...), ", "))
+	if len(c.Headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(c.Headers, ", "))
+	}
+	if c.Credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	return header
+}
+
+// preflight answers a preflight request, from the cached decision for the
+// same origin, method and headers if there is one.
+func (c *cors) preflight(w http.ResponseWriter, req *http.Request) {
+	atomic.AddUint64(&c.preflights, 1)
+	origin := req.Header.Get("Origin")
+	method := req.Header.Get("Access-Control-Request-Method")
+	headers := req.Header.Get("Access-Control-Request-Headers")
+	key := origin + "\x00" + method + "\x00" + strings.ToLower(headers)
+	now := time.Now()
+
+	c.Lock()
+	decision, ok := c.decisions[key]
+	if ok && now.Before(decision.expires) {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		ttl := c.MaxAge
+		if ttl <= 0 {
+			ttl = DefaultPreflightTTL
+		}
+		decision = preflight{c.decide(origin, method, headers), now.Add(ttl)}
+		if _, ok := c.decisions[key]; !ok {
+			c.order = append(c.order, key)
+		}
+		c.decisions[key] = decision
+		for len(c.order) > PreflightCacheSize {
+			delete(c.decisions, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.Unlock()
+
+	w.Header().Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	if decision.header == nil {
+		atomic.AddUint64(&c.rejected, 1)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	for k, v := range decision.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply adds the CORS headers to the response to an actual request.
+func (c *cors) apply(w http.ResponseWriter, req *http.Request) {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	allowed := c.allowedOrigin(origin)
+	if allowed != "*" {
+		w.Header().Add("Vary", "Origin")
+	}
+	if allowed == "" {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
+	if c.Credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(c.Expose) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.Expose, ", "))
+	}
+}
@@ -0,0 +1,91 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	App := NewAppServer("0", 30)
+	route := Route("^/api$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "ok", http.StatusOK
+	}, Methods("GET", "PUT"), CORS(CORSPolicy{
+		Origins: []string{"https://app.example"},
+		Methods: []string{"PUT"},
+		Headers: []string{"Content-Type"},
+		Expose:  []string{"X-Total"},
+		MaxAge:  time.Minute,
+	}))
+	App.AddURLs(route)
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://app.example", "PUT", "content-type")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("allowed preflight: got status %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("got Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "60" {
+		t.Errorf("got Max-Age %q, want 60", got)
+	}
+	preflight("https://app.example", "PUT", "content-type")
+	if w := preflight("https://evil.example", "PUT", ""); w.Code != http.StatusForbidden {
+		t.Errorf("preflight from another origin: got status %d", w.Code)
+	}
+	if w := preflight("https://app.example", "DELETE", ""); w.Code != http.StatusForbidden {
+		t.Errorf("preflight for a disallowed method: got status %d", w.Code)
+	}
+	if w := preflight("https://app.example", "PUT", "X-Secret"); w.Code != http.StatusForbidden {
+		t.Errorf("preflight with a disallowed header: got status %d", w.Code)
+	}
+	if preflights, hits, rejected := route.PreflightStats(); preflights != 5 || hits != 1 || rejected != 3 {
+		t.Errorf("got stats (%d, %d, %d), want (5, 1, 3)", preflights, hits, rejected)
+	}
+
+	req := httptest.NewRequest("GET", "/api", nil)
+	req.Header.Set("Origin", "https://app.example")
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("actual request: got Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Total" {
+		t.Errorf("actual request: got Expose-Headers %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("actual request: got Vary %q, want Origin", got)
+	}
+}
+
+func TestCORSWildcardCredentials(t *testing.T) {
+	// without credentials any origin gets a literal "*"
+	c := &cors{CORSPolicy: CORSPolicy{Origins: []string{"*"}}}
+	header := c.decide("https://evil.example", "GET", "")
+	if got := header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("got Allow-Origin %q, want *", got)
+	}
+	if got := header.Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("got Allow-Credentials %q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("a policy allowing any origin with credentials didn't panic")
+		}
+	}()
+	CORS(CORSPolicy{Origins: []string{"*"}, Credentials: true})(&Rule{})
+}
@@ -0,0 +1,77 @@
+package wedge
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Values for CrossOriginResourcePolicy.
+const (
+	SameOrigin  = "same-origin"
+	SameSite    = "same-site"
+	CrossOrigin = "cross-origin"
+)
+
+// Values for CrossOriginEmbedderPolicy.
+const (
+	RequireCORP    = "require-corp"
+	Credentialless = "credentialless"
+)
+
+// setHeader sets a header sent with every response from the route.
+func (u *Rule) setHeader(key, value string) *Rule {
+	if u.headers == nil {
+		u.headers = make(http.Header)
+	}
+	u.headers.Set(key, value)
+	return u
+}
+
+// CrossOriginResourcePolicy sets which sites may load the route's
+// responses with a Cross-Origin-Resource-Policy header: SameOrigin,
+// SameSite or CrossOrigin. Pages using a Cross-Origin-Embedder-Policy can
+// only load assets from other origins which allow it with CrossOrigin.
+//
+// Example:
+//
+//	wedge.StaticFiles("/static/", "static").CrossOriginResourcePolicy(wedge.CrossOrigin)
+func (u *Rule) CrossOriginResourcePolicy(policy string) *Rule {
+	return u.setHeader("Cross-Origin-Resource-Policy", policy)
+}
+
+// CrossOriginEmbedderPolicy sets the Cross-Origin-Embedder-Policy of the
+// route's pages, RequireCORP or Credentialless, which is needed for
+// features such as SharedArrayBuffer.
+func (u *Rule) CrossOriginEmbedderPolicy(policy string) *Rule {
+	return u.setHeader("Cross-Origin-Embedder-Policy", policy)
+}
+
+// TimingAllowOrigin lets pages on origins see the detailed resource
+// timings of the route's responses, for assets embedded by other sites
+// which want to measure their performance. "*" allows every origin.
+func (u *Rule) TimingAllowOrigin(origins ...string) *Rule {
+	return u.setHeader("Timing-Allow-Origin", strings.Join(origins, ", "))
+}
+
+// CrossOriginResourcePolicy is the Option form of
+// Rule.CrossOriginResourcePolicy.
+func CrossOriginResourcePolicy(policy string) Option {
+	return func(u *Rule) {
+		u.CrossOriginResourcePolicy(policy)
+	}
+}
+
+// CrossOriginEmbedderPolicy is the Option form of
+// Rule.CrossOriginEmbedderPolicy.
+func CrossOriginEmbedderPolicy(policy string) Option {
+	return func(u *Rule) {
+		u.CrossOriginEmbedderPolicy(policy)
+	}
+}
+
+// TimingAllowOrigin is the Option form of Rule.TimingAllowOrigin.
+func TimingAllowOrigin(origins ...string) Option {
+	return func(u *Rule) {
+		u.TimingAllowOrigin(origins...)
+	}
+}
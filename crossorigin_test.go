@@ -0,0 +1,27 @@
+package wedge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrossOriginHeaders(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	App.AddURLs(StaticFiles("/static/", ".").
+		CrossOriginResourcePolicy(CrossOrigin).
+		CrossOriginEmbedderPolicy(RequireCORP).
+		TimingAllowOrigin("https://a.example.com", "https://b.example.com"))
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/static/crossorigin.go", nil))
+	want := map[string]string{
+		"Cross-Origin-Resource-Policy": "cross-origin",
+		"Cross-Origin-Embedder-Policy": "require-corp",
+		"Timing-Allow-Origin":          "https://a.example.com, https://b.example.com",
+	}
+	for key, value := range want {
+		if got := w.Header().Get(key); got != value {
+			t.Errorf("%s: got %q, want %q", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package wedge
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// denyList holds the client addresses which are refused, along with when
+// each stops being refused. A zero time never expires.
+type denyList struct {
+	sync.Mutex
+	until map[string]time.Time
+}
+
+// initDenied makes sure App has a deny list, for AppServers which weren't
+// made by NewAppServer. It's called while the AppServer is being set up,
+// as ServeHTTP reads App.denied without a lock.
+func (App *AppServer) initDenied() {
+	if App.denied == nil {
+		App.denied = &denyList{until: make(map[string]time.Time)}
+	}
+}
+
+// DenyIP refuses every request from ip with a 403 Forbidden for d, or
+// until AllowIP is called if d isn't positive. The address is the one
+// from the client behind any proxies given to TrustProxies.
+func (App *AppServer) DenyIP(ip string, d time.Duration) {
+	var until time.Time
+	if d > 0 {
+		until = time.Now().Add(d)
+	}
+	App.denied.Lock()
+	defer App.denied.Unlock()
+	if _, ok := App.denied.until[ip]; !ok && len(App.denied.until) >= MaxBanClients {
+		App.denied.evict()
+	}
+	App.denied.until[ip] = until
+}
+
+// evict forgets a denial to make room for another, preferring one which
+// has expired and never one which doesn't expire. It must be called with
+// l locked.
+func (l *denyList) evict() {
+	now := time.Now()
+	victim, found := "", false
+	for ip, until := range l.until {
+		if until.IsZero() {
+			continue
+		}
+		victim, found = ip, true
+		if now.After(until) {
+			break
+		}
+	}
+	if found {
+		delete(l.until, victim)
+	}
+}
+
+// prune forgets the denials which have expired.
+func (l *denyList) prune(now time.Time) {
+	l.Lock()
+	defer l.Unlock()
+	for ip, until := range l.until {
+		if !until.IsZero() && now.After(until) {
+			delete(l.until, ip)
+		}
+	}
+}
+
+// AllowIP stops refusing requests from ip.
+func (App *AppServer) AllowIP(ip string) {
+	if App.denied == nil {
+		return
+	}
+	App.denied.Lock()
+	defer App.denied.Unlock()
+	delete(App.denied.until, ip)
+}
+
+// Denied reports whether requests from ip are being refused.
+func (App *AppServer) Denied(ip string) bool {
+	if App.denied == nil {
+		return false
+	}
+	App.denied.Lock()
+	defer App.denied.Unlock()
+	until, ok := App.denied.until[ip]
+	if !ok {
+		return false
+	}
+	if !until.IsZero() && time.Now().After(until) {
+		delete(App.denied.until, ip)
+		return false
+	}
+	return true
+}
+
+// handle403req refuses a request from a denied address.
+func (App *AppServer) handle403req(w http.ResponseWriter, req *http.Request) {
+	log.Println("403 on path:", req.URL.Path)
+	if App.stat_map != nil {
+		App.incrementStats("403 => " + App.statPath(req))
+	}
+
+	if App.sendAPIError(w, req, http.StatusForbidden, "") {
+		return
+	}
+	if App.renderError(w, req, http.StatusForbidden) {
+		return
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
@@ -0,0 +1,68 @@
+package wedge
+
+import (
+	"log"
+	"net/http"
+)
+
+// ErrorPage is what error templates set with ErrorTemplate are rendered
+// with.
+type ErrorPage struct {
+	Status     int
+	StatusText string
+	// Path is the path which was asked for.
+	Path      string
+	RequestID string
+	// Suggestions are paths the client may have meant, if suggestions are
+	// enabled with EnableSuggestions.
+	Suggestions []string
+}
+
+// SetTemplates gives the AppServer the templates error pages are rendered
+// from.
+func (App *AppServer) SetTemplates(r *TemplateRegistry) {
+	App.templates = r
+}
+
+// ErrorTemplate renders responses with status, such as 404, 500 or 415,
+// from the template called name in the registry given to SetTemplates. It
+// takes the place of Handler404, Handler405 and Handler500, so error pages
+// can share the site's layout without a view of their own. The template is
+// given an ErrorPage.
+//
+// Example:
+//
+//	App.SetTemplates(registry)
+//	App.ErrorTemplate(404, "errors/404.html")
+//	App.ErrorTemplate(500, "errors/500.html")
+func (App *AppServer) ErrorTemplate(status int, name string) {
+	if App.errorPages == nil {
+		App.errorPages = make(map[int]string)
+	}
+	App.errorPages[status] = name
+}
+
+// renderError answers req with the error template for status, reporting
+// whether there was one to render it with.
+func (App *AppServer) renderError(w http.ResponseWriter, req *http.Request, status int) bool {
+	name, ok := App.errorPages[status]
+	if !ok || App.templates == nil {
+		return false
+	}
+	page := ErrorPage{
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		Path:        req.URL.Path,
+		RequestID:   RequestID(req),
+		Suggestions: Suggestions(req),
+	}
+	body, err := App.templates.Render(name, page)
+	if err != nil {
+		log.Println("Error rendering error page", name+":", err)
+		return false
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+	return true
+}
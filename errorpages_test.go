@@ -0,0 +1,46 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorTemplate(t *testing.T) {
+	registry, err := NewTemplateRegistry(TemplateBundle{
+		"layout.html": `<main>{{block "content" .}}{{end}}</main>`,
+		"404.html": `{{template "layout.html" .}}{{define "content"}}{{.Status}} {{.Path}} ` +
+			`{{range .Suggestions}}{{.}}{{end}} {{.RequestID}}{{end}}`,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	App := NewAppServer("0", 30)
+	App.AddURLs(URL("^/about/$", "About", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "about", http.StatusOK
+	}, HTML))
+	App.EnableSuggestions()
+	App.SetTemplates(registry)
+	App.ErrorTemplate(404, "404.html")
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/abot/", nil))
+	id := w.Header().Get("X-Request-ID")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %d", w.Code)
+	}
+	if want := "<main>404 /abot/ /about/ " + id + "</main>"; id == "" || w.Body.String() != want {
+		t.Errorf("got %q, want %q", w.Body, want)
+	}
+
+	// without a template for 500 the built-in page is still used.
+	App.AddURLs(URL("^/broken/$", "Broken", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "", http.StatusInternalServerError
+	}, HTML))
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/broken/", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Internal Server Error") {
+		t.Errorf("got %d %q", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,186 @@
+package wedge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// VisitorCookie is the cookie which identifies a visitor, so that they
+	// are shown the same variant of each experiment on every visit.
+	VisitorCookie = "wedge_visitor"
+	// VisitorCookieAge is how long the visitor cookie lasts.
+	VisitorCookieAge = 365 * 24 * time.Hour
+)
+
+// Experiment is an A/B test, showing each visitor one of its Variants.
+// Visitors are assigned a variant by hashing the experiment's name with
+// their visitor cookie, so they keep it without anything being stored on
+// the server, and the same visitor can land in different variants of
+// different experiments.
+type Experiment struct {
+	Name     string
+	Variants []string
+	// Weights are the relative shares of visitors given each variant.
+	// Each variant gets an equal share if it's nil.
+	Weights []int
+
+	mu        sync.Mutex
+	exposures map[string]uint64
+}
+
+// AddExperiments registers experiments so that their variants can be
+// looked up by name with Variant, ExperimentFuncs and SplitView.
+//
+// Example:
+//
+//	App.AddExperiments(&wedge.Experiment{
+//		Name:     "signup-button",
+//		Variants: []string{"green", "blue"},
+//		Weights:  []int{90, 10},
+//	})
+func (App *AppServer) AddExperiments(experiments ...*Experiment) {
+	if App.trials == nil {
+		App.trials = make(map[string]*Experiment)
+	}
+	for _, e := range experiments {
+		if len(e.Variants) == 0 {
+			panic("wedge: experiment " + e.Name + " has no variants")
+		}
+		if e.Weights != nil && len(e.Weights) != len(e.Variants) {
+			panic("wedge: experiment " + e.Name + " needs a weight for each variant")
+		}
+		App.trials[e.Name] = e
+	}
+}
+
+// visitorID returns the ID from the visitor cookie of req, giving it one
+// if it hasn't got one. A new ID is added to req too, so the visitor gets
+// the same variants however many experiments the page takes part in.
+func visitorID(w http.ResponseWriter, req *http.Request) string {
+	if cookie, err := req.Cookie(VisitorCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	cookie := &http.Cookie{
+		Name:     VisitorCookie,
+		Value:    hex.EncodeToString(b),
+		Path:     "/",
+		MaxAge:   int(VisitorCookieAge / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, cookie)
+	req.AddCookie(cookie)
+	return cookie.Value
+}
+
+// assign picks the variant for visitor.
+func (e *Experiment) assign(visitor string) string {
+	sum := sha256.Sum256([]byte(e.Name + "\x00" + visitor))
+	n := binary.BigEndian.Uint64(sum[:8])
+	if e.Weights == nil {
+		return e.Variants[n%uint64(len(e.Variants))]
+	}
+	var total uint64
+	for _, weight := range e.Weights {
+		total += uint64(weight)
+	}
+	if total == 0 {
+		return e.Variants[0]
+	}
+	n %= total
+	for i, weight := range e.Weights {
+		if n < uint64(weight) {
+			return e.Variants[i]
+		}
+		n -= uint64(weight)
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// Variant returns the variant of the named experiment to show to the
+// visitor making req, and records that they were shown it. Exposures are
+// counted on the statistics page, if stat tracking is enabled, and by
+// Exposures. It returns "" for an experiment which hasn't been added.
+func (App *AppServer) Variant(w http.ResponseWriter, req *http.Request, name string) string {
+	e, ok := App.trials[name]
+	if !ok {
+		log.Println("Unknown experiment:", name)
+		return ""
+	}
+	variant := e.assign(visitorID(w, req))
+	e.mu.Lock()
+	if e.exposures == nil {
+		e.exposures = make(map[string]uint64)
+	}
+	e.exposures[variant]++
+	e.mu.Unlock()
+	if App.stat_map != nil {
+		App.incrementStats("EXPERIMENT => " + name + ": " + variant)
+	}
+	return variant
+}
+
+// Exposures returns how many times each variant of the named experiment
+// has been shown.
+func (App *AppServer) Exposures(name string) map[string]uint64 {
+	counts := make(map[string]uint64)
+	e, ok := App.trials[name]
+	if !ok {
+		return counts
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for variant, n := range e.exposures {
+		counts[variant] = n
+	}
+	return counts
+}
+
+// ExperimentFuncs returns template functions for branching on the variants
+// shown to the visitor making req. It has to be added to the template's
+// FuncMap for each request.
+//
+// Example:
+//
+//	t, _ := page.Clone()
+//	t.Funcs(App.ExperimentFuncs(w, req))
+//
+//	{{if eq (variant "signup-button") "blue"}}...{{end}}
+func (App *AppServer) ExperimentFuncs(w http.ResponseWriter, req *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"variant": func(name string) string {
+			return App.Variant(w, req, name)
+		},
+	}
+}
+
+// SplitView returns a view which hands each request to the view for the
+// variant of the named experiment shown to the visitor. Variants without
+// a view are a 404. A cached route would show every visitor the same
+// variant, unless VisitorCookie is passed to SetSessionCookies.
+//
+// Example:
+//
+//	wedge.URL("^/signup/$", "Signup", App.SplitView("signup-page", map[string]func(http.ResponseWriter, *http.Request) (string, int){
+//		"old": OldSignup,
+//		"new": NewSignup,
+//	}), wedge.HTML)
+func (App *AppServer) SplitView(name string, views map[string]func(http.ResponseWriter, *http.Request) (string, int)) func(http.ResponseWriter, *http.Request) (string, int) {
+	return func(w http.ResponseWriter, req *http.Request) (string, int) {
+		v, ok := views[App.Variant(w, req, name)]
+		if !ok {
+			return "", http.StatusNotFound
+		}
+		return v(w, req)
+	}
+}
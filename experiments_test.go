@@ -0,0 +1,61 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExperiments(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.AddExperiments(
+		&Experiment{Name: "button", Variants: []string{"green", "blue"}},
+		&Experiment{Name: "rollout", Variants: []string{"old", "new"}, Weights: []int{0, 1}},
+	)
+	App.AddURLs(
+		URL("^/$", "Index", App.SplitView("button", map[string]func(http.ResponseWriter, *http.Request) (string, int){
+			"green": func(w http.ResponseWriter, req *http.Request) (string, int) { return "green", http.StatusOK },
+			"blue":  func(w http.ResponseWriter, req *http.Request) (string, int) { return "blue", http.StatusOK },
+		}), HTML),
+	)
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != VisitorCookie {
+		t.Fatalf("visitor cookie not set: %v", cookies)
+	}
+	first := w.Body.String()
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookies[0])
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		if w.Body.String() != first {
+			t.Fatalf("visitor was shown %q then %q", first, w.Body.String())
+		}
+		if len(w.Result().Cookies()) != 0 {
+			t.Error("visitor cookie set again")
+		}
+	}
+	if n := App.Exposures("button")[first]; n != 6 {
+		t.Errorf("%d exposures of %q, want 6", n, first)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		seen[App.Variant(w, req, "button")] = true
+		if v := App.Variant(w, req, "rollout"); v != "new" {
+			t.Errorf("variant %q has no weight", v)
+		}
+	}
+	if !seen["green"] || !seen["blue"] {
+		t.Errorf("visitors weren't split between variants: %v", seen)
+	}
+	if v := App.Variant(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "missing"); v != "" {
+		t.Errorf("unknown experiment gave %q", v)
+	}
+}
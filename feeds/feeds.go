@@ -0,0 +1,307 @@
+// Package feeds is an extension to wedge which serializes RSS 2.0 and Atom
+// feeds, for blog-style sites built with wedge.
+//
+// A view builds a Feed and hands it to Serve, which picks the content type
+// and answers conditional GETs with a 304 when the feed hasn't changed:
+//
+//	func Posts(w http.ResponseWriter, req *http.Request) (string, int) {
+//		feed := &feeds.Feed{
+//			Title: "My Blog",
+//			Link:  "https://example.com/",
+//		}
+//		for _, post := range recentPosts() {
+//			feed.Items = append(feed.Items, feeds.Item{
+//				Title:     post.Title,
+//				Link:      "https://example.com/posts/" + post.Slug,
+//				Published: post.Date,
+//				Content:   post.HTML,
+//			})
+//		}
+//		return feeds.Serve(w, req, feed, feeds.Atom)
+//	}
+//
+//	wedge.URL("^/feed.xml$", "Feed", Posts, wedge.FEED)
+package feeds
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Format is the flavour of XML a feed is written as.
+type Format int
+
+const (
+	RSS Format = iota
+	Atom
+)
+
+// Author is the person responsible for a feed or an item.
+type Author struct {
+	Name  string
+	Email string
+}
+
+// Feed is a channel of items, such as the posts on a blog.
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Author      Author
+	// Updated is when the feed last changed. If it is zero, the most
+	// recent date of any of the items is used.
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is a single entry in a Feed.
+type Item struct {
+	Title string
+	Link  string
+	// ID uniquely identifies the item. If it is empty, Link is used.
+	ID          string
+	Author      Author
+	Description string
+	// Content is the full HTML content of the item.
+	Content   string
+	Published time.Time
+	// Updated is when the item last changed. If it is zero, Published
+	// is used.
+	Updated time.Time
+}
+
+func (i Item) id() string {
+	if i.ID != "" {
+		return i.ID
+	}
+	return i.Link
+}
+
+func (i Item) updated() time.Time {
+	if i.Updated.IsZero() {
+		return i.Published
+	}
+	return i.Updated
+}
+
+// updated returns when the feed last changed.
+func (f *Feed) updated() time.Time {
+	updated := f.Updated
+	if updated.IsZero() {
+		for _, item := range f.Items {
+			if item.updated().After(updated) {
+				updated = item.updated()
+			}
+		}
+	}
+	return updated
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string    `xml:"title"`
+	Link           string    `xml:"link"`
+	Description    string    `xml:"description"`
+	ManagingEditor string    `xml:"managingEditor,omitempty"`
+	LastBuildDate  string    `xml:"lastBuildDate,omitempty"`
+	Items          []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title,omitempty"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	Author      string  `xml:"author,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssDate formats t as RSS requires, or returns an empty string so the
+// element is omitted if t is zero.
+func rssDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+// RSS authors are an email address with an optional name in brackets.
+func rssAuthor(a Author) string {
+	if a.Email == "" {
+		return ""
+	}
+	if a.Name == "" {
+		return a.Email
+	}
+	return fmt.Sprintf("%s (%s)", a.Email, a.Name)
+}
+
+// RSS returns the feed as an RSS 2.0 document.
+func (f *Feed) RSS() (string, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:          f.Title,
+			Link:           f.Link,
+			Description:    f.Description,
+			ManagingEditor: rssAuthor(f.Author),
+			LastBuildDate:  rssDate(f.updated()),
+		},
+	}
+	for _, item := range f.Items {
+		description := item.Content
+		if description == "" {
+			description = item.Description
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: description,
+			Author:      rssAuthor(item.Author),
+			GUID:        rssGUID{item.ID == "", item.id()},
+			PubDate:     rssDate(item.Published),
+		})
+	}
+	return marshal(feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Summary   *atomText   `xml:"summary,omitempty"`
+	Content   *atomText   `xml:"content,omitempty"`
+}
+
+func atomDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func newAtomAuthor(a Author) *atomAuthor {
+	if a.Name == "" {
+		return nil
+	}
+	return &atomAuthor{a.Name, a.Email}
+}
+
+// Atom returns the feed as an Atom document.
+//
+// Atom requires every entry to have an author, so either the Feed or
+// each of its Items should have an Author with a Name.
+func (f *Feed) Atom() (string, error) {
+	feed := atomFeed{
+		Title:   f.Title,
+		ID:      f.Link,
+		Updated: atomDate(f.updated()),
+		Link:    atomLink{f.Link},
+		Author:  newAtomAuthor(f.Author),
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:     item.Title,
+			ID:        item.id(),
+			Link:      atomLink{item.Link},
+			Updated:   atomDate(item.updated()),
+			Published: atomDate(item.Published),
+			Author:    newAtomAuthor(item.Author),
+		}
+		if item.Description != "" {
+			entry.Summary = &atomText{"html", item.Description}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{"html", item.Content}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshal(feed)
+}
+
+func marshal(v interface{}) (string, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+// Serve writes the headers for feed in the given format and returns its
+// body, ready to be returned from a view registered with wedge.FEED.
+//
+// An ETag and a Last-Modified header are set, and if the request's
+// If-None-Match or If-Modified-Since headers show the client already has
+// the current feed, a 304 with no body is returned instead.
+func Serve(w http.ResponseWriter, req *http.Request, feed *Feed, format Format) (string, int) {
+	var body string
+	var err error
+	if format == Atom {
+		body, err = feed.Atom()
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	} else {
+		body, err = feed.RSS()
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum([]byte(body)))
+	w.Header().Set("ETag", etag)
+	updated := feed.updated()
+	if !updated.IsZero() {
+		w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	}
+
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		if match == etag || match == "*" {
+			return "", http.StatusNotModified
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil {
+		if !updated.IsZero() && !updated.Truncate(time.Second).After(since) {
+			return "", http.StatusNotModified
+		}
+	}
+	return body, http.StatusOK
+}
@@ -0,0 +1,154 @@
+package feeds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var posted = time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+
+func testFeed() *Feed {
+	return &Feed{
+		Title:       "Blog & News",
+		Link:        "https://example.com/",
+		Description: "Posts about wedge",
+		Author:      Author{"Ann", "ann@example.com"},
+		Items: []Item{
+			{
+				Title:     "First <post>",
+				Link:      "https://example.com/posts/first",
+				Published: posted,
+				Updated:   posted.Add(48 * time.Hour),
+				Content:   "<p>Hello</p>",
+			},
+			{
+				Title:       "Second",
+				Link:        "https://example.com/posts/second",
+				ID:          "tag:example.com,2024:second",
+				Author:      Author{Name: "Bob"},
+				Description: "A summary",
+				Published:   posted.Add(24 * time.Hour),
+			},
+		},
+	}
+}
+
+const wantRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Blog &amp; News</title>
+    <link>https://example.com/</link>
+    <description>Posts about wedge</description>
+    <managingEditor>ann@example.com (Ann)</managingEditor>
+    <lastBuildDate>Sun, 03 Mar 2024 12:00:00 +0100</lastBuildDate>
+    <item>
+      <title>First &lt;post&gt;</title>
+      <link>https://example.com/posts/first</link>
+      <description>&lt;p&gt;Hello&lt;/p&gt;</description>
+      <guid isPermaLink="true">https://example.com/posts/first</guid>
+      <pubDate>Fri, 01 Mar 2024 12:00:00 +0100</pubDate>
+    </item>
+    <item>
+      <title>Second</title>
+      <link>https://example.com/posts/second</link>
+      <description>A summary</description>
+      <guid isPermaLink="false">tag:example.com,2024:second</guid>
+      <pubDate>Sat, 02 Mar 2024 12:00:00 +0100</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+const wantAtom = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Blog &amp; News</title>
+  <id>https://example.com/</id>
+  <updated>2024-03-03T11:00:00Z</updated>
+  <link href="https://example.com/"></link>
+  <author>
+    <name>Ann</name>
+    <email>ann@example.com</email>
+  </author>
+  <entry>
+    <title>First &lt;post&gt;</title>
+    <id>https://example.com/posts/first</id>
+    <link href="https://example.com/posts/first"></link>
+    <updated>2024-03-03T11:00:00Z</updated>
+    <published>2024-03-01T11:00:00Z</published>
+    <content type="html">&lt;p&gt;Hello&lt;/p&gt;</content>
+  </entry>
+  <entry>
+    <title>Second</title>
+    <id>tag:example.com,2024:second</id>
+    <link href="https://example.com/posts/second"></link>
+    <updated>2024-03-02T11:00:00Z</updated>
+    <published>2024-03-02T11:00:00Z</published>
+    <author>
+      <name>Bob</name>
+    </author>
+    <summary type="html">A summary</summary>
+  </entry>
+</feed>`
+
+func TestRSS(t *testing.T) {
+	got, err := testFeed().RSS()
+	if err != nil || got != wantRSS {
+		t.Errorf("got %s and %v, want %s", got, err, wantRSS)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	got, err := testFeed().Atom()
+	if err != nil || got != wantAtom {
+		t.Errorf("got %s and %v, want %s", got, err, wantAtom)
+	}
+}
+
+func TestServe(t *testing.T) {
+	modified := posted.Add(48 * time.Hour).UTC().Format(http.TimeFormat)
+	w := httptest.NewRecorder()
+	body, code := Serve(w, httptest.NewRequest("GET", "/feed.xml", nil), testFeed(), RSS)
+	etag := w.Header().Get("ETag")
+	if body != wantRSS || code != http.StatusOK || etag == "" ||
+		w.Header().Get("Content-Type") != "application/rss+xml; charset=utf-8" ||
+		w.Header().Get("Last-Modified") != modified {
+		t.Errorf("got %d and headers %v", code, w.Header())
+	}
+
+	for _, test := range []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"etag", "If-None-Match", etag, http.StatusNotModified},
+		{"any etag", "If-None-Match", "*", http.StatusNotModified},
+		{"old etag", "If-None-Match", `"old"`, http.StatusOK},
+		{"modified since", "If-Modified-Since", modified, http.StatusNotModified},
+		{"modified before", "If-Modified-Since", posted.UTC().Format(http.TimeFormat), http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/feed.xml", nil)
+		req.Header.Set(test.header, test.value)
+		body, code := Serve(httptest.NewRecorder(), req, testFeed(), RSS)
+		if code != test.want || (code == http.StatusNotModified) != (body == "") {
+			t.Errorf("%s: got %d with %d bytes, want %d", test.name, code, len(body), test.want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	body, code = Serve(w, httptest.NewRequest("GET", "/feed.xml", nil), testFeed(), Atom)
+	if body != wantAtom || code != http.StatusOK ||
+		w.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" ||
+		w.Header().Get("ETag") == etag {
+		t.Errorf("got %d and headers %v", code, w.Header())
+	}
+
+	// an empty feed has no date to compare against.
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/feed.xml", nil)
+	req.Header.Set("If-Modified-Since", modified)
+	if _, code := Serve(w, req, &Feed{Title: "Empty"}, RSS); code != http.StatusOK || w.Header().Get("Last-Modified") != "" {
+		t.Errorf("empty feed: got %d and headers %v", code, w.Header())
+	}
+}
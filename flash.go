@@ -0,0 +1,52 @@
+package wedge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// FlashCookie is the cookie flash messages are kept in between a redirect
+// and the page it leads to.
+var FlashCookie = "wedge_flash"
+
+// AddFlash queues msg to be shown on the next page the client loads, such
+// as the one it's redirected to after submitting a form. Messages are kept
+// in a cookie, so they aren't secret, and must be escaped when they're
+// shown like anything else which came from the client.
+func AddFlash(w http.ResponseWriter, req *http.Request, msg string) {
+	messages := pendingFlashes(req)
+	messages = append(messages, msg)
+	b, _ := json.Marshal(messages)
+	http.SetCookie(w, &http.Cookie{
+		Name:     FlashCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Flashes returns the messages queued with AddFlash, removing them so
+// they're only shown once.
+func Flashes(w http.ResponseWriter, req *http.Request) []string {
+	messages := pendingFlashes(req)
+	if len(messages) > 0 {
+		http.SetCookie(w, &http.Cookie{Name: FlashCookie, Path: "/", MaxAge: -1})
+	}
+	return messages
+}
+
+func pendingFlashes(req *http.Request) []string {
+	cookie, err := req.Cookie(FlashCookie)
+	if err != nil {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	var messages []string
+	json.Unmarshal(b, &messages)
+	return messages
+}
@@ -0,0 +1,73 @@
+package forms
+
+import (
+	"html/template"
+	"strings"
+)
+
+// condition makes a Field depend on the value of another.
+type condition struct {
+	field  string
+	values []string
+}
+
+// When returns a copy of the Form in which the Field called name is only
+// required and validated when the Field called field was submitted with
+// one of values, such as an "Other, please specify" TextField which only
+// matters when "Other" is picked.
+//
+// Display wraps dependent Fields in the conditional.html template, which
+// by default gives them data-depends-on and data-depends-values attributes
+// so that client side scripts can show and hide them to match.
+//
+// Example:
+//
+//	var SurveyForm = forms.NewForm(md,
+//		forms.RadioField("source",
+//			forms.Choice("A friend", "friend", false),
+//			forms.Choice("Other", "other", false),
+//		),
+//		forms.TextField("source_other", "Please specify", 100),
+//	).When("source_other", "source", "other")
+func (f Form) When(name, field string, values ...string) *Form {
+	conditions := make(map[string]condition, len(f.conditions)+1)
+	for key, value := range f.conditions {
+		conditions[key] = value
+	}
+	conditions[name] = condition{field, values}
+	f.conditions = conditions
+	return &f
+}
+
+// active reports whether the Field called name applies to the submitted
+// input, which it does unless it depends on a Field with another value.
+func (f Form) active(name string, input map[string]interface{}) bool {
+	cond, ok := f.conditions[name]
+	if !ok {
+		return true
+	}
+	submitted, _ := input[cond.field].([]string)
+	for _, value := range submitted {
+		for _, want := range cond.values {
+			if value == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// displayField renders field, wrapped in conditional.html if it depends
+// on another Field.
+func (f Form) displayField(field Field) template.HTML {
+	html := template.HTML(field.Display())
+	cond, ok := f.conditions[field.Name()]
+	if !ok {
+		return html
+	}
+	return template.HTML(render("conditional.html", map[string]interface{}{
+		"Field":     html,
+		"DependsOn": cond.field,
+		"Values":    strings.Join(cond.values, ","),
+	}))
+}
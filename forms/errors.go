@@ -0,0 +1,127 @@
+package forms
+
+import "html/template"
+
+// ErrorStyle sets the classes and wording used when validation errors are
+// rendered, by a BoundForm's Display, ErrorSummary and FieldError.
+type ErrorStyle struct {
+	// SummaryClass is the class of the list of every error shown above
+	// the Fields, and SummaryTitle its heading.
+	SummaryClass string
+	SummaryTitle string
+	// FieldClass is the class of the element wrapping a Field which
+	// failed to validate, and MessageClass that of the message shown
+	// with it.
+	FieldClass   string
+	MessageClass string
+}
+
+// Styling is the ErrorStyle of every form. The markup itself comes from
+// the errors.html and invalid.html templates, which can be replaced like
+// the others.
+var Styling = ErrorStyle{
+	SummaryClass: "error-summary",
+	SummaryTitle: "There is a problem with your submission.",
+	FieldClass:   "invalid",
+	MessageClass: "error",
+}
+
+// formError is how an error is presented to errors.html.
+type formError struct {
+	Field   string
+	ID      string
+	Message string
+}
+
+// fieldID is the id of the element wrapping the Field called name when
+// it failed to validate, which the summary links to.
+func (b *BoundForm) fieldID(name string) string {
+	return b.form.md.name + "-" + name
+}
+
+// ErrorSummary renders every error, those of the whole Form first and
+// then those of each Field in order, linked to the Field. It's empty if
+// the form is valid. The summary is announced by screen readers as soon
+// as it's shown. A nil BoundForm, for a form which hasn't been submitted,
+// has no errors.
+func (b *BoundForm) ErrorSummary() template.HTML {
+	if b == nil || b.Valid() {
+		return ""
+	}
+	var errors []formError
+	if err, ok := b.errors[""]; ok {
+		errors = append(errors, formError{Message: err})
+	}
+	for _, field := range b.form.fieldslice {
+		name := field.Name()
+		if err, ok := b.errors[name]; ok {
+			errors = append(errors, formError{name, b.fieldID(name), err})
+		}
+	}
+	return template.HTML(render("errors.html", map[string]interface{}{
+		"ID":     b.form.md.name + "-errors",
+		"Class":  Styling.SummaryClass,
+		"Title":  Styling.SummaryTitle,
+		"Errors": errors,
+	}))
+}
+
+// FieldError renders the error of the Field called name, to be shown next
+// to it in a form laid out by hand. It's empty if the Field is valid.
+func (b *BoundForm) FieldError(name string) template.HTML {
+	if b == nil {
+		return ""
+	}
+	err, ok := b.errors[name]
+	if !ok || name == "" {
+		return ""
+	}
+	return template.HTML(render("field_error.html", map[string]interface{}{
+		"ID":      b.fieldID(name) + "-error",
+		"Class":   Styling.MessageClass,
+		"Message": err,
+	}))
+}
+
+// invalid wraps the rendered Field called name with its error, marking
+// the wrapper as described by the error for screen readers.
+func (b *BoundForm) invalid(name string, field template.HTML) template.HTML {
+	if _, ok := b.errors[name]; !ok || name == "" {
+		return field
+	}
+	return template.HTML(render("invalid.html", map[string]interface{}{
+		"ID":      b.fieldID(name),
+		"Class":   Styling.FieldClass,
+		"Field":   field,
+		"Error":   b.FieldError(name),
+		"ErrorID": b.fieldID(name) + "-error",
+	}))
+}
+
+// FuncMap returns template functions for rendering the errors of a
+// BoundForm in a page laid out by hand: errorSummary, fieldError and
+// fieldInvalid, which reports whether a Field failed to validate.
+//
+// Example:
+//
+//	t := template.Must(template.New("signup").Funcs(forms.FuncMap()).Parse(
+//		`{{errorSummary .Form}}` +
+//		`<input name="email" {{if fieldInvalid .Form "email"}}aria-invalid="true"{{end}}>` +
+//		`{{fieldError .Form "email"}}`))
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"errorSummary": func(b *BoundForm) template.HTML {
+			return b.ErrorSummary()
+		},
+		"fieldError": func(b *BoundForm, name string) template.HTML {
+			return b.FieldError(name)
+		},
+		"fieldInvalid": func(b *BoundForm, name string) bool {
+			if b == nil {
+				return false
+			}
+			_, ok := b.errors[name]
+			return ok
+		},
+	}
+}
@@ -1,12 +1,23 @@
 package forms
 
 import (
-	"bytes"
 	"fmt"
+	"html/template"
 	"log"
+	"math"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
+// MaxMemory is how much of a multipart form is held in memory while it is
+// parsed, the remainder being stored in temporary files.
+var MaxMemory int64 = 32 << 20
+
 type FormMetadata struct {
 	name   string
 	action string
@@ -62,68 +73,263 @@ type Form struct {
 	md         FormMetadata
 	fields     map[string]Field
 	fieldslice []Field
+	validators []FormValidator
+	conditions map[string]condition
+	once       bool
+	limiter    *rateLimiter
+	apiHeader  string
+	apiCheck   func(string, *http.Request) bool
 }
 
 // Fields allows you to iterate through the fields and have a custom order, or specialized
-// output versus using the Display method.
+// output versus using the Display method. The slice is a copy, so the Form
+// itself can't be changed through it.
 func (f Form) Fields() []Field {
-	return f.fieldslice
+	return append([]Field(nil), f.fieldslice...)
 }
 
-// Display iterates through all the Fields and calls their Display method
-// adding their return values to a buffer and flushing that to the caller.
+// Display iterates through all the Fields and calls their Display method,
+// passing their return values to the form.html template.
 func (f Form) Display() string {
-	buf := bytes.NewBufferString("")
-	buf.WriteString(
-		fmt.Sprintf(`<form name="%s" action="%s" method="%s">`,
-			f.md.name, f.md.action, f.md.method,
-		),
-	)
+	return f.display(nil)
+}
 
+// display renders the form along with the errors of b, if it's given.
+func (f Form) display(b *BoundForm) string {
+	var fields []template.HTML
 	for _, field := range f.fieldslice {
-		buf.WriteString(field.Display())
-		buf.WriteString(`<br/>`)
+		html := f.displayField(field)
+		if b != nil {
+			html = b.invalid(field.Name(), html)
+		}
+		fields = append(fields, html)
 	}
-	if f.md.submit {
-		buf.WriteString(`<input type="submit" value="Submit">`)
+	var errors []string
+	if err, ok := b.formError(); ok {
+		errors = append(errors, err)
 	}
-	buf.WriteString(`</form>`)
-	return buf.String()
-}
-
-// Validate takes the incoming request object and checks if the form
-// included with it.
+	enctype := ""
+	if f.multipart() {
+		enctype = "multipart/form-data"
+	}
+	token := ""
+	if f.once {
+		token = issueToken()
+	}
+	return render("form.html", map[string]interface{}{
+		"Name":       f.md.name,
+		"Action":     f.md.action,
+		"Method":     f.md.method,
+		"Enctype":    enctype,
+		"Errors":     errors,
+		"Summary":    b.ErrorSummary(),
+		"TokenField": OnceTokenField,
+		"Token":      token,
+		"Fields":     fields,
+		"Submit":     f.md.submit,
+	})
+}
+
+// Validate takes the incoming request object and checks the form
+// included with it, returning a BoundForm which holds the submitted values
+// and any errors.
 //
 // Validate works on the Field interface. Considering that we will have
 // quite a lot of field types, which need to be grouped onto a Form.
-func (f Form) Validate(req *http.Request) bool {
-	req.ParseForm()
-
-	inputForm := req.Form
-	for key, value := range f.fields {
+//
+// The values are read from where the FormMetadata's method says they will
+// be: the query string for GET forms and the request body otherwise. A
+// request made with a different method, or a form with a FileField which
+// wasn't submitted as multipart/form-data, fails to validate.
+//
+// A Form is never changed after NewForm, so a single definition can be
+// shared by every request and goroutine. Anything which belongs to one
+// submission lives on the BoundForm.
+func (f Form) Validate(req *http.Request) *BoundForm {
+	b := &BoundForm{
+		form:   f,
+		req:    req,
+		errors: make(map[string]string),
+	}
+	if f.limiter != nil && !f.limiter.allow(req) {
+		log.Println("Form submitted too often:", f.md.name)
+		b.errors[""] = TooManySubmissionsMessage
+		return b
+	}
+	inputForm, ok := f.input(req)
+	if !ok {
+		b.errors[""] = "invalid submission"
+		return b
+	}
+	b.values = inputForm
+	if f.once && !f.apiClient(req) && !f.checkToken(inputForm) {
+		log.Println("Form submitted twice:", f.md.name)
+		b.errors[""] = AlreadySubmittedMessage
+		return b
+	}
+	for _, field := range f.fieldslice {
+		key := field.Name()
+		if !f.active(key, inputForm) {
+			continue
+		}
 		if _, ok := inputForm[key]; !ok {
 			log.Println("Key not in inputForm:", key)
-			return false
+			b.errors[key] = "required"
+			continue
 		}
-		if !value.Validate(inputForm[key], req) {
+		if !field.Validate(inputForm[key], req) {
 			log.Println("Failed to validate:", key)
-			return false
+			b.errors[key] = "invalid"
 		}
 	}
-	return true
+	if !b.Valid() {
+		return b
+	}
+	for _, validator := range f.validators {
+		if err := validator(b); err != nil {
+			log.Println("Form didn't validate:", err)
+			b.errors[""] = err.Error()
+			break
+		}
+	}
+	return b
+}
+
+// WithValidators returns a copy of the Form which also runs validators
+// once every Field is valid. The first to fail is reported by the
+// BoundForm's Errors under the empty name.
+//
+// Example:
+//     var SignupForm = forms.NewForm(md, fields...).WithValidators(passwordsMatch)
+func (f Form) WithValidators(validators ...FormValidator) *Form {
+	f.validators = append(append([]FormValidator(nil), f.validators...), validators...)
+	return &f
 }
 
-// Form iterates through all the Fields on the Form and calls their
-// Convert method and assigns the result in a map.
+// Convert is a shorthand for validating req and converting the result,
+// for callers which have already checked the form is valid.
 func (f Form) Convert(req *http.Request) map[string]interface{} {
-	inputForm := req.Form
+	return f.Validate(req).Convert()
+}
+
+// BoundForm is a Form bound to the values of a single request.
+type BoundForm struct {
+	form   Form
+	req    *http.Request
+	values map[string]interface{}
+	errors map[string]string
+}
+
+// Valid reports whether every Field validated.
+func (b *BoundForm) Valid() bool {
+	return len(b.errors) == 0
+}
+
+// Errors maps the name of each Field which failed to validate to the
+// reason, being "required" or "invalid". A submission which couldn't be
+// read at all is reported under the empty name.
+func (b *BoundForm) Errors() map[string]string {
+	return b.errors
+}
+
+// Display renders the form again for showing the submitter what went
+// wrong, with a summary of the errors above the Fields, and each Field
+// which failed wrapped along with its error. See ErrorStyle.
+func (b *BoundForm) Display() string {
+	return b.form.display(b)
+}
+
+// formError returns the error from a form level check, such as
+// TooManySubmissionsMessage.
+func (b *BoundForm) formError() (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	err, ok := b.errors[""]
+	return err, ok
+}
+
+// Request returns the request the form was bound to.
+func (b *BoundForm) Request() *http.Request {
+	return b.req
+}
+
+// Value returns the submitted value for the Field called name, as it was
+// passed to the Field's Validate method.
+func (b *BoundForm) Value(name string) interface{} {
+	return b.values[name]
+}
+
+// Convert iterates through all the Fields on the Form and calls their
+// Convert method and assigns the result in a map. Fields which weren't
+// submitted, or which don't apply because of When, are left out.
+func (b *BoundForm) Convert() map[string]interface{} {
 	outform := make(map[string]interface{})
-	for key, value := range f.fields {
-		outform[key] = value.Convert(inputForm[key], req)
+	for key, value := range b.form.fields {
+		if input, ok := b.values[key]; ok && b.form.active(key, b.values) {
+			outform[key] = value.Convert(input, b.req)
+		}
 	}
 	return outform
 }
 
+// method returns the method the form is submitted with, which is GET if
+// the FormMetadata doesn't give one, as it is for HTML forms.
+func (f Form) method() string {
+	if f.md.method == "" {
+		return "GET"
+	}
+	return strings.ToUpper(f.md.method)
+}
+
+// multipart reports whether the form has to be sent as multipart/form-data.
+func (f Form) multipart() bool {
+	for _, field := range f.fieldslice {
+		if _, ok := unwrap(field).(File); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// input collects the submitted values for the form from req. Each value
+// is a []string, apart from those for FileFields which are a
+// []*multipart.FileHeader.
+func (f Form) input(req *http.Request) (map[string]interface{}, bool) {
+	if req.Method != f.method() {
+		log.Printf("Form %s submitted with %s, expected %s\n", f.md.name, req.Method, f.method())
+		return nil, false
+	}
+
+	var values url.Values
+	var files map[string][]*multipart.FileHeader
+	if f.method() == "GET" {
+		values = req.URL.Query()
+	} else if f.multipart() {
+		if err := req.ParseMultipartForm(MaxMemory); err != nil {
+			log.Println("Error parsing multipart form:", err)
+			return nil, false
+		}
+		values = req.PostForm
+		files = req.MultipartForm.File
+	} else {
+		if err := req.ParseForm(); err != nil {
+			log.Println("Error parsing form:", err)
+			return nil, false
+		}
+		values = req.PostForm
+	}
+
+	input := make(map[string]interface{})
+	for key, value := range values {
+		input[key] = value
+	}
+	for key, value := range files {
+		input[key] = value
+	}
+	return input, true
+}
+
 // NewForm creates an instance of a *Form and returns a pointer to it.
 func NewForm(md FormMetadata, forms ...Field) *Form {
 	newForm := Form{
@@ -139,13 +345,16 @@ func NewForm(md FormMetadata, forms ...Field) *Form {
 }
 
 type Text struct {
-	name      string
-	long_name string
-	max_len   int
+	name       string
+	long_name  string
+	max_len    int
+	validators []Validator
 }
 
-func TextField(name, long_name string, l int) Field {
-	return Text{name, long_name, l}
+// TextField creates a Text value for a string shorter than l, with any
+// extra validators or Sanitizer given by opts.
+func TextField(name, long_name string, l int, opts ...Option) Field {
+	return sanitize(Text{name, long_name, l, applyOptions(opts)}, opts)
 }
 
 func (t Text) Validate(key interface{}, f *http.Request) bool {
@@ -155,7 +364,7 @@ func (t Text) Validate(key interface{}, f *http.Request) bool {
 		return false
 	}
 	if len(k[0]) < t.max_len {
-		return true
+		return runValidators(t, t.validators, key, f)
 	}
 	log.Println("TextField didn't validate")
 	return false
@@ -175,7 +384,11 @@ func (t Text) Name() string {
 }
 
 func (t Text) Display() string {
-	return fmt.Sprintf(`%s: <input type="text" name="%s" />`, t.long_name, t.name)
+	return render("text.html", map[string]interface{}{
+		"Name":   t.name,
+		"Label":  t.long_name,
+		"MaxLen": t.max_len,
+	})
 }
 
 type Radio struct {
@@ -210,7 +423,7 @@ func (r Radio) Convert(key interface{}, req *http.Request) interface{} {
 		log.Println("Error converting Radio value")
 		return false
 	}
-	return k[0]
+	return choiceValue(r.choices_slice, k[0])
 }
 
 func (r Radio) Name() string {
@@ -218,7 +431,7 @@ func (r Radio) Name() string {
 }
 
 func (r Radio) Display() string {
-	return writeMultipleOptions(r, r.choices_slice, "radio")
+	return writeMultipleOptions(r, r.choices_slice, "radio.html")
 }
 
 type Check struct {
@@ -232,6 +445,7 @@ type choice_options struct {
 	choice  string
 	name    string
 	checked string
+	value   interface{}
 }
 
 func Choice(choice, name string, checked bool) choice_options {
@@ -240,7 +454,56 @@ func Choice(choice, name string, checked bool) choice_options {
 		checkstr = `checked="checked"`
 	}
 
-	return choice_options{choice, name, checkstr}
+	return choice_options{choice, name, checkstr, nil}
+}
+
+// TypedChoice is the same as Choice, except that the Field's Convert method
+// returns value when this choice is picked rather than a string. The value
+// is written into the HTML with fmt.Sprint, so it should have a unique
+// textual form, such as an int ID or a UUID.
+func TypedChoice(choice string, value interface{}, checked bool) choice_options {
+	c := Choice(choice, fmt.Sprint(value), checked)
+	c.value = value
+	return c
+}
+
+// ChoicesFrom builds a TypedChoice for each element of slice, in the manner
+// of sort.Slice. key returns the value of the i'th element and label how it
+// should be shown on the form.
+//
+// Example:
+//     forms.ComboField("author", "Author", forms.ChoicesFrom(users,
+//         func(i int) interface{} { return users[i].ID },
+//         func(i int) string { return users[i].Name },
+//     )...)
+func ChoicesFrom(slice interface{}, key func(i int) interface{}, label func(i int) string) []choice_options {
+	n := reflect.ValueOf(slice).Len()
+	choices := make([]choice_options, n)
+	for i := 0; i < n; i++ {
+		choices[i] = TypedChoice(label(i), key(i), false)
+	}
+	return choices
+}
+
+// choiceValue returns the value of the choice whose name is key, being
+// the typed value for a TypedChoice and key itself otherwise.
+func choiceValue(choices []choice_options, key string) interface{} {
+	for _, choice := range choices {
+		if choice.name == key && choice.value != nil {
+			return choice.value
+		}
+	}
+	return key
+}
+
+// typedChoices reports whether any of the choices are TypedChoices.
+func typedChoices(choices []choice_options) bool {
+	for _, choice := range choices {
+		if choice.value != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // CheckField creates a Check value which will have it's fields properly initialized
@@ -279,6 +542,13 @@ func (c Check) Convert(key interface{}, req *http.Request) interface{} {
 		log.Printf("Error converting a Check value")
 		return false
 	}
+	if typedChoices(c.choices_slice) {
+		values := make([]interface{}, len(k))
+		for i, key := range k {
+			values[i] = choiceValue(c.choices_slice, key)
+		}
+		return values
+	}
 	return k
 }
 
@@ -287,22 +557,24 @@ func (c Check) Name() string {
 }
 
 func (c Check) Display() string {
-	return writeMultipleOptions(c, c.choices_slice, "checkbox")
+	return writeMultipleOptions(c, c.choices_slice, "checkbox.html")
 }
 
 type Password struct {
-	name      string
-	long_name string
-	min       int
-	max       int
+	name       string
+	long_name  string
+	min        int
+	max        int
+	validators []Validator
 }
 
-func PasswordField(name, long_name string, min, max int) Password {
+func PasswordField(name, long_name string, min, max int, opts ...Option) Password {
 	return Password{
-		name:      name,
-		long_name: long_name,
-		min:       min,
-		max:       max,
+		name:       name,
+		long_name:  long_name,
+		min:        min,
+		max:        max,
+		validators: applyOptions(opts),
 	}
 }
 
@@ -313,7 +585,7 @@ func (p Password) Validate(key interface{}, req *http.Request) bool {
 		return false
 	}
 	if (len(val[0]) >= p.min) && (len(val[0]) <= p.max) {
-		return true
+		return runValidators(p, p.validators, key, req)
 	}
 	log.Println("Failure to validate Password: Length")
 	return false
@@ -333,7 +605,12 @@ func (p Password) Name() string {
 }
 
 func (p Password) Display() string {
-	return fmt.Sprintf(`%s: <input type="password" name="%s" />`, p.long_name, p.name)
+	return render("password.html", map[string]interface{}{
+		"Name":  p.name,
+		"Label": p.long_name,
+		"Min":   p.min,
+		"Max":   p.max,
+	})
 }
 
 type Combo struct {
@@ -364,7 +641,7 @@ func (c Combo) Convert(key interface{}, req *http.Request) interface{} {
 	if !ok {
 		log.Println("Error converting Combo: assert")
 	}
-	return k[0]
+	return choiceValue(c.choices_slice, k[0])
 }
 
 func (c Combo) Name() string {
@@ -372,35 +649,290 @@ func (c Combo) Name() string {
 }
 
 func (c Combo) Display() string {
-	buf := bytes.NewBufferString("")
-	buf.WriteString(
-		fmt.Sprintf(`%s: <select name="%s">`, c.long_name, c.name),
-	)
-	for _, choice := range c.choices_slice {
-		buf.WriteString(
-			fmt.Sprintf(`<option value="%s">%s</option>`,
-				choice.name, choice.choice,
-			),
-		)
+	return render("combo.html", map[string]interface{}{
+		"Name":    c.name,
+		"Label":   c.long_name,
+		"Choices": choicesData(c.choices_slice),
+	})
+}
+
+type File struct {
+	name       string
+	long_name  string
+	max_size   int64
+	validators []Validator
+}
+
+// FileField creates a File value for uploading a file of at most max bytes.
+// A Form with a FileField is displayed with the multipart/form-data enctype,
+// and its Convert method gives a *multipart.FileHeader for the upload.
+func FileField(name, long_name string, max int64, opts ...Option) Field {
+	return File{name, long_name, max, applyOptions(opts)}
+}
+
+func (f File) Validate(key interface{}, req *http.Request) bool {
+	k, ok := key.([]*multipart.FileHeader)
+	if !ok || len(k) == 0 {
+		log.Println("Error validating File value")
+		return false
+	}
+	if k[0].Size > f.max_size {
+		log.Println("FileField didn't validate: Size")
+		return false
+	}
+	return runValidators(f, f.validators, key, req)
+}
+
+func (f File) Convert(key interface{}, req *http.Request) interface{} {
+	k, ok := key.([]*multipart.FileHeader)
+	if !ok || len(k) == 0 {
+		log.Println("Error converting File value")
+		return false
 	}
-	buf.WriteString(`</select>`)
-	return buf.String()
+	return k[0]
+}
+
+func (f File) Name() string {
+	return f.name
+}
+
+func (f File) Display() string {
+	return render("file.html", map[string]interface{}{
+		"Name":    f.name,
+		"Label":   f.long_name,
+		"MaxSize": f.max_size,
+	})
+}
+
+type Color struct {
+	name       string
+	long_name  string
+	validators []Validator
+}
+
+var colorre = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ColorField creates a Color value for a colour picker, which submits
+// colours in the "#rrggbb" form.
+func ColorField(name, long_name string, opts ...Option) Field {
+	return Color{name, long_name, applyOptions(opts)}
+}
+
+func (c Color) Validate(key interface{}, req *http.Request) bool {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error validating Color value")
+		return false
+	}
+	if colorre.MatchString(k[0]) {
+		return runValidators(c, c.validators, key, req)
+	}
+	log.Println("ColorField didn't validate")
+	return false
+}
+
+func (c Color) Convert(key interface{}, req *http.Request) interface{} {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error converting Color value")
+		return false
+	}
+	return strings.ToLower(k[0])
+}
+
+func (c Color) Name() string {
+	return c.name
+}
+
+func (c Color) Display() string {
+	return render("color.html", map[string]interface{}{
+		"Name":  c.name,
+		"Label": c.long_name,
+	})
+}
+
+type Range struct {
+	name       string
+	long_name  string
+	min        float64
+	max        float64
+	step       float64
+	validators []Validator
+}
+
+// RangeField creates a Range value for a slider between min and max,
+// moving in increments of step from min. A step of 0 allows any value.
+// Its Convert method returns a float64.
+func RangeField(name, long_name string, min, max, step float64, opts ...Option) Field {
+	return Range{name, long_name, min, max, step, applyOptions(opts)}
+}
+
+func (r Range) Validate(key interface{}, req *http.Request) bool {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error validating Range value")
+		return false
+	}
+	value, err := strconv.ParseFloat(k[0], 64)
+	if err != nil {
+		log.Println("RangeField didn't validate: Number")
+		return false
+	}
+	if value < r.min || value > r.max {
+		log.Println("RangeField didn't validate: Range")
+		return false
+	}
+	if r.step > 0 {
+		steps := (value - r.min) / r.step
+		if math.Abs(steps-math.Round(steps)) > 1e-9 {
+			log.Println("RangeField didn't validate: Step")
+			return false
+		}
+	}
+	return runValidators(r, r.validators, key, req)
+}
+
+func (r Range) Convert(key interface{}, req *http.Request) interface{} {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error converting Range value")
+		return false
+	}
+	value, _ := strconv.ParseFloat(k[0], 64)
+	return value
+}
+
+func (r Range) Name() string {
+	return r.name
+}
+
+func (r Range) Display() string {
+	step := "any"
+	if r.step > 0 {
+		step = fmt.Sprint(r.step)
+	}
+	return render("range.html", map[string]interface{}{
+		"Name":  r.name,
+		"Label": r.long_name,
+		"Min":   r.min,
+		"Max":   r.max,
+		"Step":  step,
+	})
+}
+
+type Tel struct {
+	name       string
+	long_name  string
+	pattern    string
+	match      *regexp.Regexp
+	validators []Validator
+}
+
+// DefaultTelPattern is the pattern used by TelField when it's given an
+// empty one. It allows an optional leading + and the usual separators.
+const DefaultTelPattern = `\+?[0-9 ()./-]{3,20}`
+
+// TelField creates a Tel value for a telephone number which must match
+// pattern. As with the HTML pattern attribute, the pattern has to match
+// the whole value. It will panic if the pattern cannot be compiled.
+func TelField(name, long_name, pattern string, opts ...Option) Field {
+	if pattern == "" {
+		pattern = DefaultTelPattern
+	}
+	match := regexp.MustCompile(`^(?:` + pattern + `)$`)
+	return Tel{name, long_name, pattern, match, applyOptions(opts)}
+}
+
+func (t Tel) Validate(key interface{}, req *http.Request) bool {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error validating Tel value")
+		return false
+	}
+	if t.match.MatchString(k[0]) {
+		return runValidators(t, t.validators, key, req)
+	}
+	log.Println("TelField didn't validate")
+	return false
+}
+
+func (t Tel) Convert(key interface{}, req *http.Request) interface{} {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error converting Tel value")
+		return false
+	}
+	return k[0]
+}
+
+func (t Tel) Name() string {
+	return t.name
+}
+
+func (t Tel) Display() string {
+	return render("tel.html", map[string]interface{}{
+		"Name":    t.name,
+		"Label":   t.long_name,
+		"Pattern": t.pattern,
+	})
+}
+
+type Search struct {
+	name       string
+	long_name  string
+	max_len    int
+	validators []Validator
+}
+
+// SearchField creates a Search value, which is a Text displayed as a
+// search box.
+func SearchField(name, long_name string, l int, opts ...Option) Field {
+	return sanitize(Search{name, long_name, l, applyOptions(opts)}, opts)
+}
+
+func (s Search) Validate(key interface{}, req *http.Request) bool {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error validating Search value")
+		return false
+	}
+	if len(k[0]) < s.max_len {
+		return runValidators(s, s.validators, key, req)
+	}
+	log.Println("SearchField didn't validate")
+	return false
+}
+
+func (s Search) Convert(key interface{}, req *http.Request) interface{} {
+	k, ok := key.([]string)
+	if !ok {
+		log.Println("Error converting Search value")
+		return false
+	}
+	return k[0]
+}
+
+func (s Search) Name() string {
+	return s.name
+}
+
+func (s Search) Display() string {
+	return render("search.html", map[string]interface{}{
+		"Name":   s.name,
+		"Label":  s.long_name,
+		"MaxLen": s.max_len,
+	})
 }
 
 // writeMultipleOptions is a helper method which is used for Fields which have
 // a very similar internal datastructure and a very similar output format.
 //
 // It's useful for things which vary very little in their HTML representation.
-func writeMultipleOptions(object Field, choices []choice_options, ftype string) string {
-	buf := bytes.NewBufferString("")
-	for _, choice := range choices {
-		buf.WriteString(
-			fmt.Sprintf(`%s: <input type="%s" name="%s" value="%s" %s /><br />`,
-			choice.choice, ftype, object.Name(), choice.name, choice.checked,
-			),
-		)
-	}
-	return buf.String()
+func writeMultipleOptions(object Field, choices []choice_options, tmpl string) string {
+	return render(tmpl, map[string]interface{}{
+		"Name":    object.Name(),
+		"Choices": choicesData(choices),
+	})
 }
 
 // initMultipleOptions is a helper method which is used for Fields which have
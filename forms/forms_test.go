@@ -0,0 +1,252 @@
+package forms
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// post makes a POST request submitting values as a urlencoded form.
+func post(values url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestFieldValidate(t *testing.T) {
+	choices := []choice_options{Choice("A", "a", false), Choice("B", "b", false)}
+	req := httptest.NewRequest("POST", "/", nil)
+	for _, test := range []struct {
+		name  string
+		field Field
+		value interface{}
+		want  bool
+	}{
+		{"text", TextField("t", "T", 5), []string{"abcd"}, true},
+		{"text too long", TextField("t", "T", 5), []string{"abcde"}, false},
+		{"text not strings", TextField("t", "T", 5), "abcd", false},
+		{"text validator", TextField("t", "T", 5, WithValidator(NotBlank())), []string{"  "}, false},
+		{"radio", RadioField("r", choices...), []string{"a"}, true},
+		{"radio unknown", RadioField("r", choices...), []string{"c"}, false},
+		{"check", CheckField("c", 1, choices...), []string{"a", "b"}, true},
+		{"check too few", CheckField("c", 1, choices...), []string{}, false},
+		{"check unknown", CheckField("c", 1, choices...), []string{"a", "c"}, false},
+		{"password", PasswordField("p", "P", 3, 5), []string{"abc"}, true},
+		{"password short", PasswordField("p", "P", 3, 5), []string{"ab"}, false},
+		{"password long", PasswordField("p", "P", 3, 5), []string{"abcdef"}, false},
+		{"combo", ComboField("c", "C", choices...), []string{"b"}, true},
+		{"combo unknown", ComboField("c", "C", choices...), []string{"z"}, false},
+		{"file", FileField("f", "F", 10), []*multipart.FileHeader{{Size: 10}}, true},
+		{"file too big", FileField("f", "F", 10), []*multipart.FileHeader{{Size: 11}}, false},
+		{"file missing", FileField("f", "F", 10), []*multipart.FileHeader{}, false},
+		{"color", ColorField("c", "C"), []string{"#A0b1c2"}, true},
+		{"color name", ColorField("c", "C"), []string{"red"}, false},
+		{"range", RangeField("r", "R", 0, 10, 2.5), []string{"7.5"}, true},
+		{"range off step", RangeField("r", "R", 0, 10, 2.5), []string{"6"}, false},
+		{"range above", RangeField("r", "R", 0, 10, 2.5), []string{"12.5"}, false},
+		{"range not a number", RangeField("r", "R", 0, 10, 2.5), []string{"x"}, false},
+		{"range any step", RangeField("r", "R", 0, 10, 0), []string{"3.3"}, true},
+		{"tel", TelField("p", "P", ""), []string{"+44 (0)20 7946 0000"}, true},
+		{"tel words", TelField("p", "P", ""), []string{"call me"}, false},
+		// the pattern has to match all of the value, whichever branch matches.
+		{"tel pattern", TelField("p", "P", "[0-9]{3}|[0-9]{5}"), []string{"12345"}, true},
+		{"tel pattern partial", TelField("p", "P", "[0-9]{3}|[0-9]{5}"), []string{"1234"}, false},
+		{"search", SearchField("s", "S", 5), []string{"abcd"}, true},
+		{"search too long", SearchField("s", "S", 5), []string{"abcde"}, false},
+	} {
+		if got := test.field.Validate(test.value, req); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFieldConvert(t *testing.T) {
+	typed := []choice_options{TypedChoice("One", 1, false), TypedChoice("Two", 2, false)}
+	file := &multipart.FileHeader{Filename: "a.txt"}
+	req := httptest.NewRequest("POST", "/", nil)
+	for _, test := range []struct {
+		name  string
+		field Field
+		value interface{}
+		want  interface{}
+	}{
+		{"text", TextField("t", "T", 5), []string{"abc", "def"}, "abc"},
+		{"radio", RadioField("r", Choice("A", "a", false)), []string{"a"}, "a"},
+		{"radio typed", RadioField("r", typed...), []string{"2"}, 2},
+		{"check", CheckField("c", 0, Choice("A", "a", false)), []string{"a"}, []string{"a"}},
+		{"check typed", CheckField("c", 0, typed...), []string{"1", "2"}, []interface{}{1, 2}},
+		{"combo typed", ComboField("c", "C", typed...), []string{"1"}, 1},
+		{"password", PasswordField("p", "P", 0, 5), []string{"pw"}, "pw"},
+		{"file", FileField("f", "F", 10), []*multipart.FileHeader{file}, file},
+		{"color", ColorField("c", "C"), []string{"#A0B1C2"}, "#a0b1c2"},
+		{"range", RangeField("r", "R", 0, 10, 0), []string{"2.5"}, 2.5},
+		{"tel", TelField("p", "P", ""), []string{"123"}, "123"},
+		{"search", SearchField("s", "S", 5), []string{"q"}, "q"},
+	} {
+		if got := test.field.Convert(test.value, req); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFormValidate(t *testing.T) {
+	md := NewFormMetadata("signup", "/signup", "POST", true)
+	form := NewForm(md,
+		TextField("user", "User", 10),
+		PasswordField("password", "Password", 3, 10),
+		PasswordField("again", "Again", 3, 10),
+	).WithValidators(func(b *BoundForm) error {
+		if b.Value("password").([]string)[0] != b.Value("again").([]string)[0] {
+			return errNoMatch
+		}
+		return nil
+	})
+	for _, test := range []struct {
+		name string
+		req  *http.Request
+		want map[string]string
+	}{
+		{"valid", post(url.Values{"user": {"bob"}, "password": {"secret"}, "again": {"secret"}}), map[string]string{}},
+		{"missing", post(url.Values{"password": {"secret"}, "again": {"secret"}}), map[string]string{"user": "required"}},
+		{"invalid", post(url.Values{"user": {"bob"}, "password": {"pw"}, "again": {"pw"}}),
+			map[string]string{"password": "invalid", "again": "invalid"}},
+		{"form validator", post(url.Values{"user": {"bob"}, "password": {"secret"}, "again": {"other"}}),
+			map[string]string{"": errNoMatch.Error()}},
+		{"wrong method", httptest.NewRequest("GET", "/?user=bob&password=secret&again=secret", nil),
+			map[string]string{"": "invalid submission"}},
+	} {
+		b := form.Validate(test.req)
+		if !reflect.DeepEqual(b.Errors(), test.want) || b.Valid() != (len(test.want) == 0) {
+			t.Errorf("%s: got %v, want %v", test.name, b.Errors(), test.want)
+		}
+	}
+
+	// GET forms are read from the query string.
+	search := NewForm(NewFormMetadata("search", "/", "", false), SearchField("q", "Search", 20))
+	b := search.Validate(httptest.NewRequest("GET", "/?q=wedge", nil))
+	if got := b.Convert(); !b.Valid() || !reflect.DeepEqual(got, map[string]interface{}{"q": "wedge"}) {
+		t.Errorf("got %v and %v", got, b.Errors())
+	}
+}
+
+var errNoMatch = errors.New("passwords don't match")
+
+func TestFormMultipart(t *testing.T) {
+	form := NewForm(NewFormMetadata("upload", "/", "POST", true),
+		TextField("title", "Title", 20),
+		FileField("doc", "Document", 100),
+	)
+	if !strings.Contains(form.Display(), `enctype="multipart/form-data"`) {
+		t.Errorf("got %s", form.Display())
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "notes")
+	fw, _ := mw.CreateFormFile("doc", "notes.txt")
+	fw.Write([]byte("hello"))
+	mw.Close()
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	b := form.Validate(req)
+	values := b.ConvertTyped()
+	if !b.Valid() || values.String("title") != "notes" || values.File("doc").Filename != "notes.txt" {
+		t.Errorf("got %v and %v", values, b.Errors())
+	}
+
+	// a urlencoded submission can't carry the file.
+	if b := form.Validate(post(url.Values{"title": {"notes"}})); b.Valid() {
+		t.Error("a urlencoded upload validated")
+	}
+}
+
+func TestFormWhen(t *testing.T) {
+	form := NewForm(NewFormMetadata("survey", "/", "POST", true),
+		RadioField("source", Choice("A friend", "friend", false), Choice("Other", "other", false)),
+		TextField("source_other", "Please specify", 100),
+	).When("source_other", "source", "other")
+	for _, test := range []struct {
+		values url.Values
+		valid  bool
+		want   map[string]interface{}
+	}{
+		{url.Values{"source": {"friend"}}, true, map[string]interface{}{"source": "friend"}},
+		// the dependent Field is dropped when it doesn't apply.
+		{url.Values{"source": {"friend"}, "source_other": {"x"}}, true, map[string]interface{}{"source": "friend"}},
+		{url.Values{"source": {"other"}}, false, map[string]interface{}{"source": "other"}},
+		{url.Values{"source": {"other"}, "source_other": {"a blog"}}, true,
+			map[string]interface{}{"source": "other", "source_other": "a blog"}},
+	} {
+		b := form.Validate(post(test.values))
+		if got := b.Convert(); b.Valid() != test.valid || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v and %v, want %v and %v", test.values, b.Valid(), got, test.valid, test.want)
+		}
+	}
+	if want := `<div data-depends-on="source" data-depends-values="other">`; !strings.Contains(form.Display(), want) {
+		t.Errorf("got %s, want it to contain %s", form.Display(), want)
+	}
+}
+
+func TestFormDisplay(t *testing.T) {
+	form := NewForm(NewFormMetadata("login", "/login", "POST", true),
+		TextField("user", "User", 10),
+		PasswordField("password", "Password", 3, 10),
+	)
+	for _, want := range []string{
+		`<form name="login" action="/login" method="POST">`,
+		`User: <input type="text" name="user" />`,
+		`Password: <input type="password" name="password" />`,
+		`<input type="submit" value="Submit"></form>`,
+	} {
+		if !strings.Contains(form.Display(), want) {
+			t.Errorf("got %s, want it to contain %s", form.Display(), want)
+		}
+	}
+
+	b := form.Validate(post(url.Values{"user": {"bob"}, "password": {"pw"}}))
+	for _, want := range []string{
+		`<div class="error-summary" role="alert"`,
+		`<a href="#login-password">password: invalid</a>`,
+		`<div id="login-password" class="invalid" role="group" aria-invalid="true" aria-describedby="login-password-error">`,
+		`<span id="login-password-error" class="error">invalid</span>`,
+	} {
+		if !strings.Contains(b.Display(), want) {
+			t.Errorf("got %s, want it to contain %s", b.Display(), want)
+		}
+	}
+	if strings.Contains(b.Display(), `id="login-user"`) {
+		t.Errorf("got %s, with the valid Field marked", b.Display())
+	}
+
+	defer SetTemplate("text.html", defaultTemplates["text.html"])
+	if err := SetTemplate("text.html", `<label>{{.Label}} <input name="{{.Name}}" maxlength="{{.MaxLen}}"></label>`); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<label>User <input name="user" maxlength="10"></label>`; !strings.Contains(form.Display(), want) {
+		t.Errorf("got %s, want it to contain %s", form.Display(), want)
+	}
+	if err := LoadBundle(map[string]string{"text.html": "{{"}); err == nil {
+		t.Error("a broken bundle loaded")
+	}
+
+	defer SetTemplate("password.html", defaultTemplates["password.html"])
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "password.html"), []byte(`<input type="password" name="{{.Name}}">`), 0644)
+	if err := LoadTemplates(filepath.Join(dir, "*.html")); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<input type="password" name="password"><br/>`; !strings.Contains(form.Display(), want) {
+		t.Errorf("got %s, want it to contain %s", form.Display(), want)
+	}
+	if err := LoadTemplates(filepath.Join(dir, "*.tmpl")); err == nil {
+		t.Error("got no error loading no templates")
+	}
+}
@@ -0,0 +1,201 @@
+package forms
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OnceTokenField is the name of the hidden input holding a Form's
+// one-time token.
+const OnceTokenField = "_once"
+
+var (
+	// OnceTokenTTL is how long a one-time token stays valid after the
+	// form holding it is displayed.
+	OnceTokenTTL = time.Hour
+	// AlreadySubmittedMessage and TooManySubmissionsMessage are the
+	// errors given under the empty name by a BoundForm rejected by Once
+	// or RateLimit, and shown by its Display method.
+	AlreadySubmittedMessage   = "This form has already been submitted."
+	TooManySubmissionsMessage = "Too many submissions, please try again later."
+)
+
+// onceTokens holds the one-time tokens which have been issued and not
+// yet used, along with when they expire.
+var onceTokens = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: make(map[string]time.Time)}
+
+// issueToken creates and stores a new one-time token.
+func issueToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Println("Error creating form token:", err)
+		return ""
+	}
+	token := hex.EncodeToString(b)
+
+	onceTokens.Lock()
+	defer onceTokens.Unlock()
+	now := time.Now()
+	for key, expires := range onceTokens.expires {
+		if now.After(expires) {
+			delete(onceTokens.expires, key)
+		}
+	}
+	onceTokens.expires[token] = now.Add(OnceTokenTTL)
+	return token
+}
+
+// useToken reports whether token is valid, and makes sure it never is
+// again.
+func useToken(token string) bool {
+	onceTokens.Lock()
+	defer onceTokens.Unlock()
+	expires, ok := onceTokens.expires[token]
+	delete(onceTokens.expires, token)
+	return ok && time.Now().Before(expires)
+}
+
+// Once returns a copy of the Form which is displayed with a one-time
+// token, so that submitting the same copy of the form twice, such as by
+// refreshing the page after a POST, fails with AlreadySubmittedMessage. It's
+// best paired with SeeOther once the submission has been handled.
+func (f Form) Once() *Form {
+	f.once = true
+	return &f
+}
+
+// Token issues a one-time token for a Form made with Once which is
+// displayed by hand rather than with Display. It has to be submitted in
+// a hidden input named OnceTokenField.
+func (f Form) Token() string {
+	return issueToken()
+}
+
+// checkToken consumes the one-time token submitted with input.
+func (f Form) checkToken(input map[string]interface{}) bool {
+	token, _ := input[OnceTokenField].([]string)
+	return len(token) > 0 && useToken(token[0])
+}
+
+// APIToken returns a copy of the Form which lets programmatic clients
+// skip the token added by Once, by sending a token in the header named
+// header which check accepts. Browsers submitting the form still need the
+// token from the page, while scripts using the same Form, and the same
+// view, don't have to scrape it first.
+//
+// Example:
+//
+//	var PostForm = forms.NewForm(md, fields...).Once().APIToken("X-API-Token",
+//		func(token string, req *http.Request) bool {
+//			return validKey(token)
+//		})
+func (f Form) APIToken(header string, check func(token string, req *http.Request) bool) *Form {
+	f.apiHeader = header
+	f.apiCheck = check
+	return &f
+}
+
+// apiClient reports whether req carries an API token which the Form
+// accepts.
+func (f Form) apiClient(req *http.Request) bool {
+	if f.apiCheck == nil {
+		return false
+	}
+	token := req.Header.Get(f.apiHeader)
+	return token != "" && f.apiCheck(token, req)
+}
+
+// SeeOther redirects the browser to to with a 303, so that refreshing the
+// page it lands on doesn't submit the form again.
+//
+// Example:
+//
+//	func Signup(w http.ResponseWriter, req *http.Request) (string, int) {
+//		form := SignupForm.Validate(req)
+//		if !form.Valid() {
+//			return form.Display(), http.StatusOK
+//		}
+//		...
+//		return forms.SeeOther("/welcome/")
+//	}
+func SeeOther(to string) (string, int) {
+	return to, http.StatusSeeOther
+}
+
+// rateLimiter counts the submissions for each key within a window.
+type rateLimiter struct {
+	sync.Mutex
+	limit  int
+	window time.Duration
+	key    func(*http.Request) string
+	seen   map[string][]time.Time
+}
+
+// allow records a submission from req, reporting whether it's within
+// the limit. Keys are only held as hashes, so client addresses aren't
+// kept in memory.
+func (l *rateLimiter) allow(req *http.Request) bool {
+	sum := sha256.Sum256([]byte(l.key(req)))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	l.Lock()
+	defer l.Unlock()
+	for k, times := range l.seen {
+		if len(times) > 0 && now.Sub(times[len(times)-1]) > l.window {
+			delete(l.seen, k)
+		}
+	}
+	var recent []time.Time
+	for _, t := range l.seen[key] {
+		if now.Sub(t) <= l.window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.limit {
+		l.seen[key] = recent
+		return false
+	}
+	l.seen[key] = append(recent, now)
+	return true
+}
+
+// RemoteIP identifies a client by the IP address it connected from. It's
+// the default key for RateLimit.
+func RemoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// RateLimit returns a copy of the Form which accepts at most limit
+// submissions within window from each client, failing the rest with
+// TooManySubmissionsMessage. Clients are told apart by key, which defaults to
+// RemoteIP and could instead return a session ID.
+//
+// Example:
+//
+//	var ContactForm = forms.NewForm(md, fields...).RateLimit(5, time.Hour, nil)
+func (f Form) RateLimit(limit int, window time.Duration, key func(*http.Request) string) *Form {
+	if key == nil {
+		key = RemoteIP
+	}
+	f.limiter = &rateLimiter{
+		limit:  limit,
+		window: window,
+		key:    key,
+		seen:   make(map[string][]time.Time),
+	}
+	return &f
+}
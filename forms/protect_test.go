@@ -0,0 +1,160 @@
+package forms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var tokenre = regexp.MustCompile(`name="` + OnceTokenField + `" value="([0-9a-f]+)"`)
+
+// displayedToken returns the one-time token in the rendered form.
+func displayedToken(t *testing.T, form *Form) string {
+	m := tokenre.FindStringSubmatch(form.Display())
+	if m == nil {
+		t.Fatalf("no token in %s", form.Display())
+	}
+	return m[1]
+}
+
+func TestOnce(t *testing.T) {
+	form := NewForm(NewFormMetadata("post", "/", "POST", true), TextField("title", "Title", 20)).Once()
+	submit := func(token string) map[string]string {
+		values := url.Values{"title": {"hello"}}
+		if token != "" {
+			values.Set(OnceTokenField, token)
+		}
+		return form.Validate(post(values)).Errors()
+	}
+
+	token := displayedToken(t, form)
+	if displayedToken(t, form) == token {
+		t.Error("got the same token twice")
+	}
+	for _, test := range []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"first", token, ""},
+		{"resubmitted", token, AlreadySubmittedMessage},
+		{"missing", "", AlreadySubmittedMessage},
+		{"made up", "0123456789abcdef0123456789abcdef", AlreadySubmittedMessage},
+		{"by hand", form.Token(), ""},
+	} {
+		if got := submit(test.token)[""]; got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	// tokens expire after OnceTokenTTL.
+	defer func(ttl time.Duration) { OnceTokenTTL = ttl }(OnceTokenTTL)
+	OnceTokenTTL = -time.Second
+	if got := submit(displayedToken(t, form))[""]; got != AlreadySubmittedMessage {
+		t.Errorf("expired: got %q", got)
+	}
+
+	// forms without Once don't display a token.
+	plain := NewForm(NewFormMetadata("post", "/", "POST", true), TextField("title", "Title", 20))
+	if tokenre.MatchString(plain.Display()) {
+		t.Errorf("got a token in %s", plain.Display())
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	form := NewForm(NewFormMetadata("post", "/", "POST", true), TextField("title", "Title", 20)).
+		Once().
+		APIToken("X-API-Token", func(token string, req *http.Request) bool {
+			return token == "key"
+		})
+	for _, test := range []struct {
+		header string
+		token  bool
+		valid  bool
+	}{
+		{"key", false, true},
+		{"wrong", false, false},
+		{"", false, false},
+		// clients without a good key can still use the page's token.
+		{"wrong", true, true},
+	} {
+		values := url.Values{"title": {"hello"}}
+		if test.token {
+			values.Set(OnceTokenField, displayedToken(t, form))
+		}
+		req := post(values)
+		if test.header != "" {
+			req.Header.Set("X-API-Token", test.header)
+		}
+		if got := form.Validate(req).Valid(); got != test.valid {
+			t.Errorf("%q with token %v: got %v, want %v", test.header, test.token, got, test.valid)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	form := NewForm(NewFormMetadata("contact", "/", "POST", true), TextField("msg", "Message", 20)).
+		RateLimit(2, time.Hour, nil)
+	submit := func(addr string) string {
+		req := post(url.Values{"msg": {"hi"}})
+		req.RemoteAddr = addr
+		return form.Validate(req).Errors()[""]
+	}
+	for i, test := range []struct {
+		addr string
+		want string
+	}{
+		{"192.0.2.1:1000", ""},
+		// the port doesn't matter.
+		{"192.0.2.1:2000", ""},
+		{"192.0.2.1:3000", TooManySubmissionsMessage},
+		{"192.0.2.2:1000", ""},
+	} {
+		if got := submit(test.addr); got != test.want {
+			t.Errorf("%d, %s: got %q, want %q", i, test.addr, got, test.want)
+		}
+	}
+
+	// submissions older than the window don't count.
+	l := form.limiter
+	l.window = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	if got := submit("192.0.2.1:4000"); got != "" {
+		t.Errorf("after the window: got %q", got)
+	}
+	l.Lock()
+	if len(l.seen) != 1 {
+		t.Errorf("got %d keys, want the stale ones dropped", len(l.seen))
+	}
+	for key := range l.seen {
+		if key == "192.0.2.1" {
+			t.Error("got the client's address in memory")
+		}
+	}
+	l.Unlock()
+}
+
+func TestRemoteIP(t *testing.T) {
+	for _, test := range []struct {
+		addr, want string
+	}{
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{"[2001:db8::1]:1234", "2001:db8::1"},
+		{"not an address", "not an address"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.addr
+		if got := RemoteIP(req); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestSeeOther(t *testing.T) {
+	if to, code := SeeOther("/done/"); to != "/done/" || code != http.StatusSeeOther {
+		t.Errorf("got %q and %d", to, code)
+	}
+}
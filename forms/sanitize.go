@@ -0,0 +1,224 @@
+package forms
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Sanitizer cleans a submitted value before it's converted, so content
+// from users can be passed on to templates or stored in the CMS without
+// it being able to run script.
+type Sanitizer func(value string) string
+
+// WithSanitizer makes Convert pass the Field's value, or each of its
+// values, through s. Validators still see the value as it was submitted.
+//
+// Example:
+//
+//	forms.TextField("comment", "Comment", 2000, forms.WithSanitizer(forms.BasicHTML.Sanitize))
+func WithSanitizer(s Sanitizer) Option {
+	return func(o *fieldOptions) {
+		o.sanitizer = s
+	}
+}
+
+// EscapeHTML is a Sanitizer which escapes every character with a meaning
+// in HTML, for values which are only ever shown as text. Values escaped
+// with it have to be marked as template.HTML when they're rendered, or
+// html/template will escape them a second time.
+var EscapeHTML Sanitizer = html.EscapeString
+
+// HTMLPolicy is an allow-list of the elements, and their attributes,
+// which may be kept in submitted HTML.
+type HTMLPolicy map[string][]string
+
+// BasicHTML allows simple formatting and links.
+var BasicHTML = HTMLPolicy{
+	"a":          {"href", "title"},
+	"b":          nil,
+	"blockquote": nil,
+	"br":         nil,
+	"code":       nil,
+	"em":         nil,
+	"i":          nil,
+	"li":         nil,
+	"ol":         nil,
+	"p":          nil,
+	"pre":        nil,
+	"strong":     nil,
+	"ul":         nil,
+}
+
+var (
+	tagre    = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'>]+))?)*)\s*/?>`)
+	attrre   = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+	schemere = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*):`)
+)
+
+// voidElements have no closing tag.
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true, "wbr": true}
+
+// scriptElements are dropped along with everything in them, as their
+// content is code rather than text.
+var scriptElements = map[string]bool{"script": true, "style": true}
+
+// urlAttributes are the attributes which are checked by safeURL.
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+// Sanitize keeps the elements and attributes allowed by the policy and
+// drops every other tag, comment and the content of scripts, escaping
+// the text around them. Links may only be to http, https and mailto URLs
+// or relative ones, and elements left open are closed.
+func (p HTMLPolicy) Sanitize(s string) string {
+	var out strings.Builder
+	var open []string
+	skip := ""
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			i = len(s)
+		}
+		if skip == "" {
+			out.WriteString(html.EscapeString(html.UnescapeString(s[:i])))
+		}
+		if s = s[i:]; s == "" {
+			break
+		}
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				break
+			}
+			s = s[end+len("-->"):]
+			continue
+		}
+		m := tagre.FindStringSubmatch(s)
+		if m == nil {
+			if skip == "" {
+				out.WriteString("&lt;")
+			}
+			s = s[1:]
+			continue
+		}
+		s = s[len(m[0]):]
+		closing, name := m[1] == "/", strings.ToLower(m[2])
+		if skip != "" {
+			if closing && name == skip {
+				skip = ""
+			}
+			continue
+		}
+		if scriptElements[name] && !closing {
+			skip = name
+			continue
+		}
+		attrs, ok := p[name]
+		if !ok {
+			continue
+		}
+		if closing {
+			// close anything left open inside the element, and drop
+			// closing tags which don't match one.
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == name {
+					for _, inner := range reverse(open[j:]) {
+						out.WriteString("</" + inner + ">")
+					}
+					open = open[:j]
+					break
+				}
+			}
+			continue
+		}
+		out.WriteString("<" + name)
+		for _, a := range attrre.FindAllStringSubmatch(m[3], -1) {
+			attr, value := strings.ToLower(a[1]), html.UnescapeString(a[2]+a[3]+a[4])
+			if !allowed(attrs, attr) || urlAttributes[attr] && !safeURL(value) {
+				continue
+			}
+			out.WriteString(" " + attr + `="` + html.EscapeString(value) + `"`)
+		}
+		out.WriteString(">")
+		if !voidElements[name] {
+			open = append(open, name)
+		}
+	}
+	for _, name := range reverse(open) {
+		out.WriteString("</" + name + ">")
+	}
+	return out.String()
+}
+
+func allowed(attrs []string, attr string) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+func reverse(names []string) []string {
+	r := make([]string, len(names))
+	for i, name := range names {
+		r[len(names)-1-i] = name
+	}
+	return r
+}
+
+// safeURL reports whether u is relative, or an http, https or mailto URL.
+// Browsers ignore whitespace and control characters in a scheme, so they
+// are too.
+func safeURL(u string) bool {
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	m := schemere.FindStringSubmatch(u)
+	if m == nil {
+		return true
+	}
+	switch strings.ToLower(m[1]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// sanitized wraps a Field whose values are passed through a Sanitizer,
+// see WithSanitizer.
+type sanitized struct {
+	Field
+	sanitizer Sanitizer
+}
+
+// sanitize wraps field if opts give it a Sanitizer.
+func sanitize(field Field, opts []Option) Field {
+	var o fieldOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.sanitizer == nil {
+		return field
+	}
+	return sanitized{field, o.sanitizer}
+}
+
+func (s sanitized) Convert(key interface{}, req *http.Request) interface{} {
+	switch value := s.Field.Convert(key, req).(type) {
+	case string:
+		return s.sanitizer(value)
+	case []string:
+		clean := make([]string, len(value))
+		for i, v := range value {
+			clean[i] = s.sanitizer(v)
+		}
+		return clean
+	default:
+		return value
+	}
+}
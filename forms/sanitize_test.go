@@ -0,0 +1,76 @@
+package forms
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"a < b & c", "a &lt; b &amp; c"},
+		{"already &amp; escaped", "already &amp; escaped"},
+		{"<p>Hello <b>there</b></p>", "<p>Hello <b>there</b></p>"},
+		{"<P CLASS=x>shouting</P>", "<p>shouting</p>"},
+		{`<a href="https://example.com" title='t' onclick="evil()">link</a>`,
+			`<a href="https://example.com" title="t">link</a>`},
+		{`<a href="/relative">link</a>`, `<a href="/relative">link</a>`},
+		{`<a href="javascript:alert(1)">link</a>`, `<a>link</a>`},
+		{`<a href="java&#x09;script:alert(1)">link</a>`, `<a>link</a>`},
+		{`<a href=" JavaScript:alert(1)">link</a>`, `<a>link</a>`},
+		{`<a href="mailto:me@example.com">mail</a>`, `<a href="mailto:me@example.com">mail</a>`},
+		{`<a href="/x" title="&quot;><script>">q</a>`, `<a href="/x" title="&#34;&gt;&lt;script&gt;">q</a>`},
+		{"<script>alert(1)</script>after", "after"},
+		{"<style>p { color: red }</style>text", "text"},
+		{"<div><span>unknown</span></div>", "unknown"},
+		{"<img src=x onerror=alert(1)>", ""},
+		{"before<!-- comment -->after", "beforeafter"},
+		{"<!-- unterminated", ""},
+		{"line<br>break<br/>", "line<br>break<br>"},
+		{"<ul><li>one<li>two</ul>", "<ul><li>one<li>two</li></li></ul>"},
+		{"<em>unclosed", "<em>unclosed</em>"},
+		{"stray</b> close", "stray close"},
+		{"<em><b>crossed</em></b>", "<em><b>crossed</b></em>"},
+		{"1 <2 and 3> 2", "1 &lt;2 and 3&gt; 2"},
+	} {
+		if got := BasicHTML.Sanitize(test.in); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestEscapeHTML(t *testing.T) {
+	if got, want := EscapeHTML(`<b>"bold" & 'brash'</b>`), "&lt;b&gt;&#34;bold&#34; &amp; &#39;brash&#39;&lt;/b&gt;"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWithSanitizer(t *testing.T) {
+	comment := TextField("comment", "Comment", 100,
+		WithSanitizer(BasicHTML.Sanitize),
+		WithValidator(Matches(`<script>`)),
+	)
+	tags := With(CheckField("tags", 0, Choice("Go", "<go>", false)), WithSanitizer(EscapeHTML))
+	form := NewForm(NewFormMetadata("comment", "/", "POST", false), comment, tags)
+
+	// validators see the value as it was submitted, and Convert the
+	// sanitized value.
+	b := form.Validate(post(url.Values{"comment": {"<script>x</script>hi"}, "tags": {"<go>"}}))
+	want := map[string]interface{}{"comment": "hi", "tags": []string{"&lt;go&gt;"}}
+	if got := b.Convert(); !b.Valid() || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v and %v, want %v", got, b.Errors(), want)
+	}
+	// and the sanitized Field is still displayed as the one it wraps.
+	if got := comment.Display(); got != `Comment: <input type="text" name="comment" />` {
+		t.Errorf("got %s", got)
+	}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	if got := With(RangeField("r", "R", 0, 1, 0), WithSanitizer(EscapeHTML)).Convert([]string{"1"}, req); got != 1.0 {
+		t.Errorf("got %#v, want values which aren't strings left alone", got)
+	}
+}
@@ -0,0 +1,145 @@
+package forms
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sync"
+)
+
+// The templates used to display each type of Field, and the Form itself.
+// They can be replaced with SetTemplate or LoadTemplates to restyle every
+// form in a project at once.
+var defaultTemplates = map[string]string{
+	"form.html": `<form name="{{.Name}}" action="{{.Action}}" method="{{.Method}}"` +
+		`{{if .Enctype}} enctype="{{.Enctype}}"{{end}}>` +
+		`{{.Summary}}` +
+		`{{if .Token}}<input type="hidden" name="{{.TokenField}}" value="{{.Token}}" />{{end}}` +
+		`{{range .Fields}}{{.}}<br/>{{end}}` +
+		`{{if .Submit}}<input type="submit" value="Submit">{{end}}</form>`,
+	"text.html":     `{{.Label}}: <input type="text" name="{{.Name}}" />`,
+	"password.html": `{{.Label}}: <input type="password" name="{{.Name}}" />`,
+	"file.html":     `{{.Label}}: <input type="file" name="{{.Name}}" />`,
+	"color.html":    `{{.Label}}: <input type="color" name="{{.Name}}" />`,
+	"range.html": `{{.Label}}: <input type="range" name="{{.Name}}"` +
+		` min="{{.Min}}" max="{{.Max}}" step="{{.Step}}" />`,
+	"tel.html":    `{{.Label}}: <input type="tel" name="{{.Name}}" pattern="{{.Pattern}}" />`,
+	"search.html": `{{.Label}}: <input type="search" name="{{.Name}}" />`,
+	"radio.html": `{{range .Choices}}{{.Label}}: <input type="radio" name="{{$.Name}}" value="{{.Value}}"` +
+		`{{if .Checked}} checked="checked"{{end}} /><br />{{end}}`,
+	"checkbox.html": `{{range .Choices}}{{.Label}}: <input type="checkbox" name="{{$.Name}}" value="{{.Value}}"` +
+		`{{if .Checked}} checked="checked"{{end}} /><br />{{end}}`,
+	"combo.html": `{{.Label}}: <select name="{{.Name}}">` +
+		`{{range .Choices}}<option value="{{.Value}}">{{.Label}}</option>{{end}}</select>`,
+	"conditional.html": `<div data-depends-on="{{.DependsOn}}" data-depends-values="{{.Values}}">` +
+		`{{.Field}}</div>`,
+	"errors.html": `<div class="{{.Class}}" role="alert" aria-labelledby="{{.ID}}" tabindex="-1">` +
+		`<p id="{{.ID}}">{{.Title}}</p><ul>{{range .Errors}}<li>` +
+		`{{if .Field}}<a href="#{{.ID}}">{{.Field}}: {{.Message}}</a>{{else}}{{.Message}}{{end}}` +
+		`</li>{{end}}</ul></div>`,
+	"field_error.html": `<span id="{{.ID}}" class="{{.Class}}">{{.Message}}</span>`,
+	"invalid.html": `<div id="{{.ID}}" class="{{.Class}}" role="group" aria-invalid="true"` +
+		` aria-describedby="{{.ErrorID}}">{{.Field}}{{.Error}}</div>`,
+}
+
+var (
+	templateLock sync.RWMutex
+	sources      = defaultTemplates
+	templates    = template.Must(parseTemplates(sources))
+)
+
+// parseTemplates parses each of sources into a new set. A set can't be
+// parsed into again once it has been executed, so every change to the
+// templates builds another from the sources.
+func parseTemplates(sources map[string]string) (*template.Template, error) {
+	t := template.New("forms")
+	for name, text := range sources {
+		if _, err := t.New(name).Parse(text); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// SetTemplate replaces the template used for name, e.g. "text.html", with
+// text. The template is executed with a value holding the Name and Label
+// of the field along with anything specific to its type, such as Choices.
+func SetTemplate(name, text string) error {
+	return LoadBundle(map[string]string{name: text})
+}
+
+// LoadTemplates replaces the templates with those in the files matching
+// pattern, each being named after its file, e.g. "templates/forms/*.html".
+// Templates which aren't found keep their current definition.
+func LoadTemplates(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("forms: pattern matches no files: %#q", pattern)
+	}
+	bundle := make(map[string]string)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		bundle[filepath.Base(file)] = string(data)
+	}
+	return LoadBundle(bundle)
+}
+
+// LoadBundle replaces the templates with those in bundle, keyed by name,
+// e.g. "text.html", so they can be compiled into the binary rather than
+// read with LoadTemplates. A wedge.TemplateBundle can be passed directly.
+// Templates which aren't in bundle keep their current definition, and none
+// are replaced if any of them fail to parse.
+func LoadBundle(bundle map[string]string) error {
+	templateLock.Lock()
+	defer templateLock.Unlock()
+	merged := make(map[string]string, len(sources)+len(bundle))
+	for name, text := range sources {
+		merged[name] = text
+	}
+	for name, text := range bundle {
+		merged[name] = text
+	}
+	t, err := parseTemplates(merged)
+	if err != nil {
+		return err
+	}
+	sources, templates = merged, t
+	return nil
+}
+
+// choiceData is how a choice_options value is presented to templates.
+type choiceData struct {
+	Label   string
+	Value   string
+	Checked bool
+}
+
+func choicesData(choices []choice_options) []choiceData {
+	var data []choiceData
+	for _, choice := range choices {
+		data = append(data, choiceData{choice.choice, choice.name, choice.checked != ""})
+	}
+	return data
+}
+
+// render executes the template called name with data. Failures are logged
+// and rendered as an empty string, in the same manner as Validate.
+func render(name string, data interface{}) string {
+	templateLock.RLock()
+	defer templateLock.RUnlock()
+	buf := new(bytes.Buffer)
+	if err := templates.ExecuteTemplate(buf, name, data); err != nil {
+		log.Println("Error rendering form template:", err)
+		return ""
+	}
+	return buf.String()
+}
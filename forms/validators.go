@@ -0,0 +1,181 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Validator is an extra check on the value of a Field. It's given the
+// value as returned by the Field's Convert method, so a TextField gives
+// a string and a TypedChoice its typed value, and returns an error
+// describing why the value isn't acceptable. The error is logged along
+// with the Field's name, so it shouldn't include the value itself, which
+// may be a password.
+type Validator func(value interface{}, req *http.Request) error
+
+// FormValidator is a check across several Fields of a bound form, such as
+// two passwords matching. It's only run once every Field is valid.
+type FormValidator func(b *BoundForm) error
+
+// Option configures a built-in Field.
+type Option func(*fieldOptions)
+
+type fieldOptions struct {
+	validators []Validator
+	sanitizer  Sanitizer
+}
+
+// WithValidator adds validators which are run, in order, after the Field's
+// built-in checks have passed.
+//
+// Example:
+//
+//	forms.TextField("user", "Username", 10, forms.WithValidator(forms.NotBlank()))
+func WithValidator(validators ...Validator) Option {
+	return func(o *fieldOptions) {
+		o.validators = append(o.validators, validators...)
+	}
+}
+
+func applyOptions(opts []Option) []Validator {
+	var o fieldOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.validators
+}
+
+// runValidators converts key with field and checks it against validators,
+// logging the first failure.
+func runValidators(field Field, validators []Validator, key interface{}, req *http.Request) bool {
+	if len(validators) == 0 {
+		return true
+	}
+	value := field.Convert(key, req)
+	for _, validator := range validators {
+		if err := validator(value, req); err != nil {
+			log.Printf("%s didn't validate: %s\n", field.Name(), err)
+			return false
+		}
+	}
+	return true
+}
+
+// validated wraps a Field with extra validators, see With.
+type validated struct {
+	Field
+	validators []Validator
+}
+
+// With applies opts to any Field, for those whose constructors don't
+// take options themselves, such as the choice fields and custom Fields.
+//
+// Example:
+//
+//	forms.With(forms.ComboField("author", "Author", choices...),
+//	    forms.WithValidator(forms.Unique(authorTaken)))
+func With(field Field, opts ...Option) Field {
+	return sanitize(validated{field, applyOptions(opts)}, opts)
+}
+
+func (v validated) Validate(key interface{}, req *http.Request) bool {
+	return v.Field.Validate(key, req) && runValidators(v.Field, v.validators, key, req)
+}
+
+// unwrap returns the Field underneath any validators and sanitizers added
+// with options.
+func unwrap(field Field) Field {
+	for {
+		switch f := field.(type) {
+		case validated:
+			field = f.Field
+		case sanitized:
+			field = f.Field
+		default:
+			return field
+		}
+	}
+}
+
+// NotBlank rejects empty values: strings which are only whitespace, nils
+// and empty slices.
+func NotBlank() Validator {
+	return func(value interface{}, req *http.Request) error {
+		blank := value == nil
+		switch v := value.(type) {
+		case string:
+			blank = strings.TrimSpace(v) == ""
+		case []string:
+			blank = len(v) == 0
+		case []interface{}:
+			blank = len(v) == 0
+		}
+		if blank {
+			return errors.New("may not be blank")
+		}
+		return nil
+	}
+}
+
+// InRange requires a number between min and max inclusive. Strings are
+// parsed as numbers, so it can be used on a TextField directly.
+func InRange(min, max float64) Validator {
+	return func(value interface{}, req *http.Request) error {
+		n, ok := toFloat(value)
+		if !ok {
+			return errors.New("is not a number")
+		}
+		if n < min || n > max {
+			return fmt.Errorf("is not between %v and %v", min, max)
+		}
+		return nil
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	if s, ok := value.(string); ok {
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return n, err == nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// Matches requires a string matching the regular expression re, which
+// will panic if it cannot be compiled.
+func Matches(re string) Validator {
+	match := regexp.MustCompile(re)
+	return func(value interface{}, req *http.Request) error {
+		s, ok := value.(string)
+		if !ok || !match.MatchString(s) {
+			return fmt.Errorf("does not match %s", re)
+		}
+		return nil
+	}
+}
+
+// Unique rejects values for which exists returns true, e.g. a username
+// which is already taken. exists is given the request so it can reach
+// any per-request state it needs.
+func Unique(exists func(value interface{}, req *http.Request) bool) Validator {
+	return func(value interface{}, req *http.Request) error {
+		if exists(value, req) {
+			return errors.New("is already taken")
+		}
+		return nil
+	}
+}
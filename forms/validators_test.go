@@ -0,0 +1,71 @@
+package forms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	taken := Unique(func(value interface{}, req *http.Request) bool {
+		return value == "admin"
+	})
+	for _, test := range []struct {
+		name      string
+		validator Validator
+		value     interface{}
+		want      bool
+	}{
+		{"not blank", NotBlank(), "x", true},
+		{"blank", NotBlank(), " \t", false},
+		{"nil", NotBlank(), nil, false},
+		{"no strings", NotBlank(), []string{}, false},
+		{"no values", NotBlank(), []interface{}{}, false},
+		{"zero", NotBlank(), 0, true},
+		{"in range", InRange(1, 10), 10, true},
+		{"below range", InRange(1, 10), 0.5, false},
+		{"string in range", InRange(1, 10), " 5 ", true},
+		{"uint above range", InRange(1, 10), uint8(11), false},
+		{"not a number", InRange(1, 10), "five", false},
+		{"matches", Matches(`^[a-z]+$`), "abc", true},
+		{"doesn't match", Matches(`^[a-z]+$`), "ABC", false},
+		{"matches non string", Matches(`.`), 1, false},
+		{"unique", taken, "bob", true},
+		{"taken", taken, "admin", false},
+	} {
+		if got := test.validator(test.value, httptest.NewRequest("GET", "/", nil)) == nil; got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	var seen []interface{}
+	record := func(value interface{}, req *http.Request) error {
+		seen = append(seen, value)
+		return nil
+	}
+	field := With(ComboField("author", "Author", TypedChoice("Bob", 1, false), TypedChoice("Ann", 2, false)),
+		WithValidator(record, InRange(2, 2)))
+	req := httptest.NewRequest("POST", "/", nil)
+	for _, test := range []struct {
+		value string
+		want  bool
+	}{
+		{"1", false},
+		{"2", true},
+		// the built-in checks run first.
+		{"3", false},
+	} {
+		if got := field.Validate([]string{test.value}, req); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.value, got, test.want)
+		}
+	}
+	// validators are given the converted value.
+	if len(seen) != 2 || seen[0] != 1 || seen[1] != 2 {
+		t.Errorf("got values %v", seen)
+	}
+	if unwrap(field).Name() != "author" {
+		t.Errorf("got %T", unwrap(field))
+	}
+}
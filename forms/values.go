@@ -0,0 +1,240 @@
+package forms
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values holds the converted values of a BoundForm, with getters which
+// save asserting on each of them. Getters return the zero value for a
+// Field which wasn't submitted, which Has tells apart from an empty one.
+type Values map[string]interface{}
+
+// TimeLayouts are the layouts Values.Time tries when it's not given any,
+// being those of the date and datetime-local inputs and RFC 3339.
+var TimeLayouts = []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339}
+
+// ConvertTyped is Convert returning Values.
+func (b *BoundForm) ConvertTyped() Values {
+	return Values(b.Convert())
+}
+
+// ConvertTyped is a shorthand for validating req and converting the
+// result, see Convert.
+func (f Form) ConvertTyped(req *http.Request) Values {
+	return f.Validate(req).ConvertTyped()
+}
+
+// Has reports whether the Field called name was submitted.
+func (v Values) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+// String returns the value of the Field called name as a string. Multiple
+// values, such as from a Check, are joined with commas.
+func (v Values) String(name string) string {
+	switch value := v[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []string:
+		return strings.Join(value, ",")
+	case *multipart.FileHeader:
+		return value.Filename
+	default:
+		return strings.Join(v.Strings(name), ",")
+	}
+}
+
+// Strings returns the values of the Field called name, such as the
+// choices ticked in a Check.
+func (v Values) Strings(name string) []string {
+	switch value := v[name].(type) {
+	case nil:
+		return nil
+	case []string:
+		return value
+	case []interface{}:
+		s := make([]string, len(value))
+		for i, item := range value {
+			s[i] = fmt.Sprint(item)
+		}
+		return s
+	case string:
+		return []string{value}
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+// Int returns the value of the Field called name as an int, or 0 if it
+// isn't a number. Fractions are truncated.
+func (v Values) Int(name string) int {
+	return int(v.Float(name))
+}
+
+// Float returns the value of the Field called name as a float64, or 0 if
+// it isn't a number.
+func (v Values) Float(name string) float64 {
+	switch value := v[name].(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	}
+	f, _ := strconv.ParseFloat(v.String(name), 64)
+	return f
+}
+
+// Bool returns the value of the Field called name as a bool. A Check with
+// anything ticked is true, as are "on", "true", "yes" and "1".
+func (v Values) Bool(name string) bool {
+	switch value := v[name].(type) {
+	case bool:
+		return value
+	case []string:
+		return len(value) > 0
+	case []interface{}:
+		return len(value) > 0
+	}
+	switch strings.ToLower(v.String(name)) {
+	case "on", "true", "yes", "1":
+		return true
+	}
+	return false
+}
+
+// Time parses the value of the Field called name with the first of
+// layouts, or TimeLayouts if none are given, which fits it. It returns
+// the zero time if none do.
+func (v Values) Time(name string, layouts ...string) time.Time {
+	if t, ok := v[name].(time.Time); ok {
+		return t
+	}
+	if len(layouts) == 0 {
+		layouts = TimeLayouts
+	}
+	s := v.String(name)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// File returns the file uploaded to the File called name, or nil.
+func (v Values) File(name string) *multipart.FileHeader {
+	f, _ := v[name].(*multipart.FileHeader)
+	return f
+}
+
+// Decode fills in the fields of the struct dst points to from the Values.
+// Each is filled from the Field named in its "form" tag, or its own name
+// in lower case, and fields tagged "-" are skipped, as are those whose
+// Field wasn't submitted. Fields may be strings, numbers, bools, string
+// slices, time.Time or *multipart.FileHeader.
+//
+// Example:
+//
+//	var signup struct {
+//		Email    string
+//		Age      int
+//		Topics   []string  `form:"topics"`
+//		Birthday time.Time `form:"dob"`
+//	}
+//	if err := form.ConvertTyped().Decode(&signup); err != nil {
+//		...
+//	}
+func (v Values) Decode(dst interface{}) error {
+	ptr := reflect.ValueOf(dst)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("forms: Decode needs a pointer to a struct, not %T", dst)
+	}
+	s := ptr.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if !v.Has(name) {
+			continue
+		}
+		if err := v.decodeField(s.Field(i), name); err != nil {
+			return fmt.Errorf("forms: field %s: %v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	fileType = reflect.TypeOf(&multipart.FileHeader{})
+)
+
+func (v Values) decodeField(f reflect.Value, name string) error {
+	switch f.Type() {
+	case timeType:
+		t := v.Time(name)
+		if t.IsZero() && v.String(name) != "" {
+			return fmt.Errorf("can't parse %q as a time", v.String(name))
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	case fileType:
+		f.Set(reflect.ValueOf(v.File(name)))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(v.String(name))
+	case reflect.Bool:
+		f.SetBool(v.Bool(name))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(v.String(name), 10, 64)
+		if err != nil {
+			n = int64(v.Float(name))
+		}
+		if f.OverflowInt(n) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Float(name)
+		if n < 0 || f.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%v is out of range", n)
+		}
+		f.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f.SetFloat(v.Float(name))
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		strs := v.Strings(name)
+		slice := reflect.MakeSlice(f.Type(), len(strs), len(strs))
+		for i, s := range strs {
+			slice.Index(i).SetString(s)
+		}
+		f.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
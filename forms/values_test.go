@@ -0,0 +1,106 @@
+package forms
+
+import (
+	"mime/multipart"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValues(t *testing.T) {
+	file := &multipart.FileHeader{Filename: "cv.pdf"}
+	v := Values{
+		"name":   "Ann",
+		"age":    "42",
+		"score":  2.5,
+		"topics": []string{"go", "web"},
+		"ids":    []interface{}{1, 2},
+		"agree":  "on",
+		"dob":    "1990-05-17",
+		"at":     "2024-01-02T15:04",
+		"cv":     file,
+		"empty":  []string{},
+	}
+	for _, test := range []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"string", v.String("name"), "Ann"},
+		{"joined", v.String("topics"), "go,web"},
+		{"file name", v.String("cv"), "cv.pdf"},
+		{"typed joined", v.String("ids"), "1,2"},
+		{"missing string", v.String("nope"), ""},
+		{"strings", v.Strings("topics"), []string{"go", "web"}},
+		{"typed strings", v.Strings("ids"), []string{"1", "2"}},
+		{"single strings", v.Strings("name"), []string{"Ann"}},
+		{"int", v.Int("age"), 42},
+		{"truncated int", v.Int("score"), 2},
+		{"not an int", v.Int("name"), 0},
+		{"float", v.Float("score"), 2.5},
+		{"bool", v.Bool("agree"), true},
+		{"ticked", v.Bool("topics"), true},
+		{"unticked", v.Bool("empty"), false},
+		{"not a bool", v.Bool("name"), false},
+		{"date", v.Time("dob"), time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"datetime", v.Time("at"), time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"layout", v.Time("dob", "02/01/2006"), time.Time{}},
+		{"file", v.File("cv"), file},
+		{"has", v.Has("empty"), true},
+		{"hasn't", v.Has("nope"), false},
+	} {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var dst struct {
+		Name     string
+		Age      uint8
+		Score    float64
+		Agree    bool
+		Topics   []string
+		Birthday time.Time `form:"dob"`
+		CV       *multipart.FileHeader
+		Skipped  string `form:"-"`
+		Missing  int
+		private  string
+	}
+	dst.Missing = 7
+	v := Values{
+		"name":    "Ann",
+		"age":     "42",
+		"score":   "2.5",
+		"agree":   "yes",
+		"topics":  []string{"go"},
+		"dob":     "1990-05-17",
+		"cv":      &multipart.FileHeader{Filename: "cv.pdf"},
+		"skipped": "x",
+		"private": "x",
+	}
+	if err := v.Decode(&dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name != "Ann" || dst.Age != 42 || dst.Score != 2.5 || !dst.Agree || len(dst.Topics) != 1 ||
+		dst.Birthday.Year() != 1990 || dst.CV.Filename != "cv.pdf" || dst.Skipped != "" || dst.Missing != 7 || dst.private != "" {
+		t.Errorf("got %+v", dst)
+	}
+
+	for _, test := range []struct {
+		name   string
+		values Values
+		dst    interface{}
+	}{
+		{"not a pointer", v, dst},
+		{"out of range", Values{"age": "300"}, &dst},
+		{"negative", Values{"age": "-1"}, &dst},
+		{"bad time", Values{"dob": "yesterday"}, &dst},
+		{"unsupported", Values{"ids": "1"}, &struct{ IDs []int }{}},
+	} {
+		if err := test.values.Decode(test.dst); err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+	}
+}
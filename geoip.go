@@ -0,0 +1,298 @@
+package wedge
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+)
+
+// GeoResolver finds the country an IP address is in, as an ISO 3166 code
+// such as "GB". It returns "" if the address isn't known.
+type GeoResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+// SetGeoResolver makes every request carry the country of the client,
+// which is added to the request logs, counted in the statistics breakdown
+// and available to views, and to things such as rate limits, through
+// Country. Passing nil turns it off again.
+func (App *AppServer) SetGeoResolver(r GeoResolver) {
+	App.geo = r
+}
+
+// withCountry returns a shallow copy of req carrying the country of the
+// client at host.
+func withCountry(req *http.Request, host string, r GeoResolver) *http.Request {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return req
+	}
+	country, err := r.Country(ip)
+	if err != nil || country == "" {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), countryKey, country))
+}
+
+// Country returns the country code the request came from, or "" if no
+// GeoResolver is set or the address couldn't be resolved.
+func Country(req *http.Request) string {
+	country, _ := req.Context().Value(countryKey).(string)
+	return country
+}
+
+// MaxMindDB is a GeoResolver reading a MaxMind DB file, such as the
+// GeoLite2 Country database. The whole file is held in memory.
+type MaxMindDB struct {
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+}
+
+var mmdbMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// ErrBadMaxMindDB is returned for files which aren't valid MaxMind DBs.
+var ErrBadMaxMindDB = errors.New("wedge: invalid MaxMind DB")
+
+// OpenMaxMindDB reads the MaxMind DB at path.
+func OpenMaxMindDB(path string) (*MaxMindDB, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMaxMindDB(data)
+}
+
+// NewMaxMindDB parses the contents of a MaxMind DB file.
+func NewMaxMindDB(data []byte) (*MaxMindDB, error) {
+	i := bytes.LastIndex(data, mmdbMarker)
+	if i < 0 {
+		return nil, ErrBadMaxMindDB
+	}
+	db := &MaxMindDB{data: data}
+	meta, _, err := db.decode(uint(i+len(mmdbMarker)), 0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, ErrBadMaxMindDB
+	}
+	nodeCount, _ := m["node_count"].(uint64)
+	recordSize, _ := m["record_size"].(uint64)
+	ipVersion, _ := m["ip_version"].(uint64)
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, fmt.Errorf("wedge: unsupported MaxMind DB record size %d", recordSize)
+	}
+	db.nodeCount = uint(nodeCount)
+	db.recordSize = uint(recordSize)
+	db.ipVersion = uint(ipVersion)
+	treeSize := db.nodeCount * db.recordSize / 4
+	db.dataStart = treeSize + 16
+	if db.dataStart > uint(i) {
+		return nil, ErrBadMaxMindDB
+	}
+
+	// IPv4 addresses live under ::/96 in an IPv6 tree
+	if db.ipVersion == 6 {
+		for bit := 0; bit < 96 && db.ipv4Start < db.nodeCount; bit++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+// record reads the left (0) or right (1) record of node.
+func (db *MaxMindDB) record(node, side uint) uint {
+	b := db.data[node*db.recordSize/4:]
+	switch db.recordSize {
+	case 24:
+		b = b[side*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if side == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[side*4:]))
+	}
+}
+
+// Lookup returns the data the database holds for ip, or nil if there is
+// none.
+func (db *MaxMindDB) Lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	bits := ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		node = db.ipv4Start
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		return nil, nil
+	}
+	offset := node - db.nodeCount - 16
+	value, _, err := db.decode(db.dataStart+offset, db.dataStart)
+	return value, err
+}
+
+// Country returns the ISO code of the country ip is in, falling back to
+// the country it's registered to.
+func (db *MaxMindDB) Country(ip net.IP) (string, error) {
+	value, err := db.Lookup(ip)
+	if err != nil {
+		return "", err
+	}
+	record, _ := value.(map[string]interface{})
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := record[key].(map[string]interface{})
+		if code, ok := country["iso_code"].(string); ok {
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
+// decode reads the value at offset, returning it along with the offset
+// following it. Pointers are relative to base, the start of the section
+// holding the value.
+func (db *MaxMindDB) decode(offset, base uint) (interface{}, uint, error) {
+	if offset >= uint(len(db.data)) {
+		return nil, 0, ErrBadMaxMindDB
+	}
+	ctrl := db.data[offset]
+	offset++
+	kind := uint(ctrl >> 5)
+
+	if kind == 1 {
+		size := uint(ctrl>>3) & 3
+		if offset+size+1 > uint(len(db.data)) {
+			return nil, 0, ErrBadMaxMindDB
+		}
+		b := db.data[offset:]
+		var pointer uint
+		switch size {
+		case 0:
+			pointer = uint(ctrl&7)<<8 | uint(b[0])
+		case 1:
+			pointer = (uint(ctrl&7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 2:
+			pointer = (uint(ctrl&7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		case 3:
+			pointer = uint(binary.BigEndian.Uint32(b))
+		}
+		value, _, err := db.decode(base+pointer, base)
+		return value, offset + size + 1, err
+	}
+
+	if kind == 0 {
+		if offset >= uint(len(db.data)) {
+			return nil, 0, ErrBadMaxMindDB
+		}
+		kind = 7 + uint(db.data[offset])
+		offset++
+	}
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(db.data)) {
+			return nil, 0, ErrBadMaxMindDB
+		}
+		extra := uint(0)
+		for _, c := range db.data[offset : offset+n] {
+			extra = extra<<8 | uint(c)
+		}
+		size = [...]uint{29, 285, 65821}[n-1] + extra
+		offset += n
+	}
+
+	switch kind {
+	case 7: // map
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := db.decode(offset, base)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, next, err := db.decode(next, base)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, ErrBadMaxMindDB
+			}
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case 11: // array
+		a := make([]interface{}, size)
+		for i := range a {
+			value, next, err := db.decode(offset, base)
+			if err != nil {
+				return nil, 0, err
+			}
+			a[i] = value
+			offset = next
+		}
+		return a, offset, nil
+	case 14: // boolean, held in the size
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(db.data)) {
+		return nil, 0, ErrBadMaxMindDB
+	}
+	b := db.data[offset : offset+size]
+	offset += size
+	switch kind {
+	case 2: // UTF-8 string
+		return string(b), offset, nil
+	case 3: // double
+		if size != 8 {
+			return nil, 0, ErrBadMaxMindDB
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case 4: // bytes
+		return append([]byte(nil), b...), offset, nil
+	case 5, 6, 9: // unsigned integers
+		if size > 8 {
+			return nil, 0, ErrBadMaxMindDB
+		}
+		n := uint64(0)
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case 8: // int32
+		n := uint32(0)
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), offset, nil
+	case 10: // uint128, rarely used and returned as bytes
+		return append([]byte(nil), b...), offset, nil
+	case 15: // float
+		if size != 4 {
+			return nil, 0, ErrBadMaxMindDB
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	}
+	return nil, 0, fmt.Errorf("wedge: unknown MaxMind DB type %d", kind)
+}
@@ -0,0 +1,83 @@
+package wedge
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+// mmdbString and friends encode the parts of the MaxMind DB data format
+// needed to build a database by hand.
+func mmdbString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+func mmdbUint(n byte) []byte {
+	return []byte{5<<5 | 1, n}
+}
+
+func mmdbMap(pairs ...[]byte) []byte {
+	b := []byte{7<<5 | byte(len(pairs)/2)}
+	for _, p := range pairs {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// testMaxMindDB maps 0.0.0.0/1 to Australia, and has nothing for the
+// rest of the addresses.
+func testMaxMindDB() []byte {
+	var db bytes.Buffer
+	// one node of 24 bit records: left points at the data at offset 0,
+	// right is the node count, which means not found
+	db.Write([]byte{0, 0, 17, 0, 0, 1})
+	db.Write(make([]byte, 16))
+	db.Write(mmdbMap(mmdbString("country"), mmdbMap(mmdbString("iso_code"), mmdbString("AU"))))
+	db.Write(mmdbMarker)
+	db.Write(mmdbMap(
+		mmdbString("node_count"), mmdbUint(1),
+		mmdbString("record_size"), mmdbUint(24),
+		mmdbString("ip_version"), mmdbUint(4),
+	))
+	return db.Bytes()
+}
+
+func TestMaxMindDB(t *testing.T) {
+	db, err := NewMaxMindDB(testMaxMindDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"1.2.3.4":         "AU",
+		"127.255.255.255": "AU",
+		"128.0.0.1":       "",
+		"::1":             "",
+	}
+	for ip, want := range tests {
+		got, err := db.Country(net.ParseIP(ip))
+		if err != nil || got != want {
+			t.Errorf("%s: got (%q, %v), want %q", ip, got, err, want)
+		}
+	}
+
+	if _, err := NewMaxMindDB([]byte("not a database")); err != ErrBadMaxMindDB {
+		t.Errorf("got %v for an invalid database", err)
+	}
+}
+
+func TestCountry(t *testing.T) {
+	db, err := NewMaxMindDB(testMaxMindDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:5678"
+	if got := Country(withCountry(req, remoteHost(req), db)); got != "AU" {
+		t.Errorf("got %q, want AU", got)
+	}
+	req.RemoteAddr = "200.1.1.1:5678"
+	if got := Country(withCountry(req, remoteHost(req), db)); got != "" {
+		t.Errorf("got %q, want none", got)
+	}
+}
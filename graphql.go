@@ -0,0 +1,529 @@
+package wedge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// GraphQLRequest is a GraphQL operation as a client sent it.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQL configures an endpoint mounted with App.GraphQL. Wedge doesn't
+// implement GraphQL itself: the schema and its resolvers come from
+// whichever library the app uses, through Execute.
+type GraphQL struct {
+	// Execute runs op against the app's schema and returns its result,
+	// which is sent as JSON, such as a library's *Result with its data
+	// and errors. ctx is the request's, so resolvers see what
+	// middleware added to it, such as the user who is logged in.
+	Execute func(ctx context.Context, op GraphQLRequest) interface{}
+	// MaxDepth is how deeply fields may be nested in an operation, and
+	// MaxComplexity how many it may select in all, counting those of
+	// each fragment wherever it's spread. Operations over either are
+	// refused before they're executed. 0 is no limit.
+	MaxDepth, MaxComplexity int
+	// MaxBody is the size of the largest POST body read, 1MB by default.
+	MaxBody int64
+}
+
+// GraphQL mounts a GraphQL endpoint at the route pattern re. Operations
+// are taken from POSTs of JSON or application/graphql bodies, and from
+// the query string of GETs, which may only be queries. In debug mode,
+// see SetDebug, a browser's GET without a query gets the GraphiQL
+// playground instead.
+//
+// The route's options apply as they do to any other route, so it can be
+// given middleware which checks who's logged in, tags and so on. Each
+// operation is counted in the statistics by its type and name, as
+// "GraphQL => query Posts". Responses are never cached.
+//
+// Example:
+//
+//	App.GraphQL("^/graphql$", wedge.GraphQL{
+//		Execute: func(ctx context.Context, op wedge.GraphQLRequest) interface{} {
+//			return graphql.Do(graphql.Params{
+//				Schema:         schema,
+//				RequestString:  op.Query,
+//				OperationName:  op.OperationName,
+//				VariableValues: op.Variables,
+//				Context:        ctx,
+//			})
+//		},
+//		MaxDepth: 10,
+//	}, wedge.With(RequireLogin))
+func (App *AppServer) GraphQL(re string, g GraphQL, opts ...Option) *Rule {
+	if g.Execute == nil {
+		panic("wedge: GraphQL endpoint without an Execute function")
+	}
+	if g.MaxBody <= 0 {
+		g.MaxBody = 1 << 20
+	}
+	opts = append([]Option{Name("GraphQL"), Methods("GET", "POST")}, opts...)
+	u := Handle(re, func(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+		return App.serveGraphQL(ctx, w, req, &g)
+	}, opts...)
+	App.AddURLs(u)
+	return u
+}
+
+// serveGraphQL answers a request to a GraphQL endpoint.
+func (App *AppServer) serveGraphQL(ctx context.Context, w http.ResponseWriter, req *http.Request, g *GraphQL) error {
+	var op GraphQLRequest
+	switch req.Method {
+	case "GET":
+		query := req.URL.Query()
+		if query.Get("query") == "" && App.debug && strings.Contains(req.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, err := io.WriteString(w, graphiql)
+			return err
+		}
+		op.Query, op.OperationName = query.Get("query"), query.Get("operationName")
+		if v := query.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &op.Variables); err != nil {
+				return graphQLError(w, http.StatusBadRequest, "variables are not a JSON object")
+			}
+		}
+	case "POST":
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, g.MaxBody+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > g.MaxBody {
+			return graphQLError(w, http.StatusRequestEntityTooLarge, "the request is too large")
+		}
+		ctype, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if ctype == "application/graphql" {
+			op.Query, op.OperationName = string(body), req.URL.Query().Get("operationName")
+		} else if err := json.Unmarshal(body, &op); err != nil {
+			return graphQLError(w, http.StatusBadRequest, "the body is not a GraphQL request")
+		}
+	}
+	if strings.TrimSpace(op.Query) == "" {
+		return graphQLError(w, http.StatusBadRequest, "no query was given")
+	}
+
+	doc, err := parseGraphQL(op.Query)
+	if err != nil {
+		return graphQLError(w, http.StatusBadRequest, err.Error())
+	}
+	operation, err := doc.operation(op.OperationName)
+	if err != nil {
+		return graphQLError(w, http.StatusBadRequest, err.Error())
+	}
+	if req.Method == "GET" && operation.kind != "query" {
+		w.Header().Set("Allow", "POST")
+		return graphQLError(w, http.StatusMethodNotAllowed, operation.kind+"s must be sent with POST")
+	}
+	depth, complexity, err := doc.measure(operation.selections, map[string]bool{})
+	if err != nil {
+		return graphQLError(w, http.StatusBadRequest, err.Error())
+	}
+	if g.MaxDepth > 0 && depth > g.MaxDepth {
+		return graphQLError(w, http.StatusBadRequest,
+			fmt.Sprintf("the operation is nested %d deep, more than the %d allowed", depth, g.MaxDepth))
+	}
+	if g.MaxComplexity > 0 && complexity > g.MaxComplexity {
+		return graphQLError(w, http.StatusBadRequest,
+			fmt.Sprintf("the operation selects %d fields, more than the %d allowed", complexity, g.MaxComplexity))
+	}
+	if App.stat_map != nil {
+		App.incrementStats(strings.TrimSpace("GraphQL => " + operation.kind + " " + operation.name))
+	}
+
+	b, err := json.Marshal(g.Execute(ctx, op))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// graphQLError answers with message in the errors of a GraphQL response.
+func graphQLError(w http.ResponseWriter, status int, message string) error {
+	b, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := w.Write(append(b, '\n'))
+	return err
+}
+
+// graphiql is the playground page served in debug mode.
+const graphiql = `<!DOCTYPE html>
+<html>
+<head>
+<title>GraphiQL</title>
+<link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+</head>
+<body style="margin: 0">
+<div id="graphiql" style="height: 100vh"></div>
+<script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+<script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+<script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+<script>
+ReactDOM.createRoot(document.getElementById("graphiql")).render(
+	React.createElement(GraphiQL, {fetcher: GraphiQL.createFetcher({url: window.location.pathname})}));
+</script>
+</body>
+</html>
+`
+
+// gqlDocument is as much of a parsed GraphQL document as is needed to
+// pick its operation and measure it.
+type gqlDocument struct {
+	operations []gqlOperation
+	fragments  map[string][]gqlSelection
+	// measured memoizes the depth and complexity of fragments, so that
+	// those spread many times aren't measured each time.
+	measured map[string][2]int
+}
+
+type gqlOperation struct {
+	kind, name string
+	selections []gqlSelection
+}
+
+// gqlSelection is a field, with its own selections if it has any, an
+// inline fragment, or the spread of a named fragment.
+type gqlSelection struct {
+	field      bool
+	spread     string
+	selections []gqlSelection
+}
+
+// gqlParser parses the tokens of a document.
+type gqlParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseGraphQL(query string) (*gqlDocument, error) {
+	tokens, err := lexGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+	doc := &gqlDocument{fragments: map[string][]gqlSelection{}, measured: map[string][2]int{}}
+	for p.peek() != "" {
+		switch tok := p.peek(); tok {
+		case "{":
+			set, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, gqlOperation{kind: "query", selections: set})
+		case "query", "mutation", "subscription":
+			p.pos++
+			op := gqlOperation{kind: tok}
+			if isGraphQLName(p.peek()) {
+				op.name = p.next()
+			}
+			if p.peek() == "(" {
+				if err := p.skipBalanced(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.directives(); err != nil {
+				return nil, err
+			}
+			if op.selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case "fragment":
+			p.pos++
+			name := p.next()
+			if !isGraphQLName(name) || p.next() != "on" || !isGraphQLName(p.next()) {
+				return nil, errors.New("syntax error in fragment " + name)
+			}
+			if err := p.directives(); err != nil {
+				return nil, err
+			}
+			set, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = set
+		default:
+			return nil, fmt.Errorf("syntax error: unexpected %q", tok)
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("the document has no operations")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *gqlParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if p.next() != "{" {
+		return nil, errors.New("syntax error: expected {")
+	}
+	var set []gqlSelection
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, errors.New("syntax error: unexpected end of document")
+		}
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	p.pos++
+	return set, nil
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	var sel gqlSelection
+	var err error
+	if p.peek() == "..." {
+		p.pos++
+		switch tok := p.peek(); {
+		case tok == "on":
+			p.pos++
+			if !isGraphQLName(p.next()) {
+				return sel, errors.New("syntax error in inline fragment")
+			}
+		case isGraphQLName(tok):
+			p.pos++
+			sel.spread = tok
+			return sel, p.directives()
+		}
+		if err = p.directives(); err != nil {
+			return sel, err
+		}
+		sel.selections, err = p.selectionSet()
+		return sel, err
+	}
+	if !isGraphQLName(p.next()) {
+		return sel, fmt.Errorf("syntax error: unexpected %q", p.tokens[p.pos-1])
+	}
+	sel.field = true
+	if p.peek() == ":" {
+		p.pos++
+		if !isGraphQLName(p.next()) {
+			return sel, errors.New("syntax error after alias")
+		}
+	}
+	if p.peek() == "(" {
+		if err = p.skipBalanced(); err != nil {
+			return sel, err
+		}
+	}
+	if err = p.directives(); err != nil {
+		return sel, err
+	}
+	if p.peek() == "{" {
+		sel.selections, err = p.selectionSet()
+	}
+	return sel, err
+}
+
+func (p *gqlParser) directives() error {
+	for p.peek() == "@" {
+		p.pos++
+		if !isGraphQLName(p.next()) {
+			return errors.New("syntax error in directive")
+		}
+		if p.peek() == "(" {
+			if err := p.skipBalanced(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipBalanced skips arguments or variable definitions, which don't
+// change what's selected, up to their closing bracket.
+func (p *gqlParser) skipBalanced() error {
+	depth := 0
+	for {
+		switch p.next() {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "":
+			return errors.New("syntax error: unexpected end of document")
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// operation returns the operation called name, or the only one if name
+// is empty.
+func (d *gqlDocument) operation(name string) (gqlOperation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return gqlOperation{}, errors.New("operationName is required for a document with several operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return gqlOperation{}, errors.New("no operation is called " + name)
+}
+
+// gqlSaturate is where complexity stops being counted, as fragments
+// spread within one another can select exponentially many fields.
+const gqlSaturate = 1 << 30
+
+// measure returns how deeply fields are nested in set and how many it
+// selects in all, with fragments spread into it. seen holds the
+// fragments being measured, to catch those which spread themselves.
+func (d *gqlDocument) measure(set []gqlSelection, seen map[string]bool) (int, int, error) {
+	depth, complexity := 0, 0
+	for _, sel := range set {
+		var dd, c int
+		var err error
+		switch {
+		case sel.spread != "":
+			if m, ok := d.measured[sel.spread]; ok {
+				dd, c = m[0], m[1]
+				break
+			}
+			fragment, ok := d.fragments[sel.spread]
+			if !ok {
+				return 0, 0, errors.New("unknown fragment " + sel.spread)
+			}
+			if seen[sel.spread] {
+				return 0, 0, errors.New("fragment " + sel.spread + " spreads itself")
+			}
+			seen[sel.spread] = true
+			dd, c, err = d.measure(fragment, seen)
+			delete(seen, sel.spread)
+			d.measured[sel.spread] = [2]int{dd, c}
+		case sel.field:
+			dd, c, err = d.measure(sel.selections, seen)
+			dd, c = dd+1, c+1
+		default:
+			dd, c, err = d.measure(sel.selections, seen)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if dd > depth {
+			depth = dd
+		}
+		if complexity += c; complexity > gqlSaturate {
+			complexity = gqlSaturate
+		}
+	}
+	return depth, complexity, nil
+}
+
+// lexGraphQL splits a document into its tokens, leaving out whitespace,
+// commas and comments. Strings are kept only as far as their quote, as
+// their values don't matter here.
+func lexGraphQL(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.IndexByte("{}()[]:=@$!|&", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			for end > 0 && src[i+3+end-1] == '\\' {
+				next := strings.Index(src[i+3+end+1:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += next + 1
+			}
+			if end < 0 {
+				return nil, errors.New("syntax error: unterminated string")
+			}
+			tokens = append(tokens, `"`)
+			i += 3 + end + 3
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"' && src[j] != '\n'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) || src[j] != '"' {
+				return nil, errors.New("syntax error: unterminated string")
+			}
+			tokens = append(tokens, `"`)
+			i = j + 1
+		case isGraphQLName(src[i:]):
+			j := i + 1
+			for j < len(src) && (isDigit(src[j]) || isGraphQLName(src[j:j+1])) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		case c == '-' || isDigit(c):
+			j := i + 1
+			for j < len(src) && (isDigit(src[j]) || strings.IndexByte(".eE+-", src[j]) >= 0) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("syntax error: unexpected %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+// isGraphQLName reports whether tok is a name rather than punctuation, a
+// number or a string.
+func isGraphQLName(tok string) bool {
+	if tok == "" {
+		return false
+	}
+	c := tok[0]
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,140 @@
+package wedge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type userKey struct{}
+
+func TestGraphQL(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.EnableStatTracking()
+	login := func(next View) View {
+		return func(w http.ResponseWriter, req *http.Request) (string, int) {
+			user := req.Header.Get("X-User")
+			if user == "" {
+				return "log in first", http.StatusForbidden
+			}
+			return next(w, req.WithContext(context.WithValue(req.Context(), userKey{}, user)))
+		}
+	}
+	var executed GraphQLRequest
+	App.GraphQL("^/graphql$", GraphQL{
+		Execute: func(ctx context.Context, op GraphQLRequest) interface{} {
+			executed = op
+			return map[string]interface{}{"data": map[string]interface{}{"viewer": ctx.Value(userKey{})}}
+		},
+		MaxDepth:      3,
+		MaxComplexity: 6,
+		MaxBody:       1024,
+	}, With(login))
+
+	do := func(method, target, ctype, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User", "ada")
+		if ctype != "" {
+			req.Header.Set("Content-Type", ctype)
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	post := func(query, operation string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(GraphQLRequest{Query: query, OperationName: operation, Variables: map[string]interface{}{"id": 1}})
+		return do("POST", "/graphql", "application/json", string(b))
+	}
+
+	w := post(`query Viewer($id: ID!) { viewer(id: $id) { name } }`, "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"data":{"viewer":"ada"}}`+"\n" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if executed.Variables["id"] != 1.0 {
+		t.Errorf("executed %+v", executed)
+	}
+	w = do("GET", "/graphql?"+url.Values{"query": {"{ viewer { name } }"}, "variables": {`{"a":"b"}`}}.Encode(), "", "")
+	if w.Code != http.StatusOK || executed.Variables["a"] != "b" {
+		t.Errorf("GET got %d %q, executed %+v", w.Code, w.Body, executed)
+	}
+	w = do("POST", "/graphql?operationName=B", "application/graphql", "query A { a } query B { b }")
+	if w.Code != http.StatusOK || executed.OperationName != "B" {
+		t.Errorf("application/graphql got %d %q, executed %+v", w.Code, w.Body, executed)
+	}
+
+	fragments := `
+		# a comment { with braces
+		query Posts {
+			posts(filter: {tag: "a } b", ids: [1, 2]}) @include(if: true) {
+				...post
+				... on Post { id }
+			}
+		}
+		fragment post on Post { title author: writer { name } }`
+	for _, test := range []struct {
+		query, operation string
+		status           int
+		errors           string
+	}{
+		{fragments, "", http.StatusOK, ""},
+		{`{ a { b { c { d } } } }`, "", http.StatusBadRequest, "nested 4 deep, more than the 3 allowed"},
+		{`{ a b c d e f g }`, "", http.StatusBadRequest, "selects 7 fields, more than the 6 allowed"},
+		{`{ a { ...f ...f } } fragment f on A { b c d }`, "", http.StatusBadRequest, "selects 7 fields"},
+		{`{ ...f } fragment f on A { a ...f }`, "", http.StatusBadRequest, "spreads itself"},
+		{`{ ...missing }`, "", http.StatusBadRequest, "unknown fragment missing"},
+		{`{ a `, "", http.StatusBadRequest, "syntax error"},
+		{`query A { a } query B { b }`, "", http.StatusBadRequest, "operationName is required"},
+		{`query A { a }`, "C", http.StatusBadRequest, "no operation is called C"},
+		{``, "", http.StatusBadRequest, "no query"},
+	} {
+		w = post(test.query, test.operation)
+		if w.Code != test.status || !strings.Contains(w.Body.String(), test.errors) {
+			t.Errorf("%q: got %d %q", test.query, w.Code, w.Body)
+		}
+	}
+
+	w = do("GET", "/graphql?query="+url.QueryEscape("mutation { like }"), "", "")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("mutation by GET got %d %v", w.Code, w.Header())
+	}
+	if w = do("POST", "/graphql", "application/json", strings.Repeat(" ", 2048)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body got %d", w.Code)
+	}
+	if w = do("PUT", "/graphql", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT got %d", w.Code)
+	}
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ a }"}`))
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got %d without logging in", w.Code)
+	}
+
+	// the playground is only served in debug mode.
+	playground := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/graphql", nil)
+		req.Header.Set("X-User", "ada")
+		req.Header.Set("Accept", "text/html,*/*")
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	if w = playground(); w.Code != http.StatusBadRequest {
+		t.Errorf("got %d for the playground outside debug mode", w.Code)
+	}
+	App.SetDebug(true)
+	if w = playground(); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "GraphiQL") {
+		t.Errorf("got %d %q for the playground", w.Code, w.Body)
+	}
+
+	App.flushStats(context.Background())
+	for key, want := range map[string]int{"GraphQL =&gt; query Posts": 1, "GraphQL =&gt; query Viewer": 1, "GraphQL =&gt; query B": 1} {
+		if n, _ := App.stat_map.Find(key).(int); n != want {
+			t.Errorf("%s: counted %d, want %d", key, n, want)
+		}
+	}
+}
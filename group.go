@@ -0,0 +1,103 @@
+package wedge
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Group adds routes below a shared path prefix, wrapped in shared
+// middleware and configured with shared options.
+type Group struct {
+	app        *AppServer
+	prefix     string
+	middleware []Middleware
+	opts       []Option
+	cache      time.Duration
+	cacheSet   bool
+}
+
+// Group returns a Group whose routes are added below prefix, and are
+// configured by opts after their own options.
+//
+// Patterns given to the Group's AddURLs are relative to the prefix, so
+// "^/users/$" in a Group for "/api/v1" matches "/api/v1/users/".
+// StaticFiles has to be added to the AppServer itself, as its view
+// depends on its pattern.
+//
+// Example:
+//
+//	api := App.Group("/api/v1", wedge.Vary("Accept"))
+//	api.Use(RequireToken)
+//	api.AddURLs(
+//		wedge.GET("^/users/$", "Users", Users, wedge.JSON),
+//		wedge.Path("/users/<int:id>", "User", User, wedge.JSON),
+//	)
+func (App *AppServer) Group(prefix string, opts ...Option) *Group {
+	return &Group{
+		app:    App,
+		prefix: strings.TrimSuffix(prefix, "/"),
+		opts:   opts,
+	}
+}
+
+// Group returns a Group below g, with g's middleware and options as well
+// as its own.
+func (g *Group) Group(prefix string, opts ...Option) *Group {
+	return &Group{
+		app:        g.app,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: append([]Middleware(nil), g.middleware...),
+		opts:       append(append([]Option(nil), g.opts...), opts...),
+		cache:      g.cache,
+		cacheSet:   g.cacheSet,
+	}
+}
+
+// Use wraps the views of routes added to the Group afterwards in
+// middleware, the first given being the outermost. Like the AppServer's
+// middleware it runs before the cache is checked.
+func (g *Group) Use(middleware ...Middleware) *Group {
+	g.middleware = append(g.middleware, middleware...)
+	return g
+}
+
+// Cache sets how long responses are cached for routes added to the
+// Group which don't set it themselves, see the Cache option.
+func (g *Group) Cache(d time.Duration) *Group {
+	g.cache = d
+	g.cacheSet = true
+	return g
+}
+
+// AddURLs adds routes below the Group's prefix.
+func (g *Group) AddURLs(patterns ...*Rule) {
+	for _, u := range patterns {
+		u.mount(g.prefix)
+		for _, opt := range g.opts {
+			opt(u)
+		}
+		if g.cacheSet && !u.cache_set {
+			Cache(g.cache)(u)
+		}
+		u.middleware = append(append([]Middleware(nil), g.middleware...), u.middleware...)
+	}
+	g.app.AddURLs(patterns...)
+}
+
+// mount moves the route below prefix. Unanchored patterns are matched
+// anywhere below it.
+func (u *Rule) mount(prefix string) {
+	if prefix == "" {
+		return
+	}
+	re := u.rawre
+	if strings.HasPrefix(re, "^") {
+		re = re[1:]
+	} else {
+		re = ".*" + re
+	}
+	re = "^" + regexp.QuoteMeta(prefix) + re
+	u.match = newMatcher(re)
+	u.rawre = re
+}
@@ -0,0 +1,89 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var order []string
+	logger := func(name string) Middleware {
+		return func(next View) View {
+			return func(w http.ResponseWriter, req *http.Request) (string, int) {
+				order = append(order, name)
+				return next(w, req)
+			}
+		}
+	}
+	auth := func(next View) View {
+		return func(w http.ResponseWriter, req *http.Request) (string, int) {
+			if req.Header.Get("X-Token") == "" {
+				return "", http.StatusNotFound
+			}
+			return next(w, req)
+		}
+	}
+
+	App := NewAppServer("0", 0)
+	api := App.Group("/api/v1/", Vary("Accept")).Cache(time.Minute)
+	api.Use(logger("outer"), logger("inner"))
+	api.AddURLs(
+		GET("^/users/$", "Users", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			order = append(order, "view")
+			return "users", http.StatusOK
+		}, HTML),
+		Path("/users/<int:id>", "User", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "user", http.StatusOK
+		}, HTML),
+		Route("^/uncached/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "uncached", http.StatusOK
+		}, Cache(0)),
+	)
+	admin := api.Group("/admin").Use(auth)
+	admin.AddURLs(
+		URL("^/$", "Admin", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "admin", http.StatusOK
+		}, HTML),
+	)
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/", nil))
+	if w.Body.String() != "users" || w.Header().Get("Vary") != "Accept" {
+		t.Errorf("got %q with Vary %q", w.Body.String(), w.Header().Get("Vary"))
+	}
+	if got := len(order); got != 3 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("middleware ran in order %v", order)
+	}
+	for path, want := range map[string]int{
+		"/users/":         http.StatusNotFound,
+		"/api/v1/users/7": http.StatusOK,
+		"/api/v1/admin/":  http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: got %d, want %d", path, w.Code, want)
+		}
+	}
+	req := httptest.NewRequest("GET", "/api/v1/admin/", nil)
+	req.Header.Set("X-Token", "t")
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	if w.Body.String() != "admin" {
+		t.Errorf("nested group got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, route := range App.Routes() {
+		want := time.Minute
+		// the uncached route opts out, and Path routes always set
+		// their own cache duration.
+		if route.Name() == "" || route.Name() == "User" {
+			want = 0
+		}
+		if route.CacheTTL() != want {
+			t.Errorf("%s is cached for %s, want %s", route.Pattern(), route.CacheTTL(), want)
+		}
+	}
+}
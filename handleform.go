@@ -0,0 +1,69 @@
+package wedge
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+
+	"wedge/forms"
+)
+
+// FormSavedMessage is the flash message HandleForm adds after a successful
+// submission.
+var FormSavedMessage = "Saved."
+
+// FormTemplate renders the pages of HandleForm. It's given the Form as
+// HTML, any Flashes and any Errors from the submission, and can be
+// replaced to match the rest of the site.
+var FormTemplate = template.Must(template.New("form.html").Parse(
+	`<!DOCTYPE html><html><body>` +
+		`{{range .Flashes}}<p class="flash">{{.}}</p>{{end}}` +
+		`{{range .Errors}}<p class="error">{{.}}</p>{{end}}` +
+		`{{.Form}}</body></html>`))
+
+// HandleForm returns a view which follows the Post/Redirect/Get pattern
+// for form. A GET shows the form, along with any flash messages. A POST
+// validates it, showing it again with the errors if it isn't valid, and
+// otherwise calls onValid with the converted values. onValid returns where
+// to send the client, which is redirected there with FormSavedMessage, or
+// an error which is shown with the form.
+//
+// Example:
+//
+//	App.AddURLs(wedge.URL("^/contact/$", "Contact", wedge.HandleForm(ContactForm,
+//		func(values map[string]interface{}) (string, error) {
+//			return "/contact/", sendMessage(values)
+//		}), wedge.HTML))
+func HandleForm(form *forms.Form, onValid func(values map[string]interface{}) (string, error)) view {
+	return func(w http.ResponseWriter, req *http.Request) (string, int) {
+		if req.Method != "POST" {
+			return renderForm(template.HTML(form.Display()), Flashes(w, req), nil)
+		}
+		bound := form.Validate(req)
+		if !bound.Valid() {
+			// the bound form shows its own errors.
+			return renderForm(template.HTML(bound.Display()), nil, nil)
+		}
+		to, err := onValid(bound.Convert())
+		if err != nil {
+			return renderForm(template.HTML(form.Display()), nil, []string{err.Error()})
+		}
+		AddFlash(w, req, FormSavedMessage)
+		return to, http.StatusSeeOther
+	}
+}
+
+func renderForm(form template.HTML, flashes, errors []string) (string, int) {
+	buf := new(bytes.Buffer)
+	err := FormTemplate.Execute(buf, map[string]interface{}{
+		"Form":    form,
+		"Flashes": flashes,
+		"Errors":  errors,
+	})
+	if err != nil {
+		log.Println("Error rendering form:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
@@ -0,0 +1,63 @@
+package wedge
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"wedge/forms"
+)
+
+func TestHandleForm(t *testing.T) {
+	form := forms.NewForm(forms.NewFormMetadata("contact", "/contact/", "POST", true),
+		forms.TextField("name", "Name", 20, forms.WithValidator(forms.NotBlank())),
+	)
+	var got map[string]interface{}
+	App := NewAppServer("0", 30)
+	App.AddURLs(URL("^/contact/$", "Contact", HandleForm(form, func(values map[string]interface{}) (string, error) {
+		if values["name"] == "fail" {
+			return "", errors.New("couldn't send")
+		}
+		got = values
+		return "/contact/", nil
+	}), HTML))
+
+	post := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/contact/", strings.NewReader(url.Values{"name": {name}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(" "); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="#contact-name">name: `) {
+		t.Errorf("invalid submission: got %d %q", w.Code, w.Body)
+	}
+	if w := post("fail"); !strings.Contains(w.Body.String(), "couldn&#39;t send") {
+		t.Errorf("failed submission: got %q", w.Body)
+	}
+
+	w := post("alice")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/contact/" {
+		t.Fatalf("valid submission: got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if got["name"] != "alice" {
+		t.Errorf("onValid got %v", got)
+	}
+
+	req := httptest.NewRequest("GET", "/contact/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `<p class="flash">`+FormSavedMessage+`</p>`) {
+		t.Errorf("page after redirect has no flash: %q", w.Body)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("flash wasn't cleared: %v", cookies)
+	}
+}
@@ -0,0 +1,225 @@
+package wedge
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScannerPaths are the paths vulnerability scanners probe for, which no
+// wedge site serves. They are the default paths of a Honeypot, and each
+// matches any path it's a prefix of.
+var ScannerPaths = []string{
+	"/wp-login.php",
+	"/wp-admin",
+	"/wp-content",
+	"/xmlrpc.php",
+	"/.env",
+	"/.git/",
+	"/.aws/",
+	"/phpmyadmin",
+	"/pma/",
+	"/admin.php",
+	"/config.php",
+	"/vendor/phpunit",
+	"/cgi-bin/",
+	"/boaform/",
+}
+
+// Honeypot catches scanners probing for software the site doesn't run.
+type Honeypot struct {
+	// Paths are the paths to catch, ScannerPaths if it's nil.
+	Paths []string
+	// Tarpit is how long to spend answering each probe, a byte at a
+	// time, to slow the scanner down. It's answered with a 404 straight
+	// away if it's zero.
+	Tarpit time.Duration
+	// MaxTarpits is how many probes are tarpitted at once, each holding
+	// a connection and a goroutine, DefaultMaxTarpits if it's zero.
+	// Probes beyond it are answered with a 404 straight away.
+	MaxTarpits int
+	// Deny refuses all requests from a client once it has made
+	// Threshold probes, for as long as Deny, see DenyIP. Offenders are
+	// only recorded if it's zero.
+	Deny      time.Duration
+	Threshold int
+}
+
+// Offender is a client which has probed the honeypot. Its IP is
+// anonymised as SetPrivacy says.
+type Offender struct {
+	IP    string
+	Hits  int
+	Last  time.Time
+	Paths []string
+}
+
+// DefaultMaxTarpits is the MaxTarpits of a Honeypot which doesn't set
+// one.
+var DefaultMaxTarpits = 100
+
+const (
+	// maxOffenders is how many offenders are kept, the one seen least
+	// recently being forgotten to make room for a new one.
+	maxOffenders = 10000
+	// maxOffenderPaths is how many of an offender's paths are kept.
+	maxOffenderPaths = 10
+)
+
+// honeypot records the clients which have probed the honeypot.
+type honeypot struct {
+	sync.Mutex
+	Honeypot
+	// offenders are keyed by the address as it is, which is only held
+	// in memory.
+	offenders map[string]*Offender
+	// tarpits holds a token for each probe being tarpitted.
+	tarpits chan struct{}
+}
+
+// EnableHoneypot adds a route catching requests for h.Paths, which are
+// tarpitted, recorded and, once a client has made enough of them, denied.
+// It should be called after AddURLs, so the site's own routes are
+// matched first.
+//
+// Example:
+//
+//	App.EnableHoneypot(wedge.Honeypot{
+//		Tarpit:    30 * time.Second,
+//		Deny:      24 * time.Hour,
+//		Threshold: 3,
+//	})
+func (App *AppServer) EnableHoneypot(h Honeypot) {
+	if h.Paths == nil {
+		h.Paths = ScannerPaths
+	}
+	if h.Threshold < 1 {
+		h.Threshold = 1
+	}
+	if h.MaxTarpits <= 0 {
+		h.MaxTarpits = DefaultMaxTarpits
+	}
+	var alts []string
+	for _, path := range h.Paths {
+		alts = append(alts, regexp.QuoteMeta(path))
+	}
+	App.initDenied()
+	App.honeypot = &honeypot{
+		Honeypot:  h,
+		offenders: make(map[string]*Offender),
+		tarpits:   make(chan struct{}, h.MaxTarpits),
+	}
+	App.routes = append(App.routes, makeurl("^(?:"+strings.Join(alts, "|")+")", "Honeypot", App.honeypotView, HTML, 0))
+}
+
+// honeypotView records the probe and wastes the scanner's time.
+func (App *AppServer) honeypotView(w http.ResponseWriter, req *http.Request) (string, int) {
+	h := App.honeypot
+	ip := App.forwardedFor(req)
+	h.Lock()
+	o, ok := h.offenders[ip]
+	if !ok {
+		if len(h.offenders) >= maxOffenders {
+			h.forgetOldest()
+		}
+		o = &Offender{IP: App.anonymise(ip)}
+		h.offenders[ip] = o
+	}
+	o.Hits++
+	o.Last = time.Now()
+	if len(o.Paths) < maxOffenderPaths {
+		o.Paths = append(o.Paths, req.URL.Path)
+	}
+	deny := h.Deny > 0 && o.Hits >= h.Threshold
+	h.Unlock()
+
+	if App.bans != nil {
+		App.reportAbuse(ip, "honeypot "+req.URL.Path)
+	}
+	if deny && !App.Denied(ip) {
+		log.Println("Honeypot: denying", App.anonymise(ip), "for", h.Deny)
+		App.DenyIP(ip, h.Deny)
+	}
+	if h.Tarpit <= 0 {
+		return "", http.StatusNotFound
+	}
+	select {
+	case h.tarpits <- struct{}{}:
+		defer func() { <-h.tarpits }()
+	default:
+		// too many are being tarpitted already.
+		return "", http.StatusNotFound
+	}
+	drip(w, req, h.Tarpit)
+	return "", http.StatusOK
+}
+
+// forgetOldest removes the offender seen least recently.
+func (h *honeypot) forgetOldest() {
+	var oldest *Offender
+	var key string
+	for ip, o := range h.offenders {
+		if oldest == nil || o.Last.Before(oldest.Last) {
+			oldest, key = o, ip
+		}
+	}
+	if oldest != nil {
+		delete(h.offenders, key)
+	}
+}
+
+// drip writes a byte a second until d is up or the client goes away.
+func drip(w http.ResponseWriter, req *http.Request, d time.Duration) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	interval := time.Second
+	if d < interval {
+		interval = d
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(d)
+	for {
+		if _, err := w.Write([]byte(" ")); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// HoneypotOffenders returns the clients which have probed the honeypot,
+// those with the most probes first.
+func (App *AppServer) HoneypotOffenders() []Offender {
+	if App.honeypot == nil {
+		return nil
+	}
+	App.honeypot.Lock()
+	defer App.honeypot.Unlock()
+	var offenders []Offender
+	for _, o := range App.honeypot.offenders {
+		offender := *o
+		offender.Paths = append([]string(nil), o.Paths...)
+		offenders = append(offenders, offender)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Hits != offenders[j].Hits {
+			return offenders[i].Hits > offenders[j].Hits
+		}
+		return offenders[i].IP < offenders[j].IP
+	})
+	return offenders
+}
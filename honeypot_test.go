@@ -0,0 +1,125 @@
+package wedge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHoneypot(t *testing.T) {
+	App := NewAppServer("0", 0)
+	App.AddURLs(
+		URL("^/$", "Index", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "index", http.StatusOK
+		}, HTML),
+	)
+	App.EnableHoneypot(Honeypot{Tarpit: 50 * time.Millisecond, Deny: time.Hour, Threshold: 2})
+
+	probe := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "203.0.113.9:1234"
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Now()
+	if w := probe("/wp-login.php"); w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("probe got %d %q", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("probe was answered in %s, want it tarpitted", elapsed)
+	}
+	if w := probe("/"); w.Code != http.StatusOK {
+		t.Errorf("client denied after one probe: %d", w.Code)
+	}
+	probe("/.env")
+	if w := probe("/"); w.Code != http.StatusForbidden {
+		t.Errorf("client not denied after two probes: %d", w.Code)
+	}
+
+	offenders := App.HoneypotOffenders()
+	if len(offenders) != 1 || offenders[0].IP != "203.0.113.9" || offenders[0].Hits != 2 {
+		t.Fatalf("unexpected offenders: %+v", offenders)
+	}
+	if paths := offenders[0].Paths; len(paths) != 2 || paths[1] != "/.env" {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+
+	App.AllowIP("203.0.113.9")
+	if w := probe("/"); w.Code != http.StatusOK {
+		t.Errorf("client still denied after AllowIP: %d", w.Code)
+	}
+}
+
+func TestHoneypotMaxTarpits(t *testing.T) {
+	App := NewAppServer("0", 0)
+	App.EnableHoneypot(Honeypot{Tarpit: time.Minute, MaxTarpits: 2, Deny: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// each probe comes from its own client, and denies it while the
+	// others are being served.
+	var clients int32
+	probe := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/wp-login.php", nil).WithContext(ctx)
+		req.RemoteAddr = fmt.Sprintf("203.0.113.%d:1234", atomic.AddInt32(&clients, 1))
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probe()
+		}()
+	}
+	for len(App.honeypot.tarpits) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// the third probe isn't held, as two already are.
+	start := time.Now()
+	if w := probe(); w.Code != http.StatusNotFound || time.Since(start) > 5*time.Second {
+		t.Errorf("got %d after %v", w.Code, time.Since(start))
+	}
+	cancel()
+	wg.Wait()
+	if n := len(App.honeypot.tarpits); n != 0 {
+		t.Errorf("%d tarpits weren't released", n)
+	}
+}
+
+func TestDenyIPExpires(t *testing.T) {
+	App := NewAppServer("0", 0)
+	App.DenyIP("198.51.100.1", 20*time.Millisecond)
+	if !App.Denied("198.51.100.1") {
+		t.Fatal("address not denied")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if App.Denied("198.51.100.1") {
+		t.Error("ban didn't expire")
+	}
+}
+
+func TestHoneypotPrivacy(t *testing.T) {
+	App := NewAppServer("0", 0)
+	App.SetPrivacy(Privacy{IPs: HashIPs})
+	App.EnableHoneypot(Honeypot{Deny: time.Hour})
+	req := httptest.NewRequest("GET", "/.env", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	App.ServeHTTP(httptest.NewRecorder(), req)
+
+	offenders := App.HoneypotOffenders()
+	if len(offenders) != 1 || offenders[0].IP != App.anonymise("203.0.113.9") || offenders[0].IP == "203.0.113.9" {
+		t.Fatalf("unexpected offenders: %+v", offenders)
+	}
+	if !App.Denied("203.0.113.9") {
+		t.Error("the client's own address wasn't denied")
+	}
+}
@@ -0,0 +1,117 @@
+package wedge
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// IsHTMX reports whether req was made by htmx, which asks for a fragment
+// of a page to swap into the one already shown.
+func IsHTMX(req *http.Request) bool {
+	return req.Header.Get("HX-Request") == "true"
+}
+
+// TurboFrame returns the id of the Turbo frame req was made for, or "" if
+// it wasn't made by a Turbo frame.
+func TurboFrame(req *http.Request) string {
+	return req.Header.Get("Turbo-Frame")
+}
+
+// IsPartial reports whether req only needs a fragment of a page, as it
+// was made by htmx or a Turbo frame. Boosted htmx requests, which
+// replace the whole body, aren't partial.
+func IsPartial(req *http.Request) bool {
+	if IsHTMX(req) {
+		return req.Header.Get("HX-Boosted") != "true"
+	}
+	return TurboFrame(req) != ""
+}
+
+// RenderBlock executes the template called name within t, such as a
+// {{define}} or {{block}} in a page, and returns the result.
+func RenderBlock(t *template.Template, name string, data interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := t.ExecuteTemplate(buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Fragment renders a page for views which serve both full page loads and
+// htmx or Turbo requests. Partial requests get only the template called
+// block, everything else the template called page. Since the same URL
+// gives two different responses, they vary on the headers which decide
+// between them.
+//
+// Example:
+//
+//	var pages = template.Must(template.ParseGlob("templates/*.html"))
+//
+//	func Posts(w http.ResponseWriter, req *http.Request) (string, int) {
+//		return wedge.Fragment(w, req, pages, "posts.html", "post-list", recentPosts())
+//	}
+func Fragment(w http.ResponseWriter, req *http.Request, t *template.Template, page, block string, data interface{}) (string, int) {
+	w.Header().Add("Vary", "HX-Request, Turbo-Frame")
+	name := page
+	if IsPartial(req) {
+		name = block
+	}
+	resp, err := RenderBlock(t, name, data)
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		return "", http.StatusInternalServerError
+	}
+	return resp, http.StatusOK
+}
+
+// HXTrigger makes htmx trigger events on the client once the response is
+// swapped in. An event may have details, passed as a map from event name
+// to detail, or be given by name alone.
+//
+// Example:
+//
+//	wedge.HXTrigger(w, "postAdded")
+//	wedge.HXTrigger(w, map[string]interface{}{"showMessage": "Saved"})
+func HXTrigger(w http.ResponseWriter, events ...interface{}) {
+	var names []string
+	details := make(map[string]interface{})
+	for _, event := range events {
+		switch e := event.(type) {
+		case string:
+			names = append(names, e)
+		case map[string]interface{}:
+			for name, detail := range e {
+				details[name] = detail
+			}
+		}
+	}
+	if len(details) == 0 {
+		w.Header().Set("HX-Trigger", strings.Join(names, ", "))
+		return
+	}
+	for _, name := range names {
+		details[name] = nil
+	}
+	b, err := json.Marshal(details)
+	if err != nil {
+		log.Println("Error encoding HX-Trigger:", err)
+		return
+	}
+	w.Header().Set("HX-Trigger", string(b))
+}
+
+// HXRedirect makes htmx load url as a full page, rather than swapping in
+// the response.
+func HXRedirect(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Redirect", url)
+}
+
+// HXPushURL makes htmx push url onto the browser history, so the address
+// bar matches the fragment which was swapped in.
+func HXPushURL(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Push-Url", url)
+}
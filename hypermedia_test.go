@@ -0,0 +1,48 @@
+package wedge
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFragment(t *testing.T) {
+	pages := template.Must(template.New("page").Parse(
+		`<html>{{block "list" .}}<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}</html>`))
+	data := []string{"a", "b"}
+
+	tests := []struct {
+		header, value string
+		want          string
+	}{
+		{"", "", "<html><ul><li>a</li><li>b</li></ul></html>"},
+		{"HX-Request", "true", "<ul><li>a</li><li>b</li></ul>"},
+		{"Turbo-Frame", "list", "<ul><li>a</li><li>b</li></ul>"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		resp, status := Fragment(w, req, pages, "page", "list", data)
+		if resp != test.want || status != 200 {
+			t.Errorf("%s: got (%q, %d), want %q", test.header, resp, status, test.want)
+		}
+		if w.Header().Get("Vary") == "" {
+			t.Errorf("%s: no Vary header", test.header)
+		}
+	}
+}
+
+func TestHXTrigger(t *testing.T) {
+	w := httptest.NewRecorder()
+	HXTrigger(w, "a", "b")
+	if got := w.Header().Get("HX-Trigger"); got != "a, b" {
+		t.Errorf("names: got %q", got)
+	}
+	HXTrigger(w, "a", map[string]interface{}{"show": "Saved"})
+	if got := w.Header().Get("HX-Trigger"); got != `{"a":null,"show":"Saved"}` {
+		t.Errorf("details: got %q", got)
+	}
+}
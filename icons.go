@@ -0,0 +1,157 @@
+package wedge
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IconSet is the icons of a site, for Icons. Each is the path of a file,
+// and those left empty aren't served.
+type IconSet struct {
+	// Favicon is served as /favicon.ico, SVG as /favicon.svg and
+	// AppleTouch, which should be a 180x180 PNG, as
+	// /apple-touch-icon.png.
+	Favicon    string
+	SVG        string
+	AppleTouch string
+	// PNGs are square PNG icons by their size in pixels, such as 192
+	// and 512 for the manifest, each served as /icon-<size>.png.
+	PNGs map[int]string
+	// Manifest is served as /site.webmanifest, if it has a Name.
+	Manifest Manifest
+	// MaxAge is how long clients may cache the icons for. It's 30 days
+	// if it's zero.
+	MaxAge time.Duration
+}
+
+// Manifest is a web app manifest, which lets the site be installed on a
+// phone's home screen. Its icons are those of the IconSet.
+type Manifest struct {
+	Name            string `json:"name"`
+	ShortName       string `json:"short_name,omitempty"`
+	StartURL        string `json:"start_url,omitempty"`
+	Display         string `json:"display,omitempty"`
+	ThemeColor      string `json:"theme_color,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"`
+}
+
+type manifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// icon is a file served by Icons.
+type icon struct {
+	data  string
+	ctype string
+}
+
+// Icons returns a *Rule which serves a whole set of icons and the web app
+// manifest, with long cache lifetimes. The files are read straight away,
+// and it will panic if one can't be, like Favicon. Links renders the tags
+// which point browsers at them.
+//
+// Example:
+//
+//	icons := wedge.IconSet{
+//		Favicon:    "static/favicon.ico",
+//		SVG:        "static/icon.svg",
+//		AppleTouch: "static/apple-touch-icon.png",
+//		PNGs:       map[int]string{192: "static/icon-192.png", 512: "static/icon-512.png"},
+//		Manifest:   wedge.Manifest{Name: "My Site", StartURL: "/", Display: "standalone"},
+//	}
+//	App.AddURLs(wedge.Icons(icons))
+func Icons(set IconSet) *Rule {
+	icons := make(map[string]icon)
+	add := func(name, path, ctype string) {
+		if path == "" {
+			return
+		}
+		data, err := readFile(path)
+		if err != nil {
+			panic(err)
+		}
+		icons[name] = icon{data, ctype}
+	}
+	add("favicon.ico", set.Favicon, "image/x-icon")
+	add("favicon.svg", set.SVG, "image/svg+xml")
+	add("apple-touch-icon.png", set.AppleTouch, "image/png")
+	for _, size := range set.pngSizes() {
+		add(fmt.Sprintf("icon-%d.png", size), set.PNGs[size], "image/png")
+	}
+	if set.Manifest.Name != "" {
+		manifest, err := json.Marshal(struct {
+			Manifest
+			Icons []manifestIcon `json:"icons,omitempty"`
+		}{set.Manifest, set.manifestIcons()})
+		if err != nil {
+			panic(err)
+		}
+		icons["site.webmanifest"] = icon{string(manifest), "application/manifest+json"}
+	}
+	maxAge := set.MaxAge
+	if maxAge <= 0 {
+		maxAge = 30 * 24 * time.Hour
+	}
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+
+	re := `^/(favicon\.ico|favicon\.svg|apple-touch-icon\.png|icon-[0-9]+\.png|site\.webmanifest)$`
+	return makeurl(re, "Icons", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		icon, ok := icons[strings.TrimPrefix(req.URL.Path, "/")]
+		if !ok {
+			return "", http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", icon.ctype)
+		w.Header().Set("Cache-Control", cacheControl)
+		return icon.data, http.StatusOK
+	}, IMAGE, 0)
+}
+
+func (set IconSet) pngSizes() []int {
+	var sizes []int
+	for size := range set.PNGs {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+func (set IconSet) manifestIcons() []manifestIcon {
+	var icons []manifestIcon
+	for _, size := range set.pngSizes() {
+		icons = append(icons, manifestIcon{
+			Src:   fmt.Sprintf("/icon-%d.png", size),
+			Sizes: fmt.Sprintf("%dx%d", size, size),
+			Type:  "image/png",
+		})
+	}
+	if set.SVG != "" {
+		icons = append(icons, manifestIcon{"/favicon.svg", "any", "image/svg+xml"})
+	}
+	return icons
+}
+
+// Links renders the link tags for the head of each page which point
+// browsers at the icons and manifest served by Icons.
+func (set IconSet) Links() template.HTML {
+	var links []string
+	if set.Favicon != "" {
+		links = append(links, `<link rel="icon" href="/favicon.ico" sizes="any">`)
+	}
+	if set.SVG != "" {
+		links = append(links, `<link rel="icon" href="/favicon.svg" type="image/svg+xml">`)
+	}
+	if set.AppleTouch != "" {
+		links = append(links, `<link rel="apple-touch-icon" href="/apple-touch-icon.png">`)
+	}
+	if set.Manifest.Name != "" {
+		links = append(links, `<link rel="manifest" href="/site.webmanifest">`)
+	}
+	return template.HTML(strings.Join(links, "\n"))
+}
@@ -0,0 +1,88 @@
+package wedge
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIcons(t *testing.T) {
+	dir, err := ioutil.TempDir("", "icons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(name string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	set := IconSet{
+		Favicon: path("favicon.ico"),
+		SVG:     path("icon.svg"),
+		PNGs:    map[int]string{512: path("512.png"), 192: path("192.png")},
+		Manifest: Manifest{
+			Name:       "Example",
+			StartURL:   "/",
+			Display:    "standalone",
+			ThemeColor: "#123456",
+		},
+	}
+	App := NewAppServer("0", 30)
+	App.AddURLs(Icons(set))
+
+	for path, want := range map[string][2]string{
+		"/favicon.ico":  {"favicon.ico", "image/x-icon"},
+		"/favicon.svg":  {"icon.svg", "image/svg+xml"},
+		"/icon-192.png": {"192.png", "image/png"},
+	} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != want[0] || w.Header().Get("Content-Type") != want[1] {
+			t.Errorf("%s: got %d %q %q", path, w.Code, w.Body, w.Header().Get("Content-Type"))
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=2592000" {
+			t.Errorf("%s: got Cache-Control %q", path, cc)
+		}
+	}
+
+	// icons which weren't given aren't served.
+	for _, path := range []string{"/apple-touch-icon.png", "/icon-64.png"} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/site.webmanifest", nil))
+	if ctype := w.Header().Get("Content-Type"); ctype != "application/manifest+json" {
+		t.Errorf("got manifest Content-Type %q", ctype)
+	}
+	var manifest struct {
+		Name       string `json:"name"`
+		ThemeColor string `json:"theme_color"`
+		Icons      []manifestIcon
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Name != "Example" || manifest.ThemeColor != "#123456" || len(manifest.Icons) != 3 ||
+		manifest.Icons[0] != (manifestIcon{"/icon-192.png", "192x192", "image/png"}) ||
+		manifest.Icons[2].Sizes != "any" {
+		t.Errorf("got manifest %+v", manifest)
+	}
+
+	links := string(set.Links())
+	if !strings.Contains(links, `rel="manifest"`) || strings.Contains(links, "apple-touch-icon") {
+		t.Errorf("got links %q", links)
+	}
+}
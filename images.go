@@ -0,0 +1,235 @@
+package wedge
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImageOptions configures a route created with Images.
+type ImageOptions struct {
+	// Sizes is the allow-list of "WIDTHxHEIGHT" strings which may be
+	// requested. Any other size is a 404, so clients can't make the
+	// server generate an unbounded number of thumbnails.
+	Sizes []string
+	// Qualities is the allow-list of JPEG qualities. If it is empty,
+	// only the default quality of 85 is allowed.
+	Qualities []int
+	// CacheDir is a directory to store resized images in. If it is
+	// empty the resized images are kept in memory.
+	CacheDir string
+	// Storage stores the resized images instead of CacheDir.
+	Storage Storage
+	// Source is where the original images are read from instead of the
+	// directory, such as the Storage of Uploads, by their paths in the
+	// request.
+	Source Storage
+	// MaxAge is sent to clients in the Cache-Control header. If it is
+	// zero, a year is used and the response is marked immutable.
+	MaxAge time.Duration
+	// MaxPixels is the most pixels a source image may have, checked
+	// before it's decoded so a small file claiming to be a huge image
+	// can't exhaust memory. If it is zero, DefaultMaxImagePixels is used.
+	MaxPixels int
+}
+
+const defaultQuality = 85
+
+// DefaultMaxImagePixels is the MaxPixels of Images which set none, 50
+// megapixels, which takes about 200MB once decoded.
+var DefaultMaxImagePixels = 50000000
+
+// errImageTooLarge is returned by resize for images with more pixels
+// than allowed.
+var errImageTooLarge = errors.New("wedge: image has too many pixels")
+
+// Images returns a *Rule which serves resized copies of the images held
+// in dir. Requests take the form:
+//
+//	<as><width>x<height>[/q<quality>]/<path to image>
+//
+// so with as set to "/thumbs/", "/thumbs/200x150/q70/cats/tom.jpg" will
+// serve dir/cats/tom.jpg scaled to fit within 200x150 at quality 70.
+// JPEG images are served as JPEG, while PNG and GIF images are served
+// as PNG.
+func Images(as, dir string, opts ImageOptions) *Rule {
+	sizes := make(map[string]bool)
+	for _, size := range opts.Sizes {
+		sizes[size] = true
+	}
+	qualities := map[int]bool{defaultQuality: len(opts.Qualities) == 0}
+	for _, q := range opts.Qualities {
+		qualities[q] = true
+	}
+	cacheControl := "public, max-age=31536000, immutable"
+	if opts.MaxAge > 0 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(opts.MaxAge.Seconds()))
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = DefaultMaxImagePixels
+	}
+	memory := NewSafeMap()
+	store := opts.Storage
+	if store == nil && opts.CacheDir != "" {
+		store = &DiskStorage{Dir: opts.CacheDir}
+	}
+
+	var u *Rule
+	re := "^" + as + `([0-9]+)x([0-9]+)(?:/q([0-9]+))?/(.+)$`
+	u = makeurl(re, "Images", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		m := u.match.FindStringSubmatch(req.URL.Path)
+		if m == nil || !sizes[m[1]+"x"+m[2]] {
+			return "", http.StatusNotFound
+		}
+		quality := defaultQuality
+		if m[3] != "" {
+			quality, _ = strconv.Atoi(m[3])
+		}
+		if !qualities[quality] {
+			return "", http.StatusNotFound
+		}
+		width, _ := strconv.Atoi(m[1])
+		height, _ := strconv.Atoi(m[2])
+
+		// Prevent Directory Traversal Attacks
+		if strings.Contains(m[4], "..") {
+			return "", http.StatusNotFound
+		}
+		key := fmt.Sprintf("%dx%d-q%d-%s", width, height, quality, m[4])
+
+		var data string
+		if store == nil {
+			data, _ = memory.Find(key).(string)
+		} else {
+			data, _ = readCachedImage(store, key)
+		}
+		if data == "" {
+			var source io.ReadCloser
+			var err error
+			if opts.Source != nil {
+				source, err = opts.Source.Get(m[4])
+			} else {
+				source, err = os.Open(filepath.Join(dir, filepath.FromSlash(m[4])))
+			}
+			if err != nil {
+				return "", http.StatusNotFound
+			}
+			data, err = resize(source, width, height, quality, opts.MaxPixels)
+			source.Close()
+			if err != nil {
+				if err == errImageTooLarge {
+					log.Println("Refusing to resize", m[4]+":", err)
+				}
+				return "", http.StatusNotFound
+			}
+			if store == nil {
+				memory.Insert(key, data)
+			} else if err := store.Put(cachedImageKey(key), strings.NewReader(data)); err != nil {
+				log.Println("Error storing resized image:", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", http.DetectContentType([]byte(data)))
+		w.Header().Set("Cache-Control", cacheControl)
+		return data, http.StatusOK
+	}, IMAGE, 0)
+	return u
+}
+
+// cachedImageKey is the key a resized image is stored under, which is
+// a hash so it's the same length however long the path is.
+func cachedImageKey(key string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(key)))
+}
+
+func readCachedImage(store Storage, key string) (string, error) {
+	r, err := store.Get(cachedImageKey(key))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	return string(data), err
+}
+
+// resize decodes the image read from r, scales it to fit within width
+// and height and encodes it again. Images with more than maxPixels are
+// refused from their header, before they're decoded.
+func resize(r io.Reader, width, height, quality, maxPixels int) (string, error) {
+	header := new(bytes.Buffer)
+	config, _, err := image.DecodeConfig(io.TeeReader(r, header))
+	if err != nil {
+		return "", err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxPixels/config.Height {
+		return "", errImageTooLarge
+	}
+	src, format, err := image.Decode(io.MultiReader(header, r))
+	if err != nil {
+		return "", err
+	}
+	dst := resizeImage(src, width, height)
+
+	buf := new(bytes.Buffer)
+	if format == "jpeg" {
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(buf, dst)
+	}
+	return buf.String(), err
+}
+
+// resizeImage scales src to fit within width and height, keeping its
+// aspect ratio. Each destination pixel is the average of the source
+// pixels it covers, which gives good results when shrinking images.
+// Images which already fit are returned as they are, never enlarged.
+func resizeImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if sw == 0 || sh == 0 || sw <= width && sh <= height {
+		return src
+	}
+	if sw*height > sh*width {
+		height = max(1, sh*width/sw)
+	} else {
+		width = max(1, sw*height/sh)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*sh/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*sh/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*sw/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*sw/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
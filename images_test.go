@@ -0,0 +1,81 @@
+package wedge
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, width, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImages(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "wide.png"), 200, 100)
+	writePNG(t, filepath.Join(dir, "small.png"), 40, 30)
+	writePNG(t, filepath.Join(dir, "huge.png"), 300, 300)
+	os.WriteFile(filepath.Join(dir, "junk.png"), []byte("not an image"), 0644)
+
+	App := NewAppServer("0", 30)
+	App.AddURLs(Images("/thumbs/", dir, ImageOptions{
+		Sizes:     []string{"100x100", "400x400"},
+		MaxPixels: 200 * 200,
+	}))
+	get := func(path string) (*httptest.ResponseRecorder, image.Config) {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		config, _, _ := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		return w, config
+	}
+
+	for _, test := range []struct {
+		path          string
+		width, height int
+	}{
+		// scaled down to fit, keeping the aspect ratio.
+		{"/thumbs/100x100/wide.png", 100, 50},
+		// never scaled up.
+		{"/thumbs/400x400/wide.png", 200, 100},
+		{"/thumbs/100x100/small.png", 40, 30},
+	} {
+		w, config := get(test.path)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s: got %d %q", test.path, w.Code, w.Header().Get("Content-Type"))
+		}
+		if config.Width != test.width || config.Height != test.height {
+			t.Errorf("%s: got %dx%d, want %dx%d", test.path, config.Width, config.Height, test.width, test.height)
+		}
+	}
+
+	for _, path := range []string{
+		"/thumbs/100x100/huge.png",
+		"/thumbs/100x100/junk.png",
+		"/thumbs/100x100/missing.png",
+		"/thumbs/50x50/wide.png",
+		"/thumbs/100x100/q70/wide.png",
+		"/thumbs/100x100/../images_test.go",
+	} {
+		if w, _ := get(path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d", path, w.Code)
+		}
+	}
+}
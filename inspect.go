@@ -0,0 +1,216 @@
+package wedge
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// InspectAction is what happens to a request after it's been inspected.
+type InspectAction int
+
+const (
+	// InspectPass lets the request through untouched.
+	InspectPass InspectAction = iota
+	// InspectLog logs the finding and lets the request through.
+	InspectLog
+	// InspectTag logs the finding and lets the request through, tagged
+	// with the name of the rule so views can see it with InspectionTags.
+	InspectTag
+	// InspectBlock refuses the request with a 403, and reports it to
+	// ReportAbuse if bans are enabled.
+	InspectBlock
+)
+
+// Inspection is the outcome of inspecting a request.
+type Inspection struct {
+	Action InspectAction
+	// Rule names the check which matched, and Detail what it matched.
+	Rule   string
+	Detail string
+}
+
+// RequestInspector checks requests before they're routed, such as for
+// signs of an attack.
+type RequestInspector interface {
+	Inspect(req *http.Request) Inspection
+}
+
+// InspectorFunc lets an ordinary function be used as a RequestInspector.
+type InspectorFunc func(req *http.Request) Inspection
+
+// Inspect calls f(req).
+func (f InspectorFunc) Inspect(req *http.Request) Inspection {
+	return f(req)
+}
+
+// AddInspectors adds inspectors which are run, in order, on every request
+// before it's routed. A request is refused as soon as one blocks it.
+//
+// Example:
+//
+//	App.AddInspectors(wedge.BasicRules)
+func (App *AppServer) AddInspectors(inspectors ...RequestInspector) {
+	App.inspectors = append(App.inspectors, inspectors...)
+}
+
+// inspect runs the inspectors on req, returning it with any tags added,
+// or false if it's blocked.
+func (App *AppServer) inspect(req *http.Request) (*http.Request, bool) {
+	var tags []string
+	for _, inspector := range App.inspectors {
+		found := inspector.Inspect(req)
+		if found.Action == InspectPass {
+			continue
+		}
+		log.Printf("Inspection: %s on %s %s: %q", found.Rule, req.Method, req.URL.Path, found.Detail)
+		switch found.Action {
+		case InspectTag:
+			tags = append(tags, found.Rule)
+		case InspectBlock:
+			App.ReportAbuse(req, found.Rule)
+			return req, false
+		}
+	}
+	if len(tags) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), inspectKey, tags))
+	}
+	return req, true
+}
+
+// InspectionTags returns the rules which tagged req when it was inspected.
+func InspectionTags(req *http.Request) []string {
+	tags, _ := req.Context().Value(inspectKey).([]string)
+	return tags
+}
+
+// InspectionRule is a signature looked for by a RuleSet.
+type InspectionRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Action  InspectAction
+	// Headers are the request headers the rule checks, as well as the
+	// path and query string.
+	Headers []string
+}
+
+// RuleSet is a RequestInspector which looks for signatures in the
+// unescaped path, query string and chosen headers of requests. The first
+// rule with the strongest action among those which match wins.
+type RuleSet []InspectionRule
+
+// Inspect implements RequestInspector.
+func (rules RuleSet) Inspect(req *http.Request) Inspection {
+	path, query := req.URL.Path, unescapeQuery(req.URL.RawQuery)
+	var found Inspection
+	for _, rule := range rules {
+		if rule.Action <= found.Action {
+			continue
+		}
+		targets := append([]string{path, query}, headerValues(req, rule.Headers)...)
+		for _, target := range targets {
+			if match := rule.Pattern.FindString(target); match != "" {
+				found = Inspection{Action: rule.Action, Rule: rule.Name, Detail: match}
+				break
+			}
+		}
+	}
+	return found
+}
+
+// unescapeQuery unescapes a query string as far as it can. Malformed
+// escapes are left as they are rather than failing the whole query, so
+// that one can't hide the rest of it from the rules.
+func unescapeQuery(query string) string {
+	if unescaped, err := url.QueryUnescape(query); err == nil {
+		return unescaped
+	}
+	var b strings.Builder
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '+':
+			b.WriteByte(' ')
+		case c == '%' && i+2 < len(query) && isHex(query[i+1]) && isHex(query[i+2]):
+			n, _ := strconv.ParseUint(query[i+1:i+3], 16, 8)
+			b.WriteByte(byte(n))
+			i += 2
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func headerValues(req *http.Request, headers []string) []string {
+	var values []string
+	for _, header := range headers {
+		values = append(values, req.Header.Values(header)...)
+	}
+	return values
+}
+
+// BasicRules catches the crudest SQL injection, cross-site scripting and
+// path traversal attempts, along with requests carrying control
+// characters. It's a starting point, not a substitute for escaping and
+// parameterised queries, and is best tried with InspectLog actions before
+// blocking anything.
+var BasicRules = RuleSet{
+	{
+		Name:    "sql-injection",
+		Pattern: regexp.MustCompile(`(?i)\bunion\b[\s(]+(all\s+)?select\b|'\s*or\s+'?\d+'?\s*=\s*'?\d+|;\s*(drop|delete|truncate)\s+table\b|\b(sleep|benchmark|pg_sleep)\s*\(\s*\d`),
+		Action:  InspectBlock,
+		Headers: []string{"User-Agent", "Referer"},
+	},
+	{
+		Name:    "xss",
+		Pattern: regexp.MustCompile(`(?i)<\s*script\b|javascript\s*:|\bon(error|load|mouseover)\s*=`),
+		Action:  InspectBlock,
+		Headers: []string{"Referer"},
+	},
+	{
+		Name:    "path-traversal",
+		Pattern: regexp.MustCompile(`(^|[/\\])\.\.([/\\]|$)`),
+		Action:  InspectBlock,
+	},
+	{
+		Name:    "control-characters",
+		Pattern: regexp.MustCompile(`[\x00-\x08\x0b\x0c\x0e-\x1f]`),
+		Action:  InspectBlock,
+		Headers: []string{"User-Agent", "Referer", "Cookie"},
+	},
+	{
+		Name:    "scanner",
+		Pattern: regexp.MustCompile(`(?i)\b(sqlmap|nikto|nmap|masscan|acunetix|wpscan|zgrab)\b`),
+		Action:  InspectTag,
+		Headers: []string{"User-Agent"},
+	},
+}
+
+// MaxHeaderValue is the longest header value HeaderAnomalies lets pass
+// untagged.
+var MaxHeaderValue = 4096
+
+// HeaderAnomalies tags requests whose headers don't look like they came
+// from a browser: those without a User-Agent, or with an unusually long
+// header value.
+var HeaderAnomalies = InspectorFunc(func(req *http.Request) Inspection {
+	if req.Header.Get("User-Agent") == "" {
+		return Inspection{Action: InspectTag, Rule: "no-user-agent"}
+	}
+	for name, values := range req.Header {
+		for _, value := range values {
+			if len(value) > MaxHeaderValue {
+				return Inspection{Action: InspectTag, Rule: "long-header", Detail: name}
+			}
+		}
+	}
+	return Inspection{}
+})
@@ -0,0 +1,52 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInspectors(t *testing.T) {
+	var tags []string
+	App := NewAppServer("0", 0)
+	App.AddInspectors(BasicRules, HeaderAnomalies)
+	App.AddURLs(
+		URL("^/search", "Search", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			tags = InspectionTags(req)
+			return "results", http.StatusOK
+		}, HTML),
+	)
+
+	tests := []struct {
+		target string
+		agent  string
+		status int
+		tags   string
+	}{
+		{"/search?q=trade+union+selection", "Mozilla/5.0", http.StatusOK, ""},
+		{"/search?q=1'+UNION+SELECT+password+FROM+users", "Mozilla/5.0", http.StatusForbidden, ""},
+		{"/search?q=%3Cscript%3Ealert(1)%3C/script%3E", "Mozilla/5.0", http.StatusForbidden, ""},
+		// a malformed escape elsewhere doesn't hide the rest of the query.
+		{"/search?q=%3Cscript%3Ealert(1)%3C/script%3E&x=%zz", "Mozilla/5.0", http.StatusForbidden, ""},
+		{"/search?q=%27+UNION+SELECT+1&x=%", "Mozilla/5.0", http.StatusForbidden, ""},
+		{"/search?q=100%25+cotton&x=%zz", "Mozilla/5.0", http.StatusOK, ""},
+		{"/search?file=../../etc/passwd", "Mozilla/5.0", http.StatusForbidden, ""},
+		{"/search?q=x'+or+1=1", "Mozilla/5.0", http.StatusForbidden, ""},
+		{"/search?q=go", "sqlmap/1.7", http.StatusOK, "scanner"},
+		{"/search?q=go", "", http.StatusOK, "no-user-agent"},
+	}
+	for _, test := range tests {
+		tags = nil
+		req := httptest.NewRequest("GET", test.target, nil)
+		req.Header.Set("User-Agent", test.agent)
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s (%s): got %d, want %d", test.target, test.agent, w.Code, test.status)
+		}
+		if got := strings.Join(tags, ","); got != test.tags {
+			t.Errorf("%s (%s): got tags %q, want %q", test.target, test.agent, got, test.tags)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package wedge
+
+import (
+	"log"
+	"net/http"
+	"unicode/utf8"
+)
+
+// OversizePolicy decides what happens to a response which is larger than
+// the limit set with LimitResponses.
+type OversizePolicy int
+
+const (
+	// LogOversize logs a warning and serves the response anyway.
+	LogOversize OversizePolicy = iota
+	// TruncateOversize logs a warning and serves only the first bytes
+	// of the response, up to the limit and without splitting a UTF-8
+	// encoded character.
+	TruncateOversize
+	// FailOversize logs a warning and serves the 500 handler instead.
+	FailOversize
+)
+
+type responseLimit struct {
+	max    int
+	policy OversizePolicy
+}
+
+// LimitResponses sets the largest response, in bytes, which views of
+// handler type t are expected to generate, and what to do when a view
+// goes over it. This guards against a buggy view building an enormous
+// response and sending all of it to the client.
+//
+// Example:
+//
+//	App.LimitResponses(wedge.HTML, 10<<20, wedge.TruncateOversize)
+func (App *AppServer) LimitResponses(t handlertype, max int, policy OversizePolicy) {
+	if App.limits == nil {
+		App.limits = make(map[handlertype]responseLimit)
+	}
+	App.limits[t] = responseLimit{max, policy}
+}
+
+// limitResponse applies the limit for the route's handler type to resp.
+// It returns the response to send, and false if the request should fail.
+func (App *AppServer) limitResponse(req *http.Request, route *Rule, resp string) (string, bool) {
+	limit, ok := App.limits[route.viewtype]
+	if !ok || len(resp) <= limit.max {
+		return resp, true
+	}
+	log.Printf("Oversized response on path: %s (%d bytes, limit %d)\n",
+		req.URL.Path, len(resp), limit.max)
+	switch limit.policy {
+	case TruncateOversize:
+		n := limit.max
+		for i := 0; i < utf8.UTFMax-1 && n > 0 && !utf8.RuneStart(resp[n]); i++ {
+			n--
+		}
+		return resp[:n], true
+	case FailOversize:
+		return "", false
+	}
+	return resp, true
+}
@@ -0,0 +1,55 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimitResponses(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy OversizePolicy
+		max    int
+		body   string
+		code   int
+		want   string
+	}{
+		{"under", TruncateOversize, 10, "short", 200, "short"},
+		{"at", FailOversize, 5, "exact", 200, "exact"},
+		{"log", LogOversize, 4, "too long", 200, "too long"},
+		{"truncate", TruncateOversize, 4, "too long", 200, "too "},
+		// é is two bytes and € three, neither of which is split.
+		{"truncate é", TruncateOversize, 4, "abcé", 200, "abc"},
+		{"truncate €", TruncateOversize, 5, "abc€", 200, "abc"},
+		{"truncate after €", TruncateOversize, 6, "abc€d", 200, "abc€"},
+		{"fail", FailOversize, 4, "too long", 500, "sorry"},
+	} {
+		App := NewAppServer("0", 30)
+		App.AddURLs(URL("^/$", "Page", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return test.body, http.StatusOK
+		}, HTML))
+		App.Handler500(func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "sorry", http.StatusInternalServerError
+		})
+		App.LimitResponses(HTML, test.max, test.policy)
+
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != test.code || w.Body.String() != test.want {
+			t.Errorf("%s: got %d %q, want %d %q", test.name, w.Code, w.Body, test.code, test.want)
+		}
+	}
+
+	// limits only apply to their own handler type.
+	App := NewAppServer("0", 30)
+	App.AddURLs(URL("^/$", "Page", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "too long", http.StatusOK
+	}, HTML))
+	App.LimitResponses(JSON, 4, FailOversize)
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "too long" {
+		t.Errorf("got %q", w.Body)
+	}
+}
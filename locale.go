@@ -0,0 +1,271 @@
+package wedge
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale holds the conventions numbers are written with in a language
+// and region.
+type Locale struct {
+	// Tag is the locale's BCP 47 language tag, such as "de-CH".
+	Tag string
+	// Decimal separates whole numbers from fractions and Group separates
+	// the thousands.
+	Decimal, Group string
+	// CurrencyFormat places the currency symbol, ¤, around the number, #,
+	// as in "¤#" or "# ¤".
+	CurrencyFormat string
+	// PercentFormat places the percent sign around the number in the
+	// same way, as in "#%" or "# %".
+	PercentFormat string
+	// Currency is the code of the currency amounts are in when none is
+	// given, such as "EUR".
+	Currency string
+}
+
+const nbsp, narrowNbsp = "\u00a0", "\u202f"
+
+// Locales are the locales requests are matched against, keyed by their
+// tags in lower case. Languages are matched by their tag alone as well,
+// so "de" should be the locale for German without a region. Apps can add
+// their own before serving requests.
+var Locales = map[string]*Locale{
+	"en":    {"en", ".", ",", "¤#", "#%", "USD"},
+	"en-us": {"en-US", ".", ",", "¤#", "#%", "USD"},
+	"en-gb": {"en-GB", ".", ",", "¤#", "#%", "GBP"},
+	"de":    {"de", ",", ".", "#" + nbsp + "¤", "#" + nbsp + "%", "EUR"},
+	"de-ch": {"de-CH", ".", "’", "¤" + nbsp + "#", "#%", "CHF"},
+	"fr":    {"fr", ",", narrowNbsp, "#" + nbsp + "¤", "#" + narrowNbsp + "%", "EUR"},
+	"es":    {"es", ",", ".", "#" + nbsp + "¤", "#" + nbsp + "%", "EUR"},
+	"it":    {"it", ",", ".", "#" + nbsp + "¤", "#%", "EUR"},
+	"nl":    {"nl", ",", ".", "¤" + nbsp + "#", "#%", "EUR"},
+	"pt":    {"pt", ",", ".", "¤" + nbsp + "#", "#%", "BRL"},
+	"sv":    {"sv", ",", nbsp, "#" + nbsp + "¤", "#" + nbsp + "%", "SEK"},
+	"pl":    {"pl", ",", nbsp, "#" + nbsp + "¤", "#%", "PLN"},
+	"ru":    {"ru", ",", nbsp, "#" + nbsp + "¤", "#" + nbsp + "%", "RUB"},
+	"ja":    {"ja", ".", ",", "¤#", "#%", "JPY"},
+	"zh":    {"zh", ".", ",", "¤#", "#%", "CNY"},
+}
+
+// DefaultLocale is the tag of the locale used for requests which accept
+// none of Locales.
+var DefaultLocale = "en"
+
+// currencySymbols are the symbols of currencies, which are otherwise
+// written by their codes.
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹",
+	"BRL": "R$", "SEK": "kr", "PLN": "zł", "RUB": "₽", "KRW": "₩",
+}
+
+// currencyDigits are the number of decimal places of currencies which
+// don't have 2.
+var currencyDigits = map[string]int{"JPY": 0, "KRW": 0, "CLP": 0, "ISK": 0, "BHD": 3, "KWD": 3}
+
+// findLocale returns the locale for tag, or for its language, or nil.
+func findLocale(tag string) *Locale {
+	tag = strings.ToLower(strings.Replace(tag, "_", "-", -1))
+	if l, ok := Locales[tag]; ok {
+		return l
+	}
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		return Locales[tag[:i]]
+	}
+	return nil
+}
+
+// WithLocale returns a shallow copy of req in the locale with tag, for
+// middleware which knows better than Accept-Language, such as from a
+// visitor's settings or the path. Tags not in Locales are ignored.
+func WithLocale(req *http.Request, tag string) *http.Request {
+	l := findLocale(tag)
+	if l == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), localeKey, l))
+}
+
+// RequestLocale returns the locale of req: the one given to WithLocale,
+// or else the one its Accept-Language header prefers most out of
+// Locales, or DefaultLocale. It's passed to templates for the number,
+// currency and percent functions of LocaleFuncs.
+//
+// Example:
+//
+//	return templates.Render("order.html", struct {
+//		Order  *Order
+//		Locale *wedge.Locale
+//	}{order, wedge.RequestLocale(req)})
+func RequestLocale(req *http.Request) *Locale {
+	if l, ok := req.Context().Value(localeKey).(*Locale); ok {
+		return l
+	}
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var accepted []weighted
+	for _, part := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted = append(accepted, weighted{tag, q})
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+	for _, a := range accepted {
+		if a.q <= 0 {
+			break
+		}
+		if l := findLocale(a.tag); l != nil {
+			return l
+		}
+	}
+	return orDefault(nil)
+}
+
+// LocaleFuncs are template functions which write numbers as the locale
+// they're given does, to be passed to NewTemplateRegistry or
+// TemplateBundle.Parse along with any others. A nil locale is
+// DefaultLocale.
+//
+//	{{number .Locale 1234.5}}          1,234.5 or 1.234,5
+//	{{number .Locale 1234.5 2}}        1,234.50 or 1.234,50
+//	{{currency .Locale 1234.5}}        $1,234.50 in the locale's currency
+//	{{currency .Locale 1234.5 "EUR"}}  €1,234.50 or 1.234,50 €
+//	{{percent .Locale 0.125 1}}        12.5% or 12,5 %
+//
+// Numbers have up to 3 decimal places unless a number of them is given,
+// amounts have as many as their currency, and percentages none. Halves
+// are rounded to even.
+var LocaleFuncs = template.FuncMap{
+	"number": func(l *Locale, v interface{}, digits ...int) (string, error) {
+		return formatLocale(l, v, -3, digits, "#")
+	},
+	"currency": func(l *Locale, v interface{}, code ...string) (string, error) {
+		l = orDefault(l)
+		currency := l.Currency
+		if len(code) > 0 {
+			currency = strings.ToUpper(code[0])
+		}
+		places, ok := currencyDigits[currency]
+		if !ok {
+			places = 2
+		}
+		symbol, ok := currencySymbols[currency]
+		if !ok {
+			symbol = currency
+		}
+		return formatLocale(l, v, places, nil, strings.Replace(l.CurrencyFormat, "¤", symbol, 1))
+	},
+	"percent": func(l *Locale, v interface{}, digits ...int) (string, error) {
+		f, err := toFloat(v)
+		if err != nil {
+			return "", err
+		}
+		l = orDefault(l)
+		return formatLocale(l, f*100, 0, digits, l.PercentFormat)
+	},
+}
+
+// orDefault returns l, or the DefaultLocale if it's nil.
+func orDefault(l *Locale) *Locale {
+	if l != nil {
+		return l
+	}
+	if l = findLocale(DefaultLocale); l != nil {
+		return l
+	}
+	return Locales["en"]
+}
+
+// formatLocale writes v with digits decimal places, or places if none
+// are given, into format in place of #. Negative places are the most
+// there may be, with trailing zeros dropped.
+func formatLocale(l *Locale, v interface{}, places int, digits []int, format string) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	l = orDefault(l)
+	if len(digits) > 0 {
+		places = digits[0]
+	}
+	trim := places < 0
+	if trim {
+		places = -places
+	}
+	s := strconv.FormatFloat(math.Abs(f), 'f', places, 64)
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if trim {
+		frac = strings.TrimRight(frac, "0")
+	}
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	number := strings.Replace(format, "#", b.String(), 1)
+	if f < 0 && strings.Trim(whole+frac, "0") != "" {
+		number = "-" + number
+	}
+	return number, nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf("wedge: can't format %T as a number", v)
+}
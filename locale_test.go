@@ -0,0 +1,71 @@
+package wedge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestLocale(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                        "en",
+		"de-DE,de;q=0.9,en;q=0.8": "de",
+		"en-GB":                   "en-GB",
+		"xx, fr-CA;q=0.5":         "fr",
+		"ja;q=0.1, de-CH;q=0.9":   "de-CH",
+		"pt_BR":                   "pt",
+		"sv;q=0, *":               "en",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", accept)
+		if got := RequestLocale(req).Tag; got != want {
+			t.Errorf("%q: got %s, want %s", accept, got, want)
+		}
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "de")
+	if got := RequestLocale(WithLocale(req, "fr-FR")).Tag; got != "fr" {
+		t.Errorf("WithLocale: got %s", got)
+	}
+	if got := RequestLocale(WithLocale(req, "tlh")).Tag; got != "de" {
+		t.Errorf("WithLocale with an unknown tag: got %s", got)
+	}
+}
+
+func TestLocaleFuncs(t *testing.T) {
+	registry, err := NewTemplateRegistry(TemplateBundle{
+		"number.html":   `{{number .L .V}}|{{number .L .V 2}}`,
+		"currency.html": `{{currency .L .V}}|{{currency .L .V "JPY"}}`,
+		"percent.html":  `{{percent .L .V}}|{{percent .L .V 1}}`,
+	}, LocaleFuncs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// halves are rounded to even, as they are by CLDR.
+	for _, test := range []struct {
+		name   string
+		locale string
+		value  interface{}
+		want   string
+	}{
+		{"number.html", "en", 1234567.891, "1,234,567.891|1,234,567.89"},
+		{"number.html", "de", 1234.5, "1.234,5|1.234,50"},
+		{"number.html", "fr", -1234, "-1\u202f234|-1\u202f234,00"},
+		{"number.html", "de-CH", 12, "12|12.00"},
+		{"number.html", "en", -0.0001, "0|0.00"},
+		{"currency.html", "en", 1234.5, "$1,234.50|¥1,234"},
+		{"currency.html", "de", -1234.5, "-1.234,50\u00a0€|-1.234\u00a0¥"},
+		{"currency.html", "de-CH", 99, "CHF\u00a099.00|¥\u00a099"},
+		{"percent.html", "en", 0.125, "12%|12.5%"},
+		{"percent.html", "fr", 0.07, "7\u202f%|7,0\u202f%"},
+	} {
+		got, err := registry.Render(test.name, map[string]interface{}{"L": findLocale(test.locale), "V": test.value})
+		if err != nil || got != test.want {
+			t.Errorf("%s %s %v: got %q (%v), want %q", test.name, test.locale, test.value, got, err, test.want)
+		}
+	}
+
+	// templates without a locale use the default one.
+	if got, _ := registry.Render("number.html", map[string]interface{}{"L": (*Locale)(nil), "V": 1000}); got != "1,000|1,000.00" {
+		t.Errorf("without a locale: got %q", got)
+	}
+}
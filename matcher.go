@@ -0,0 +1,160 @@
+package wedge
+
+import (
+	"container/list"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RegexpCacheSize is how many routes' regular expressions are kept
+// compiled. The rest are compiled again when they're next needed, so
+// apps which generate thousands of routes only hold the busy ones in
+// memory. Routes whose patterns are literal paths, such as "^/about/$",
+// are matched by comparing strings and are never compiled.
+var RegexpCacheSize = 1024
+
+// matcher matches paths against a route's pattern. It has the methods of
+// *regexp.Regexp which routes use, but only compiles the pattern when it
+// isn't a literal path, and then lazily through the regexpCache.
+type matcher struct {
+	re string
+	// names are the names of the pattern's groups, as SubexpNames.
+	names []string
+	// literal is the only path a literal pattern matches.
+	literal   string
+	isLiteral bool
+}
+
+// newMatcher returns the matcher for re, panicking if it isn't a valid
+// regular expression, as regexp.MustCompile does.
+func newMatcher(re string) *matcher {
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		panic("regexp: Compile(" + strconv.Quote(re) + "): " + err.Error())
+	}
+	m := &matcher{re: re, names: parsed.CapNames()}
+	m.literal, m.isLiteral = exactPath(parsed)
+	return m
+}
+
+// exactPath returns the path re matches if it's anchored at both ends
+// and matches nothing else.
+func exactPath(re *syntax.Regexp) (string, bool) {
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 ||
+		re.Sub[0].Op != syntax.OpBeginText || re.Sub[len(re.Sub)-1].Op != syntax.OpEndText {
+		return "", false
+	}
+	var path strings.Builder
+	for _, sub := range re.Sub[1 : len(re.Sub)-1] {
+		if !writeExact(&path, sub) {
+			return "", false
+		}
+	}
+	return path.String(), true
+}
+
+func writeExact(b *strings.Builder, re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return false
+		}
+		b.WriteString(string(re.Rune))
+	case syntax.OpEmptyMatch:
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !writeExact(b, sub) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *matcher) String() string {
+	return m.re
+}
+
+func (m *matcher) SubexpNames() []string {
+	return m.names
+}
+
+func (m *matcher) MatchString(s string) bool {
+	if m.isLiteral {
+		return s == m.literal
+	}
+	return m.compiled().MatchString(s)
+}
+
+func (m *matcher) FindStringSubmatch(s string) []string {
+	if m.isLiteral {
+		if s != m.literal {
+			return nil
+		}
+		return []string{s}
+	}
+	return m.compiled().FindStringSubmatch(s)
+}
+
+func (m *matcher) FindAllStringSubmatch(s string, n int) [][]string {
+	if m.isLiteral {
+		if s != m.literal || n == 0 {
+			return nil
+		}
+		return [][]string{{s}}
+	}
+	return m.compiled().FindAllStringSubmatch(s, n)
+}
+
+// compiled returns the compiled form of the pattern from the cache,
+// compiling it if it isn't there.
+func (m *matcher) compiled() *regexp.Regexp {
+	return regexps.get(m.re)
+}
+
+// regexps holds the compiled patterns of the routes used most recently.
+var regexps = &regexpCache{entries: make(map[string]*list.Element), order: list.New()}
+
+type regexpCache struct {
+	sync.Mutex
+	entries map[string]*list.Element
+	// order holds the most recently used pattern at the front.
+	order *list.List
+}
+
+type cachedRegexp struct {
+	re       string
+	compiled *regexp.Regexp
+}
+
+func (c *regexpCache) get(re string) *regexp.Regexp {
+	c.Lock()
+	if e, ok := c.entries[re]; ok {
+		c.order.MoveToFront(e)
+		c.Unlock()
+		return e.Value.(*cachedRegexp).compiled
+	}
+	c.Unlock()
+
+	// patterns are checked when their routes are made, and compiling can
+	// take a while, so it's done without holding the lock.
+	compiled := regexp.MustCompile(re)
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[re]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*cachedRegexp).compiled
+	}
+	c.entries[re] = c.order.PushFront(&cachedRegexp{re, compiled})
+	for c.order.Len() > RegexpCacheSize && c.order.Len() > 1 {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedRegexp).re)
+	}
+	return compiled
+}
@@ -0,0 +1,75 @@
+package wedge
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExactPath(t *testing.T) {
+	for re, want := range map[string]string{
+		"^/about/$":          "/about/",
+		"^(?:/about/)$":      "/about/",
+		`^/a\.b$`:            "/a.b",
+		"^$":                 "",
+		"^/about/?$":         "-",
+		"/about/$":           "-",
+		"^/about/":           "-",
+		"^(?i)/about/$":      "-",
+		"^/posts/([0-9]+)$":  "-",
+		"^/(?P<name>about)$": "-",
+	} {
+		m := newMatcher(re)
+		got := m.literal
+		if !m.isLiteral {
+			got = "-"
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", re, got, want)
+		}
+	}
+}
+
+func TestRegexpCache(t *testing.T) {
+	defer func(size int) { RegexpCacheSize = size }(RegexpCacheSize)
+	RegexpCacheSize = 10
+
+	App := NewAppServer("0", 30)
+	for i := 0; i < 1000; i++ {
+		i := i
+		App.AddURLs(
+			Route(fmt.Sprintf("^/static/%d/$", i), func(w http.ResponseWriter, req *http.Request) (string, int) {
+				return fmt.Sprint("static ", i), http.StatusOK
+			}),
+			Route(fmt.Sprintf("^/items/%d/(?P<id>[0-9]+)/$", i), func(w http.ResponseWriter, req *http.Request) (string, int) {
+				return fmt.Sprint("item ", i, " ", Params(req)["id"]), http.StatusOK
+			}),
+		)
+	}
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Body.String()
+	}
+	for i := 0; i < 1000; i += 37 {
+		if got, want := get(fmt.Sprintf("/static/%d/", i)), fmt.Sprint("static ", i); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got, want := get(fmt.Sprintf("/items/%d/42/", i)), fmt.Sprint("item ", i, " 42"); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	regexps.Lock()
+	defer regexps.Unlock()
+	if regexps.order.Len() > RegexpCacheSize || len(regexps.entries) != regexps.order.Len() {
+		t.Errorf("%d patterns are cached", regexps.order.Len())
+	}
+	if _, ok := regexps.entries["^/static/37/$"]; ok {
+		t.Error("a literal pattern was compiled")
+	}
+	if _, ok := regexps.entries["^/items/999/(?P<id>[0-9]+)/$"]; !ok {
+		t.Error("the most recent pattern isn't cached")
+	}
+}
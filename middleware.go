@@ -0,0 +1,89 @@
+package wedge
+
+import "net/http"
+
+// Middleware wraps a view, such as to check the request before calling
+// it or to change its response. It can return without calling next to
+// answer the request itself.
+//
+// Example:
+//
+//	func RequireLogin(next wedge.View) wedge.View {
+//		return func(w http.ResponseWriter, req *http.Request) (string, int) {
+//			if !loggedIn(req) {
+//				return "/login/", http.StatusSeeOther
+//			}
+//			return next(w, req)
+//		}
+//	}
+type Middleware func(next View) View
+
+// Use wraps the view of every route in middleware, the first given being
+// the outermost. Middleware runs before the cache is checked, so it can
+// refuse requests for cached pages too. Routes in a Group are wrapped in
+// the AppServer's middleware outside the Group's, and routes with tags in
+// it outside that given to UseTag.
+//
+// Example:
+//
+//	App.Use(LogRequests, RequireLogin)
+func (App *AppServer) Use(middleware ...Middleware) {
+	App.middleware = append(App.middleware, middleware...)
+}
+
+// UseHandler wraps the whole AppServer in http.Handler middleware, such as
+// compression, the first given being the outermost. It applies to servers
+// started with Run, RunE and Start, and to the Handler method.
+func (App *AppServer) UseHandler(wrappers ...func(http.Handler) http.Handler) {
+	App.wrappers = append(App.wrappers, wrappers...)
+}
+
+// Handler returns the AppServer wrapped in the middleware given to
+// UseHandler, for serving it with an http.Server of your own.
+func (App *AppServer) Handler() http.Handler {
+	var h http.Handler = App
+	for i := len(App.wrappers) - 1; i >= 0; i-- {
+		h = App.wrappers[i](h)
+	}
+	return h
+}
+
+// callRoute gets the response to req from route, through the middleware.
+func (App *AppServer) callRoute(w http.ResponseWriter, req *http.Request, route *Rule) (string, int) {
+	tagged := App.tagMiddlewareFor(route)
+	if len(App.middleware)+len(tagged)+len(route.middleware) == 0 {
+		return App.getResponse(w, req, route)
+	}
+	v := View(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return App.getResponse(w, req, route)
+	})
+	for i := len(route.middleware) - 1; i >= 0; i-- {
+		v = route.middleware[i](v)
+	}
+	for i := len(tagged) - 1; i >= 0; i-- {
+		v = tagged[i](v)
+	}
+	for i := len(App.middleware) - 1; i >= 0; i-- {
+		v = App.middleware[i](v)
+	}
+	return v(w, req)
+}
+
+// With wraps the route's view in middleware, the first given being the
+// outermost, inside any from Use and the route's Group. Like them it runs
+// before the cache is checked.
+//
+// Example:
+//
+//	wedge.URL("^/account/$", "Account", Account, wedge.HTML).With(RequireLogin, Throttle)
+func (u *Rule) With(middleware ...Middleware) *Rule {
+	u.middleware = append(u.middleware, middleware...)
+	return u
+}
+
+// With is the Option form of Rule.With.
+func With(middleware ...Middleware) Option {
+	return func(u *Rule) {
+		u.With(middleware...)
+	}
+}
@@ -0,0 +1,120 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next View) View {
+			return func(w http.ResponseWriter, req *http.Request) (string, int) {
+				order = append(order, name)
+				return next(w, req)
+			}
+		}
+	}
+	auth := func(next View) View {
+		return func(w http.ResponseWriter, req *http.Request) (string, int) {
+			if req.Header.Get("X-Token") == "" {
+				return "", http.StatusNotFound
+			}
+			return next(w, req)
+		}
+	}
+
+	App := NewAppServer("0", 0)
+	App.Use(trace("first"), trace("second"))
+	App.AddURLs(
+		Route("^/cached$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			order = append(order, "view")
+			return "cached", http.StatusOK
+		}, Cache(time.Minute)),
+	)
+	App.Group("/private").Use(trace("group"), auth).AddURLs(
+		Route("^/$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "secret", http.StatusOK
+		}, Cache(time.Minute)),
+	)
+	App.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Wrapped", "yes")
+			next.ServeHTTP(w, req)
+		})
+	})
+	h := App.Handler()
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("X-Token", token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/cached", "")
+	if got := strings.Join(order, ","); got != "first,second,view" {
+		t.Errorf("middleware ran as %s", got)
+	}
+	if w.Header().Get("X-Wrapped") != "yes" {
+		t.Error("handler middleware didn't run")
+	}
+	order = nil
+	get("/cached", "")
+	if got := strings.Join(order, ","); got != "first,second" {
+		t.Errorf("middleware ran as %s for a cached response", got)
+	}
+
+	if w := get("/private/", "t"); w.Body.String() != "secret" {
+		t.Errorf("authorised request got %d %q", w.Code, w.Body.String())
+	}
+	order = nil
+	if w := get("/private/", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unauthorised request for a cached page got %d", w.Code)
+	}
+	if got := strings.Join(order, ","); got != "first,second,group" {
+		t.Errorf("middleware ran as %s in a group", got)
+	}
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next View) View {
+			return func(w http.ResponseWriter, req *http.Request) (string, int) {
+				order = append(order, name)
+				return next(w, req)
+			}
+		}
+	}
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		order = append(order, "view")
+		return "ok", http.StatusOK
+	}
+
+	App := NewAppServer("0", 0)
+	App.Use(trace("app"))
+	api := App.Group("/api").Use(trace("group"))
+	api.AddURLs(
+		URL("^/a$", "A", view, HTML).With(trace("a1"), trace("a2")),
+		Route("^/b$", view, With(trace("b"))),
+		URL("^/c$", "C", view, HTML),
+	)
+
+	for path, want := range map[string]string{
+		"/api/a": "app,group,a1,a2,view",
+		"/api/b": "app,group,b,view",
+		"/api/c": "app,group,view",
+	} {
+		order = nil
+		App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if got := strings.Join(order, ","); got != want {
+			t.Errorf("%s: middleware ran as %s, want %s", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package wedge
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NDJSONFlush is how long what's been written of an NDJSON response may
+// be held back before it's flushed to the client, so that items are sent
+// as they come without a flush for each of them.
+var NDJSONFlush = 100 * time.Millisecond
+
+// ItemsView is a view whose response is the items it sends on a channel,
+// which it closes after the last. It should stop sending once
+// req.Context() is done, as it is when the client has gone away.
+type ItemsView func(http.ResponseWriter, *http.Request) (<-chan interface{}, int)
+
+// ItemsRoute is the form of StreamRoute for an ItemsView. Its items are
+// sent as a JSON array, as by JSONArray, or on lines of their own for an
+// NDJSON route, as by JSONLines, such as for log tails and bulk exports.
+// They're encoded as they're received, and a client which goes away
+// stops the response, however many are left.
+//
+// Example:
+//
+//	wedge.ItemsRoute("^/export/events$", func(w http.ResponseWriter, req *http.Request) (<-chan interface{}, int) {
+//		events := make(chan interface{})
+//		go func() {
+//			defer close(events)
+//			for e := range tail(req.Context()) {
+//				events <- e
+//			}
+//		}()
+//		return events, http.StatusOK
+//	}, wedge.ContentType(wedge.NDJSON))
+func ItemsRoute(re string, v ItemsView, opts ...Option) *Rule {
+	var u *Rule
+	u = StreamRoute(re, func(w http.ResponseWriter, req *http.Request) (io.Reader, int) {
+		items, status := v(w, req)
+		if items == nil {
+			return nil, status
+		}
+		return &jsonItems{items: items, lines: u.viewtype == NDJSON, done: req.Context().Done()}, status
+	}, append([]Option{ContentType(JSON)}, opts...)...)
+	return u
+}
+
+// flushWriter flushes what's written to it once it's been held for
+// NDJSONFlush.
+type flushWriter struct {
+	sync.Mutex
+	w       io.Writer
+	flusher http.Flusher
+	timer   *time.Timer
+	stopped bool
+}
+
+func newFlushWriter(w http.ResponseWriter) *flushWriter {
+	f := &flushWriter{w: w}
+	f.flusher, _ = w.(http.Flusher)
+	return f
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	n, err := f.w.Write(p)
+	if f.flusher != nil && f.timer == nil {
+		f.timer = time.AfterFunc(NDJSONFlush, f.flush)
+	}
+	return n, err
+}
+
+func (f *flushWriter) flush() {
+	f.Lock()
+	defer f.Unlock()
+	if !f.stopped {
+		f.flusher.Flush()
+	}
+	f.timer = nil
+}
+
+// stop cancels any pending flush, as the response is over.
+func (f *flushWriter) stop() {
+	f.Lock()
+	defer f.Unlock()
+	f.stopped = true
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+}
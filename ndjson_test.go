@@ -0,0 +1,106 @@
+package wedge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flushCounter counts the times a response was flushed.
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes int32
+}
+
+func (f *flushCounter) Flush() {
+	atomic.AddInt32(&f.flushes, 1)
+	f.ResponseRecorder.Flush()
+}
+
+func TestNDJSON(t *testing.T) {
+	defer func(d time.Duration) { NDJSONFlush = d }(NDJSONFlush)
+	NDJSONFlush = 10 * time.Millisecond
+
+	var w *flushCounter
+	stopped := make(chan bool, 1)
+	items := func(n int) ItemsView {
+		return func(w http.ResponseWriter, req *http.Request) (<-chan interface{}, int) {
+			ch := make(chan interface{})
+			go func() {
+				defer close(ch)
+				for i := 0; i != n; i++ {
+					select {
+					case ch <- map[string]int{"n": i}:
+					case <-req.Context().Done():
+						stopped <- true
+						return
+					}
+				}
+			}()
+			return ch, http.StatusOK
+		}
+	}
+	App := NewAppServer("0", 30)
+	App.AddURLs(
+		ItemsRoute("^/events$", items(3), ContentType(NDJSON)),
+		ItemsRoute("^/events.json$", items(3)),
+		ItemsRoute("^/forever$", items(-1), ContentType(NDJSON)),
+		ItemsRoute("^/slow$", func(_ http.ResponseWriter, req *http.Request) (<-chan interface{}, int) {
+			ch := make(chan interface{})
+			go func() {
+				defer close(ch)
+				ch <- "first"
+				// the first item reaches the client before the next.
+				for atomic.LoadInt32(&w.flushes) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				ch <- "second"
+			}()
+			return ch, http.StatusOK
+		}, ContentType(NDJSON)),
+	)
+	get := func(req *http.Request) *flushCounter {
+		w = &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	w = get(httptest.NewRequest("GET", "/events", nil))
+	if got := w.Body.String(); got != "{\"n\":0}\n{\"n\":1}\n{\"n\":2}\n" {
+		t.Errorf("got %q", got)
+	}
+	if ctype := w.Header().Get("Content-Type"); ctype != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q", ctype)
+	}
+	w = get(httptest.NewRequest("GET", "/events.json", nil))
+	if got := w.Body.String(); got != `[{"n":0},{"n":1},{"n":2}]`+"\n" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %q with %v", got, w.Header())
+	}
+
+	done := make(chan bool)
+	go func() {
+		w = get(httptest.NewRequest("GET", "/slow", nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first item was never flushed")
+	}
+	if got := w.Body.String(); got != "\"first\"\n\"second\"\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// a client which has gone away stops the items.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	get(httptest.NewRequest("GET", "/forever", nil).WithContext(ctx))
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("the view wasn't stopped")
+	}
+}
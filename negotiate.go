@@ -0,0 +1,174 @@
+package wedge
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DataView is a view whose response is a value, which is written in
+// whichever representation the client prefers of those offered by its
+// route. See Negotiate.
+type DataView func(http.ResponseWriter, *http.Request) (interface{}, int)
+
+// Renderer writes the value a DataView returned in one representation.
+type Renderer func(v interface{}) (string, error)
+
+// offer is a representation of a Negotiate route's responses.
+type offer struct {
+	mediaType string
+	render    Renderer
+}
+
+// RenderJSON is the Renderer for JSON.
+func RenderJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// RenderXML is the Renderer for XML, which adds the XML declaration.
+func RenderXML(v interface{}) (string, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+// Renderer returns the Renderer which executes the template called name
+// with the value, for HTML representations.
+func (r *TemplateRegistry) Renderer(name string) Renderer {
+	return func(v interface{}) (string, error) {
+		return r.Render(name, v)
+	}
+}
+
+// Negotiate returns a route whose view's value is sent in each of the
+// representations it's offered in with Offer, choosing the one the
+// request's Accept header prefers, so one route serves a page, its JSON
+// and its XML. Requests without an Accept header get the first offered,
+// and those which accept none of them a 406 Not Acceptable. Routes
+// offered in nothing else are offered in JSON and then XML.
+//
+// Responses vary on Accept, so cached routes cache each representation
+// apart.
+//
+// Example:
+//
+//	wedge.Negotiate("^/posts/(?P<id>[0-9]+)$", Post,
+//		wedge.Offer("text/html; charset=utf-8", templates.Renderer("post.html")),
+//		wedge.Offer("application/json", wedge.RenderJSON),
+//		wedge.Offer("application/xml", wedge.RenderXML),
+//	)
+func Negotiate(re string, v DataView, opts ...Option) *Rule {
+	var u *Rule
+	u = Route(re, func(w http.ResponseWriter, req *http.Request) (string, int) {
+		offers := u.offers
+		if len(offers) == 0 {
+			offers = []offer{{"application/json", RenderJSON}, {"application/xml", RenderXML}}
+		}
+		chosen, ok := negotiate(req.Header.Get("Accept"), offers)
+		if !ok {
+			return "Not Acceptable", http.StatusNotAcceptable
+		}
+		value, status := v(w, req)
+		if value == nil && status != http.StatusOK {
+			return "", status
+		}
+		resp, err := chosen.render(value)
+		if err != nil {
+			log.Println("Error rendering response:", u.name, chosen.mediaType, err)
+			return "", http.StatusInternalServerError
+		}
+		w.Header().Set("Content-Type", chosen.mediaType)
+		return resp, status
+	}, opts...)
+	u.Vary("Accept")
+	return u
+}
+
+// Offer offers the responses of a Negotiate route as mediaType, such as
+// "application/json", rendered by r. Representations are preferred in
+// the order they're offered when a client accepts several equally.
+func (u *Rule) Offer(mediaType string, r Renderer) *Rule {
+	u.offers = append(u.offers, offer{mediaType, r})
+	return u
+}
+
+// Offer is the Option form of Rule.Offer.
+func Offer(mediaType string, r Renderer) Option {
+	return func(u *Rule) {
+		u.Offer(mediaType, r)
+	}
+}
+
+// negotiate returns the offer accept prefers, by the quality of the most
+// specific media range matching each, as in RFC 7231 section 5.3.2.
+func negotiate(accept string, offers []offer) (offer, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		typ, subtype := splitMediaType(fields[0])
+		ranges = append(ranges, mediaRange{typ, subtype, q})
+	}
+	var best offer
+	bestQ := 0.0
+	for _, o := range offers {
+		typ, subtype := splitMediaType(strings.Split(o.mediaType, ";")[0])
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// splitMediaType splits a media type such as "text/html" into its type
+// and subtype, in lower case.
+func splitMediaType(mediaType string) (string, string) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		return mediaType[:i], mediaType[i+1:]
+	}
+	if mediaType == "*" {
+		// sent by some clients for */*.
+		return "*", "*"
+	}
+	return mediaType, ""
+}
@@ -0,0 +1,70 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type post struct {
+	ID    int    `json:"id" xml:"id,attr"`
+	Title string `json:"title" xml:"title"`
+}
+
+func TestNegotiate(t *testing.T) {
+	templates, err := NewTemplateRegistry(TemplateBundle{"post.html": "<h1>{{.Title}}</h1>"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := func(w http.ResponseWriter, req *http.Request) (interface{}, int) {
+		if Params(req)["id"] != "1" {
+			return nil, http.StatusNotFound
+		}
+		return post{1, "Hello"}, http.StatusOK
+	}
+	App := NewAppServer("0", 30)
+	App.AddURLs(
+		Negotiate("^/posts/(?P<id>[0-9]+)$", view,
+			Offer("text/html; charset=utf-8", templates.Renderer("post.html")),
+			Offer("application/json", RenderJSON),
+			Offer("application/xml", RenderXML),
+		),
+		Negotiate("^/data/(?P<id>[0-9]+)$", view),
+	)
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, test := range []struct {
+		path, accept, ctype, body string
+	}{
+		{"/posts/1", "", "text/html; charset=utf-8", "<h1>Hello</h1>"},
+		{"/posts/1", "text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<h1>Hello</h1>"},
+		{"/posts/1", "application/json", "application/json", `{"id":1,"title":"Hello"}` + "\n"},
+		{"/posts/1", "text/*;q=0.5, application/xml", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<post id="1"><title>Hello</title></post>`},
+		{"/posts/1", "*/*;q=0.1, text/html;q=0", "application/json", `{"id":1,"title":"Hello"}` + "\n"},
+		{"/data/1", "*", "application/json", `{"id":1,"title":"Hello"}` + "\n"},
+		{"/data/1", "text/xml, application/xml;q=0.9", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<post id="1"><title>Hello</title></post>`},
+	} {
+		w := get(test.path, test.accept)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != test.ctype || w.Body.String() != test.body {
+			t.Errorf("%s accepting %q: got %d %q %q", test.path, test.accept, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("got Vary %q", vary)
+		}
+	}
+
+	if w := get("/posts/1", "image/png"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("got %d for an unacceptable type", w.Code)
+	}
+	if w := get("/posts/2", "text/html"); w.Code != http.StatusNotFound {
+		t.Errorf("got %d for a missing post", w.Code)
+	}
+}
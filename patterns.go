@@ -0,0 +1,209 @@
+package wedge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	paramsKey contextKey = iota
+	tagsKey
+	suggestionsKey
+	countryKey
+	basePathKey
+	originKey
+	requestIDKey
+	traceKey
+	inspectKey
+	streamKey
+	apiKey
+	localeKey
+)
+
+// converter is a named type which knows which text it can match within a
+// path segment and how to turn the matched text into a Go value.
+type converter struct {
+	re      string
+	convert func(string) (interface{}, error)
+}
+
+func asString(s string) (interface{}, error) {
+	return s, nil
+}
+
+// converters holds the types which may be used in Path patterns,
+// e.g. "/posts/<int:id>/". A parameter without a type, e.g. "<id>",
+// uses the "str" converter.
+var converters = map[string]converter{
+	"str":  {`[^/]+`, asString},
+	"int":  {`[0-9]+`, func(s string) (interface{}, error) { return strconv.Atoi(s) }},
+	"slug": {`[-a-zA-Z0-9_]+`, asString},
+	"uuid": {`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+		func(s string) (interface{}, error) { return strings.ToLower(s), nil }},
+	"path": {`.+`, asString},
+}
+
+var paramre = regexp.MustCompile(`<(?:([a-z]+):)?([a-zA-Z_][a-zA-Z0-9_]*)>`)
+
+// compilePattern turns a pattern such as "/posts/<int:id>/comments" into
+// an anchored regular expression with a named group for each parameter,
+// along with the converter which should be applied to each group.
+//
+// Everything outside of the angle brackets is matched literally.
+func compilePattern(pattern string) (string, map[string]converter, error) {
+	buf := []string{"^"}
+	convs := make(map[string]converter)
+	last := 0
+	for _, loc := range paramre.FindAllStringSubmatchIndex(pattern, -1) {
+		buf = append(buf, regexp.QuoteMeta(pattern[last:loc[0]]))
+		last = loc[1]
+
+		ctype := "str"
+		if loc[2] >= 0 {
+			ctype = pattern[loc[2]:loc[3]]
+		}
+		name := pattern[loc[4]:loc[5]]
+		conv, ok := converters[ctype]
+		if !ok {
+			return "", nil, fmt.Errorf("wedge: unknown converter %q in pattern %q", ctype, pattern)
+		}
+		if _, ok := convs[name]; ok {
+			return "", nil, fmt.Errorf("wedge: duplicate parameter %q in pattern %q", name, pattern)
+		}
+		convs[name] = conv
+		buf = append(buf, fmt.Sprintf("(?P<%s>%s)", name, conv.re))
+	}
+	buf = append(buf, regexp.QuoteMeta(pattern[last:]), "$")
+	return strings.Join(buf, ""), convs, nil
+}
+
+// Path is a function which returns a *Rule value from a simplified
+// pattern rather than a regular expression.
+//
+// Parameters are written as <type:name>, where type is one of int, slug,
+// uuid, path or str. The type is optional and defaults to str, which
+// matches a single path segment. The pattern is always anchored, so
+// "/posts/<int:id>/" will not match "/posts/1/comments/".
+//
+// The converted parameters are available to the view via Params.
+//
+// Path will panic if the pattern refers to an unknown type.
+func Path(pattern, name string, v view, t handlertype) *Rule {
+	re, convs, err := compilePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	u := makeurl(re, name, v, t, 0)
+	u.converters = convs
+	return u
+}
+
+// convertParams runs each named submatch through its converter. If any
+// of them fail then the route should be treated as not having matched.
+func (u *Rule) convertParams(submatches []string) (map[string]interface{}, bool) {
+	params := make(map[string]interface{})
+	for i, name := range u.match.SubexpNames() {
+		conv, ok := u.converters[name]
+		if !ok {
+			continue
+		}
+		val, err := conv.convert(submatches[i])
+		if err != nil {
+			return nil, false
+		}
+		params[name] = val
+	}
+	return params, true
+}
+
+// withParams returns a shallow copy of req which carries params.
+func withParams(req *http.Request, params map[string]interface{}) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), paramsKey, params))
+}
+
+// Params returns the converted URL parameters for the request, from a
+// Path pattern or the named groups of a regular expression. The map will
+// be empty if the route did not declare any parameters.
+//
+// Example:
+//
+//	id := wedge.Params(req)["id"].(int)
+func Params(req *http.Request) map[string]interface{} {
+	params, ok := req.Context().Value(paramsKey).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return params
+}
+
+// StrictURLs enables strict pattern handling for URL, CacheURL, Download
+// and Redirect. When set, patterns are anchored at both ends unless they
+// are registered with RawURL, and patterns which nest unbounded
+// repetitions, e.g. "(a+)+", are rejected with a panic at registration.
+//
+// Go's regular expressions run in linear time so such patterns cannot
+// cause catastrophic backtracking here, but they are almost always a
+// mistake and will misbehave if the route table is ever shared with
+// another router.
+var StrictURLs = false
+
+// strictPattern anchors re when StrictURLs is enabled. The whole pattern
+// is always wrapped, as anchors of its own may only apply to one branch:
+// "^/a|/b$" matches "/x/b". Its leading and trailing anchors are dropped
+// first so that a Group can still mount the route below a prefix.
+func strictPattern(re string) string {
+	if !StrictURLs {
+		return re
+	}
+	re = strings.TrimPrefix(re, "^")
+	if strings.HasSuffix(re, "$") {
+		escapes := len(re) - 1 - len(strings.TrimRight(re[:len(re)-1], `\`))
+		if escapes%2 == 0 {
+			re = re[:len(re)-1]
+		}
+	}
+	return "^(?:" + re + ")$"
+}
+
+// checkPattern returns an error if re contains an unbounded repetition
+// nested inside of another unbounded repetition.
+func checkPattern(re string) error {
+	if !StrictURLs {
+		return nil
+	}
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	if nestedRepeat(parsed, false) {
+		return fmt.Errorf("wedge: pattern %q nests unbounded repetitions", re)
+	}
+	return nil
+}
+
+func nestedRepeat(re *syntax.Regexp, inRepeat bool) bool {
+	unbounded := re.Op == syntax.OpStar || re.Op == syntax.OpPlus ||
+		(re.Op == syntax.OpRepeat && re.Max == -1)
+	if unbounded && inRepeat {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if nestedRepeat(sub, inRepeat || unbounded) {
+			return true
+		}
+	}
+	return false
+}
+
+// RawURL is the same as URL, but the pattern is never anchored even when
+// StrictURLs is enabled.
+func RawURL(re, name string, v view, t handlertype) *Rule {
+	return makeurl(re, name, v, t, 0)
+}
@@ -0,0 +1,116 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/posts/<int:id>/comments", "/posts/12/comments", true},
+		{"/posts/<int:id>/comments", "/posts/abc/comments", false},
+		{"/posts/<int:id>/comments", "/posts/12/comments/extra", false},
+		{"/tags/<slug:tag>/", "/tags/go-lang_1/", true},
+		{"/tags/<slug:tag>/", "/tags/go lang/", false},
+		{"/files/<path:rest>", "/files/a/b/c.txt", true},
+		{"/users/<name>/", "/users/a/b/", false},
+		{"/things/<uuid:id>", "/things/123e4567-e89b-12d3-a456-426614174000", true},
+		{"/a.b/<id>", "/aXb/1", false},
+	}
+	for _, test := range tests {
+		u := Path(test.pattern, "test", nil, HTML)
+		if got := u.match.MatchString(test.path); got != test.match {
+			t.Errorf("%s against %s: got %v, want %v", test.pattern, test.path, got, test.match)
+		}
+	}
+}
+
+func TestCompilePatternErrors(t *testing.T) {
+	for _, pattern := range []string{"/<float:x>/", "/<id>/<int:id>/"} {
+		if _, _, err := compilePattern(pattern); err == nil {
+			t.Errorf("expected an error compiling %s", pattern)
+		}
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	var got map[string]interface{}
+	App := NewAppServer("0", 0)
+	App.AddURLs(
+		Path("/posts/<int:id>/<slug:title>", "post",
+			func(w http.ResponseWriter, req *http.Request) (string, int) {
+				got = Params(req)
+				return "", http.StatusOK
+			}, HTML),
+	)
+	App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/posts/42/hello", nil))
+
+	if got["id"] != 42 || got["title"] != "hello" {
+		t.Errorf("unexpected params: %v", got)
+	}
+}
+
+func TestStrictURLs(t *testing.T) {
+	StrictURLs = true
+	defer func() { StrictURLs = false }()
+
+	if re := strictPattern("/about/"); re != "^(?:/about/)$" {
+		t.Errorf("pattern not anchored: %s", re)
+	}
+	for re, want := range map[string]string{
+		"^/about/$": "^(?:/about/)$",
+		"^/a|/b$":   "^(?:/a|/b)$",
+		`^/price\$`: `^(?:/price\$)$`,
+		`^/dir\\$`:  `^(?:/dir\\)$`,
+	} {
+		if got := strictPattern(re); got != want {
+			t.Errorf("%s: got %s, want %s", re, got, want)
+		}
+	}
+	// anchors which only applied to one branch now apply to them all.
+	App := NewAppServer("0", 0)
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "matched", http.StatusOK
+	}
+	App.AddURLs(URL("^/a|/b$", "Either", view, HTML))
+	App.Group("/g/").AddURLs(URL("^/c/$", "Mounted", view, HTML))
+	for path, want := range map[string]int{"/a": 200, "/b": 200, "/x/b": 404, "/a/x": 404, "/g/c/": 200, "/c/": 404} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: got %d, want %d", path, w.Code, want)
+		}
+	}
+	for _, re := range []string{"^(a+)+$", "^(?:x*y)*$", "^(a{2,})*$"} {
+		if checkPattern(re) == nil {
+			t.Errorf("expected %s to be rejected", re)
+		}
+	}
+	for _, re := range []string{"^/a+/b+$", "^(ab){2,3}$"} {
+		if err := checkPattern(re); err != nil {
+			t.Errorf("expected %s to be accepted: %v", re, err)
+		}
+	}
+}
+
+func TestURLNamedGroups(t *testing.T) {
+	var got map[string]interface{}
+	App := NewAppServer("0", 0)
+	App.AddURLs(
+		URL(`^/users/(?P<id>\d+)/(\w+)/(?P<tab>\w+)$`, "user",
+			func(w http.ResponseWriter, req *http.Request) (string, int) {
+				got = Params(req)
+				return "", http.StatusOK
+			}, HTML),
+	)
+	App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/42/posts/recent", nil))
+
+	if len(got) != 2 || got["id"] != "42" || got["tab"] != "recent" {
+		t.Errorf("unexpected params: %v", got)
+	}
+}
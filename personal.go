@@ -0,0 +1,80 @@
+package wedge
+
+import "net/http"
+
+// DefaultSessionCookies are the cookies which mark a request as coming
+// from a signed in user, unless SetSessionCookies is given others.
+var DefaultSessionCookies = []string{"session", "sessionid", "sid"}
+
+// SetSessionCookies sets the names of the cookies which mark a request as
+// coming from a signed in user. Responses to such requests, and to any
+// carrying an Authorization header, are never taken from or stored in the
+// cache, and are sent with "Cache-Control: private" so that shared caches
+// between the AppServer and the client don't keep them either.
+func (App *AppServer) SetSessionCookies(names ...string) {
+	App.sessions = append([]string{}, names...)
+}
+
+// SharedCache marks the route's responses as the same for every user, so
+// that they're cached even for requests carrying credentials.
+func (u *Rule) SharedCache() *Rule {
+	u.shared_cache = true
+	return u
+}
+
+// SharedCache is the Option form of Rule.SharedCache.
+func SharedCache() Option {
+	return func(u *Rule) {
+		u.SharedCache()
+	}
+}
+
+// personal reports whether the response to req may differ between users
+// and so can't be shared with other requests.
+func (App *AppServer) personal(req *http.Request, route *Rule) bool {
+	if route.shared_cache {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" {
+		return true
+	}
+	names := App.sessions
+	if names == nil {
+		names = DefaultSessionCookies
+	}
+	for _, name := range names {
+		if _, err := req.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// StripCookies lets the route's responses be cached, and shared between
+// coalesced requests, even when the view sets cookies. Only the body is
+// kept, so the cookies go to the client whose request set them and to no
+// one else. Without it such responses are never shared, as they're likely
+// to have been made for that client alone.
+func (u *Rule) StripCookies() *Rule {
+	u.strip_cookies = true
+	return u
+}
+
+// StripCookies is the Option form of Rule.StripCookies.
+func StripCookies() Option {
+	return func(u *Rule) {
+		u.StripCookies()
+	}
+}
+
+// cookieCount is how many cookies the response sets so far.
+func cookieCount(w http.ResponseWriter) int {
+	return len(w.Header()["Set-Cookie"])
+}
+
+// shareable reports whether the response the view has written to w may
+// be shared with other requests, given it set cookies cookies before the
+// view was called.
+func (u *Rule) shareable(w http.ResponseWriter, cookies int) bool {
+	return u.strip_cookies || cookieCount(w) == cookies
+}
@@ -0,0 +1,102 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPersonalBypassesCache(t *testing.T) {
+	App := NewAppServer("0", 30)
+	calls := 0
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls++
+		return strconv.Itoa(calls), http.StatusOK
+	}
+	App.AddURLs(
+		Route("^/cached$", view, Cache(time.Hour)),
+		Route("^/shared$", view, Cache(time.Hour), SharedCache()),
+	)
+	App.SetSessionCookies("auth")
+
+	get := func(path string, setup func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if setup != nil {
+			setup(req)
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	withCookie := func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "auth", Value: "alice"})
+	}
+	withAuth := func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer bob")
+	}
+
+	first := get("/cached", nil).Body.String()
+	w := get("/cached", withCookie)
+	if w.Body.String() == first {
+		t.Error("request with a session cookie was served from the cache")
+	}
+	if w.Header().Get("Cache-Control") != "private" {
+		t.Errorf("got Cache-Control %q, want private", w.Header().Get("Cache-Control"))
+	}
+	if get("/cached", withAuth).Body.String() == first {
+		t.Error("request with Authorization was served from the cache")
+	}
+	if got := get("/cached", nil).Body.String(); got != first {
+		t.Errorf("anonymous request got %q, want the cached %q", got, first)
+	}
+	if got := get("/cached", func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "session", Value: "x"})
+	}).Body.String(); got != first {
+		t.Error("a cookie not given to SetSessionCookies bypassed the cache")
+	}
+
+	shared := get("/shared", nil).Body.String()
+	if got := get("/shared", withCookie).Body.String(); got != shared {
+		t.Errorf("SharedCache route got %q, want the cached %q", got, shared)
+	}
+}
+
+func TestCachedCookies(t *testing.T) {
+	var calls int
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "visitor", Value: strconv.Itoa(calls)})
+		return "call " + strconv.Itoa(calls), http.StatusOK
+	}
+	App := NewAppServer("0", 0)
+	App.AddURLs(
+		Route("^/sets$", view, Cache(time.Minute)),
+		Route("^/strips$", view, Cache(time.Minute), StripCookies()),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	first, second := get("/sets"), get("/sets")
+	if first.Body.String() == second.Body.String() {
+		t.Error("response which set a cookie was cached")
+	}
+	if second.Header().Get("Set-Cookie") == "" {
+		t.Error("uncached response lost its cookie")
+	}
+
+	first, second = get("/strips"), get("/strips")
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("StripCookies response wasn't cached: %q then %q", first.Body, second.Body)
+	}
+	if first.Header().Get("Set-Cookie") == "" {
+		t.Error("client whose request set the cookie didn't get it")
+	}
+	if cookie := second.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("cached response replayed cookie %q", cookie)
+	}
+}
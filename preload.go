@@ -0,0 +1,129 @@
+package wedge
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PushAssets makes preloaded assets be pushed to clients connected over
+// HTTP/2 as well as announced in Link headers. Browsers are free to
+// ignore pushes, and many now do, so the Link headers are always sent.
+var PushAssets = true
+
+// preloadTypes maps asset extensions to the "as" of a preload link.
+var preloadTypes = map[string]string{
+	".css":   "style",
+	".js":    "script",
+	".mjs":   "script",
+	".woff":  "font",
+	".woff2": "font",
+	".ttf":   "font",
+	".otf":   "font",
+	".png":   "image",
+	".jpg":   "image",
+	".jpeg":  "image",
+	".gif":   "image",
+	".svg":   "image",
+	".webp":  "image",
+}
+
+// preloadLink is the Link header value for an asset.
+func preloadLink(asset string) string {
+	link := fmt.Sprintf("<%s>; rel=preload", asset)
+	if as, ok := preloadTypes[strings.ToLower(path.Ext(asset))]; ok {
+		link += "; as=" + as
+		// fonts are always fetched in CORS mode, so the preload has
+		// to be too or it won't be used.
+		if as == "font" {
+			link += "; crossorigin"
+		}
+	}
+	return link
+}
+
+// AddPreload declares assets the page being rendered can't be shown
+// without, such as its stylesheets and scripts, so the browser fetches
+// them before it has parsed the page. Each is announced in a Link header,
+// and pushed if the connection supports HTTP/2 server push and PushAssets
+// is set. It must be called before the view returns.
+//
+// Example:
+//
+//	func Index(w http.ResponseWriter, req *http.Request) (string, int) {
+//		wedge.AddPreload(w, req, "/static/site.css", "/static/site.js")
+//		return render("index.html"), http.StatusOK
+//	}
+func AddPreload(w http.ResponseWriter, req *http.Request, assets ...string) {
+	pusher, push := w.(http.Pusher)
+	push = push && PushAssets
+	for _, asset := range assets {
+		asset = Link(req, asset)
+		w.Header().Add("Link", preloadLink(asset))
+		if push && strings.HasPrefix(asset, "/") {
+			if err := pusher.Push(asset, nil); err != nil && err != http.ErrNotSupported {
+				log.Println("Failed to push", asset, err)
+			}
+		}
+	}
+}
+
+// Preload declares assets needed by every page the route renders, see
+// AddPreload.
+func (u *Rule) Preload(assets ...string) *Rule {
+	u.preload = append(u.preload, assets...)
+	return u
+}
+
+// Preload is the Option form of Rule.Preload.
+func Preload(assets ...string) Option {
+	return func(u *Rule) {
+		u.Preload(assets...)
+	}
+}
+
+// Preconnect declares origins, such as a CDN, which every page the route
+// renders fetches from, so the browser can open connections to them
+// early. They are announced in Link headers along with Preload's assets.
+func (u *Rule) Preconnect(origins ...string) *Rule {
+	u.preconnect = append(u.preconnect, origins...)
+	return u
+}
+
+// Preconnect is the Option form of Rule.Preconnect.
+func Preconnect(origins ...string) Option {
+	return func(u *Rule) {
+		u.Preconnect(origins...)
+	}
+}
+
+// EarlyHints makes the route send its Preload and Preconnect links in a
+// 103 Early Hints response before the view is called, so the browser can
+// start on them while a slow page is still being rendered.
+func (u *Rule) EarlyHints() *Rule {
+	u.early_hints = true
+	return u
+}
+
+// EarlyHints is the Option form of Rule.EarlyHints.
+func EarlyHints() Option {
+	return func(u *Rule) {
+		u.EarlyHints()
+	}
+}
+
+// sendHints adds the route's links to the response and, if the route
+// asks for them, sends them ahead in a 103 Early Hints response. Clients
+// older than HTTP/1.1 don't understand informational responses.
+func (u *Rule) sendHints(w http.ResponseWriter, req *http.Request) {
+	for _, origin := range u.preconnect {
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=preconnect", origin))
+	}
+	AddPreload(w, req, u.preload...)
+	if u.early_hints && req.ProtoAtLeast(1, 1) &&
+		len(u.preload)+len(u.preconnect) > 0 {
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+}
@@ -0,0 +1,59 @@
+package wedge
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAddPreload(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	AddPreload(w, req, "/static/site.css", "/static/site.js", "/fonts/a.woff2", "/data.json")
+
+	want := []string{
+		"</static/site.css>; rel=preload; as=style",
+		"</static/site.js>; rel=preload; as=script",
+		"</fonts/a.woff2>; rel=preload; as=font; crossorigin",
+		"</data.json>; rel=preload",
+	}
+	if got := w.Header()["Link"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// informationalRecorder records the 1xx responses written to it, which
+// httptest.ResponseRecorder doesn't.
+type informationalRecorder struct {
+	*httptest.ResponseRecorder
+	informational []int
+}
+
+func (r *informationalRecorder) WriteHeader(status int) {
+	if status < 200 {
+		r.informational = append(r.informational, status)
+		return
+	}
+	r.ResponseRecorder.WriteHeader(status)
+}
+
+func TestEarlyHints(t *testing.T) {
+	route := Route("^/$", nil,
+		Preload("/static/site.css"),
+		Preconnect("https://cdn.example.com"),
+		EarlyHints(),
+	)
+	w := &informationalRecorder{ResponseRecorder: httptest.NewRecorder()}
+	route.sendHints(w, httptest.NewRequest("GET", "/", nil))
+
+	if !reflect.DeepEqual(w.informational, []int{103}) {
+		t.Errorf("got informational responses %v, want [103]", w.informational)
+	}
+	want := []string{
+		"<https://cdn.example.com>; rel=preconnect",
+		"</static/site.css>; rel=preload; as=style",
+	}
+	if got := w.Header()["Link"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package wedge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+// IPMode is how client addresses are anonymised by ClientIP.
+type IPMode int
+
+const (
+	// KeepIPs leaves addresses as they are.
+	KeepIPs IPMode = iota
+	// TruncateIPs zeroes the last octet of IPv4 addresses and the last
+	// 80 bits of IPv6 ones, which still tells networks apart.
+	TruncateIPs
+	// HashIPs replaces addresses with a keyed hash, which tells clients
+	// apart without revealing them. The key is made afresh each time the
+	// server starts, so hashes can't be linked across restarts.
+	HashIPs
+)
+
+// Privacy controls what is recorded about the people using the site.
+type Privacy struct {
+	// HonorDNT stops requests with a "DNT: 1" or "Sec-GPC: 1" header
+	// being tracked by path, referrer, browser or country. They are
+	// still counted in the aggregate statistics.
+	HonorDNT bool
+	// IPs is how addresses are anonymised by ClientIP.
+	IPs IPMode
+	// AggregateOnly counts every request under a single entry in the
+	// statistics rather than by path, and turns off the breakdown.
+	AggregateOnly bool
+}
+
+// aggregateStat is the statistics entry requests are counted under when
+// they aren't tracked by path.
+const aggregateStat = "(all requests)"
+
+// SetPrivacy configures what the server records about the people using
+// it, for sites which need to keep personal data to a minimum.
+//
+// Example:
+//
+//	App.SetPrivacy(wedge.Privacy{HonorDNT: true, IPs: wedge.HashIPs})
+func (App *AppServer) SetPrivacy(p Privacy) {
+	App.privacy = p
+	if p.IPs == HashIPs && App.ipKey == nil {
+		App.ipKey = make([]byte, 32)
+		rand.Read(App.ipKey)
+	}
+}
+
+// DoNotTrack reports whether req asks not to be tracked.
+func DoNotTrack(req *http.Request) bool {
+	return req.Header.Get("DNT") == "1" || req.Header.Get("Sec-GPC") == "1"
+}
+
+// trackable reports whether the details of req may be recorded.
+func (App *AppServer) trackable(req *http.Request) bool {
+	if App.privacy.AggregateOnly {
+		return false
+	}
+	return !App.privacy.HonorDNT || !DoNotTrack(req)
+}
+
+// statPath is the path req is counted under in the statistics.
+func (App *AppServer) statPath(req *http.Request) string {
+	if !App.trackable(req) {
+		return aggregateStat
+	}
+	return req.URL.Path
+}
+
+// ClientIP returns the address req came from, anonymised as configured by
+// SetPrivacy, so that it can be logged or stored. Requests from proxies
+// given to TrustProxies are attributed to the client they forwarded for.
+func (App *AppServer) ClientIP(req *http.Request) string {
+	return App.anonymise(App.forwardedFor(req))
+}
+
+func (App *AppServer) anonymise(host string) string {
+	switch App.privacy.IPs {
+	case TruncateIPs:
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return ""
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return ip.Mask(net.CIDRMask(48, 128)).String()
+	case HashIPs:
+		mac := hmac.New(sha256.New, App.ipKey)
+		mac.Write([]byte(host))
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	return host
+}
@@ -0,0 +1,60 @@
+package wedge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	tests := []struct {
+		mode IPMode
+		addr string
+		want string
+	}{
+		{KeepIPs, "192.0.2.33:1234", "192.0.2.33"},
+		{TruncateIPs, "192.0.2.33:1234", "192.0.2.0"},
+		{TruncateIPs, "[2001:db8:1:2:3:4:5:6]:1234", "2001:db8:1::"},
+	}
+	for _, test := range tests {
+		App.SetPrivacy(Privacy{IPs: test.mode})
+		req.RemoteAddr = test.addr
+		if got := App.ClientIP(req); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.addr, got, test.want)
+		}
+	}
+
+	App.SetPrivacy(Privacy{IPs: HashIPs})
+	req.RemoteAddr = "192.0.2.33:1234"
+	hashed := App.ClientIP(req)
+	if hashed == "192.0.2.33" || len(hashed) != 16 {
+		t.Errorf("got %q, want a hash", hashed)
+	}
+	req.RemoteAddr = "192.0.2.33:4321"
+	if again := App.ClientIP(req); again != hashed {
+		t.Errorf("same address hashed to %q and %q", hashed, again)
+	}
+}
+
+func TestStatPath(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	req := httptest.NewRequest("GET", "/about/", nil)
+	req.Header.Set("DNT", "1")
+	if got := App.statPath(req); got != "/about/" {
+		t.Errorf("DNT ignored: got %q", got)
+	}
+	App.SetPrivacy(Privacy{HonorDNT: true})
+	if got := App.statPath(req); got != aggregateStat {
+		t.Errorf("DNT honored: got %q", got)
+	}
+	req.Header.Del("DNT")
+	if got := App.statPath(req); got != "/about/" {
+		t.Errorf("no DNT: got %q", got)
+	}
+	App.SetPrivacy(Privacy{AggregateOnly: true})
+	if got := App.statPath(req); got != aggregateStat {
+		t.Errorf("aggregate only: got %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package wedge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustProxies declares the addresses of the reverse proxies in front of
+// the AppServer, as CIDRs or single IPs. Requests arriving from them have
+// their Forwarded, X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-For
+// headers believed by AbsoluteURL and ClientIP. Those headers are ignored
+// on requests from anywhere else, as any client can send them.
+//
+// Example:
+//
+//	if err := App.TrustProxies("10.0.0.0/8", "127.0.0.1"); err != nil {
+//		log.Fatal(err)
+//	}
+func (App *AppServer) TrustProxies(cidrs ...string) error {
+	nets, err := parseNets(cidrs, "proxy")
+	if err != nil {
+		return err
+	}
+	App.proxies = nets
+	return nil
+}
+
+// parseNets parses CIDRs and single IPs, naming them as what in errors.
+func parseNets(cidrs []string, what string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("wedge: bad %s address %q", what, cidr)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			cidr = fmt.Sprintf("%s/%d", cidr, bits)
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("wedge: bad %s address %q", what, cidr)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// trusted reports whether host is one of the AppServer's proxies.
+func (App *AppServer) trusted(host string) bool {
+	return inNets(App.proxies, host)
+}
+
+// inNets reports whether host is an IP within one of nets.
+func inNets(nets []*net.IPNet, host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwarded returns the scheme and host the client asked for, from the
+// proxy headers if req came through a trusted proxy.
+func (App *AppServer) forwarded(req *http.Request) (scheme, host string) {
+	scheme, host = "http", req.Host
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if !App.trusted(remoteHost(req)) {
+		return scheme, host
+	}
+	// the first element of Forwarded was added by the proxy nearest the
+	// client, which is the one that saw the original request.
+	if fwd := req.Header.Get("Forwarded"); fwd != "" {
+		first := strings.Split(fwd, ",")[0]
+		for _, pair := range strings.Split(first, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value := strings.Trim(kv[1], `"`)
+			switch strings.ToLower(kv[0]) {
+			case "proto":
+				scheme = value
+			case "host":
+				host = value
+			}
+		}
+		return validScheme(scheme), host
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	if fwdHost := req.Header.Get("X-Forwarded-Host"); fwdHost != "" {
+		host = strings.TrimSpace(strings.Split(fwdHost, ",")[0])
+	}
+	return validScheme(scheme), host
+}
+
+func validScheme(scheme string) string {
+	if strings.EqualFold(scheme, "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// withOrigin returns a shallow copy of req carrying the origin clients
+// reach the AppServer at, for AbsoluteURL.
+func (App *AppServer) withOrigin(req *http.Request) *http.Request {
+	scheme, host := App.forwarded(req)
+	return req.WithContext(context.WithValue(req.Context(), originKey, scheme+"://"+host))
+}
+
+// AbsoluteURL returns the full URL of path as clients see it, for links
+// which leave the site such as those in emails, feeds, sitemaps and OAuth
+// callbacks. The scheme and host come from the proxy headers if the request
+// came through a proxy given to TrustProxies, and from the request itself
+// otherwise. Rooted paths are prefixed with the base path, as with Link.
+//
+// Example:
+//
+//	callback := wedge.AbsoluteURL(req, "/oauth/callback")
+//	// "https://example.com/app/oauth/callback"
+func AbsoluteURL(req *http.Request, path string) string {
+	origin, ok := req.Context().Value(originKey).(string)
+	if !ok {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		origin = scheme + "://" + req.Host
+	}
+	path = Link(req, path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return origin + path
+}
+
+// forwardedFor returns the address of the client behind the trusted
+// proxies, being the last address in X-Forwarded-For which isn't one of
+// them.
+func (App *AppServer) forwardedFor(req *http.Request) string {
+	host := remoteHost(req)
+	if !App.trusted(host) {
+		return host
+	}
+	hops := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !App.trusted(hop) {
+			break
+		}
+	}
+	return host
+}
@@ -0,0 +1,58 @@
+package wedge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAbsoluteURL(t *testing.T) {
+	App := NewAppServer("0", 30)
+	if err := App.TrustProxies("10.0.0.0/8", "::1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := App.TrustProxies("not an ip"); err == nil {
+		t.Error("TrustProxies accepted a bad address")
+	}
+	App.TrustProxies("10.0.0.0/8")
+	App.SetBasePath("/app")
+
+	tests := []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"10.1.2.3:80", nil, "http://example.com/app/login"},
+		{"10.1.2.3:80", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example"},
+			"https://public.example/app/login"},
+		{"10.1.2.3:80", map[string]string{"Forwarded": `proto=https;host="a.example", proto=http;host=b`},
+			"https://a.example/app/login"},
+		{"192.0.2.1:80", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"},
+			"http://example.com/app/login"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/app/", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		req, _ = App.stripBase(App.withOrigin(req))
+		if got := AbsoluteURL(req, "/login"); got != test.want {
+			t.Errorf("%s %v: got %q, want %q", test.remote, test.headers, got, test.want)
+		}
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.TrustProxies("10.0.0.0/8")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2, 10.0.0.2")
+	req.RemoteAddr = "10.0.0.1:80"
+	if got := App.ClientIP(req); got != "2.2.2.2" {
+		t.Errorf("trusted proxy: got %q, want 2.2.2.2", got)
+	}
+	req.RemoteAddr = "3.3.3.3:80"
+	if got := App.ClientIP(req); got != "3.3.3.3" {
+		t.Errorf("untrusted client: got %q, want 3.3.3.3", got)
+	}
+}
@@ -0,0 +1,617 @@
+package wedge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+)
+
+// QRLevel is the amount of error correction in a QR code. Higher levels
+// can be read when more of the code is damaged, at the cost of holding
+// less data.
+type QRLevel int
+
+const (
+	QRLow QRLevel = iota
+	QRMedium
+	QRQuartile
+	QRHigh
+)
+
+// ErrQRTooLong is returned when data won't fit in the largest QR code.
+var ErrQRTooLong = errors.New("wedge: data is too long for a QR code")
+
+// The number of error correction codewords in each block, and the number
+// of blocks, indexed by level and then by version. These are taken from
+// table 9 of ISO/IEC 18004.
+var qrECCPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// The value of each level in the format information.
+var qrFormatBits = [4]int{1, 0, 3, 2}
+
+// qrCode is a QR code being built up. modules holds whether each module
+// is dark, and function marks those which are part of the fixed patterns
+// rather than the data.
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// EncodeQR encodes data in byte mode as the smallest QR code which can
+// hold it at the given level. The result is indexed by row then column,
+// with true meaning a dark module, and doesn't include a quiet zone.
+func EncodeQR(data []byte, level QRLevel) ([][]bool, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		countBits := 8
+		if v > 9 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= qrDataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRTooLong
+	}
+
+	codewords := qrAddECC(qrDataBits(data, version, level), version, level)
+
+	qr := newQRCode(version)
+	qr.drawFunctionPatterns(version)
+	qr.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(level, mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		// masks are an XOR, so applying it again undoes it
+		qr.applyMask(mask)
+	}
+	qr.applyMask(best)
+	qr.drawFormatBits(level, best)
+	return qr.modules, nil
+}
+
+// qrRawModules is the number of modules available for data and error
+// correction in a code of the given version.
+func qrRawModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		result -= (25*align-10)*align - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[level][version]*qrBlocks[level][version]
+}
+
+// qrDataBits packs data into the data codewords for the code, including
+// the mode, character count, terminator and padding.
+func qrDataBits(data []byte, version int, level QRLevel) []byte {
+	var bits []bool
+	appendBits := func(val, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (val>>uint(i))&1 == 1)
+		}
+	}
+	countBits := 8
+	if version > 9 {
+		countBits = 16
+	}
+	appendBits(0x4, 4)
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := qrDataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return codewords
+}
+
+// qrAddECC splits the data into blocks, adds Reed-Solomon error
+// correction to each and interleaves the result.
+func qrAddECC(data []byte, version int, level QRLevel) []byte {
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrECCPerBlock[level][version]
+	raw := qrRawModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < numShort {
+			// a placeholder so every block has the same length
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func newQRCode(version int) *qrCode {
+	size := version*4 + 17
+	qr := &qrCode{size: size}
+	qr.modules = make([][]bool, size)
+	qr.function = make([][]bool, size)
+	for i := 0; i < size; i++ {
+		qr.modules[i] = make([]bool, size)
+		qr.function[i] = make([]bool, size)
+	}
+	return qr
+}
+
+func (qr *qrCode) set(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns(version int) {
+	for i := 0; i < qr.size; i++ {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+
+	qr.drawFinder(3, 3)
+	qr.drawFinder(qr.size-4, 3)
+	qr.drawFinder(3, qr.size-4)
+
+	align := qrAlignmentPositions(version, qr.size)
+	last := len(align) - 1
+	for i, x := range align {
+		for j, y := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas, they're drawn for real once the mask
+	// has been chosen
+	qr.drawFormatBits(0, 0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>uint(i))&1 == 1
+			a, b := qr.size-11+i%3, i/3
+			qr.set(a, b, dark)
+			qr.set(b, a, dark)
+		}
+	}
+}
+
+func (qr *qrCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			dist := max(abs(dx), abs(dy))
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && xx < qr.size && yy >= 0 && yy < qr.size {
+				qr.set(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func qrAlignmentPositions(version, size int) []int {
+	if version == 1 {
+		return nil
+	}
+	num := version/7 + 2
+	step := (version*8 + num*3 + 5) / (num*4 - 4) * 2
+	result := make([]int, num)
+	result[0] = 6
+	for i, pos := num-1, size-7; i > 0; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (qr *qrCode) drawFormatBits(level QRLevel, mask int) {
+	data := qrFormatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool {
+		return (bits>>uint(i))&1 == 1
+	}
+
+	for i := 0; i <= 5; i++ {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.set(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, qr.size-15+i, bit(i))
+	}
+	qr.set(8, qr.size-8, true)
+}
+
+// drawCodewords places the data in the zig-zag order used by QR codes,
+// two columns at a time from the bottom right.
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.function[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = (data[i>>3]>>uint(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.function[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the code using the rules from the standard, a lower
+// score being easier for readers to scan.
+func (qr *qrCode) penalty() int {
+	result := 0
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return qr.modules[x][y]
+		}
+		return qr.modules[y][x]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < qr.size; y++ {
+			run := 1
+			for x := 1; x < qr.size; x++ {
+				if at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					if run == 5 {
+						result += 3
+					} else if run > 5 {
+						result++
+					}
+				} else {
+					run = 1
+				}
+			}
+
+			// a finder-like pattern with four light modules either side
+			for x := 0; x+7 <= qr.size; x++ {
+				matches := true
+				for i, dark := range finder {
+					if at(x+i, y, transpose) != dark {
+						matches = false
+						break
+					}
+				}
+				if matches && (qr.light(x-4, x, y, transpose) || qr.light(x+7, x+11, y, transpose)) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := qr.modules[y][x]
+				if c == qr.modules[y-1][x] && c == qr.modules[y][x-1] && c == qr.modules[y-1][x-1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := qr.size * qr.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*10
+}
+
+// light reports whether the modules from start up to end on a line are
+// all light, treating the area outside of the code as light.
+func (qr *qrCode) light(start, end, line int, transpose bool) bool {
+	for i := start; i < end; i++ {
+		if i < 0 || i >= qr.size {
+			continue
+		}
+		dark := qr.modules[line][i]
+		if transpose {
+			dark = qr.modules[i][line]
+		}
+		if dark {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// qrQuietZone is the border of light modules the standard requires.
+const qrQuietZone = 4
+
+// qrPNG renders modules as a PNG with each module scale pixels wide.
+func qrPNG(modules [][]bool, scale int) string {
+	size := (len(modules) + qrQuietZone*2) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size),
+		color.Palette{color.White, color.Black})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+qrQuietZone)*scale+dx, (y+qrQuietZone)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	buf := new(bytes.Buffer)
+	png.Encode(buf, img)
+	return buf.String()
+}
+
+// qrSVG renders modules as an SVG image which is size pixels wide.
+func qrSVG(modules [][]bool, size int) string {
+	n := len(modules) + qrQuietZone*2
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, n, n,
+	))
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				buf.WriteString(fmt.Sprintf("M%d,%dh1v1h-1z", x+qrQuietZone, y+qrQuietZone))
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.String()
+}
+
+// QROptions configures a route created with QRCode.
+type QROptions struct {
+	// MaxData is the largest amount of data, in bytes, which will be
+	// encoded. If it is zero, 512 is used.
+	MaxData int
+	// MaxSize is the largest image, in pixels, which may be requested.
+	// If it is zero, 1024 is used.
+	MaxSize int
+}
+
+var qrLevels = map[string]QRLevel{
+	"L": QRLow, "M": QRMedium, "Q": QRQuartile, "H": QRHigh,
+}
+
+// QRCode returns a *Rule which renders the query parameter "data" as a
+// QR code. The other query parameters are all optional:
+//
+//	size    the width of the image in pixels, 256 by default
+//	level   the error correction level, one of L, M, Q or H, M by default
+//	format  either png or svg, png by default
+//
+// Codes aren't kept, as any data can be asked for, but are sent with a
+// day's Cache-Control for browsers and proxies to keep them.
+//
+// Example:
+//
+//	wedge.QRCode("^/qr/?$", wedge.QROptions{})
+//	// <img src="/qr/?data=https%3A%2F%2Fexample.com&size=128">
+func QRCode(re string, opts QROptions) *Rule {
+	if opts.MaxData == 0 {
+		opts.MaxData = 512
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = 1024
+	}
+
+	return makeurl(re, "QR Code", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		query := req.URL.Query()
+		data := query.Get("data")
+		if data == "" || len(data) > opts.MaxData {
+			return "", http.StatusNotFound
+		}
+		size := 256
+		if s := query.Get("size"); s != "" {
+			var err error
+			size, err = strconv.Atoi(s)
+			if err != nil || size <= 0 || size > opts.MaxSize {
+				return "", http.StatusNotFound
+			}
+		}
+		levelName := query.Get("level")
+		if levelName == "" {
+			levelName = "M"
+		}
+		level, ok := qrLevels[levelName]
+		if !ok {
+			return "", http.StatusNotFound
+		}
+		format := query.Get("format")
+		if format == "" {
+			format = "png"
+		}
+		if format != "png" && format != "svg" {
+			return "", http.StatusNotFound
+		}
+
+		ctype := "image/png"
+		if format == "svg" {
+			ctype = "image/svg+xml"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+
+		modules, err := EncodeQR([]byte(data), level)
+		if err != nil {
+			return "", http.StatusNotFound
+		}
+		if format == "svg" {
+			return qrSVG(modules, size), http.StatusOK
+		}
+		return qrPNG(modules, max(1, size/(len(modules)+qrQuietZone*2))), http.StatusOK
+	}, IMAGE, 0)
+}
@@ -0,0 +1,264 @@
+package wedge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// qrGolden are codes checked by decoding them below, kept so any change
+// to the encoder's output is noticed. Each is the SHA-256 of its modules,
+// a row per line with '#' for dark modules.
+var qrGolden = []struct {
+	data    string
+	level   QRLevel
+	version int
+	sha256  string
+}{
+	{"HELLO WORLD", QRLow, 1, "14e69b9f97c11a35e689a7d0e10ddcd521ebdac4a6a80859e510ccc2c15f9f68"},
+	{"HELLO WORLD", QRMedium, 1, "e597290ced963b377ec7b25f8f142f692a76e42c00193e22167d8d193c2f368b"},
+	{"HELLO WORLD", QRQuartile, 1, "48764f64c17e4cc0483776ffe3fe7ab68f9b916dd3c9be425531283c81410e97"},
+	{"HELLO WORLD", QRHigh, 2, "6f4268befd013457ba539e93c9deae54e22e4cbf6b67dce0c4bf367c61f4d7ca"},
+	{"https://example.com/", QRMedium, 2, "00c71e3f7bbf13fc5fa619a0c34adc5f18b914943af7d2ddd2d36521730393b5"},
+	{strings.Repeat("wedge ", 10), QRQuartile, 5, "d58f46d373395aced4569ea5fa14d8666d99609d7ed903d81f206729f1713108"},
+	{strings.Repeat("wedge ", 25), QRLow, 7, "33ddeb2655fc3d61919db833301f2400f005f320e737b9ad04019027ab0455fb"},
+	{strings.Repeat("0123456789", 25), QRHigh, 16, "48b8ff602499589c6716826f5fa373088655f13af746a9d78e124f5c9634e859"},
+	{strings.Repeat("0123456789", 100), QRMedium, 26, "3c93435d5b0374bd1ebec1343a2f1a6cabc371f3aa817900fc37f1cab5459254"},
+	{strings.Repeat("x", 2953), QRLow, 40, "f160d9a222ab5178250d1e03983c50fce08fdad3a045447eb0d3c10dc095d835"},
+}
+
+// qrVersionInfo are version information words from annex D of ISO/IEC
+// 18004.
+var qrVersionInfo = map[int]int{7: 0x07C94, 40: 0x28C69}
+
+func qrString(modules [][]bool) string {
+	var buf bytes.Buffer
+	for _, row := range modules {
+		for _, dark := range row {
+			if dark {
+				buf.WriteByte('#')
+			} else {
+				buf.WriteByte('.')
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func TestEncodeQR(t *testing.T) {
+	for _, test := range qrGolden {
+		modules, err := EncodeQR([]byte(test.data), test.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version := (len(modules) - 17) / 4; version != test.version {
+			t.Errorf("%.20q at %d: got version %d, want %d", test.data, test.level, version, test.version)
+			continue
+		}
+		data, err := decodeQR(modules, test.level)
+		if err != nil {
+			t.Errorf("%.20q at %d: %v", test.data, test.level, err)
+		} else if data != test.data {
+			t.Errorf("%.20q at %d: decoded %.20q", test.data, test.level, data)
+		}
+		sum := sha256.Sum256([]byte(qrString(modules)))
+		if got := hex.EncodeToString(sum[:]); got != test.sha256 {
+			t.Errorf("%.20q at %d: got modules with SHA-256 %s", test.data, test.level, got)
+		}
+	}
+	if _, err := EncodeQR(make([]byte, 2954), QRLow); err != ErrQRTooLong {
+		t.Errorf("got %v for too much data", err)
+	}
+}
+
+// decodeQR reads back the byte mode data of a code made by EncodeQR at
+// level, checking its format and version information and that each
+// block's error correction is consistent with its data.
+func decodeQR(modules [][]bool, level QRLevel) (string, error) {
+	size := len(modules)
+	version := (size - 17) / 4
+	bit := func(x, y int) int {
+		if modules[y][x] {
+			return 1
+		}
+		return 0
+	}
+
+	// the format information is kept twice, around the finder patterns.
+	var format, copied int
+	for i := 0; i <= 5; i++ {
+		format |= bit(8, i) << uint(i)
+	}
+	format |= bit(8, 7)<<6 | bit(8, 8)<<7 | bit(7, 8)<<8
+	for i := 9; i < 15; i++ {
+		format |= bit(14-i, 8) << uint(i)
+	}
+	for i := 0; i < 8; i++ {
+		copied |= bit(size-1-i, 8) << uint(i)
+	}
+	for i := 8; i < 15; i++ {
+		copied |= bit(8, size-15+i) << uint(i)
+	}
+	if format != copied {
+		return "", fmt.Errorf("the format information copies differ, %015b and %015b", format, copied)
+	}
+	format ^= 0x5412
+	rem := format
+	for i := 14; i >= 10; i-- {
+		if rem>>uint(i)&1 == 1 {
+			rem ^= 0x537 << uint(i-10)
+		}
+	}
+	if rem != 0 {
+		return "", fmt.Errorf("format information %015b isn't a BCH codeword", format)
+	}
+	if want := [4]int{1, 0, 3, 2}[level]; format>>13 != want {
+		return "", fmt.Errorf("got level bits %02b, want %02b", format>>13, want)
+	}
+	mask := format >> 10 & 7
+
+	if want, ok := qrVersionInfo[version]; ok {
+		var info, transposed int
+		for i := 0; i < 18; i++ {
+			info |= bit(size-11+i%3, i/3) << uint(i)
+			transposed |= bit(i/3, size-11+i%3) << uint(i)
+		}
+		if info != want || transposed != want {
+			return "", fmt.Errorf("got version information %05x and %05x, want %05x", info, transposed, want)
+		}
+	}
+
+	masked := [8]func(x, y int) bool{
+		func(x, y int) bool { return (x+y)%2 == 0 },
+		func(x, y int) bool { return y%2 == 0 },
+		func(x, y int) bool { return x%3 == 0 },
+		func(x, y int) bool { return (x+y)%3 == 0 },
+		func(x, y int) bool { return (y/2+x/3)%2 == 0 },
+		func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+		func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+		func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+	}[mask]
+	function := newQRCode(version)
+	function.drawFunctionPatterns(version)
+	var stream []byte
+	var b, n int
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if function.function[y][x] {
+					continue
+				}
+				dark := modules[y][x] != masked(x, y)
+				b <<= 1
+				if dark {
+					b |= 1
+				}
+				if n++; n == 8 {
+					stream = append(stream, byte(b))
+					b, n = 0, 0
+				}
+			}
+		}
+	}
+
+	// undo the interleaving, short blocks having a data codeword less.
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrECCPerBlock[level][version]
+	raw := len(stream)
+	numShort := numBlocks - raw%numBlocks
+	dataLen := raw/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	i := 0
+	for col := 0; col <= dataLen; col++ {
+		for j := range blocks {
+			if col < dataLen || j >= numShort {
+				blocks[j] = append(blocks[j], stream[i])
+				i++
+			}
+		}
+	}
+	for col := 0; col < eccLen; col++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], stream[i])
+			i++
+		}
+	}
+	var data []byte
+	for j, block := range blocks {
+		// a block is a codeword if it vanishes at each root of the
+		// generator, 2^0 to 2^(eccLen-1).
+		root := byte(1)
+		for r := 0; r < eccLen; r++ {
+			var syndrome byte
+			for _, c := range block {
+				syndrome = gfMultiply(syndrome, root) ^ c
+			}
+			if syndrome != 0 {
+				return "", fmt.Errorf("block %d has syndrome %d at root %d", j, syndrome, r)
+			}
+			root = gfMultiply(root, 2)
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	if data[0]>>4 != 0x4 {
+		return "", fmt.Errorf("got mode %x, want byte mode", data[0]>>4)
+	}
+	bits := func(from, n int) int {
+		v := 0
+		for i := from; i < from+n; i++ {
+			v = v<<1 | int(data[i/8]>>uint(7-i%8)&1)
+		}
+		return v
+	}
+	countBits := 8
+	if version > 9 {
+		countBits = 16
+	}
+	count := bits(4, countBits)
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = byte(bits(4+countBits+i*8, 8))
+	}
+	return string(out), nil
+}
+
+func TestQRCodeRoute(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.AddURLs(QRCode("^/qr/?$", QROptions{}))
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/qr/?"+query, nil))
+		return w
+	}
+	w := get("data=hello&size=100")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(w.Body.String(), "\x89PNG") {
+		t.Errorf("png: got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=86400" {
+		t.Errorf("got Cache-Control %q", cc)
+	}
+	w = get("data=hello&format=svg&level=H")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "<svg") {
+		t.Errorf("svg: got %d %.20q", w.Code, w.Body)
+	}
+	for _, query := range []string{"", "data=hello&size=5000", "data=hello&level=Z", "data=hello&format=gif", "data=" + strings.Repeat("x", 600)} {
+		if w := get(query); w.Code != http.StatusNotFound {
+			t.Errorf("%.30q: got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sort"
+)
+
+// SetDebug turns debug mode on or off. In debug mode a view which panics
+// is answered with a page showing the panic, where it happened and the
+// request, rather than the 500 page. It's for development only: the page
+// shows every header of the request, cookies included.
+func (App *AppServer) SetDebug(on bool) {
+	App.debug = on
+}
+
+// recovered answers req after its view panicked with err, with the 500
+// page or, in debug mode, the debug page. Panics with
+// http.ErrAbortHandler are passed on, as they're how a view aborts a
+// response on purpose.
+func (App *AppServer) recovered(w http.ResponseWriter, req *http.Request, err interface{}) {
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	stack := debug.Stack()
+	log.Printf("Panic on path %s: %v\n%s", req.URL.Path, err, stack)
+	if !App.debug {
+		App.handle500req(w, req)
+		return
+	}
+	if App.stat_map != nil {
+		App.incrementStats("500 => " + App.statPath(req))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(debugPage(req, err, stack))
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(
+	`<!DOCTYPE html><html><head><title>Panic: {{.Panic}}</title></head><body>` +
+		`<h1>Panic: {{.Panic}}</h1>` +
+		`<p>{{.Method}} {{.URL}} {{.Proto}} from {{.RemoteAddr}}{{with .RequestID}}, request {{.}}{{end}}</p>` +
+		`<h2>Stack</h2><pre>{{.Stack}}</pre>` +
+		`<h2>Headers</h2><table border="2">` +
+		`{{range .Headers}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>{{end}}` +
+		`</table>` +
+		`{{with .Form}}<h2>Form</h2><table border="2">` +
+		`{{range .}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>{{end}}` +
+		`</table>{{end}}` +
+		`</body></html>`))
+
+type debugValue struct {
+	Name  string
+	Value string
+}
+
+// debugValues flattens a header or form, sorted by name.
+func debugValues(values map[string][]string) []debugValue {
+	var flat []debugValue
+	for name, vs := range values {
+		for _, v := range vs {
+			flat = append(flat, debugValue{name, v})
+		}
+	}
+	sort.SliceStable(flat, func(i, j int) bool {
+		return flat[i].Name < flat[j].Name
+	})
+	return flat
+}
+
+// debugPage renders the debug page for a panic with err while serving req.
+func debugPage(req *http.Request, err interface{}, stack []byte) []byte {
+	buf := new(bytes.Buffer)
+	terr := debugTemplate.Execute(buf, map[string]interface{}{
+		"Panic":      fmt.Sprint(err),
+		"Method":     req.Method,
+		"URL":        req.URL.String(),
+		"Proto":      req.Proto,
+		"RemoteAddr": req.RemoteAddr,
+		"RequestID":  RequestID(req),
+		"Stack":      string(stack),
+		"Headers":    debugValues(req.Header),
+		// only the form the view parsed itself, as reading the body
+		// now could block.
+		"Form": debugValues(req.Form),
+	})
+	if terr != nil {
+		return []byte(template.HTMLEscapeString(fmt.Sprint(err)) + "<pre>" +
+			template.HTMLEscapeString(string(stack)) + "</pre>")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,56 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.AddURLs(URL("^/panic/$", "Panic", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		panic("out of <biscuits>")
+	}, HTML))
+	App.Handler500(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "sorry", http.StatusInternalServerError
+	})
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/panic/", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "sorry" {
+		t.Errorf("got %d %q", w.Code, w.Body)
+	}
+
+	App.SetDebug(true)
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/panic/?q=1", nil)
+	req.Header.Set("User-Agent", "tester")
+	App.ServeHTTP(w, req)
+	body := w.Body.String()
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d", w.Code)
+	}
+	for _, want := range []string{
+		"Panic: out of &lt;biscuits&gt;",
+		"GET /panic/?q=1",
+		"recover_test.go",
+		"<th>User-Agent</th><td>tester</td>",
+		w.Header().Get("X-Request-ID"),
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("debug page is missing %q", want)
+		}
+	}
+
+	// aborting a response is left to net/http.
+	App.AddURLs(URL("^/abort/$", "Abort", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		panic(http.ErrAbortHandler)
+	}, HTML))
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("got panic %v", err)
+		}
+	}()
+	App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort/", nil))
+}
@@ -0,0 +1,253 @@
+package wedge
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RedirectPoll is how often a file loaded with LoadRedirects is checked
+// for changes.
+var RedirectPoll = 5 * time.Second
+
+// redirect is a single entry in a redirect map.
+type redirect struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+// redirectNode is a node in a trie keyed on path segments. A path such
+// as "/a/b/" is split into the segments "a", "b" and "", so trailing
+// slashes are significant, as they are for regular routes.
+type redirectNode struct {
+	children map[string]*redirectNode
+	// exact is the redirect for the path ending at this node.
+	exact *redirect
+	// prefix is the redirect for every path below this node, from an
+	// entry ending in "/*".
+	prefix *redirect
+}
+
+func newRedirectNode() *redirectNode {
+	return &redirectNode{children: make(map[string]*redirectNode)}
+}
+
+func (n *redirectNode) insert(r *redirect) {
+	from := r.From
+	wildcard := strings.HasSuffix(from, "/*")
+	if wildcard {
+		from = from[:len(from)-2]
+	}
+	node := n
+	for _, segment := range strings.Split(strings.TrimPrefix(from, "/"), "/") {
+		if wildcard && segment == "" {
+			continue
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			child = newRedirectNode()
+			node.children[segment] = child
+		}
+		node = child
+	}
+	if wildcard {
+		node.prefix = r
+	} else {
+		node.exact = r
+	}
+}
+
+// lookup finds where path should be redirected to. An exact entry wins
+// over a prefix, and longer prefixes win over shorter ones. For prefix
+// entries the rest of the path is appended to the target.
+func (n *redirectNode) lookup(path string) (string, int, bool) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	var best *redirect
+	var rest []string
+
+	node := n
+	for i, segment := range segments {
+		if node.prefix != nil {
+			best, rest = node.prefix, segments[i:]
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			node = nil
+			break
+		}
+		node = child
+	}
+	if node != nil && node.exact != nil {
+		return node.exact.To, node.exact.Status, true
+	}
+	if node != nil && node.prefix != nil {
+		best, rest = node.prefix, nil
+	}
+	if best == nil {
+		return "", 0, false
+	}
+	return strings.TrimSuffix(best.To, "/") + "/" + strings.Join(rest, "/"), best.Status, true
+}
+
+// redirectMap holds the redirects loaded by LoadRedirects, which can be
+// swapped out wholesale when the file is reloaded.
+type redirectMap struct {
+	sync.RWMutex
+	root    *redirectNode
+	path    string
+	modtime time.Time
+}
+
+func (m *redirectMap) lookup(path string) (string, int, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.root.lookup(path)
+}
+
+// readRedirects parses a redirect map. JSON files hold an array of
+// objects with "from", "to" and an optional "status", anything else is
+// read as CSV with the same three columns.
+func readRedirects(path string) ([]*redirect, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var redirects []*redirect
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.NewDecoder(file).Decode(&redirects); err != nil {
+			return nil, err
+		}
+	} else {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.Comment = '#'
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(record) < 2 || len(record) > 3 {
+				line, _ := reader.FieldPos(0)
+				return nil, fmt.Errorf("wedge: %s:%d: expected from,to[,status]", path, line)
+			}
+			r := &redirect{From: record[0], To: record[1]}
+			if len(record) == 3 {
+				if r.Status, err = strconv.Atoi(strings.TrimSpace(record[2])); err != nil {
+					return nil, err
+				}
+			}
+			redirects = append(redirects, r)
+		}
+	}
+
+	for _, r := range redirects {
+		if r.Status == 0 {
+			r.Status = http.StatusMovedPermanently
+		}
+		if r.Status < 300 || r.Status > 399 {
+			return nil, fmt.Errorf("wedge: %s: %d is not a redirect status", path, r.Status)
+		}
+		if !strings.HasPrefix(r.From, "/") {
+			return nil, fmt.Errorf("wedge: %s: %q is not an absolute path", path, r.From)
+		}
+	}
+	return redirects, nil
+}
+
+// load reads the file and replaces the current redirects with it.
+func (m *redirectMap) load() error {
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return err
+	}
+	redirects, err := readRedirects(m.path)
+	if err != nil {
+		return err
+	}
+	root := newRedirectNode()
+	for _, r := range redirects {
+		root.insert(r)
+	}
+
+	m.Lock()
+	m.root = root
+	m.modtime = info.ModTime()
+	m.Unlock()
+	log.Printf("Loaded %d redirects from %s\n", len(redirects), m.path)
+	return nil
+}
+
+// watch reloads the file when it changes or the process receives SIGHUP,
+// until quit is closed. A file which fails to load is logged and the old
+// redirects are kept.
+func (m *redirectMap) watch(quit <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	tick := time.NewTicker(RedirectPoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-hup:
+		case <-tick.C:
+			info, err := os.Stat(m.path)
+			m.RLock()
+			unchanged := err == nil && info.ModTime().Equal(m.modtime)
+			m.RUnlock()
+			if unchanged {
+				continue
+			}
+		}
+		if err := m.load(); err != nil {
+			log.Println("Failed to reload redirects:", err)
+		}
+	}
+}
+
+// LoadRedirects reads a map of old paths to new URLs from path, for sites
+// with a large number of legacy URLs. Rather than a regular expression
+// per entry, the paths are held in a trie so each lookup only costs as
+// much as the depth of the path.
+//
+// A CSV file has one redirect per line:
+//
+//	/old/about.html,/about/
+//	/blog/*,https://blog.example.com/,302
+//
+// A JSON file holds the same as an array:
+//
+//	[{"from": "/old/about.html", "to": "/about/", "status": 301}]
+//
+// The status defaults to 301. A from path ending in "/*" redirects
+// everything below it, with the rest of the path appended to the target.
+//
+// The file is reloaded when it changes, or when the process receives a
+// SIGHUP. Redirects are checked before any other routes.
+func (App *AppServer) LoadRedirects(path string) error {
+	m := &redirectMap{path: path}
+	if err := m.load(); err != nil {
+		return err
+	}
+	App.redirects = m
+	go m.watch(App.quit)
+	return nil
+}
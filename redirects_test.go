@@ -0,0 +1,40 @@
+package wedge
+
+import "testing"
+
+func TestRedirectTrie(t *testing.T) {
+	root := newRedirectNode()
+	for _, r := range []*redirect{
+		{"/old/about.html", "/about/", 301},
+		{"/blog/*", "https://blog.example.com/", 302},
+		{"/blog/archive/*", "/archive", 301},
+		{"/blog/feed", "/feed.xml", 301},
+		{"/dir/", "/folder/", 301},
+	} {
+		root.insert(r)
+	}
+
+	tests := []struct {
+		path   string
+		to     string
+		status int
+		ok     bool
+	}{
+		{"/old/about.html", "/about/", 301, true},
+		{"/old/about.htm", "", 0, false},
+		{"/blog/2013/post", "https://blog.example.com/2013/post", 302, true},
+		{"/blog/archive/2012", "/archive/2012", 301, true},
+		{"/blog/feed", "/feed.xml", 301, true},
+		{"/blog", "https://blog.example.com/", 302, true},
+		{"/dir/", "/folder/", 301, true},
+		{"/dir", "", 0, false},
+		{"/", "", 0, false},
+	}
+	for _, test := range tests {
+		to, status, ok := root.lookup(test.path)
+		if to != test.to || status != test.status || ok != test.ok {
+			t.Errorf("%s: got (%q, %d, %v), want (%q, %d, %v)",
+				test.path, to, status, ok, test.to, test.status, test.ok)
+		}
+	}
+}
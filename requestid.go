@@ -0,0 +1,34 @@
+package wedge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// validRequestID is what a request ID passed on by a proxy has to look
+// like to be kept, so that it's safe to log and to show on pages.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// withRequestID returns a shallow copy of req carrying its ID, which is
+// also sent back in the X-Request-ID header. The ID from a proxy given to
+// TrustProxies is kept, so the same one appears in both of their logs.
+func (App *AppServer) withRequestID(w http.ResponseWriter, req *http.Request) *http.Request {
+	id := req.Header.Get("X-Request-ID")
+	if !validRequestID.MatchString(id) || !App.trusted(remoteHost(req)) {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set("X-Request-ID", id)
+	return req.WithContext(context.WithValue(req.Context(), requestIDKey, id))
+}
+
+// RequestID returns the ID of req, for logs and error pages, or "" if it
+// isn't being served by an AppServer.
+func RequestID(req *http.Request) string {
+	id, _ := req.Context().Value(requestIDKey).(string)
+	return id
+}
@@ -0,0 +1,112 @@
+package wedge
+
+import (
+	"net/http"
+)
+
+// Response is a view's response along with the headers and cookies it
+// sends, for views which would rather return them than set them on w.
+// Send sets them on w and returns the view's body and status:
+//
+//	func Signin(w http.ResponseWriter, req *http.Request) (string, int) {
+//		token := login(req)
+//		return wedge.Response{
+//			Body:    "Welcome back",
+//			Header:  http.Header{"Cache-Control": {"no-store"}},
+//			Cookies: []*http.Cookie{{Name: "session", Value: token, HttpOnly: true}},
+//		}.Send(w)
+//	}
+//
+// Headers a view sets on w, whether through a Response or not, are kept:
+// the server only sets a Content-Type if the view hasn't, and responses
+// served from the cache carry the headers the view set when it made them.
+// Cookies are never served from the cache, see StripCookies.
+//
+// Status is 200 if it isn't given. Views can return any status, with or
+// without a Response, see AppServer.ServeHTTP.
+type Response struct {
+	Body    string
+	Status  int
+	Header  http.Header
+	Cookies []*http.Cookie
+}
+
+// Send sets the headers and cookies of r on w and returns its body and
+// status, for a view to return.
+func (r Response) Send(w http.ResponseWriter) (string, int) {
+	for key, values := range r.Header {
+		w.Header()[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	for _, cookie := range r.Cookies {
+		http.SetCookie(w, cookie)
+	}
+	if r.Status == 0 {
+		return r.Body, http.StatusOK
+	}
+	return r.Body, r.Status
+}
+
+// statusWriter sends a success status other than 200 in place of the
+// 200 the response would otherwise be sent with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.wrote {
+		return
+	}
+	s.wrote = true
+	if status == http.StatusOK {
+		status = s.status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if !s.wrote {
+		s.WriteHeader(http.StatusOK)
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// viewHeaders returns the headers which were set between before and
+// after, other than cookies, so they can be cached with the response.
+func viewHeaders(before, after http.Header) http.Header {
+	set := make(http.Header)
+	for key, values := range after {
+		if key == "Set-Cookie" || equalValues(before[key], values) {
+			continue
+		}
+		set[key] = append([]string(nil), values...)
+	}
+	return set
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheHeaders stores the headers the view set while making the response
+// cached under key, to go with it when it's served from the cache.
+func (App *AppServer) cacheHeaders(key string, before, after http.Header, tags []string) {
+	App.cacheInsert(key, "\x00hdr", viewHeaders(before, after), tags)
+}
+
+// replayHeaders sets the headers cached with the response under key.
+func (App *AppServer) replayHeaders(w http.ResponseWriter, key string) {
+	header, _ := App.cache_map.Find(key + "\x00hdr").(http.Header)
+	for k, values := range header {
+		w.Header()[k] = append([]string(nil), values...)
+	}
+}
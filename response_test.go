@@ -0,0 +1,80 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResponse(t *testing.T) {
+	App := NewAppServer("0", 30)
+	calls := 0
+	App.AddURLs(
+		Route("^/signin$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return Response{
+				Body:    "welcome",
+				Header:  http.Header{"x-frame-options": {"DENY"}},
+				Cookies: []*http.Cookie{{Name: "session", Value: "abc"}},
+			}.Send(w)
+		}),
+		Route("^/api/orders$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return Response{
+				Body:   "created",
+				Status: http.StatusCreated,
+				Header: http.Header{"Location": {"/api/orders/1"}, "Content-Type": {"application/vnd.api+json"}},
+			}.Send(w)
+		}, ContentType(JSON)),
+		Route("^/api/invalid$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return Response{Body: "name is required", Status: http.StatusBadRequest}.Send(w)
+		}),
+		Route("^/cached$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			calls++
+			w.Header().Set("ETag", strconv.Itoa(calls))
+			return strconv.Itoa(calls), http.StatusOK
+		}, Cache(time.Hour), CacheTags("counter")),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/signin")
+	if w.Code != http.StatusOK || w.Body.String() != "welcome" || w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("got %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != "abc" {
+		t.Errorf("got cookies %v", cookies)
+	}
+
+	w = get("/api/orders")
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/api/orders/1" {
+		t.Errorf("got %d %v", w.Code, w.Header())
+	}
+	if ctype := w.Header().Get("Content-Type"); ctype != "application/vnd.api+json" {
+		t.Errorf("the view's Content-Type was replaced with %q", ctype)
+	}
+	if w.Body.String() != encodeJSON("created") {
+		t.Errorf("got %q", w.Body)
+	}
+
+	w = get("/api/invalid")
+	if w.Code != http.StatusBadRequest || w.Body.String() != "name is required" {
+		t.Errorf("got %d %q", w.Code, w.Body)
+	}
+
+	// the headers the view set go with the response when it's served
+	// from the cache.
+	cached := App.routes[len(App.routes)-1]
+	first := get("/cached")
+	w = get("/cached")
+	if w.Body.String() != first.Body.String() || w.Header().Get("ETag") != first.Header().Get("ETag") || calls != 1 {
+		t.Errorf("got %q with ETag %q after %d calls", w.Body, w.Header().Get("ETag"), calls)
+	}
+	App.InvalidateTag("counter")
+	if _, ok := App.cache_map.Find(cacheKey(httptest.NewRequest("GET", "/cached", nil), cached) + "\x00hdr").(http.Header); ok {
+		t.Error("the cached headers outlived the response")
+	}
+}
@@ -0,0 +1,104 @@
+package wedge
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// routeTree indexes routes by the literal text their patterns start with,
+// so that a request is only matched against the routes which could match
+// it rather than every route in turn. Routes whose patterns don't start
+// with literal text, or aren't anchored, are tried for every request.
+type routeTree struct {
+	routes []*Rule
+	root   *routeNode
+}
+
+// routeNode holds the routes whose literal prefix ends at it, by their
+// position in the route table.
+type routeNode struct {
+	children map[byte]*routeNode
+	routes   []int
+}
+
+// routePrefix returns the literal text every path matched by the pattern
+// re has to start with.
+func routePrefix(re string) string {
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	subs := []*syntax.Regexp{parsed}
+	if parsed.Op == syntax.OpConcat {
+		subs = parsed.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	var prefix strings.Builder
+	for _, sub := range subs[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix.WriteString(string(sub.Rune))
+	}
+	return prefix.String()
+}
+
+func newRouteTree(routes []*Rule) *routeTree {
+	t := &routeTree{routes: routes, root: &routeNode{}}
+	for i, route := range routes {
+		n := t.root
+		prefix := routePrefix(route.match.String())
+		for j := 0; j < len(prefix); j++ {
+			if n.children == nil {
+				n.children = make(map[byte]*routeNode)
+			}
+			child, ok := n.children[prefix[j]]
+			if !ok {
+				child = &routeNode{}
+				n.children[prefix[j]] = child
+			}
+			n = child
+		}
+		n.routes = append(n.routes, i)
+	}
+	return t
+}
+
+// candidates returns the routes which could match path, in the order they
+// were added.
+func (t *routeTree) candidates(path string) []*Rule {
+	var found []int
+	n := t.root
+	for i := 0; ; i++ {
+		found = append(found, n.routes...)
+		if i == len(path) {
+			break
+		}
+		if n = n.children[path[i]]; n == nil {
+			break
+		}
+	}
+	// routes with longer prefixes were found later, but the first
+	// route added which matches has to win.
+	sort.Ints(found)
+	routes := make([]*Rule, len(found))
+	for i, index := range found {
+		routes[i] = t.routes[index]
+	}
+	return routes
+}
+
+// routesFor returns the routes which could match path, rebuilding the
+// index if routes have been added since it was last built.
+func (App *AppServer) routesFor(path string) []*Rule {
+	t, _ := App.tree.Load().(*routeTree)
+	if t == nil || len(t.routes) != len(App.routes) ||
+		len(App.routes) > 0 && &t.routes[0] != &App.routes[0] {
+		t = newRouteTree(App.routes)
+		App.tree.Store(t)
+	}
+	return t.candidates(path)
+}
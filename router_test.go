@@ -0,0 +1,64 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutePrefix(t *testing.T) {
+	tests := map[string]string{
+		`^/posts/$`:                   "/posts/",
+		`^/users/(?P<id>\d+)/$`:       "/users/",
+		`^/favicon.ico$`:              "/favicon",
+		`(?i)^/About`:                 "",
+		`/anywhere`:                   "",
+		`^/a|^/b`:                     "",
+		`^(?:/static/(.*))$`:          "/static/",
+		`^/files/(?P<rest>.+)$`:       "/files/",
+		`^/tags/(?P<tag>[-a-z0-9]+)/`: "/tags/",
+	}
+	for re, want := range tests {
+		if got := routePrefix(re); got != want {
+			t.Errorf("routePrefix(%q) = %q, want %q", re, got, want)
+		}
+	}
+}
+
+func TestRouteTreeOrder(t *testing.T) {
+	view := func(body string) func(http.ResponseWriter, *http.Request) (string, int) {
+		return func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return body, http.StatusOK
+		}
+	}
+	App := NewAppServer("0", 0)
+	App.AddURLs(
+		URL("^/posts/new/$", "New", view("new"), HTML),
+		URL(`^/posts/(?P<slug>[^/]+)/$`, "Post", view("post"), HTML),
+		URL("/legacy", "Legacy", view("legacy"), HTML),
+		URL("^/posts/legacy/$", "Shadowed", view("shadowed"), HTML),
+		URL("^/$", "Index", view("index"), HTML),
+	)
+
+	for path, want := range map[string]string{
+		"/posts/new/":       "new",
+		"/posts/hello/":     "post",
+		"/posts/legacy/":    "post",
+		"/old/legacy/thing": "legacy",
+		"/":                 "index",
+	} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != want {
+			t.Errorf("%s: got %q, want %q", path, w.Body.String(), want)
+		}
+	}
+
+	// routes added after the index was built are found too.
+	App.AddURLs(URL("^/late/$", "Late", view("late"), HTML))
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/late/", nil))
+	if w.Body.String() != "late" {
+		t.Errorf("late route not matched: %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,199 @@
+package wedge
+
+import (
+	"strings"
+	"time"
+)
+
+// Option configures a route created with Route.
+type Option func(*Rule)
+
+// Route is a function which returns a *Rule value configured by opts. It
+// is the general form of URL, CacheURL and Download, which are all
+// wrappers around it.
+//
+// re is a regular expression, just as for URL. Without any options the
+// route is an uncached HTML route which answers every method.
+//
+// Example:
+//
+//	wedge.Route("^/x$", X,
+//		wedge.Name("x"),
+//		wedge.Cache(5*time.Minute),
+//		wedge.Methods("GET"),
+//		wedge.ContentType(wedge.HTML),
+//	)
+func Route(re string, v view, opts ...Option) *Rule {
+	u := makeurl(strictPattern(re), "", v, HTML, 0)
+	// no duration has been given yet, so the AppServer's cache policy
+	// for the route's handler type applies unless an option sets one.
+	u.cache_set = false
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Name sets what the route should be referred to as.
+func Name(name string) Option {
+	return func(u *Rule) {
+		u.name = name
+	}
+}
+
+// ContentType sets the handler type of the route, which decides how the
+// response is sent to the client.
+func ContentType(t handlertype) Option {
+	return func(u *Rule) {
+		u.viewtype = t
+	}
+}
+
+// Cache caches responses from the route for d. A negative duration caches
+// responses forever.
+func Cache(d time.Duration) Option {
+	return func(u *Rule) {
+		u.setCache(cacheUnits(d))
+	}
+}
+
+// cacheUnits converts d into multiples of TIMEOUT, rounding durations
+// shorter than TIMEOUT up rather than disabling caching. Negative
+// durations stay negative, meaning forever.
+func cacheUnits(d time.Duration) time.Duration {
+	if d < 0 {
+		return -1
+	}
+	units := d / TIMEOUT
+	if d > 0 && units == 0 {
+		units = 1
+	}
+	return units
+}
+
+// Methods restricts the route to the given HTTP methods. Requests with
+// any other method carry on looking for a matching route, and are answered
+// with a 405 Method Not Allowed if no route takes them. Routes which allow
+// GET allow HEAD as well.
+func Methods(methods ...string) Option {
+	return func(u *Rule) {
+		for _, method := range methods {
+			u.methods = append(u.methods, strings.ToUpper(method))
+		}
+	}
+}
+
+// Accepts is the Option form of Rule.Accepts.
+func Accepts(ctypes ...string) Option {
+	return func(u *Rule) {
+		u.Accepts(ctypes...)
+	}
+}
+
+// Vary is the Option form of Rule.Vary.
+func Vary(headers ...string) Option {
+	return func(u *Rule) {
+		u.Vary(headers...)
+	}
+}
+
+// CacheTags is the Option form of Rule.CacheTags.
+func CacheTags(tags ...string) Option {
+	return func(u *Rule) {
+		u.CacheTags(tags...)
+	}
+}
+
+// allows reports whether the route answers requests with method.
+func (u *Rule) allows(method string) bool {
+	if len(u.methods) == 0 {
+		return true
+	}
+	for _, m := range u.methods {
+		if m == method || m == "GET" && method == "HEAD" {
+			return true
+		}
+	}
+	return false
+}
+
+// GET returns a *Rule like URL's which only answers GET and HEAD requests.
+//
+// Example:
+//
+//	wedge.GET("^/posts/$", "Posts", ListPosts, wedge.HTML),
+//	wedge.POST("^/posts/$", "New post", CreatePost, wedge.HTML),
+func GET(re, name string, v view, t handlertype) *Rule {
+	return Route(re, v, Name(name), ContentType(t), Methods("GET"))
+}
+
+// POST returns a *Rule like URL's which only answers POST requests.
+func POST(re, name string, v view, t handlertype) *Rule {
+	return Route(re, v, Name(name), ContentType(t), Methods("POST"))
+}
+
+// PUT returns a *Rule like URL's which only answers PUT requests.
+func PUT(re, name string, v view, t handlertype) *Rule {
+	return Route(re, v, Name(name), ContentType(t), Methods("PUT"))
+}
+
+// PATCH returns a *Rule like URL's which only answers PATCH requests.
+func PATCH(re, name string, v view, t handlertype) *Rule {
+	return Route(re, v, Name(name), ContentType(t), Methods("PATCH"))
+}
+
+// DELETE returns a *Rule like URL's which only answers DELETE requests.
+func DELETE(re, name string, v view, t handlertype) *Rule {
+	return Route(re, v, Name(name), ContentType(t), Methods("DELETE"))
+}
+
+// Pattern returns the regular expression the route matches against.
+func (u *Rule) Pattern() string {
+	return u.rawre
+}
+
+// Name returns what the route is referred to as.
+func (u *Rule) Name() string {
+	return u.name
+}
+
+// Methods returns the HTTP methods the route answers. It is empty if
+// the route answers every method.
+func (u *Rule) Methods() []string {
+	return append([]string{}, u.methods...)
+}
+
+// ContentType returns the handler type of the route.
+func (u *Rule) ContentType() handlertype {
+	return u.viewtype
+}
+
+// CacheTTL returns how long responses from the route are cached for. It
+// is zero if the route isn't cached and negative if it is cached forever.
+func (u *Rule) CacheTTL() time.Duration {
+	if u.cache_duration == forever {
+		return -1
+	}
+	return u.cache_duration * TIMEOUT
+}
+
+// SetMeta attaches a value to the route under key, for tools which work
+// with wedge route tables.
+func (u *Rule) SetMeta(key string, value interface{}) *Rule {
+	if u.meta == nil {
+		u.meta = make(map[string]interface{})
+	}
+	u.meta[key] = value
+	return u
+}
+
+// Meta returns the value attached to the route under key, or nil.
+func (u *Rule) Meta(key string) interface{} {
+	return u.meta[key]
+}
+
+// Routes returns the routes which have been added to the AppServer, in
+// the order they are matched against.
+func (App *AppServer) Routes() []*Rule {
+	return append([]*Rule{}, App.routes...)
+}
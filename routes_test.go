@@ -0,0 +1,141 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMethodRouting(t *testing.T) {
+	App := NewAppServer("0", 0)
+	App.AddURLs(
+		GET("^/posts/$", "List", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "list", http.StatusOK
+		}, HTML),
+		POST("^/posts/$", "Create", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "create", http.StatusOK
+		}, HTML),
+	)
+
+	for method, want := range map[string]string{"GET": "list", "POST": "create", "HEAD": "list"} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest(method, "/posts/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s: got %d %q, want %q", method, w.Code, w.Body.String(), want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("DELETE", "/posts/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: got %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, POST, HEAD" {
+		t.Errorf("got Allow %q", allow)
+	}
+
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("DELETE", "/missing/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown path: got %d, want 404", w.Code)
+	}
+
+	App.Handler405(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return req.Method + " isn't one of " + w.Header().Get("Allow"), http.StatusMethodNotAllowed
+	})
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("PUT", "/posts/", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "PUT isn't one of GET, POST, HEAD" {
+		t.Errorf("PUT: got %d %q", w.Code, w.Body)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "ok", http.StatusOK
+	}
+	route := Route("^/x$", view,
+		Name("x"),
+		Cache(5*time.Minute),
+		Methods("get", "post"),
+		ContentType(JSON),
+	)
+	if route.name != "x" || route.viewtype != JSON || route.cache_duration != 300 ||
+		!reflect.DeepEqual(route.methods, []string{"GET", "POST"}) {
+		t.Errorf("got %s, %v, %d and %v", route.name, route.viewtype, route.cache_duration, route.methods)
+	}
+	for method, want := range map[string]bool{"GET": true, "HEAD": true, "POST": true, "DELETE": false} {
+		if route.allows(method) != want {
+			t.Errorf("%s: got %v, want %v", method, !want, want)
+		}
+	}
+
+	// without options a route is uncached HTML answering every method.
+	plain := Route("^/x$", view)
+	if plain.viewtype != HTML || plain.cache_duration != 0 || plain.cache_set || !plain.allows("DELETE") {
+		t.Errorf("got %v, %d, %v and %v", plain.viewtype, plain.cache_duration, plain.cache_set, plain.methods)
+	}
+
+	for _, test := range []struct {
+		name  string
+		route *Rule
+		want  time.Duration
+	}{
+		{"rounded up", Route("^/x$", view, Cache(time.Millisecond)), TIMEOUT},
+		{"forever", Route("^/x$", view, Cache(-1)), -1},
+		{"CacheURL", CacheURL("^/x$", "x", view, HTML, 10), 10 * TIMEOUT},
+		{"URL", URL("^/x$", "x", view, HTML), 0},
+	} {
+		if got := test.route.CacheTTL(); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	if d := Download("^/x$", "x", view); d.viewtype != DOWNLOAD || d.name != "x" {
+		t.Errorf("Download: got %v and %q", d.viewtype, d.name)
+	}
+
+	App := NewAppServer("0", 0)
+	App.AddURLs(route)
+	for method, want := range map[string]int{"POST": http.StatusOK, "PUT": http.StatusMethodNotAllowed} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest(method, "/x", nil))
+		if w.Code != want {
+			t.Errorf("%s: got %d, want %d", method, w.Code, want)
+		}
+	}
+}
+
+func TestRuleAccessors(t *testing.T) {
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "ok", http.StatusOK
+	}
+	posts := GET("^/posts/$", "Posts", view, JSON).SetMeta("summary", "List posts")
+	forever := Route("^/about/$", view, Name("About"), Cache(-1))
+	App := NewAppServer("0", 0)
+	App.AddURLs(posts, forever)
+
+	routes := App.Routes()
+	if len(routes) != 2 || routes[0] != posts || routes[1] != forever {
+		t.Fatalf("got routes %v", routes)
+	}
+	if posts.Pattern() != "^/posts/$" || posts.Name() != "Posts" || posts.ContentType() != JSON ||
+		posts.CacheTTL() != 0 || forever.CacheTTL() != -1 {
+		t.Errorf("got %q, %q, %v, %v and %v", posts.Pattern(), posts.Name(), posts.ContentType(),
+			posts.CacheTTL(), forever.CacheTTL())
+	}
+	if posts.Meta("summary") != "List posts" || posts.Meta("missing") != nil || forever.Meta("summary") != nil {
+		t.Errorf("got meta %v", posts.meta)
+	}
+
+	// the slices returned are copies.
+	posts.Methods()[0] = "DELETE"
+	routes[0] = nil
+	if !reflect.DeepEqual(posts.Methods(), []string{"GET"}) || App.Routes()[0] != posts {
+		t.Errorf("got %v and %v", posts.Methods(), App.Routes())
+	}
+	if len(forever.Methods()) != 0 {
+		t.Errorf("got %v", forever.Methods())
+	}
+}
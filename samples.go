@@ -0,0 +1,298 @@
+package wedge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sampling configures the example requests and responses recorded for
+// JSON routes by EnableSampling.
+type Sampling struct {
+	// PerRoute is how many examples are kept for each route, the most
+	// recent replacing the oldest. It defaults to 5.
+	PerRoute int
+	// Rate is the fraction of requests sampled, between 0 and 1. Zero
+	// samples every request.
+	Rate float64
+	// MaxBody is the largest body, in bytes, which is kept. Larger
+	// bodies are recorded by their size alone. It defaults to 64KB.
+	MaxBody int
+	// Redact adds to the names of headers, query parameters and JSON or
+	// form fields whose values are never recorded. Names containing any
+	// of SensitiveNames, or of these, are redacted, ignoring case.
+	Redact []string
+}
+
+// SensitiveNames are the parts of names whose values are redacted from
+// examples, whatever Sampling.Redact holds.
+var SensitiveNames = []string{
+	"auth", "cookie", "password", "passwd", "secret", "token", "session",
+	"api-key", "api_key", "apikey", "signature", "card", "cvv", "ssn",
+}
+
+// redacted replaces the values of sensitive names in examples.
+const redacted = "[redacted]"
+
+// Example is a request to a JSON route and the response to it, with
+// sensitive values redacted. They're served as JSON under
+// ^/statistics/examples/?$, keyed by route.
+type Example struct {
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+}
+
+// sampler holds the examples recorded for each route.
+type sampler struct {
+	sync.Mutex
+	Sampling
+	examples map[string][]Example
+	next     map[string]int
+}
+
+// EnableSampling records example requests and responses for JSON routes,
+// to show API consumers real payloads and to seed API documentation.
+// Headers, query parameters and fields named in s.Redact or
+// SensitiveNames are redacted, as are bodies which are neither JSON nor
+// forms, and requests which ask not to be tracked are never sampled.
+//
+// This function will append a new *Rule onto the associated AppServer,
+// which serves the examples as JSON under ^/statistics/examples/?$.
+//
+// Example:
+//
+//	App.EnableSampling(wedge.Sampling{PerRoute: 3, Rate: 0.01})
+func (App *AppServer) EnableSampling(s Sampling) {
+	if s.PerRoute <= 0 {
+		s.PerRoute = 5
+	}
+	if s.MaxBody <= 0 {
+		s.MaxBody = 64 << 10
+	}
+	App.sampler = &sampler{
+		Sampling: s,
+		examples: make(map[string][]Example),
+		next:     make(map[string]int),
+	}
+	App.routes = append(App.routes, makeurl("^/statistics/examples/?$", "Statistics Examples",
+		func(w http.ResponseWriter, req *http.Request) (string, int) {
+			b, err := json.MarshalIndent(App.Examples(), "", "  ")
+			if err != nil {
+				return "", http.StatusInternalServerError
+			}
+			w.Header().Set("Content-Type", "application/json")
+			return string(b), http.StatusOK
+		}, HTML, 0))
+}
+
+// Examples returns the examples recorded for each route, oldest first,
+// keyed by the route's name or, if it has none, its pattern.
+func (App *AppServer) Examples() map[string][]Example {
+	s := App.sampler
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	examples := make(map[string][]Example, len(s.examples))
+	for route, ring := range s.examples {
+		next := s.next[route] % len(ring)
+		examples[route] = append(append([]Example(nil), ring[next:]...), ring[:next]...)
+	}
+	return examples
+}
+
+// sampled reports whether a request to route should be recorded.
+func (s *sampler) sampled(route *Rule) bool {
+	return route.viewtype == JSON && (s.Rate <= 0 || rand.Float64() < s.Rate)
+}
+
+// start begins recording an example of req, returning the request and
+// writer to serve it with and a func to call once it has been answered.
+func (s *sampler) start(w http.ResponseWriter, req *http.Request, route *Rule) (http.ResponseWriter, *http.Request, func()) {
+	example := Example{
+		Time:           time.Now(),
+		Method:         req.Method,
+		URL:            s.redactURL(req.URL),
+		RequestHeaders: s.redactHeader(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		// only as much of the body as may be kept is read ahead, and the
+		// view is given all of it.
+		head, _ := ioutil.ReadAll(io.LimitReader(req.Body, int64(s.MaxBody)+1))
+		example.RequestBody = s.redactBody(head, req.Header.Get("Content-Type"), req.ContentLength)
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+	}
+	capture := &captureWriter{ResponseWriter: w, max: s.MaxBody}
+	name := route.name
+	if name == "" {
+		name = route.rawre
+	}
+	return WrapWriter(w, capture), req, func() {
+		// nothing was written if the view panicked.
+		if capture.status == 0 {
+			return
+		}
+		example.Status = capture.status
+		example.ResponseHeaders = s.redactHeader(w.Header())
+		example.ResponseBody = s.redactBody(capture.body.Bytes(), w.Header().Get("Content-Type"), int64(capture.size))
+		s.record(name, example)
+	}
+}
+
+// record adds example to those kept for route.
+func (s *sampler) record(route string, example Example) {
+	s.Lock()
+	defer s.Unlock()
+	ring := s.examples[route]
+	if len(ring) < s.PerRoute {
+		s.examples[route] = append(ring, example)
+		return
+	}
+	ring[s.next[route]] = example
+	s.next[route] = (s.next[route] + 1) % s.PerRoute
+}
+
+// sensitive reports whether the value of name mustn't be recorded.
+func (s *sampler) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, names := range [][]string{SensitiveNames, s.Redact} {
+		for _, part := range names {
+			if strings.Contains(name, strings.ToLower(part)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *sampler) redactHeader(h http.Header) http.Header {
+	clean := make(http.Header, len(h))
+	for key, values := range h {
+		if s.sensitive(key) {
+			clean[key] = []string{redacted}
+		} else {
+			clean[key] = append([]string(nil), values...)
+		}
+	}
+	return clean
+}
+
+func (s *sampler) redactValues(values url.Values) url.Values {
+	for key := range values {
+		if s.sensitive(key) {
+			values[key] = []string{redacted}
+		}
+	}
+	return values
+}
+
+func (s *sampler) redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return u.Path + "?" + redacted
+	}
+	return u.Path + "?" + s.redactValues(query).Encode()
+}
+
+// redactBody returns body, which is size bytes long in full, with the
+// values of sensitive fields redacted. Bodies which are too large or
+// can't be parsed are only described, as there's no telling what they
+// hold.
+func (s *sampler) redactBody(body []byte, ctype string, size int64) string {
+	if size < 0 {
+		size = int64(len(body))
+	}
+	if size == 0 {
+		return ""
+	}
+	mediatype, _, _ := mime.ParseMediaType(ctype)
+	if len(body) <= s.MaxBody {
+		switch {
+		case mediatype == "application/json" || strings.HasSuffix(mediatype, "+json"):
+			var v interface{}
+			if json.Unmarshal(body, &v) == nil {
+				b, _ := json.Marshal(s.redactJSON(v))
+				return string(b)
+			}
+		case mediatype == "application/x-www-form-urlencoded":
+			if values, err := url.ParseQuery(string(body)); err == nil {
+				return s.redactValues(values).Encode()
+			}
+		}
+	}
+	if mediatype == "" {
+		mediatype = "unknown type"
+	}
+	return fmt.Sprintf("(%d bytes of %s)", size, mediatype)
+}
+
+func (s *sampler) redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s.sensitive(key) {
+				v[key] = redacted
+			} else {
+				v[key] = s.redactJSON(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = s.redactJSON(value)
+		}
+	}
+	return v
+}
+
+// captureWriter keeps the status and the start of the body of a response
+// as it's written.
+type captureWriter struct {
+	http.ResponseWriter
+	max    int
+	status int
+	size   int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.size += len(b)
+	if room := c.max + 1 - c.body.Len(); room > 0 {
+		if room > len(b) {
+			room = len(b)
+		}
+		c.body.Write(b[:room])
+	}
+	return c.ResponseWriter.Write(b)
+}
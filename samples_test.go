@@ -0,0 +1,81 @@
+package wedge
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSampling(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.AddURLs(
+		Route("^/api/login$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			body, _ := ioutil.ReadAll(req.Body)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			return string(body), http.StatusOK
+		}, Name("Login"), ContentType(JSON)),
+		Route("^/api/upload$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			body, _ := ioutil.ReadAll(req.Body)
+			return strings.ToUpper(string(body[:3])), http.StatusOK
+		}, ContentType(JSON)),
+		Route("^/page$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "page", http.StatusOK
+		}),
+	)
+	App.EnableSampling(Sampling{PerRoute: 2, MaxBody: 100, Redact: []string{"email"}})
+	send := func(method, path, ctype, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", ctype)
+		req.Header.Set("Authorization", "Bearer xyz")
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/login?next=/home&token=t0p", "application/json",
+		`{"user":"ann","password":"hunter2","profile":{"Email":"ann@example.com"}}`)
+	if !strings.Contains(w.Body.String(), "hunter2") {
+		t.Fatalf("the view didn't get the whole body, got %q", w.Body)
+	}
+	send("GET", "/page", "", "")
+	for i := 0; i < 3; i++ {
+		send("POST", "/api/upload", "application/octet-stream", strings.Repeat("x", 200+i))
+	}
+
+	examples := App.Examples()
+	if len(examples) != 2 || len(examples["Login"]) != 1 || len(examples["^/api/upload$"]) != 2 {
+		t.Fatalf("got examples for %v", examples)
+	}
+	login := examples["Login"][0]
+	if login.URL != "/api/login?next=%2Fhome&token=%5Bredacted%5D" {
+		t.Errorf("got URL %q", login.URL)
+	}
+	if login.RequestBody != `{"password":"[redacted]","profile":{"Email":"[redacted]"},"user":"ann"}` {
+		t.Errorf("got request body %q", login.RequestBody)
+	}
+	if login.RequestHeaders.Get("Authorization") != redacted || login.ResponseHeaders.Get("Set-Cookie") != redacted {
+		t.Errorf("got headers %v and %v", login.RequestHeaders, login.ResponseHeaders)
+	}
+	if login.Status != http.StatusOK || strings.Contains(login.ResponseBody, "hunter2") {
+		t.Errorf("got %d %q", login.Status, login.ResponseBody)
+	}
+
+	// the most recent examples are kept, and bodies which are too large
+	// to keep, or aren't JSON or forms, are only described.
+	uploads := examples["^/api/upload$"]
+	if uploads[0].RequestBody != "(201 bytes of application/octet-stream)" || uploads[1].RequestBody != "(202 bytes of application/octet-stream)" {
+		t.Errorf("got %q and %q", uploads[0].RequestBody, uploads[1].RequestBody)
+	}
+	if uploads[1].ResponseBody != `{"message":"XXX"}` {
+		t.Errorf("got response body %q", uploads[1].ResponseBody)
+	}
+
+	w = send("GET", "/statistics/examples/", "", "")
+	var served map[string][]Example
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil || len(served["Login"]) != 1 {
+		t.Errorf("got %v from %q", err, w.Body)
+	}
+}
@@ -0,0 +1,320 @@
+// Package search is an extension to wedge which keeps a small full-text
+// index of a site's pages in memory, so that mostly static sites can offer
+// search without an external service.
+//
+// Pages are added from their rendered HTML, either directly with Add or
+// by fetching them from the AppServer itself with Fetch, and are searched
+// with the Search view:
+//
+//	index := search.NewIndex()
+//	App.AddURLs(wedge.URL("^/search/?$", "Search", index.Search, wedge.HTML))
+//	go func() {
+//		if err := index.Fetch(App, "/", "/about/", "/blog/"); err != nil {
+//			log.Println(err)
+//		}
+//	}()
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TitleWeight is how many times more a word in a page's title counts
+// than one in its body.
+var TitleWeight = 3
+
+// stopWords are too common to be worth indexing.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+// document is an indexed page.
+type document struct {
+	path  string
+	title string
+	text  string
+	terms map[string]int
+}
+
+// Result is a page matching a search.
+type Result struct {
+	Path  string
+	Title string
+	// Snippet is the text around the first match in the page.
+	Snippet string
+	Score   float64
+}
+
+// Index is an in-memory full-text index of pages. It's safe to use from
+// multiple goroutines.
+type Index struct {
+	sync.RWMutex
+	docs map[string]*document
+	// postings maps each term to the paths of the pages containing it.
+	postings map[string]map[string]bool
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]bool),
+	}
+}
+
+// Add indexes the page at path from its HTML, replacing any page already
+// indexed there. The contents of script and style elements are ignored,
+// and the title is taken from the title element if there is one. Plain
+// text and markdown can be added too, they simply have no title.
+func (i *Index) Add(path, page string) {
+	title, text := extractText(page)
+	doc := &document{path: path, title: title, text: text, terms: make(map[string]int)}
+	for _, term := range tokenize(title) {
+		doc.terms[term] += TitleWeight
+	}
+	for _, term := range tokenize(text) {
+		doc.terms[term]++
+	}
+
+	i.Lock()
+	defer i.Unlock()
+	i.remove(path)
+	i.docs[path] = doc
+	for term := range doc.terms {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[string]bool)
+		}
+		i.postings[term][path] = true
+	}
+}
+
+// Remove drops the page at path from the index.
+func (i *Index) Remove(path string) {
+	i.Lock()
+	defer i.Unlock()
+	i.remove(path)
+}
+
+func (i *Index) remove(path string) {
+	doc, ok := i.docs[path]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(i.postings[term], path)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.docs, path)
+}
+
+// Len returns how many pages are indexed.
+func (i *Index) Len() int {
+	i.RLock()
+	defer i.RUnlock()
+	return len(i.docs)
+}
+
+// Fetch renders each of paths with a GET request to h, usually the
+// AppServer, and adds the ones answered with 200 to the index. It carries
+// on past failures, returning an error naming the paths which failed.
+func (i *Index) Fetch(h http.Handler, paths ...string) error {
+	var failed []string
+	for _, path := range paths {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			failed = append(failed, path)
+			continue
+		}
+		w := &recorder{header: make(http.Header), status: http.StatusOK}
+		h.ServeHTTP(w, req)
+		if w.status != http.StatusOK {
+			failed = append(failed, path)
+			continue
+		}
+		i.Add(path, w.body.String())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("search: couldn't index %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// recorder is the http.ResponseWriter pages are rendered into by Fetch.
+type recorder struct {
+	header http.Header
+	status int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status = status
+		r.wrote = true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(b)
+}
+
+// Query returns the pages matching any of the words of query, best first,
+// ranked by TF-IDF. At most limit results are returned, or all of them if
+// limit isn't positive.
+func (i *Index) Query(query string, limit int) []Result {
+	terms := tokenize(query)
+	i.RLock()
+	defer i.RUnlock()
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		paths := i.postings[term]
+		if len(paths) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(i.docs))/float64(len(paths)))
+		for path := range paths {
+			doc := i.docs[path]
+			scores[path] += float64(doc.terms[term]) / float64(len(doc.terms)) * idf
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for path, score := range scores {
+		doc := i.docs[path]
+		results = append(results, Result{
+			Path:    path,
+			Title:   doc.title,
+			Snippet: snippet(doc.text, terms),
+			Score:   score,
+		})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Path < results[b].Path
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// tokenize splits s into lower case words, leaving out stop words and
+// single characters.
+func tokenize(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) > 1 && !stopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// extractText returns the title and visible text of an HTML page.
+func extractText(page string) (title, text string) {
+	var buf, titleBuf bytes.Buffer
+	skip := ""
+	inTitle := false
+	for len(page) > 0 {
+		start := strings.IndexByte(page, '<')
+		if start < 0 {
+			start = len(page)
+		}
+		if skip == "" {
+			if inTitle {
+				titleBuf.WriteString(page[:start])
+			} else {
+				buf.WriteString(page[:start])
+			}
+		}
+		page = page[start:]
+		if page == "" {
+			break
+		}
+		end := strings.IndexByte(page, '>')
+		if end < 0 {
+			break
+		}
+		tag := strings.ToLower(strings.Fields(page[1:end] + " ")[0])
+		page = page[end+1:]
+		switch {
+		case skip != "":
+			if tag == "/"+skip {
+				skip = ""
+			}
+		case tag == "script" || tag == "style":
+			skip = tag
+		case tag == "title":
+			inTitle = true
+		case tag == "/title":
+			inTitle = false
+		default:
+			buf.WriteByte(' ')
+		}
+	}
+	title = strings.Join(strings.Fields(html.UnescapeString(titleBuf.String())), " ")
+	text = strings.Join(strings.Fields(html.UnescapeString(buf.String())), " ")
+	return title, text
+}
+
+// snippetLength is roughly how many characters a Result's Snippet has.
+const snippetLength = 160
+
+// snippet returns the text around the first of terms in text.
+func snippet(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	at := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (at < 0 || i < at) {
+			at = i
+		}
+	}
+	runes := []rune(text)
+	start := 0
+	if at > 0 {
+		start = len([]rune(lower[:at])) - snippetLength/4
+		if start < 0 {
+			start = 0
+		}
+		if start+snippetLength > len(runes) {
+			start = len(runes) - snippetLength
+		}
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+	s := string(runes[start:end])
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const page = `<html><head><title>Gardening &amp; Tools</title>
+<style>.spade { color: red }</style><script>var spade = 1;</script></head>
+<body><h1>Tools</h1><p>A spade<br>and a <em>rake</em>.</p></body></html>`
+
+func TestExtractText(t *testing.T) {
+	title, text := extractText(page)
+	if title != "Gardening & Tools" {
+		t.Errorf("got title %q", title)
+	}
+	if text != "Tools A spade and a rake ." {
+		t.Errorf("got text %q", text)
+	}
+	if _, text := extractText("plain *markdown* text"); text != "plain *markdown* text" {
+		t.Errorf("got text %q", text)
+	}
+	if got := strings.Join(tokenize("The Spade, a RAKE & x-ray 2024!"), " "); got != "spade rake ray 2024" {
+		t.Errorf("got terms %q", got)
+	}
+}
+
+func paths(results []Result) string {
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Path)
+	}
+	return strings.Join(got, " ")
+}
+
+func TestQuery(t *testing.T) {
+	index := NewIndex()
+	index.Add("/tools/", page)
+	index.Add("/rakes/", "<p>Rake after rake.</p>")
+	index.Add("/about/", "<title>About the rake shop</title><p>We sell things.</p>")
+	index.Add("/blog/", "<p>Nothing here about gardening.</p>")
+
+	for _, test := range []struct{ query, want string }{
+		// terms count for more the more of a page they make up.
+		{"rake", "/rakes/ /about/ /tools/"},
+		{"gardening", "/tools/ /blog/"},
+		// and for more the fewer pages they're in.
+		{"RAKE spade", "/rakes/ /tools/ /about/"},
+		// script and style aren't indexed, and nor are stop words.
+		{"var color", ""},
+		{"the and", ""},
+		{"", ""},
+	} {
+		if got := paths(index.Query(test.query, 0)); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.query, got, test.want)
+		}
+	}
+	if got := paths(index.Query("rake", 2)); got != "/rakes/ /about/" {
+		t.Errorf("limited to 2 got %q", got)
+	}
+	results := index.Query("spade", 0)
+	if len(results) != 1 || results[0].Title != "Gardening & Tools" || results[0].Snippet != "Tools A spade and a rake ." || results[0].Score <= 0 {
+		t.Errorf("got %+v", results)
+	}
+
+	// adding a page again replaces it.
+	index.Add("/tools/", "<p>Only hoses now.</p>")
+	if got := paths(index.Query("spade", 0)); got != "" {
+		t.Errorf("a replaced page still matches: %q", got)
+	}
+	if got := paths(index.Query("hoses", 0)); got != "/tools/" {
+		t.Errorf("got %q", got)
+	}
+	index.Remove("/tools/")
+	index.Remove("/missing/")
+	if got := paths(index.Query("hoses", 0)); got != "" || index.Len() != 3 {
+		t.Errorf("got %q with %d pages after removing one", got, index.Len())
+	}
+	if _, ok := index.postings["hoses"]; ok {
+		t.Error("a removed page's terms are still posted")
+	}
+}
+
+func TestTitleWeight(t *testing.T) {
+	index := NewIndex()
+	index.Add("/body/", "<title>Shop</title><p>Rake</p>")
+	index.Add("/title/", "<title>Rake</title><p>Shop</p>")
+	if got := paths(index.Query("rake", 0)); got != "/title/ /body/" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler ", 50) + "needle " + strings.Repeat("padding ", 50)
+	s := snippet(text, []string{"needle"})
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") || !strings.Contains(s, "needle") {
+		t.Errorf("got %q", s)
+	}
+	if n := len([]rune(s)); n != snippetLength+2 {
+		t.Errorf("got %d characters", n)
+	}
+	if s := snippet("short text", []string{"text"}); s != "short text" {
+		t.Errorf("got %q", s)
+	}
+	// a match near the end still fills the snippet.
+	if s := snippet(strings.Repeat("x ", 100)+"end", []string{"end"}); !strings.HasSuffix(s, "end") || len([]rune(s)) != snippetLength+1 {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, "<title>Home</title><p>Welcome home.</p>")
+	})
+	mux.HandleFunc("/about/", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "<p>About us.</p>")
+	})
+	mux.HandleFunc("/broken/", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+
+	index := NewIndex()
+	err := index.Fetch(mux, "/", "/missing/", "/about/", "/broken/")
+	if err == nil || err.Error() != "search: couldn't index /missing/, /broken/" {
+		t.Errorf("got %v", err)
+	}
+	if index.Len() != 2 {
+		t.Errorf("indexed %d pages", index.Len())
+	}
+	if results := index.Query("welcome", 0); len(results) != 1 || results[0].Title != "Home" {
+		t.Errorf("got %+v", results)
+	}
+	if err := index.Fetch(mux, "/about/"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// MaxResults is how many results the Search view shows.
+var MaxResults = 20
+
+// Template renders the Search view. It's given the Query and the Results,
+// and can be replaced to match the rest of the site.
+var Template = template.Must(template.New("search.html").Parse(
+	`<!DOCTYPE html><html><head><title>Search</title></head><body>` +
+		`<form method="GET" action=""><input type="search" name="q" value="{{.Query}}" /> ` +
+		`<input type="submit" value="Search"></form>` +
+		`{{if .Query}}{{if .Results}}<ol>{{range .Results}}` +
+		`<li><a href="{{.Path}}">{{if .Title}}{{.Title}}{{else}}{{.Path}}{{end}}</a><p>{{.Snippet}}</p></li>` +
+		`{{end}}</ol>{{else}}<p>Nothing matched {{.Query}}.</p>{{end}}{{end}}` +
+		`</body></html>`))
+
+// Search is a wedge view showing a search form along with the pages
+// matching the "q" query parameter.
+func (i *Index) Search(w http.ResponseWriter, req *http.Request) (string, int) {
+	query := req.URL.Query().Get("q")
+	var results []Result
+	if query != "" {
+		results = i.Query(query, MaxResults)
+	}
+	buf := new(bytes.Buffer)
+	err := Template.Execute(buf, map[string]interface{}{
+		"Query":   query,
+		"Results": results,
+	})
+	if err != nil {
+		log.Println("Error rendering search results:", err)
+		return "", http.StatusInternalServerError
+	}
+	return buf.String(), http.StatusOK
+}
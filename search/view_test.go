@@ -0,0 +1,40 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	index := NewIndex()
+	for _, path := range []string{"/a/", "/b/", "/c/"} {
+		index.Add(path, "<p>Gardening tips from "+path+"</p>")
+	}
+	index.Add("/titled/", "<title>Gardening &lt;guide&gt;</title>")
+	search := func(target string) string {
+		body, status := index.Search(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+		if status != http.StatusOK {
+			t.Errorf("%s: got %d", target, status)
+		}
+		return body
+	}
+
+	body := search("/search/?q=gardening")
+	if strings.Count(body, "<li>") != 4 || !strings.Contains(body, `<a href="/a/">/a/</a>`) || !strings.Contains(body, `<a href="/titled/">Gardening &lt;guide&gt;</a>`) {
+		t.Errorf("got %q", body)
+	}
+	if body := search("/search/"); strings.Contains(body, "<ol>") || strings.Contains(body, "Nothing matched") {
+		t.Errorf("an empty search got %q", body)
+	}
+	if body := search("/search/?q=%3Cb%3Ebulbs"); !strings.Contains(body, "Nothing matched &lt;b&gt;bulbs.") || !strings.Contains(body, `value="&lt;b&gt;bulbs"`) {
+		t.Errorf("got %q", body)
+	}
+
+	defer func(max int) { MaxResults = max }(MaxResults)
+	MaxResults = 2
+	if body := search("/search/?q=gardening"); strings.Count(body, "<li>") != 2 {
+		t.Errorf("got %q with MaxResults 2", body)
+	}
+}
@@ -2,15 +2,19 @@ package wedge
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"mime"
+	"net"
 	"net/http"
 	"html/template"
-	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,27 +22,75 @@ import (
 // so that it satisfies the http.Server interface.
 type AppServer struct {
 	port       string
-	routes     []*url
+	routes     []*Rule
 	timeout    time.Duration
 	cache_map  *safeMap
 	handler404 view
 	handler500 view
+	handler405 view
 	stat_map   *safeMap
+	series     *statSeries
+	timings    *timings
+	breakdown  *breakdown
+	sampler    *sampler
+	geo        GeoResolver
+	privacy    Privacy
+	ipKey      []byte
+	verbosity  Verbosity
+	policies   map[handlertype]time.Duration
+	server     *http.Server
+	done       chan struct{}
+	serveErr   error
+	workers    *workerPool
+	redirects  *redirectMap
+	suggester  *suggester
+	limits     map[handlertype]responseLimit
+	base       string
+	proxies    []*net.IPNet
+	canonical  *Canonical
+	sessions   []string
+	templates  *TemplateRegistry
+	errorPages map[int]string
+	tagUse     map[string][]Middleware
+	tagCache   map[string]time.Duration
+	watchdog   *watchdog
+	offline    int32
+	slow       *slowTracing
+	stages     *stageTimings
+	trials     map[string]*Experiment
+	denied     *denyList
+	honeypot   *honeypot
+	bans       *bans
+	tree       atomic.Value
+	inspectors []RequestInspector
+	middleware []Middleware
+	wrappers   []func(http.Handler) http.Handler
+	debug      bool
+	tlsConfig  *tls.Config
+	autoTLS    AutoTLS
+	redirector *http.Server
+	quit       chan struct{}
+	quitOnce   sync.Once
+	pending    sync.WaitGroup
 }
 
 // AppServer constructor
 func NewAppServer(port string, timeout time.Duration) *AppServer {
 	return &AppServer{
 		port:      port,
-		routes:    make([]*url, 0),
+		routes:    make([]*Rule, 0),
 		timeout:   timeout,
 		cache_map: NewSafeMap(),
+		verbosity: Summary,
+		quit:      make(chan struct{}),
+		denied:    &denyList{until: make(map[string]time.Time)},
 	}
 }
 
-// Attaches more *urls to the Routes slice on the AppServer value
-func (App *AppServer) AddURLs(patterns ...*url) {
+// Attaches more *Rules to the routes slice on the AppServer value
+func (App *AppServer) AddURLs(patterns ...*Rule) {
 	for _, url := range patterns {
+		App.applyCachePolicy(url)
 		App.routes = append(App.routes, url)
 	}
 }
@@ -48,10 +100,13 @@ func (App *AppServer) AddURLs(patterns ...*url) {
 // EnableStatTracking creates a NewSafeMap under the stat_map field which will
 // then be used to increment and aggregate hits to URLs.
 //
-// This function will append a new *url onto the associated AppServer. The url
-// which this is under is ^/statistics/?$.
+// This function will append a new *Rule onto the associated AppServer. The url
+// which this is under is ^/statistics/?$. Hits are also counted over time in
+// the StatWindows, which are served as JSON under ^/statistics/series/?$.
 func (App *AppServer) EnableStatTracking() {
 	App.stat_map = NewSafeMap()
+	App.series = newStatSeries(StatWindows)
+	App.timings = newTimings()
 	now := time.Now().String()
 	staturl := makeurl("^/statistics/?$", "Statistics",
 		func(w http.ResponseWriter, req *http.Request) (string, int) {
@@ -84,7 +139,17 @@ func (App *AppServer) EnableStatTracking() {
 				buf.WriteString(
 					fmt.Sprintf(`<tr><td>Total</td><td>%d</td></tr>`, total),
 				)
-				buf.WriteString(`</table></html>`)
+				buf.WriteString(`</table>`)
+				buf.WriteString(App.series.html())
+				buf.WriteString(App.timings.html())
+				buf.WriteString(App.stages.html())
+				if App.breakdown != nil {
+					buf.WriteString(App.breakdown.html())
+				}
+				if App.workers != nil {
+					buf.WriteString(App.WorkerStats().html())
+				}
+				buf.WriteString(`</html>`)
 				return buf.String()
 			})
 			if !ok {
@@ -93,7 +158,7 @@ func (App *AppServer) EnableStatTracking() {
 			return rawdata.(string), 200
 
 		}, HTML, 0)
-	App.routes = append(App.routes, staturl)
+	App.routes = append(App.routes, staturl, App.seriesURL())
 }
 
 // incrementStats is a non-blocking method to increment a page counter
@@ -103,10 +168,14 @@ func (App *AppServer) incrementStats(k string) {
 	if App.stat_map == nil {
 		panic("Cannot increment statistics when it has not been enabled!")
 	}
+	App.series.record(k, time.Now())
 
 	// create a goroutine which sends a function literal to the async
 	// map which tries to increment the value under the k string.
+	// Shutdown waits for them, so no hits are lost.
+	App.pending.Add(1)
 	go App.stat_map.Do(func(m freemap) interface{} {
+		defer App.pending.Done()
 		val, ok := m[k]
 		if ok {
 			val, ok := val.(int)
@@ -131,27 +200,181 @@ func (App *AppServer) Handler500(fn view) {
 	App.handler500 = fn
 }
 
+// Sets the 405 Handler for the AppServer to fn. It answers requests for
+// paths whose routes don't handle the request's method, and is called
+// with the methods they do handle already set in the Allow header.
+func (App *AppServer) Handler405(fn view) {
+	App.handler405 = fn
+}
+
 // This is the main 'event loop' for the web server. All requests are
 // sent to this handler, which checks the incoming request against
 // all the routes we have setup if it finds a match it will invoke
 // the handler which is attached to that match.
 //
+// The status the view returns decides how its response is sent:
+//
+//   - 200 and other success statuses are sent as the route's handler
+//     type says, apart from 204 and 205 which, like 304, have no body.
+//   - 301, 302, 303, 307 and 308 redirect to the response, a URL.
+//   - 404 and 500 go to the 404 and 500 handlers.
+//   - Other errors are sent with the response as their body, unless
+//     they're answered with an APIError or an ErrorTemplate.
+//   - Anything else, including statuses outside 200 to 599, is a 500.
+//
 // If somehow the URL it finds has been created with a non-existant
-// handler type it will panic.
+// handler type it will panic. Panics are recovered and answered with the
+// 500 handler, or the debug page if SetDebug is on.
 func (App *AppServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	request := req.URL.Path
 	w.Header().Set("Server", "Wedge")
+	if App.watchdog != nil {
+		defer App.watchdog.observe(time.Now())
+	}
+	// a view which panics gets the 500 page, or the debug page, instead
+	// of a dropped connection.
+	defer func() {
+		if err := recover(); err != nil {
+			App.recovered(w, req, err)
+		}
+	}()
+	if App.canonical != nil && App.canonicalize(w, req) {
+		return
+	}
+	req = App.withOrigin(req)
+	req = App.withRequestID(w, req)
+	if App.denied != nil && App.Denied(App.forwardedFor(req)) {
+		App.handle403req(w, req)
+		return
+	}
+	if len(App.inspectors) > 0 {
+		var ok bool
+		if req, ok = App.inspect(req); !ok {
+			App.handle403req(w, req)
+			return
+		}
+	}
+	req, trace := App.traceRequest(req)
+	defer trace.finish()
+	if App.Maintenance() {
+		App.handle503req(w, req)
+		return
+	}
+	if App.base != "" {
+		var ok bool
+		if req, ok = App.stripBase(req); !ok {
+			App.handle404req(w, req)
+			return
+		}
+	}
+	request := req.URL.Path
+	if App.geo != nil {
+		req = withCountry(req, App.forwardedFor(req), App.geo)
+	}
 
-	for _, route := range App.routes {
+	if App.redirects != nil {
+		if to, status, ok := App.redirects.lookup(request); ok {
+			log.Println("Redirect:", request, "=>", to)
+			sendRedirect(w, req, to, status)
+			return
+		}
+	}
+
+	// the methods of routes which match the path but not the method, to
+	// answer with a 405 if nothing else matches.
+	var allowed []string
+	for _, route := range App.routesFor(request) {
+		if route.cors != nil && isPreflight(req) && route.match.MatchString(request) {
+			log.Println("Preflight:", route.name, request)
+			if App.stat_map != nil {
+				App.incrementStats("OPTIONS => " + App.statPath(req))
+			}
+			route.cors.preflight(w, req)
+			return
+		}
+		if !route.allows(req.Method) {
+			if route.match.MatchString(request) {
+				allowed = append(allowed, route.methods...)
+			}
+			continue
+		}
 		matches := route.match.FindAllStringSubmatch(request, 1)
 		if len(matches) > 0 {
-			log.Println("Request:", route.name, request)
+			if route.converters != nil {
+				params, ok := route.convertParams(matches[0])
+				if !ok {
+					continue
+				}
+				req = withParams(req, params)
+			}
+			if route.viewtype == JSON || route.viewtype == NDJSON {
+				req = withAPI(req)
+			}
+			if country := Country(req); country != "" {
+				log.Println("Request:", route.name, request, country)
+			} else {
+				log.Println("Request:", route.name, request)
+			}
+
+			if !route.accepted(req) {
+				App.handle415req(w, req, route)
+				return
+			}
+			if len(route.preload) > 0 || len(route.preconnect) > 0 {
+				route.sendHints(w, req)
+			}
+			for key, values := range route.headers {
+				w.Header()[key] = append([]string(nil), values...)
+			}
+			if len(route.vary) > 0 {
+				w.Header().Set("Vary", strings.Join(route.vary, ", "))
+			}
+			if route.cors != nil {
+				route.cors.apply(w, req)
+			}
+			if route.cache_duration != 0 {
+				req = withTags(req)
+			}
 
 			if App.stat_map != nil {
-				App.incrementStats(request)
+				App.incrementStats(App.statPath(req))
+				App.countTags(route)
+				if App.breakdown != nil && App.trackable(req) {
+					App.breakdown.record(route.name, req, time.Now())
+				}
 			}
 
-			resp, status := App.getResponse(w, req, route)
+			if route.deadline > 0 {
+				ctx, cancel := context.WithTimeout(req.Context(), route.deadline)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			var stream *streamed
+			if route.stream {
+				req, stream = withStream(req)
+			}
+			if App.sampler != nil && App.trackable(req) && App.sampler.sampled(route) {
+				var recorded func()
+				w, req, recorded = App.sampler.start(w, req, route)
+				defer recorded()
+			}
+			trace.matched(route, req)
+			resp, status := App.callRoute(w, req, route)
+			trace.viewDone()
+			if status == StatusClientClosed || clientGone(req) {
+				if stream != nil {
+					stream.close()
+				}
+				App.handleAborted(req)
+				return
+			}
+			// middleware may have answered without calling the view.
+			if stream != nil && stream.r != nil {
+				if status == 200 {
+					App.handle200stream(w, req, stream.r, route)
+					return
+				}
+				stream.close()
+			}
 
 			switch status {
 			case 404:
@@ -161,14 +384,58 @@ func (App *AppServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 				App.handle500req(w, req)
 				return
 			case 200:
+				if route.direct {
+					// the view wrote its own response.
+					return
+				}
+				resp, ok := App.limitResponse(req, route, resp)
+				if !ok {
+					App.handle500req(w, req)
+					return
+				}
 				App.handle200req(w, req, resp, route)
 				return
-			case 303:
-				http.Redirect(w, req, resp, status)
+			case 301, 302, 303, 307, 308:
+				sendRedirect(w, req, resp, status)
+				return
+			case 204, 205, 304:
+				// these responses have no body.
+				w.WriteHeader(status)
+				return
+			default:
+				if status > 200 && status < 300 {
+					resp, ok := App.limitResponse(req, route, resp)
+					if !ok {
+						App.handle500req(w, req)
+						return
+					}
+					App.handle200req(&statusWriter{ResponseWriter: w, status: status}, req, resp, route)
+					return
+				}
+				if status < 300 || status >= 600 {
+					log.Println("Invalid status", status, "from", route.name, request)
+					App.handle500req(w, req)
+					return
+				}
+				if status >= 400 {
+					log.Println(status, "on path:", req.URL.Path)
+					if App.stat_map != nil {
+						App.incrementStats(fmt.Sprint(status) + " => " + App.statPath(req))
+					}
+					if App.sendAPIError(w, req, status, resp) || App.renderError(w, req, status) {
+						return
+					}
+				}
+				w.WriteHeader(status)
+				io.WriteString(w, resp)
 				return
 			}
 		}
 	}
+	if len(allowed) > 0 {
+		App.handle405req(w, req, allowed)
+		return
+	}
 	App.handle404req(w, req)
 	return
 }
@@ -178,9 +445,21 @@ func (App *AppServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func (App *AppServer) handle404req(w http.ResponseWriter, req *http.Request) {
 	log.Println("404 on path:", req.URL.Path)
 	if App.stat_map != nil {
-		App.incrementStats("404 => " + req.URL.Path)
+		App.incrementStats("404 => " + App.statPath(req))
+	}
+	if App.bans != nil && App.bans.Count404s {
+		App.ReportAbuse(req, "404")
 	}
 
+	if App.suggester != nil {
+		req = App.withSuggestions(req)
+	}
+	if App.sendAPIError(w, req, http.StatusNotFound, "") {
+		return
+	}
+	if App.renderError(w, req, http.StatusNotFound) {
+		return
+	}
 	if App.handler404 != nil {
 		resp, status := App.handler404(w, req)
 		w.WriteHeader(status)
@@ -198,9 +477,15 @@ func (App *AppServer) handle404req(w http.ResponseWriter, req *http.Request) {
 func (App *AppServer) handle500req(w http.ResponseWriter, req *http.Request) {
 	log.Println("500 on path:", req.URL.Path)
 	if App.stat_map != nil {
-		App.incrementStats("500 => " + req.URL.Path)
+		App.incrementStats("500 => " + App.statPath(req))
 	}
 
+	if App.sendAPIError(w, req, http.StatusInternalServerError, "") {
+		return
+	}
+	if App.renderError(w, req, http.StatusInternalServerError) {
+		return
+	}
 	if App.handler500 != nil {
 		resp, status := App.handler500(w, req)
 		w.WriteHeader(status)
@@ -212,104 +497,292 @@ func (App *AppServer) handle500req(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// handle415req rejects a request whose body is of a type the route does
+// not accept, listing the types which it does accept.
+func (App *AppServer) handle415req(w http.ResponseWriter, req *http.Request, route *Rule) {
+	log.Println("415 on path:", req.URL.Path)
+	if App.stat_map != nil {
+		App.incrementStats("415 => " + App.statPath(req))
+	}
+
+	switch req.Method {
+	case "POST":
+		w.Header().Set("Accept-Post", strings.Join(route.accepts, ", "))
+	case "PATCH":
+		w.Header().Set("Accept-Patch", strings.Join(route.accepts, ", "))
+	}
+	if App.sendAPIError(w, req, http.StatusUnsupportedMediaType, "") {
+		return
+	}
+	if App.renderError(w, req, http.StatusUnsupportedMediaType) {
+		return
+	}
+	http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+}
+
+// handle405req answers a request whose path is only handled by routes
+// for other methods, listing them in the Allow header.
+func (App *AppServer) handle405req(w http.ResponseWriter, req *http.Request, methods []string) {
+	log.Println("405 on path:", req.Method, req.URL.Path)
+	if App.stat_map != nil {
+		App.incrementStats("405 => " + App.statPath(req))
+	}
+
+	seen := make(map[string]bool)
+	var allow []string
+	for _, method := range methods {
+		if method == "GET" {
+			methods = append(methods, "HEAD")
+		}
+	}
+	for _, method := range methods {
+		if !seen[method] {
+			seen[method] = true
+			allow = append(allow, method)
+		}
+	}
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	if App.sendAPIError(w, req, http.StatusMethodNotAllowed, "") {
+		return
+	}
+	if App.renderError(w, req, http.StatusMethodNotAllowed) {
+		return
+	}
+	if App.handler405 != nil {
+		resp, status := App.handler405(w, req)
+		w.WriteHeader(status)
+		io.WriteString(w, resp)
+		return
+	}
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+}
+
 // handle200req handles the regular 200 response by checking the response
 // type and then switching the response based on that.
-func (App *AppServer) handle200req(w http.ResponseWriter, req *http.Request, resp string, route *url) {
+func (App *AppServer) handle200req(w http.ResponseWriter, req *http.Request, resp string, route *Rule) {
+	if route.viewtype == JSON {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		resp = App.jsonBody(req, route, resp)
+	}
+	if route.viewtype != STATIC && App.conditional(w, req, route, resp) {
+		return
+	}
+	traceOf(req).writeStarted(w)
 	switch route.viewtype {
 	case HTML:
 		io.WriteString(w, resp)
 		return
 	case JSON:
-		w.Header().Set("Content-type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"message": resp,
-		})
+		io.WriteString(w, resp)
 		return
 	case STATIC:
-		reqstr := req.URL.Path[len(route.rawre):]
-		ctype := mime.TypeByExtension(filepath.Ext(reqstr))
-		w.Header().Set("Content-Type", ctype)
-		io.WriteString(w, resp)
+		App.serveStatic(w, req, resp, route)
 		return
 	case ICON:
-		w.Header().Set("Content-Type", "image/x-icon")
-		io.WriteString(w, resp)
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "image/x-icon")
+		}
+		serveRanges(w, req, resp)
 		return
 	case DOWNLOAD:
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Disposition", "attachment")
+		downloadHeaders(w)
+		App.serveDownload(w, req, resp, route)
+	case NDJSON:
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		io.WriteString(w, resp)
+	case IMAGE, FEED:
+		// the view is expected to have set the Content-Type, as it
+		// depends on which format the response was encoded in.
 		io.WriteString(w, resp)
 	default:
 		panic("Unknown handler type!")
 	}
 }
 
-// getResponse checks the *url's cache_duration, if the cache duration
-// is zero. Then we never cache the response. Otherwise, we check to
-// see if the cache_duration has passed by reading the timeout channel
-// if so, we run the URL handler associated with the route and store it's
-// new response value. We then store the response in the cache_map and
-// return it to the client.
+// downloadHeaders sets the headers of a download which its view hasn't.
+func downloadHeaders(w http.ResponseWriter) {
+	// views may have named the file, or streamed it themselves.
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	if w.Header().Get("Content-Disposition") == "" {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+}
+
+// getResponse checks the *Rule's cache_duration, if the cache duration
+// is zero. Then we never cache the response. Otherwise, we look for the
+// response in the cache_map, and if it isn't there or has expired we run
+// the URL handler associated with the route and store its new response
+// value, which expires cache_duration later. We then return it to the
+// client.
 //
 // Accessing the cache_map from multiple threads is safe. There are two
 // implementations of a safe map included with this library. One is sync'd
 // with channels (safeMap) and the other is sync'd with a mutex lock
 // (lockMap). We currently use the safeMap.
-func (App *AppServer) getResponse(w http.ResponseWriter, req *http.Request, route *url) (string, int) {
+func (App *AppServer) getResponse(w http.ResponseWriter, req *http.Request, route *Rule) (string, int) {
 
-	if route.cache_duration == 0 {
+	if route.cache_duration != 0 && !safeMethod(req) {
+		// only GETs and HEADs are cached, whatever methods the route
+		// takes.
+		return App.callView(w, req, route)
+	}
+	if (route.cache_duration != 0 || route.adaptive != nil) && App.personal(req, route) {
+		w.Header().Set("Cache-Control", "private")
 		return route.handler(w, req)
 	}
+	if route.cache_duration == 0 {
+		if route.adaptive != nil {
+			return App.adaptiveResponse(w, req, route)
+		}
+		return App.callView(w, req, route)
+	}
+
+	key := cacheKey(req, route)
+	lookup := time.Now()
+	resp, ok := App.cachedResponse(key, lookup)
+	if t := traceOf(req); t != nil {
+		t.cache = time.Since(lookup)
+	}
+	if ok {
+		App.replayHeaders(w, key)
+		return resp, http.StatusOK
+	}
+	cookies := cookieCount(w)
+	before := w.Header().Clone()
+	resp, status := route.handler(w, req)
+	if status == http.StatusOK && route.shareable(w, cookies) {
+		App.cacheResponse(key, resp, route.cacheExpiry(time.Now()), requestTags(req, route))
+		App.cacheHeaders(key, before, w.Header(), requestTags(req, route))
+	}
+	return resp, status
+}
+
+// callView calls the view of an uncached route, sharing the call between
+// identical requests if the route coalesces them.
+func (App *AppServer) callView(w http.ResponseWriter, req *http.Request, route *Rule) (string, int) {
+	if route.coalesce != nil && safeMethod(req) && !App.personal(req, route) {
+		return route.coalesce.do(w, req, route)
+	}
+	return route.handler(w, req)
+}
+
+// ShutdownTimeout is how long RunE waits for requests in flight to finish
+// once its context is done.
+var ShutdownTimeout = 10 * time.Second
+
+// ErrNotStarted is returned by Wait and Shutdown if Start hasn't been
+// called.
+var ErrNotStarted = errors.New("wedge: server not started")
+
+// Starts the server running on PORT `port` with the timeout duration
+// `timeout`, until it's interrupted by one of ShutdownSignals, when it's
+// shut down gracefully. Any error from the server is logged, use RunE to
+// handle it.
+func (App *AppServer) Run() {
+	ctx, stop := SignalContext(context.Background())
+	defer stop()
+	if err := App.RunE(ctx); err != nil {
+		log.Println(err)
+	}
+}
 
+// RunE runs the server until it fails or ctx is done, in which case it
+// is shut down gracefully. It returns nil after a shutdown, so it can be
+// composed with other servers and workers in an errgroup:
+//
+//	g, ctx := errgroup.WithContext(ctx)
+//	g.Go(func() error { return App.RunE(ctx) })
+//	g.Go(func() error { return Admin.RunE(ctx) })
+//	err := g.Wait()
+func (App *AppServer) RunE(ctx context.Context) error {
+	return App.run(ctx, App.Start)
+}
+
+// run starts the server with start and runs it until it fails or ctx is
+// done, see RunE.
+func (App *AppServer) run(ctx context.Context, start func() error) error {
+	if err := start(); err != nil {
+		return err
+	}
 	select {
-	case <-route.timeout:
-		// get the new response and cache it in the map
-		resp, err := route.handler(w, req)
-		if err != http.StatusOK {
-			go func() {
-				route.timeout <- true
-			}()
-			return resp, err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := App.Shutdown(shutdown); err != nil {
+			return err
 		}
-		if !App.cache_map.Insert(req.URL.Path, resp) {
-			panic("Inserting into cache_map failure!")
-		}
-		// reset the timeout timer
-		go func() {
-			log.Println("Timed out")
-			f := time.After(route.cache_duration * TIMEOUT)
-			<-f
-			go func() {
-				route.timeout <- true
-			}()
-		}()
-		return resp, err
-	default:
-		resp, ok := App.cache_map.Find(req.URL.Path).(string)
-		var status int = 200
-		if !ok {
-			resp, status = route.handler(w, req)
+	case <-App.done:
+	}
+	return App.Wait()
+}
+
+// Start listens on the server's port and serves requests in the
+// background, returning once it's listening. Errors binding the port are
+// returned straight away, and any later ones by Wait.
+func (App *AppServer) Start() error {
+	listener, err := net.Listen("tcp", ":"+App.port)
+	if err != nil {
+		return err
+	}
+	App.serve(listener, nil)
+	return nil
+}
+
+// serve serves requests from listener in the background, over TLS with
+// config if it isn't nil.
+func (App *AppServer) serve(listener net.Listener, config *tls.Config) {
+	App.server = &http.Server{
+		Handler:     App.Handler(),
+		ReadTimeout: App.timeout * time.Second,
+		TLSConfig:   config,
+	}
+	App.done = make(chan struct{})
+	App.banner()
+	App.watchStatic(App.quit)
+	go func() {
+		var err error
+		if config != nil {
+			// the certificates are already in config.
+			err = App.server.ServeTLS(listener, "", "")
+		} else {
+			err = App.server.Serve(listener)
 		}
-		if status != 404 {
-			if !App.cache_map.Insert(req.URL.Path, resp) {
-				panic("Inserting into cache_map failure!")
-			}
+		if err != http.ErrServerClosed {
+			App.serveErr = err
 		}
-		return resp, status
+		close(App.done)
+	}()
+}
+
+// Wait blocks until the server started by Start stops, returning the
+// error which stopped it, or nil if it was shut down.
+func (App *AppServer) Wait() error {
+	if App.done == nil {
+		return ErrNotStarted
 	}
-	panic("unreachable")
+	<-App.done
+	return App.serveErr
 }
 
-// Starts the server running on PORT `port` with the timeout duration
-func (App *AppServer) Run() {
-	server := http.Server{
-		Addr:        ":" + App.port,
-		Handler:     App,
-		ReadTimeout: App.timeout * time.Second,
+// Shutdown stops the server started by Start from accepting requests
+// and waits for those in flight to finish, or for ctx to be done. It then
+// waits for the hits still being counted by the statistics, and stops
+// the goroutines of the workers, watchdog and redirects, see Close.
+func (App *AppServer) Shutdown(ctx context.Context) error {
+	if App.server == nil {
+		return ErrNotStarted
 	}
-	fmt.Printf("Serving on PORT: %s\n", App.port)
-	err := server.ListenAndServe()
-	if err != nil {
-		fmt.Println(err)
+	err := App.server.Shutdown(ctx)
+	if App.redirector != nil {
+		App.redirector.Shutdown(ctx)
 	}
+	App.flushStats(ctx)
+	App.Close()
+	return err
 }
@@ -0,0 +1,103 @@
+package wedge
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRunE(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.SetVerbosity(Quiet)
+	if err := App.Wait(); err != ErrNotStarted {
+		t.Errorf("Wait before Start: got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- App.RunE(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("RunE after shutdown: got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunE didn't return after its context was cancelled")
+	}
+}
+
+func TestStartError(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	App := NewAppServer(port, 30)
+	App.SetVerbosity(Quiet)
+	if err := App.RunE(context.Background()); err == nil {
+		t.Error("RunE on a port in use returned nil")
+	}
+}
+
+func TestViewStatuses(t *testing.T) {
+	registry, err := NewTemplateRegistry(TemplateBundle{"403.html": `forbidden {{.Path}}`}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	App := NewAppServer("0", 30)
+	App.SetTemplates(registry)
+	App.ErrorTemplate(403, "403.html")
+	App.AddURLs(Route("^/status/(?P<code>[0-9]+)$", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		status, _ := strconv.Atoi(Params(req)["code"].(string))
+		if status >= 300 && status < 400 {
+			return "/elsewhere", status
+		}
+		return "body", status
+	}))
+	for _, test := range []struct {
+		status   int
+		body     string
+		location string
+	}{
+		{200, "body", ""},
+		{201, "body", ""},
+		{202, "body", ""},
+		{204, "", ""},
+		{301, "", "/elsewhere"},
+		{302, "", "/elsewhere"},
+		{307, "", "/elsewhere"},
+		{308, "", "/elsewhere"},
+		{304, "", ""},
+		{300, "/elsewhere", ""},
+		{401, "body", ""},
+		{403, "forbidden /status/403", ""},
+		{409, "body", ""},
+		{503, "body", ""},
+	} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/status/"+strconv.Itoa(test.status), nil))
+		if w.Code != test.status || w.Header().Get("Location") != test.location ||
+			test.location == "" && w.Body.String() != test.body {
+			t.Errorf("%d: got %d %q to %q", test.status, w.Code, w.Body, w.Header().Get("Location"))
+		}
+	}
+
+	// statuses which can't be the final one are the view's error.
+	for _, status := range []string{"100", "0", "600"} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/status/"+status, nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: got %d", status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StageTimings breaks down how long requests to a route spend in each
+// stage of being served: matching the route, looking the response up in
+// the cache, calling the view (less any cache lookup), serializing the
+// body once the view has returned, such as wrapping a JSON response, and
+// writing it to the client.
+type StageTimings struct {
+	Route     time.Duration
+	Cache     time.Duration
+	View      time.Duration
+	Serialize time.Duration
+	Write     time.Duration
+}
+
+// Total is the sum of the stages.
+func (s StageTimings) Total() time.Duration {
+	return s.Route + s.Cache + s.View + s.Serialize + s.Write
+}
+
+// header formats the stages which have finished before the body is
+// written as a Server-Timing header, in milliseconds.
+func (s StageTimings) header() string {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return fmt.Sprintf("route;dur=%.3f, cache;dur=%.3f, view;dur=%.3f, serialize;dur=%.3f",
+		ms(s.Route), ms(s.Cache), ms(s.View), ms(s.Serialize))
+}
+
+// stageTimings holds the total time spent in each stage for each route,
+// as turned on by EnableServerTiming.
+type stageTimings struct {
+	sync.Mutex
+	header bool
+	routes map[string]*stageTotals
+}
+
+type stageTotals struct {
+	count int
+	total StageTimings
+}
+
+// EnableServerTiming times each stage of serving every request, see
+// StageTimings, averaging them for each route on the statistics page and
+// in App.StageTimings. If header is set, responses carry a Server-Timing
+// header so browser developer tools can show the breakdown too. As the
+// header is sent before the body, it can't include the write.
+//
+// Example:
+//
+//	App.EnableServerTiming(true)
+func (App *AppServer) EnableServerTiming(header bool) {
+	App.stages = &stageTimings{
+		header: header,
+		routes: make(map[string]*stageTotals),
+	}
+}
+
+// StageTimings returns the mean time spent in each stage for every route
+// which has been requested since EnableServerTiming was called.
+func (App *AppServer) StageTimings() map[string]StageTimings {
+	means := make(map[string]StageTimings)
+	if App.stages == nil {
+		return means
+	}
+	App.stages.Lock()
+	defer App.stages.Unlock()
+	for route, r := range App.stages.routes {
+		means[route] = r.mean()
+	}
+	return means
+}
+
+func (s *stageTimings) record(route string, stages StageTimings) {
+	s.Lock()
+	defer s.Unlock()
+	r, ok := s.routes[route]
+	if !ok {
+		r = &stageTotals{}
+		s.routes[route] = r
+	}
+	r.count++
+	r.total.Route += stages.Route
+	r.total.Cache += stages.Cache
+	r.total.View += stages.View
+	r.total.Serialize += stages.Serialize
+	r.total.Write += stages.Write
+}
+
+func (r *stageTotals) mean() StageTimings {
+	n := time.Duration(r.count)
+	return StageTimings{
+		Route:     r.total.Route / n,
+		Cache:     r.total.Cache / n,
+		View:      r.total.View / n,
+		Serialize: r.total.Serialize / n,
+		Write:     r.total.Write / n,
+	}
+}
+
+// html renders the mean stage timings as a table, or nothing if there
+// are none.
+func (s *stageTimings) html() string {
+	if s == nil {
+		return ""
+	}
+	s.Lock()
+	defer s.Unlock()
+	if len(s.routes) == 0 {
+		return ""
+	}
+	var names []string
+	for name := range s.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(`<p>Time per stage</p><table border="2"><tr><th>Route</th><th>Requests</th>` +
+		`<th>Route</th><th>Cache</th><th>View</th><th>Serialize</th><th>Write</th><th>Total</th></tr>`)
+	for _, name := range names {
+		r := s.routes[name]
+		m := r.mean()
+		fmt.Fprintf(buf, "<tr><td>%s</td><td>%d</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			template.HTMLEscapeString(name), r.count, m.Route, m.Cache, m.View, m.Serialize, m.Write, m.Total())
+	}
+	buf.WriteString(`</table>`)
+	return buf.String()
+}
+
+// stages breaks the trace down by stage, for a request which finished
+// at end.
+func (t *requestTrace) stages(end time.Time) StageTimings {
+	return StageTimings{
+		Route:     t.match.Sub(t.start),
+		Cache:     t.cache,
+		View:      t.handled.Sub(t.match) - t.cache,
+		Serialize: t.writing.Sub(t.handled),
+		Write:     end.Sub(t.writing),
+	}
+}
+
+// writeStarted records that the response body is about to be written,
+// adding the Server-Timing header if it's turned on.
+func (t *requestTrace) writeStarted(w http.ResponseWriter) {
+	if t == nil {
+		return
+	}
+	t.writing = time.Now()
+	if t.app.stages != nil && t.app.stages.header {
+		w.Header().Set("Server-Timing", t.stages(t.writing).header())
+	}
+}
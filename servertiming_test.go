@@ -0,0 +1,52 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTiming(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.EnableServerTiming(true)
+	App.AddURLs(
+		URL("^/slow$", "Slow", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			time.Sleep(20 * time.Millisecond)
+			return `{"ok": true}`, http.StatusOK
+		}, JSON),
+	)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		header := w.Header().Get("Server-Timing")
+		for _, stage := range []string{"route;dur=", "cache;dur=", "view;dur=", "serialize;dur="} {
+			if !strings.Contains(header, stage) {
+				t.Errorf("Server-Timing %q is missing %q", header, stage)
+			}
+		}
+	}
+
+	stages, ok := App.StageTimings()["Slow"]
+	if !ok {
+		t.Fatalf("no timings for route: %v", App.StageTimings())
+	}
+	if stages.View < 20*time.Millisecond {
+		t.Errorf("mean view time %s, want at least 20ms", stages.View)
+	}
+	if stages.Total() < stages.View {
+		t.Errorf("total %s is less than the view time %s", stages.Total(), stages.View)
+	}
+
+	App.EnableServerTiming(false)
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if header := w.Header().Get("Server-Timing"); header != "" {
+		t.Errorf("Server-Timing sent when turned off: %q", header)
+	}
+	if _, ok := App.StageTimings()["Slow"]; !ok {
+		t.Error("timings not recorded without the header")
+	}
+}
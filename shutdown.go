@@ -0,0 +1,70 @@
+package wedge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ErrShutdown is returned by Submit once the AppServer has been shut down.
+var ErrShutdown = errors.New("wedge: server shut down")
+
+// ShutdownSignals are the signals Run, and contexts from SignalContext,
+// shut down gracefully on.
+var ShutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// SignalContext returns a copy of parent which is done once the process
+// receives one of ShutdownSignals, for passing to RunE. A second signal
+// kills the process as usual. stop releases the signals early.
+//
+// Example:
+//
+//	ctx, stop := wedge.SignalContext(context.Background())
+//	defer stop()
+//	if err := App.RunE(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+func SignalContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, ShutdownSignals...)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+		}
+		// restore the default behaviour, so a second signal kills a
+		// shutdown which is taking too long.
+		signal.Stop(signals)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// flushStats waits for the hits which are still being counted, or for
+// ctx to be done.
+func (App *AppServer) flushStats(ctx context.Context) {
+	flushed := make(chan struct{})
+	go func() {
+		App.pending.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+	}
+}
+
+// Close stops the goroutines the AppServer runs in the background: the
+// workers, once they finish the tasks they're running, the watchdog and
+// the watcher of the redirects file. Shutdown calls it, so it's only
+// needed for AppServers which serve through Handler rather than Start.
+func (App *AppServer) Close() {
+	App.quitOnce.Do(func() {
+		if App.quit != nil {
+			close(App.quit)
+		}
+	})
+}
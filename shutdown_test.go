@@ -0,0 +1,77 @@
+package wedge
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	App := NewAppServer(port, 30)
+	App.SetVerbosity(Quiet)
+	App.EnableStatTracking()
+	App.EnableWorkers(1, 1)
+	started := make(chan bool)
+	App.AddURLs(URL("^/slow/$", "Slow", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return "done", http.StatusOK
+	}, HTML))
+	if err := App.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	body := make(chan string)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/slow/")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	if err := App.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("request in flight got %q", got)
+	}
+	if hits, _ := App.stat_map.Find("/slow/").(int); hits != 1 {
+		t.Errorf("got %d hits after shutdown", hits)
+	}
+	if _, err := App.Submit(context.Background(), func() (interface{}, error) { return nil, nil }); err != ErrShutdown {
+		t.Errorf("Submit after shutdown: got %v", err)
+	}
+	if err := App.Wait(); err != nil {
+		t.Errorf("Wait after shutdown: got %v", err)
+	}
+	// closing twice is harmless.
+	App.Close()
+}
+
+func TestSignalContext(t *testing.T) {
+	ctx, stop := SignalContext(context.Background())
+	defer stop()
+	self, _ := os.FindProcess(os.Getpid())
+	self.Signal(syscall.SIGTERM)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context wasn't done after SIGTERM")
+	}
+}
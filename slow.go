@@ -0,0 +1,199 @@
+package wedge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SensitiveParams are the words which mark a parameter's value as too
+// sensitive to log. Parameters whose names contain any of them are logged
+// as "[redacted]".
+var SensitiveParams = []string{"pass", "secret", "token", "key", "auth", "session", "card", "csrf"}
+
+// slowTracing is the configuration from TraceSlowRequests.
+type slowTracing struct {
+	threshold time.Duration
+	stacks    bool
+}
+
+// TraceSlowRequests logs every request which takes longer than threshold,
+// with its route, its parameters and how long was spent matching the
+// route, in the view and writing the response. Parameters named in
+// SensitiveParams are redacted. If stacks is set, the stack of a request
+// still running when it passes the threshold is logged too, showing where
+// it's stuck. A threshold of zero turns tracing off.
+//
+// Example:
+//
+//	App.TraceSlowRequests(500*time.Millisecond, true)
+func (App *AppServer) TraceSlowRequests(threshold time.Duration, stacks bool) {
+	if threshold <= 0 {
+		App.slow = nil
+		return
+	}
+	App.slow = &slowTracing{threshold, stacks}
+}
+
+// requestTrace times one request. Its methods do nothing on a nil
+// *requestTrace, so requests aren't traced when neither TraceSlowRequests
+// nor EnableServerTiming is on.
+type requestTrace struct {
+	app     *AppServer
+	slow    *slowTracing
+	start   time.Time
+	match   time.Time
+	cache   time.Duration
+	handled time.Time
+	writing time.Time
+	route   string
+	req     *http.Request
+	timer   *time.Timer
+	stack   chan []byte
+}
+
+// traceRequest starts timing req, returning a copy of it which carries
+// the trace.
+func (App *AppServer) traceRequest(req *http.Request) (*http.Request, *requestTrace) {
+	if App.slow == nil && App.stages == nil {
+		return req, nil
+	}
+	t := &requestTrace{app: App, slow: App.slow, start: time.Now()}
+	req = req.WithContext(context.WithValue(req.Context(), traceKey, t))
+	t.req = req
+	if t.slow != nil && t.slow.stacks {
+		id := goroutineID()
+		t.stack = make(chan []byte, 1)
+		t.timer = time.AfterFunc(t.slow.threshold, func() {
+			t.stack <- goroutineStack(id)
+		})
+	}
+	return req, t
+}
+
+// traceOf returns the trace of req, or nil.
+func traceOf(req *http.Request) *requestTrace {
+	t, _ := req.Context().Value(traceKey).(*requestTrace)
+	return t
+}
+
+// matched records that req has been matched to route.
+func (t *requestTrace) matched(route *Rule, req *http.Request) {
+	if t == nil {
+		return
+	}
+	t.match = time.Now()
+	t.route = route.name
+	t.req = req
+}
+
+// viewDone records that the view has returned.
+func (t *requestTrace) viewDone() {
+	if t == nil {
+		return
+	}
+	t.handled = time.Now()
+}
+
+// finish records the request's timings, and logs it if it was slow.
+func (t *requestTrace) finish() {
+	if t == nil {
+		return
+	}
+	end := time.Now()
+	var stack []byte
+	// if the timer has fired, wait for it to finish taking the stack.
+	if t.timer != nil && !t.timer.Stop() {
+		stack = <-t.stack
+	}
+	if t.match.IsZero() {
+		t.match = end
+	}
+	if t.handled.IsZero() {
+		t.handled = end
+	}
+	if t.writing.IsZero() {
+		t.writing = t.handled
+	}
+	stages := t.stages(end)
+	if t.app.stages != nil && t.route != "" {
+		t.app.stages.record(t.route, stages)
+	}
+
+	elapsed := end.Sub(t.start)
+	if t.slow == nil || elapsed < t.slow.threshold {
+		return
+	}
+	log.Printf("Slow request: %s %s (%s) took %s: match %s, cache %s, handler %s, serialize %s, write %s%s",
+		t.req.Method, t.req.URL.Path, t.route, elapsed,
+		stages.Route, stages.Cache, stages.View, stages.Serialize, stages.Write,
+		sanitizedParams(t.req))
+	if stack != nil {
+		log.Printf("Slow request: stack at %s\n%s", t.slow.threshold, stack)
+	}
+}
+
+// sanitizedParams formats the path and query parameters of req for the
+// log, redacting any named in SensitiveParams.
+func sanitizedParams(req *http.Request) string {
+	params := make(map[string]string)
+	for name, value := range Params(req) {
+		params[name] = fmt.Sprint(value)
+	}
+	for name, values := range req.URL.Query() {
+		params[name] = strings.Join(values, ",")
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value := params[name]
+		lower := strings.ToLower(name)
+		for _, word := range SensitiveParams {
+			if strings.Contains(lower, word) {
+				value = "[redacted]"
+				break
+			}
+		}
+		parts[i] = name + "=" + value
+	}
+	return " params: " + strings.Join(parts, " ")
+}
+
+// goroutineID returns the ID of the calling goroutine, from the header of
+// its stack.
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return ""
+	}
+	return string(fields[1])
+}
+
+// goroutineStack returns the stack of the goroutine with id, or nil if
+// it has finished.
+func goroutineStack(id string) []byte {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	header := []byte("goroutine " + id + " ")
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, header) {
+			return stack
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package wedge
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceSlowRequests(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.TraceSlowRequests(20*time.Millisecond, true)
+	App.AddURLs(
+		Path("/slow/<int:id>", "Slow", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			time.Sleep(50 * time.Millisecond)
+			return "slow", http.StatusOK
+		}, HTML),
+		URL("^/fast$", "Fast", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "fast", http.StatusOK
+		}, HTML),
+	)
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	if strings.Contains(buf.String(), "Slow request") {
+		t.Errorf("fast request was logged as slow:\n%s", buf)
+	}
+	App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow/42?q=go&api_token=hunter2", nil))
+	out := buf.String()
+	for _, want := range []string{
+		"Slow request: GET /slow/42 (Slow)",
+		"params: api_token=[redacted] id=42 q=go",
+		"handler ",
+		"Slow request: stack at 20ms",
+		"time.Sleep",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Error("sensitive parameter was logged")
+	}
+}
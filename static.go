@@ -0,0 +1,212 @@
+package wedge
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Common values for Rule.CacheControl.
+const (
+	// Immutable is for assets whose names change whenever their
+	// content does, such as fingerprinted scripts and fonts.
+	Immutable = "public, max-age=31536000, immutable"
+	// NoCache makes clients check with the server before reusing a
+	// response, which costs little as they're answered with a 304 if
+	// the file hasn't changed.
+	NoCache = "no-cache"
+)
+
+// staticCache is the Cache-Control header for the static files matched
+// by match, see Rule.CacheControl.
+type staticCache struct {
+	match string
+	value string
+}
+
+// CacheControl sets the Cache-Control header of files served by
+// StaticFiles which match match: an extension such as ".woff2", a
+// subpath under the route such as "fonts/", or "*" for every file. The
+// first match added wins, and files which match none are sent without
+// the header.
+//
+// Example:
+//
+//	wedge.StaticFiles("/static/", "static").
+//		CacheControl("fonts/", wedge.Immutable).
+//		CacheControl(".html", wedge.NoCache).
+//		CacheControl("*", "public, max-age=3600")
+func (u *Rule) CacheControl(match, value string) *Rule {
+	u.static_cache = append(u.static_cache, staticCache{match, value})
+	return u
+}
+
+// CacheControl is the Option form of Rule.CacheControl.
+func CacheControl(match, value string) Option {
+	return func(u *Rule) {
+		u.CacheControl(match, value)
+	}
+}
+
+// cacheControl returns the Cache-Control header for the static file
+// name, which is relative to the route.
+func (u *Rule) cacheControl(name string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, c := range u.static_cache {
+		switch {
+		case c.match == "*",
+			strings.HasPrefix(c.match, ".") && strings.ToLower(c.match) == ext,
+			!strings.HasPrefix(c.match, ".") && strings.HasPrefix(name, c.match):
+			return c.value, true
+		}
+	}
+	return "", false
+}
+
+// modTime returns when the static file name, which is relative to the
+// route, was last modified, looking in the directories in the same order
+// as StaticFiles does. It's the zero time if there's no such file.
+func (u *Rule) modTime(name string) time.Time {
+	if strings.Contains(name, "..") {
+		return time.Time{}
+	}
+	for _, dir := range u.static_dirs {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Time{}
+}
+
+// serveStatic sends the static file resp, which StaticFiles read from
+// disk or the cache, with its Cache-Control, Last-Modified and ETag
+// headers. Requests whose If-None-Match has its ETag, or whose
+// If-Modified-Since is no older than the file, are answered with a 304,
+// and those for a Range of it with just that part.
+func (App *AppServer) serveStatic(w http.ResponseWriter, req *http.Request, resp string, route *Rule) {
+	name := req.URL.Path[len(route.rawre):]
+	ctype := mime.TypeByExtension(filepath.Ext(name))
+	w.Header().Set("Content-Type", ctype)
+	if value, ok := route.cacheControl(name); ok {
+		w.Header().Set("Cache-Control", value)
+	}
+	modified := route.modTime(name)
+	if w.Header().Get("ETag") == "" && !modified.IsZero() {
+		w.Header().Set("ETag", staticETag(modified, len(resp)))
+	}
+	http.ServeContent(w, req, name, modified, strings.NewReader(resp))
+}
+
+// serveRanges sends resp, or just the parts of it req asks for with a
+// Range header, so downloads can be resumed and media seeked. A Range
+// with an If-Range is only honoured if it matches the response's ETag, so
+// a part of one which has since changed is never sent.
+func serveRanges(w http.ResponseWriter, req *http.Request, resp string) {
+	http.ServeContent(w, req, "", time.Time{}, strings.NewReader(resp))
+}
+
+// StaticPoll is how often the directories of cached StaticFiles routes
+// are checked for changed files, see watchStatic. They aren't checked if
+// it's zero, as they are by default, in which case files changed on disk
+// are served once their cached copies expire. Each check walks every
+// directory, so a few seconds suits a site being deployed to in place.
+//
+// Example:
+//
+//	wedge.StaticPoll = 5 * time.Second
+var StaticPoll time.Duration
+
+// fileStamp is what's compared to tell whether a static file changed.
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// staticFiles lists the files the StaticFiles route u serves, by their
+// paths relative to it, each from the first of its directories which
+// has it. walked holds the directories already walked for other routes,
+// so each is only walked once per check.
+func (u *Rule) staticFiles(walked map[string]map[string]fileStamp) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	for _, dir := range u.static_dirs {
+		if _, ok := walked[dir]; !ok {
+			walked[dir] = walkStatic(dir)
+		}
+		for name, stamp := range walked[dir] {
+			if _, ok := files[name]; !ok {
+				files[name] = stamp
+			}
+		}
+	}
+	return files
+}
+
+// walkStatic lists the files in dir by their slash separated paths
+// relative to it.
+func walkStatic(dir string) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		files[filepath.ToSlash(name)] = fileStamp{info.ModTime(), info.Size()}
+		return nil
+	})
+	return files
+}
+
+// watchStatic evicts the cached copies of static files when they change
+// on disk, so assets deployed by copying over the old ones are served as
+// soon as they're there, rather than after a restart. The standard
+// library has no portable way to be notified of changes, so the
+// directories are polled every StaticPoll until quit is closed. It
+// returns straight away if StaticPoll is zero or no StaticFiles route is
+// cached.
+func (App *AppServer) watchStatic(quit <-chan struct{}) {
+	if StaticPoll <= 0 {
+		return
+	}
+	seen := make(map[*Rule]map[string]fileStamp)
+	walked := make(map[string]map[string]fileStamp)
+	for _, route := range App.routes {
+		if route.viewtype == STATIC && route.cache_duration != 0 && len(route.static_dirs) > 0 {
+			seen[route] = route.staticFiles(walked)
+		}
+	}
+	if len(seen) == 0 {
+		return
+	}
+	go func() {
+		tick := time.NewTicker(StaticPoll)
+		defer tick.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-tick.C:
+			}
+			walked := make(map[string]map[string]fileStamp)
+			for route, before := range seen {
+				after := route.staticFiles(walked)
+				for name, stamp := range after {
+					if old, ok := before[name]; !ok || old != stamp {
+						evictPath(App.cache_map, route.rawre+name)
+					}
+				}
+				for name := range before {
+					if _, ok := after[name]; !ok {
+						evictPath(App.cache_map, route.rawre+name)
+					}
+				}
+				seen[route] = after
+			}
+		}
+	}()
+}
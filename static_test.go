@@ -0,0 +1,163 @@
+package wedge
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticCacheControl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "fonts"), 0755)
+	modified := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"fonts/a.woff2", "index.html", "app.css", "data.txt"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modified, modified)
+	}
+
+	App := NewAppServer("0", 30)
+	App.AddURLs(StaticFiles("/static/", dir).
+		CacheControl("fonts/", Immutable).
+		CacheControl(".HTML", NoCache).
+		CacheControl(".css", "public, max-age=3600"))
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	for path, want := range map[string]string{
+		"/static/fonts/a.woff2": Immutable,
+		"/static/index.html":    NoCache,
+		"/static/app.css":       "public, max-age=3600",
+		"/static/data.txt":      "",
+	} {
+		// the second request is served from the cache.
+		for i := 0; i < 2; i++ {
+			w := get(path)
+			if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != want {
+				t.Errorf("%s: got %d %q, want %q", path, w.Code, w.Header().Get("Cache-Control"), want)
+			}
+			if got := w.Header().Get("Last-Modified"); got != modified.Format(http.TimeFormat) {
+				t.Errorf("%s: got Last-Modified %q", path, got)
+			}
+		}
+	}
+
+	w := get("/static/app.css", "If-Modified-Since", modified.Format(http.TimeFormat))
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("conditional request got %d %q", w.Code, w.Body)
+	}
+	w = get("/static/app.css", "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+	if w.Code != http.StatusOK || w.Body.String() != "app.css" {
+		t.Errorf("request for a changed file got %d %q", w.Code, w.Body)
+	}
+	w = get("/static/index.html", "Range", "bytes=0-4")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "index" {
+		t.Errorf("range request got %d %q", w.Code, w.Body)
+	}
+}
+
+func TestWatchStatic(t *testing.T) {
+	defer func(poll time.Duration) { StaticPoll = poll }(StaticPoll)
+	StaticPoll = 10 * time.Millisecond
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.css")
+	ioutil.WriteFile(path, []byte("body { color: red }"), 0644)
+
+	route := StaticFiles("/static/", dir)
+	App := NewAppServer("0", 30)
+	App.AddURLs(route)
+	get := func() string {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", "/static/app.css", nil))
+		return w.Body.String()
+	}
+	if body := get(); body != "body { color: red }" {
+		t.Fatalf("got %q", body)
+	}
+
+	App.watchStatic(App.quit)
+	defer App.Close()
+	ioutil.WriteFile(path, []byte("body { color: blue }"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	deadline := time.Now().Add(5 * time.Second)
+	for get() != "body { color: blue }" {
+		if time.Now().After(deadline) {
+			t.Fatal("the changed file was never served")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	body := "0123456789abcdef"
+	App := NewAppServer("0", 30)
+	release := CacheURL("^/release.tar.gz$", "Release", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return body, http.StatusOK
+	}, DOWNLOAD, -1)
+	App.AddURLs(
+		release,
+		Download("^/report.pdf$", "Report", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return body, http.StatusOK
+		}).Checksums(),
+		URL("^/favicon.ico$", "Icon", func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return body, http.StatusOK
+		}, ICON),
+	)
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/release.tar.gz", "/report.pdf", "/favicon.ico"} {
+		w := get(path)
+		if w.Code != http.StatusOK || w.Body.String() != body || w.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("%s: got %d %q %v", path, w.Code, w.Body, w.Header())
+		}
+		w = get(path, "Range", "bytes=10-")
+		if w.Code != http.StatusPartialContent || w.Body.String() != "abcdef" || w.Header().Get("Content-Range") != "bytes 10-15/16" {
+			t.Errorf("%s: got %d %q %v for a range", path, w.Code, w.Body, w.Header())
+		}
+	}
+	if w := get("/favicon.ico", "Range", "bytes=0-1"); w.Header().Get("Content-Type") != "image/x-icon" {
+		t.Errorf("got Content-Type %q", w.Header().Get("Content-Type"))
+	}
+	if w := get("/report.pdf", "Range", "bytes=0-1"); w.Header().Get("Content-MD5") != "" || w.Header().Get("Digest") == "" {
+		t.Errorf("got digests %v for a range", w.Header())
+	}
+
+	// a resumed download is only ranged if it hasn't changed.
+	etag := get("/release.tar.gz").Header().Get("ETag")
+	if w := get("/release.tar.gz", "Range", "bytes=10-", "If-Range", etag); w.Code != http.StatusPartialContent {
+		t.Errorf("got %d for a matching If-Range", w.Code)
+	}
+	if w := get("/release.tar.gz", "Range", "bytes=10-", "If-Range", `"old"`); w.Code != http.StatusOK || w.Body.String() != body {
+		t.Errorf("got %d %q for a changed download", w.Code, w.Body)
+	}
+}
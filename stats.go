@@ -0,0 +1,131 @@
+package wedge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StatWindow is a time series kept by EnableStatTracking. Hits are counted
+// in buckets of Bucket length and only the latest Retain buckets are kept,
+// so the memory used stays bounded however long the server runs.
+type StatWindow struct {
+	Name   string
+	Bucket time.Duration
+	Retain int
+}
+
+// StatWindows are the series kept by EnableStatTracking. Change them
+// before calling it to keep more or less history.
+var StatWindows = []StatWindow{
+	{"minute", time.Minute, 60},
+	{"hour", time.Hour, 48},
+	{"day", 24 * time.Hour, 30},
+}
+
+// StatBucket is the hits to each URL within one bucket of a StatWindow.
+type StatBucket struct {
+	Start time.Time      `json:"start"`
+	Total int            `json:"total"`
+	Hits  map[string]int `json:"hits"`
+}
+
+// statSeries holds the buckets for each of the StatWindows.
+type statSeries struct {
+	sync.Mutex
+	windows []StatWindow
+	buckets map[string][]*StatBucket
+}
+
+func newStatSeries(windows []StatWindow) *statSeries {
+	return &statSeries{
+		windows: append([]StatWindow(nil), windows...),
+		buckets: make(map[string][]*StatBucket),
+	}
+}
+
+// record counts a hit to k at now in every window.
+func (s *statSeries) record(k string, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	for _, window := range s.windows {
+		start := now.Truncate(window.Bucket)
+		buckets := s.buckets[window.Name]
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, &StatBucket{Start: start, Hits: make(map[string]int)})
+			if len(buckets) > window.Retain {
+				buckets = buckets[len(buckets)-window.Retain:]
+			}
+			s.buckets[window.Name] = buckets
+		}
+		bucket := buckets[len(buckets)-1]
+		bucket.Hits[k]++
+		bucket.Total++
+	}
+}
+
+// series returns a copy of the buckets of the window called name.
+func (s *statSeries) series(name string) ([]StatBucket, bool) {
+	s.Lock()
+	defer s.Unlock()
+	found := false
+	for _, window := range s.windows {
+		found = found || window.Name == name
+	}
+	if !found {
+		return nil, false
+	}
+	result := make([]StatBucket, 0, len(s.buckets[name]))
+	for _, bucket := range s.buckets[name] {
+		hits := make(map[string]int, len(bucket.Hits))
+		for k, v := range bucket.Hits {
+			hits[k] = v
+		}
+		result = append(result, StatBucket{bucket.Start, bucket.Total, hits})
+	}
+	return result, true
+}
+
+// html renders the totals of each bucket, one table per window.
+func (s *statSeries) html() string {
+	buf := new(bytes.Buffer)
+	for _, window := range s.windows {
+		buckets, _ := s.series(window.Name)
+		fmt.Fprintf(buf, `<p>Hits per %s</p><table border="2"><tr><th>From</th><th>Hits</th></tr>`,
+			template.HTMLEscapeString(window.Name))
+		for i := len(buckets) - 1; i >= 0; i-- {
+			fmt.Fprintf(buf, "<tr><td>%s</td><td>%d</td></tr>",
+				buckets[i].Start.Format(time.RFC3339), buckets[i].Total)
+		}
+		buf.WriteString(`</table>`)
+	}
+	return buf.String()
+}
+
+// seriesURL serves a window of the time series as JSON for graphing, the
+// window being picked by name with the "window" query parameter.
+//
+//	GET /statistics/series/?window=hour
+func (App *AppServer) seriesURL() *Rule {
+	return makeurl("^/statistics/series/?$", "Statistics Series",
+		func(w http.ResponseWriter, req *http.Request) (string, int) {
+			name := req.URL.Query().Get("window")
+			if name == "" && len(App.series.windows) > 0 {
+				name = App.series.windows[0].Name
+			}
+			buckets, ok := App.series.series(name)
+			if !ok {
+				return "", http.StatusNotFound
+			}
+			b, err := json.Marshal(buckets)
+			if err != nil {
+				return "", http.StatusInternalServerError
+			}
+			w.Header().Set("Content-Type", "application/json")
+			return string(b), http.StatusOK
+		}, HTML, 0)
+}
@@ -0,0 +1,76 @@
+package wedge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatSeries(t *testing.T) {
+	s := newStatSeries([]StatWindow{{"minute", time.Minute, 2}})
+	start := time.Date(2013, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.record("/a", start)
+	s.record("/a", start.Add(10*time.Second))
+	s.record("/b", start.Add(time.Minute))
+	s.record("/b", start.Add(2*time.Minute))
+	s.record("/a", start.Add(2*time.Minute+time.Second))
+
+	buckets, ok := s.series("minute")
+	if !ok {
+		t.Fatal("minute window not found")
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("got %d buckets, want 2", len(buckets))
+	}
+	if !buckets[0].Start.Equal(start.Add(time.Minute)) || buckets[0].Total != 1 {
+		t.Errorf("oldest bucket: got %v with %d hits", buckets[0].Start, buckets[0].Total)
+	}
+	last := buckets[1]
+	if last.Total != 2 || last.Hits["/a"] != 1 || last.Hits["/b"] != 1 {
+		t.Errorf("latest bucket: got %d hits, %v", last.Total, last.Hits)
+	}
+	if _, ok := s.series("week"); ok {
+		t.Error("unknown window was found")
+	}
+}
+
+func TestTopN(t *testing.T) {
+	now := time.Date(2013, 6, 1, 12, 0, 0, 0, time.UTC)
+	top := newTopN(1, time.Hour)
+	for i := 0; i < 3; i++ {
+		top.add("old", now)
+	}
+	later := now.Add(3 * time.Hour)
+	top.add("new", later)
+	top.add("new", later)
+
+	counts := top.top(later)
+	if len(counts) != 1 || counts[0].Key != "new" {
+		t.Fatalf("got %v, want new on top", counts)
+	}
+	if counts[0].Count != 2 {
+		t.Errorf("new: got %v, want 2", counts[0].Count)
+	}
+
+	// only four times the size are kept
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		top.add(key, later)
+	}
+	if len(top.entries) != 4 {
+		t.Errorf("got %d entries, want 4", len(top.entries))
+	}
+}
+
+func TestAgentFamily(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (X11; Linux x86_64; rv:21.0) Gecko/20100101 Firefox/21.0":                                   "Firefox",
+		"Mozilla/5.0 (Windows NT 6.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/27.0.1453.110 Safari/537.36": "Chrome",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                               "Bot",
+		"curl/7.29.0": "curl",
+		"":            "(none)",
+	}
+	for agent, want := range tests {
+		if got := AgentFamily(agent); got != want {
+			t.Errorf("%q: got %q, want %q", agent, got, want)
+		}
+	}
+}
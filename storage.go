@@ -0,0 +1,382 @@
+package wedge
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage keeps files, such as uploads and resized images, under keys
+// which are slash separated paths like "posts/3f2a.png", so the code
+// using it works the same whether they're on disk or with a cloud
+// provider. It's used by Uploads, Images and the admin package.
+type Storage interface {
+	// Put stores what's read from r under key, replacing any file which
+	// is already there.
+	Put(key string, r io.Reader) error
+	// Get opens the file under key. The error for a missing file is one
+	// os.IsNotExist reports.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the file under key. Deleting a missing file isn't
+	// an error.
+	Delete(key string) error
+	// URL returns where clients can fetch the file under key.
+	URL(key string) string
+}
+
+// ErrBadKey is returned for keys which are empty, absolute or climb out
+// of the storage with "..".
+var ErrBadKey = errors.New("wedge: bad storage key")
+
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrBadKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrBadKey
+		}
+	}
+	return nil
+}
+
+// DiskStorage keeps files below a directory on the local disk.
+//
+// Example:
+//
+//	media := &wedge.DiskStorage{Dir: "media", BaseURL: "/media/"}
+//	App.AddURLs(wedge.StaticFiles("/media/", "media"))
+type DiskStorage struct {
+	// Dir is where the files are kept. Directories within it are made
+	// as they're needed.
+	Dir string
+	// BaseURL is where Dir is served, by StaticFiles or another server.
+	BaseURL string
+}
+
+func (d *DiskStorage) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(d.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file beside where it goes and then renames it into
+// place, so a concurrent Get never sees half of it.
+func (d *DiskStorage) Put(key string, r io.Reader) error {
+	name, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".put-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (d *DiskStorage) Get(key string) (io.ReadCloser, error) {
+	name, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+func (d *DiskStorage) Delete(key string) error {
+	name, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *DiskStorage) URL(key string) string {
+	return strings.TrimRight(d.BaseURL, "/") + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// S3Storage keeps files in a bucket of Amazon S3 or a compatible service
+// such as MinIO, Cloudflare R2 or DigitalOcean Spaces, addressing it by
+// path, as Endpoint/Bucket/key. Requests are signed with AWS Signature
+// Version 4.
+//
+// Example:
+//
+//	media := &wedge.S3Storage{
+//		Endpoint:  "https://s3.eu-west-1.amazonaws.com",
+//		Region:    "eu-west-1",
+//		Bucket:    "example-media",
+//		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+//		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+//	}
+type S3Storage struct {
+	Endpoint string
+	// Region is the bucket's region, "us-east-1" if it's empty. R2 uses
+	// "auto".
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// BaseURL is where the bucket's files are public, such as a CDN in
+	// front of it. Without it URL returns links signed to be valid for
+	// URLExpiry, 1 hour if it's zero.
+	BaseURL   string
+	URLExpiry time.Duration
+	// Client makes the requests. If it's nil they're made with
+	// http.DefaultTransport and time out after S3Timeout.
+	Client *http.Client
+}
+
+// S3Timeout bounds each request made by an S3Storage without its own
+// Client, including sending or reading the file, so a bucket which stops
+// answering can't hold a view forever.
+var S3Timeout = 5 * time.Minute
+
+// emptySHA256 is the hash of an empty request body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (s *S3Storage) region() string {
+	if s.Region == "" {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+func (s *S3Storage) client() *http.Client {
+	if s.Client == nil {
+		return &http.Client{Timeout: S3Timeout}
+	}
+	return s.Client
+}
+
+func (s *S3Storage) objectURL(key string) string {
+	return strings.TrimRight(s.Endpoint, "/") + "/" + s.Bucket + "/" + uriEncode(key, false)
+}
+
+// do makes a signed request for key, returning an error for any status
+// but those in ok.
+func (s *S3Storage) do(method, key string, body io.Reader, size int64, ok ...int) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	payload := emptySHA256
+	if body != nil {
+		req.ContentLength = size
+		payload = "UNSIGNED-PAYLOAD"
+		if ctype := mime.TypeByExtension(path.Ext(key)); ctype != "" {
+			req.Header.Set("Content-Type", ctype)
+		}
+	}
+	s.sign(req, payload, time.Now())
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: strings.ToLower(method), Path: key, Err: os.ErrNotExist}
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("wedge: S3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(msg))
+}
+
+// Put sends the file in a single request, which needs its length. It's
+// found by seeking if r can, such as when it's an *os.File, otherwise r
+// is read into memory first.
+func (s *S3Storage) Put(key string, r io.Reader) error {
+	size := int64(-1)
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		size = int64(v.Len())
+	case io.Seeker:
+		if start, err := v.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := v.Seek(0, io.SeekEnd); err == nil {
+				if _, err := v.Seek(start, io.SeekStart); err == nil {
+					size = end - start
+				}
+			}
+		}
+	}
+	if size < 0 {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(b), int64(len(b))
+	}
+	body := io.Reader(ioutil.NopCloser(r))
+	if size == 0 {
+		body = http.NoBody
+	}
+	resp, err := s.do("PUT", key, body, size, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", key, nil, 0, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do("DELETE", key, nil, 0, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) URL(key string) string {
+	if s.BaseURL != "" {
+		return strings.TrimRight(s.BaseURL, "/") + "/" + uriEncode(key, false)
+	}
+	expiry := s.URLExpiry
+	if expiry <= 0 {
+		expiry = time.Hour
+	}
+	req, _ := http.NewRequest("GET", s.objectURL(key), nil)
+	return s.presign(req, expiry, time.Now())
+}
+
+// scope returns the credential scope of a request signed at t.
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region() + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at t.
+func (s *S3Storage) signature(canonical string, t time.Time) string {
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + s.scope(t) + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{t.Format("20060102"), s.region(), "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return hex.EncodeToString(key)
+}
+
+// sign adds the Authorization header to req, whose body has the hash
+// payload, signing every header it has.
+func (s *S3Storage) sign(req *http.Request, payload string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n" + uriEncode(req.URL.Path, false) + "\n" + canonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + payload)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+s.scope(t)+
+		", SignedHeaders="+signed+", Signature="+s.signature(canonical.String(), t))
+}
+
+// presign returns req's URL with a signature in its query which is valid
+// for expiry from t.
+func (s *S3Storage) presign(req *http.Request, expiry time.Duration, t time.Time) string {
+	t = t.UTC()
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonical := req.Method + "\n" + uriEncode(req.URL.Path, false) + "\n" + canonicalQuery(query) + "\n" +
+		"host:" + req.URL.Host + "\n\nhost\nUNSIGNED-PAYLOAD"
+	query.Set("X-Amz-Signature", s.signature(canonical, t))
+	u := *req.URL
+	u.RawQuery = canonicalQuery(query)
+	return u.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes s as Signature Version 4 expects, leaving
+// slashes alone unless slash is set.
+func uriEncode(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
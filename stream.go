@@ -0,0 +1,205 @@
+package wedge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+)
+
+// StreamView is a view whose response is read from an io.Reader rather
+// than held in a string, so large files and generated content are sent
+// as they're read without being held in memory. A []byte can be sent as
+// a bytes.NewReader, and readers which implement io.WriterTo write
+// themselves to the response. Readers which are also io.Closers are
+// closed once they've been sent.
+type StreamView func(http.ResponseWriter, *http.Request) (io.Reader, int)
+
+// streamed holds the reader a StreamView returned for a request, until
+// the response is written.
+type streamed struct {
+	r io.Reader
+}
+
+// StreamRoute is the form of Route for a StreamView. The response is
+// copied to the client in the same way as a view's would be written for
+// the route's handler type, so a JSON stream is sent as it is, and a
+// DOWNLOAD stream gets the same default headers as a Download.
+//
+// Streams aren't held in memory, so the server never caches them and
+// concurrent requests aren't coalesced, whatever the options given.
+//
+// Example:
+//
+//	wedge.StreamRoute(`^/export/orders.csv$`, func(w http.ResponseWriter, req *http.Request) (io.Reader, int) {
+//		pr, pw := io.Pipe()
+//		go func() { pw.CloseWithError(writeOrders(pw)) }()
+//		return pr, http.StatusOK
+//	}, wedge.Name("Export"), wedge.ContentType(wedge.DOWNLOAD))
+func StreamRoute(re string, v StreamView, opts ...Option) *Rule {
+	u := Route(re, func(w http.ResponseWriter, req *http.Request) (string, int) {
+		r, status := v(w, req)
+		if slot, ok := req.Context().Value(streamKey).(*streamed); ok {
+			slot.r = r
+		} else if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+		return "", status
+	}, opts...)
+	u.stream = true
+	u.cache_duration, u.cache_set = 0, true
+	u.adaptive, u.coalesce = nil, nil
+	return u
+}
+
+// StreamDownload is the form of Download for a StreamView.
+func StreamDownload(re, name string, v StreamView) *Rule {
+	return StreamRoute(re, v, Name(name), ContentType(DOWNLOAD))
+}
+
+// withStream returns a shallow copy of req which can hold the reader its
+// StreamView returns.
+func withStream(req *http.Request) (*http.Request, *streamed) {
+	slot := &streamed{}
+	return req.WithContext(context.WithValue(req.Context(), streamKey, slot)), slot
+}
+
+// close closes the reader if the response wasn't sent from it.
+func (s *streamed) close() {
+	if c, ok := s.r.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// handle200stream is handle200req for a StreamView's response r.
+func (App *AppServer) handle200stream(w http.ResponseWriter, req *http.Request, r io.Reader, route *Rule) {
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	var dst io.Writer = w
+	switch route.viewtype {
+	case JSON:
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	case ICON:
+		w.Header().Set("Content-Type", "image/x-icon")
+	case DOWNLOAD:
+		downloadHeaders(w)
+	case NDJSON:
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		f := newFlushWriter(w)
+		defer f.stop()
+		dst = f
+	}
+	traceOf(req).writeStarted(w)
+	if _, err := io.Copy(dst, r); err != nil {
+		if clientGone(req) {
+			App.handleAborted(req)
+			return
+		}
+		log.Println("Error streaming response:", route.name, err)
+	}
+}
+
+// JSONArray returns a reader of the items sent on items, written as a
+// JSON array, for a StreamView to return so a large result set is sent as
+// it's read rather than marshalled in memory first. The array is closed
+// once items is. An item which is an error ends the response there
+// instead, leaving the array unclosed so clients can tell it's
+// incomplete, and is logged.
+//
+// Producers should stop once req.Context() is done, as it is when the
+// response is over, whether or not items were all read.
+//
+// Example:
+//
+//	wedge.StreamRoute("^/api/orders/$", func(w http.ResponseWriter, req *http.Request) (io.Reader, int) {
+//		orders := make(chan interface{})
+//		go func() {
+//			defer close(orders)
+//			for rows.Next() {
+//				select {
+//				case orders <- scanOrder(rows):
+//				case <-req.Context().Done():
+//					return
+//				}
+//			}
+//		}()
+//		return wedge.JSONArray(orders), http.StatusOK
+//	}, wedge.ContentType(wedge.JSON))
+func JSONArray(items <-chan interface{}) io.Reader {
+	return &jsonItems{items: items}
+}
+
+// JSONLines is the form of JSONArray which writes each item on a line of
+// its own, as newline-delimited JSON, rather than in an array.
+func JSONLines(items <-chan interface{}) io.Reader {
+	return &jsonItems{items: items, lines: true}
+}
+
+// jsonItems encodes items as they're read.
+type jsonItems struct {
+	items <-chan interface{}
+	lines bool
+	buf   bytes.Buffer
+	n     int
+	err   error
+	// done ends the items early, when the client has gone away.
+	done <-chan struct{}
+}
+
+func (j *jsonItems) Read(p []byte) (int, error) {
+	for j.buf.Len() == 0 && j.err == nil {
+		j.err = j.next()
+	}
+	if j.buf.Len() > 0 {
+		return j.buf.Read(p)
+	}
+	return 0, j.err
+}
+
+// next encodes the next item into the buffer, returning io.EOF after the
+// last.
+func (j *jsonItems) next() error {
+	var item interface{}
+	var ok bool
+	select {
+	case item, ok = <-j.items:
+	case <-j.done:
+		return context.Canceled
+	}
+	if !ok {
+		if !j.lines {
+			if j.n == 0 {
+				j.buf.WriteByte('[')
+			}
+			j.buf.WriteString("]\n")
+		}
+		return io.EOF
+	}
+	if err, ok := item.(error); ok {
+		return err
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	switch {
+	case j.lines:
+	case j.n == 0:
+		j.buf.WriteByte('[')
+	default:
+		j.buf.WriteByte(',')
+	}
+	j.n++
+	j.buf.Write(b)
+	if j.lines {
+		j.buf.WriteByte('\n')
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package wedge
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// closer records whether the reader it wraps was closed.
+type closer struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestStreamRoute(t *testing.T) {
+	var last *closer
+	App := NewAppServer("0", 30)
+	App.AddURLs(
+		StreamDownload(`^/export/(?P<rows>[0-9]+)\.csv$`, "Export", func(w http.ResponseWriter, req *http.Request) (io.Reader, int) {
+			if Params(req)["rows"] == "0" {
+				last = &closer{Reader: strings.NewReader("")}
+				return last, http.StatusNotFound
+			}
+			pr, pw := io.Pipe()
+			go func() {
+				for i := 0; i < 1000; i++ {
+					io.WriteString(pw, "a,b,c\n")
+				}
+				pw.Close()
+			}()
+			last = &closer{Reader: pr}
+			return last, http.StatusOK
+		}),
+		StreamRoute("^/data.json$", func(w http.ResponseWriter, req *http.Request) (io.Reader, int) {
+			return bytes.NewReader([]byte(`{"ok":true}`)), http.StatusOK
+		}, ContentType(JSON), Cache(-1)),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/export/1000.csv")
+	if w.Code != http.StatusOK || w.Body.Len() != 6000 || !last.closed {
+		t.Errorf("got %d with %d bytes, closed: %v", w.Code, w.Body.Len(), last.closed)
+	}
+	if w.Header().Get("Content-Type") != "application/octet-stream" || w.Header().Get("Content-Disposition") != "attachment" {
+		t.Errorf("got headers %v", w.Header())
+	}
+	if w = get("/export/0.csv"); w.Code != http.StatusNotFound || !last.closed {
+		t.Errorf("got %d, closed: %v", w.Code, last.closed)
+	}
+
+	// JSON streams are sent as they are, and never cached.
+	for i := 0; i < 2; i++ {
+		w = get("/data.json")
+		body, _ := ioutil.ReadAll(w.Body)
+		if string(body) != `{"ok":true}` || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("got %q with %v", body, w.Header())
+		}
+	}
+}
+
+func TestJSONArray(t *testing.T) {
+	items := func(values ...interface{}) <-chan interface{} {
+		ch := make(chan interface{}, len(values))
+		for _, v := range values {
+			ch <- v
+		}
+		close(ch)
+		return ch
+	}
+	for _, test := range []struct {
+		r    io.Reader
+		want string
+		err  bool
+	}{
+		{JSONArray(items()), "[]\n", false},
+		{JSONArray(items(1, "two", map[string]int{"three": 3})), `[1,"two",{"three":3}]` + "\n", false},
+		{JSONLines(items()), "", false},
+		{JSONLines(items(1, "two")), "1\n\"two\"\n", false},
+		{JSONArray(items(1, io.ErrUnexpectedEOF, 2)), "[1", true},
+		{JSONArray(items(1, func() {})), "[1", true},
+	} {
+		got, err := ioutil.ReadAll(test.r)
+		if string(got) != test.want || (err != nil) != test.err {
+			t.Errorf("got %q, %v, want %q", got, err, test.want)
+		}
+	}
+
+	App := NewAppServer("0", 30)
+	App.AddURLs(StreamRoute("^/items/$", func(w http.ResponseWriter, req *http.Request) (io.Reader, int) {
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			for i := 0; i < 1000; i++ {
+				ch <- i
+			}
+		}()
+		return JSONArray(ch), http.StatusOK
+	}, ContentType(JSON)))
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/items/", nil))
+	var got []int
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || len(got) != 1000 || got[999] != 999 {
+		t.Errorf("got %d items, %v", len(got), err)
+	}
+}
@@ -0,0 +1,194 @@
+package wedge
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// MaxSuggestions is the most suggestions offered for a single 404.
+	MaxSuggestions = 3
+	// maxStaticCandidates bounds how many static files are considered,
+	// so that a huge static directory doesn't make every 404 slow.
+	maxStaticCandidates = 2000
+	// maxSuggestDistance is the largest edit distance a suggestion may
+	// be from the path, however long the path.
+	maxSuggestDistance = 8
+	// maxSuggestPath is the longest path which is compared by edit
+	// distance. Longer ones, which are unlikely to be typos, are only
+	// matched against their prefixes, so a long URL can't make a 404
+	// expensive.
+	maxSuggestPath = 256
+)
+
+// suggester offers "did you mean" suggestions for paths which 404.
+type suggester struct {
+	once       sync.Once
+	extra      []string
+	candidates []string
+}
+
+// EnableSuggestions makes the 404 handler offer paths similar to the one
+// which wasn't found, which a custom handler can retrieve with
+// Suggestions.
+//
+// Candidates are the routes whose pattern is a plain path, such as
+// "^/about/$", the files under any StaticFiles directories and any extra
+// paths given here. They are collected on the first 404, so routes added
+// after that aren't considered.
+func (App *AppServer) EnableSuggestions(extra ...string) {
+	App.suggester = &suggester{extra: extra}
+}
+
+// collect gathers the candidate paths from the routes.
+func (s *suggester) collect(routes []*Rule) {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			s.candidates = append(s.candidates, path)
+		}
+	}
+	for _, path := range s.extra {
+		add(path)
+	}
+	for _, route := range routes {
+		if path, ok := literalPath(route.rawre); ok {
+			add(path)
+		}
+		for _, dir := range route.static_dirs {
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if len(s.candidates) >= maxStaticCandidates {
+					return filepath.SkipDir
+				}
+				if err == nil && !info.IsDir() {
+					rel, err := filepath.Rel(dir, path)
+					if err == nil {
+						add(route.rawre + filepath.ToSlash(rel))
+					}
+				}
+				return nil
+			})
+		}
+	}
+}
+
+// literalPath returns the path matched by re if re is an anchored plain
+// path, allowing for an optional trailing slash.
+func literalPath(re string) (string, bool) {
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	parsed = parsed.Simplify()
+	if parsed.Op != syntax.OpConcat || len(parsed.Sub) < 3 {
+		return "", false
+	}
+	subs := parsed.Sub
+	if subs[0].Op != syntax.OpBeginText || subs[len(subs)-1].Op != syntax.OpEndText {
+		return "", false
+	}
+	path := ""
+	for _, sub := range subs[1 : len(subs)-1] {
+		switch {
+		case sub.Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == 0:
+			path += string(sub.Rune)
+		case sub.Op == syntax.OpQuest && sub.Sub[0].Op == syntax.OpLiteral &&
+			string(sub.Sub[0].Rune) == "/":
+			path += "/"
+		default:
+			return "", false
+		}
+	}
+	return path, strings.HasPrefix(path, "/")
+}
+
+// suggest returns the candidates closest to path, nearest first. Paths
+// are close if they are within a small edit distance, or if the
+// candidate is a prefix of path at a segment boundary.
+func (s *suggester) suggest(path string) []string {
+	type scored struct {
+		path     string
+		distance int
+	}
+	var matches []scored
+	limit := len(path) / 4
+	if limit < 2 {
+		limit = 2
+	}
+	if limit > maxSuggestDistance {
+		limit = maxSuggestDistance
+	}
+	for _, candidate := range s.candidates {
+		if candidate == path {
+			continue
+		}
+		// the distance is at least the difference in length, so only
+		// candidates close in length need comparing.
+		diff := len(candidate) - len(path)
+		if len(path) <= maxSuggestPath && diff >= -limit && diff <= limit {
+			if distance := editDistance(path, candidate); distance <= limit {
+				matches = append(matches, scored{candidate, distance})
+				continue
+			}
+		}
+		trimmed := strings.TrimSuffix(candidate, "/")
+		if trimmed != "" && strings.HasPrefix(path, trimmed+"/") {
+			matches = append(matches, scored{candidate, len(path) - len(candidate)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	var result []string
+	for i := 0; i < len(matches) && i < MaxSuggestions; i++ {
+		result = append(result, matches[i].path)
+	}
+	return result
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// withSuggestions returns a shallow copy of req carrying the suggestions
+// for its path.
+func (App *AppServer) withSuggestions(req *http.Request) *http.Request {
+	s := App.suggester
+	s.once.Do(func() {
+		s.collect(App.routes)
+	})
+	suggestions := s.suggest(req.URL.Path)
+	return req.WithContext(context.WithValue(req.Context(), suggestionsKey, suggestions))
+}
+
+// Suggestions returns the paths similar to the one requested, for use in
+// a custom 404 handler. It is empty unless EnableSuggestions was called.
+func Suggestions(req *http.Request) []string {
+	suggestions, _ := req.Context().Value(suggestionsKey).([]string)
+	return suggestions
+}
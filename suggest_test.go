@@ -0,0 +1,92 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	s := &suggester{candidates: []string{
+		"/about/", "/about/team/", "/blog/", "/blog/archive/", "/contact/", "/docs/install/",
+	}}
+	for _, test := range []struct{ path, want string }{
+		// nearest first.
+		{"/abut/", "/about/"},
+		{"/about/tem/", "/about/team/ /about/"},
+		{"/blog/archve/", "/blog/archive/ /blog/"},
+		{"/contacts/", "/contact/"},
+		// a candidate which is a prefix of the path at a segment.
+		{"/docs/install/linux/debian/", "/docs/install/"},
+		{"/blog/2019/03/a-very-long-post-title/", "/blog/"},
+		// too far from anything.
+		{"/pricing/", ""},
+		{"/x", ""},
+		{"/", ""},
+		// the path itself isn't suggested.
+		{"/contact/", ""},
+	} {
+		if got := strings.Join(s.suggest(test.path), " "); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.path, got, test.want)
+		}
+	}
+
+	// long paths are only matched by prefix, and at most MaxSuggestions
+	// are offered.
+	long := "/blog/" + strings.Repeat("x", 2*maxSuggestPath)
+	if got := strings.Join(s.suggest(long), " "); got != "/blog/" {
+		t.Errorf("got %q for a long path", got)
+	}
+	if got := s.suggest(strings.Repeat("a", maxSuggestPath+1)); len(got) != 0 {
+		t.Errorf("got %v for a long path", got)
+	}
+	s.candidates = []string{"/a1/", "/a2/", "/a3/", "/a4/"}
+	if got := s.suggest("/a/"); len(got) != MaxSuggestions {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"/about/", "/abut/", 1},
+		{"/flaw/", "/lawn/", 2},
+	} {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("%q, %q: got %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	App := NewAppServer("0", 0)
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "", http.StatusOK
+	}
+	App.AddURLs(
+		URL("^/about/?$", "About", view, HTML),
+		URL("^/posts/[0-9]+/$", "Post", view, HTML),
+	)
+	App.EnableSuggestions("/contact/")
+	var got []string
+	App.Handler404(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		got = Suggestions(req)
+		return "", http.StatusNotFound
+	})
+	for path, want := range map[string]string{
+		"/abuot/":  "/about/",
+		"/contct/": "/contact/",
+		"/posts/":  "",
+	} {
+		App.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: got %v, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package wedge
+
+import "time"
+
+// Tag labels the route with tags such as "public", "admin" or "api", so
+// that middleware, cache policies and statistics can be attached to every
+// route with a tag, following what routes are for rather than where they
+// are. They're unrelated to CacheTags, which group cached responses to be
+// invalidated together.
+//
+// Example:
+//
+//	App.UseTag("admin", RequireStaff)
+//	App.SetTagCachePolicy("public", 10*time.Minute)
+//	App.AddURLs(
+//		wedge.Route("^/$", Home, wedge.Tag("public")),
+//		wedge.Route("^/admin/users/$", Users, wedge.Tag("admin")),
+//	)
+func (u *Rule) Tag(tags ...string) *Rule {
+	for _, tag := range tags {
+		if !u.HasTag(tag) {
+			u.tags = append(u.tags, tag)
+		}
+	}
+	return u
+}
+
+// Tag is the Option form of Rule.Tag.
+func Tag(tags ...string) Option {
+	return func(u *Rule) {
+		u.Tag(tags...)
+	}
+}
+
+// Tags returns the tags the route was given with Tag.
+func (u *Rule) Tags() []string {
+	return append([]string{}, u.tags...)
+}
+
+// HasTag reports whether the route was given tag with Tag.
+func (u *Rule) HasTag(tag string) bool {
+	for _, t := range u.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutesTagged returns the routes with tag, in the order they are matched
+// against.
+func (App *AppServer) RoutesTagged(tag string) []*Rule {
+	var routes []*Rule
+	for _, route := range App.routes {
+		if route.HasTag(tag) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// UseTag wraps the view of every route with tag in middleware, the first
+// given being the outermost, such as to require a login for every "admin"
+// route. It applies to routes whenever they're added, and runs inside the
+// middleware from Use but outside that of the route's Group and its own.
+// A route with several tags runs their middleware in the order of its
+// tags.
+func (App *AppServer) UseTag(tag string, middleware ...Middleware) {
+	if App.tagUse == nil {
+		App.tagUse = make(map[string][]Middleware)
+	}
+	App.tagUse[tag] = append(App.tagUse[tag], middleware...)
+}
+
+// tagMiddlewareFor returns the middleware for the tags of route.
+func (App *AppServer) tagMiddlewareFor(route *Rule) []Middleware {
+	var middleware []Middleware
+	for _, tag := range route.tags {
+		middleware = append(middleware, App.tagUse[tag]...)
+	}
+	return middleware
+}
+
+// SetTagCachePolicy caches responses from routes with tag for d, unless
+// they set a cache duration of their own. It takes precedence over the
+// policy for their handler type from SetCachePolicy, and for routes with
+// several tags the policy of the first of them with one applies.
+func (App *AppServer) SetTagCachePolicy(tag string, d time.Duration) {
+	if App.tagCache == nil {
+		App.tagCache = make(map[string]time.Duration)
+	}
+	App.tagCache[tag] = d
+	for _, route := range App.routes {
+		App.applyCachePolicy(route)
+	}
+}
+
+// tagPolicy returns the cache policy for the tags of route.
+func (App *AppServer) tagPolicy(route *Rule) (time.Duration, bool) {
+	for _, tag := range route.tags {
+		if d, ok := App.tagCache[tag]; ok {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// countTags counts a request to route under each of its tags in the
+// statistics, as "Tag => api".
+func (App *AppServer) countTags(route *Rule) {
+	for _, tag := range route.tags {
+		App.incrementStats("Tag => " + tag)
+	}
+}
@@ -0,0 +1,97 @@
+package wedge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteTags(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.EnableStatTracking()
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "view", http.StatusOK
+	}
+	mark := func(name string) Middleware {
+		return func(next View) View {
+			return func(w http.ResponseWriter, req *http.Request) (string, int) {
+				resp, status := next(w, req)
+				return name + "(" + resp + ")", status
+			}
+		}
+	}
+	requireStaff := func(next View) View {
+		return func(w http.ResponseWriter, req *http.Request) (string, int) {
+			if req.Header.Get("X-Staff") == "" {
+				return "staff only", http.StatusForbidden
+			}
+			return next(w, req)
+		}
+	}
+	home := Route("^/$", view, Tag("public"), With(mark("route")))
+	users := Route("^/users/$", view, Tag("admin", "api", "admin"))
+	App.Use(mark("app"))
+	App.UseTag("admin", requireStaff)
+	App.UseTag("public", mark("public"))
+	App.AddURLs(home, users)
+	App.Group("/v1", Tag("api")).AddURLs(Route("^/ping/$", view, ContentType(JSON)))
+	App.UseTag("api", mark("api"))
+
+	get := func(path string, staff bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if staff {
+			req.Header.Set("X-Staff", "1")
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	if got := get("/", false).Body.String(); got != "app(public(route(view)))" {
+		t.Errorf("got %q", got)
+	}
+	if w := get("/users/", false); w.Code != http.StatusForbidden {
+		t.Errorf("got %d %q without staff", w.Code, w.Body)
+	}
+	if got := get("/users/", true).Body.String(); got != "app(api(view))" {
+		t.Errorf("got %q", got)
+	}
+	if got := users.Tags(); strings.Join(got, ",") != "admin,api" {
+		t.Errorf("got tags %v", got)
+	}
+	if routes := App.RoutesTagged("api"); len(routes) != 2 || routes[0] != users || routes[1].Pattern() != "^/v1/ping/$" {
+		t.Errorf("got %v tagged api", routes)
+	}
+
+	App.flushStats(context.Background())
+	for tag, want := range map[string]int{"public": 1, "admin": 2, "api": 2} {
+		if n, _ := App.stat_map.Find("Tag =&gt; " + tag).(int); n != want {
+			t.Errorf("%s: counted %d, want %d", tag, n, want)
+		}
+	}
+}
+
+func TestTagCachePolicy(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "", http.StatusOK
+	}
+	public := Route("^/$", view, Tag("public"), ContentType(JSON))
+	own := Route("^/own/$", view, Tag("public"), Cache(0))
+	other := Route("^/other/$", view, ContentType(JSON))
+	App.AddURLs(public, own, other)
+	App.SetCachePolicy(JSON, 30*time.Second)
+	App.SetTagCachePolicy("public", time.Minute)
+
+	if ttl := public.CacheTTL(); ttl != time.Minute {
+		t.Errorf("tagged: got %v, want 1m", ttl)
+	}
+	if ttl := own.CacheTTL(); ttl != 0 {
+		t.Errorf("route with its own duration: got %v, want 0", ttl)
+	}
+	if ttl := other.CacheTTL(); ttl != 30*time.Second {
+		t.Errorf("untagged: got %v, want 30s", ttl)
+	}
+}
@@ -0,0 +1,219 @@
+package wedge
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TemplateBundle holds the sources of a set of templates, keyed by name.
+// It's built once, at build time, and compiled into the binary, so that a
+// production server renders pages without reading the filesystem.
+//
+// A bundle is built from a project's template files by a program run with
+// go generate, which writes it out as Go source:
+//
+//	//go:generate go run gen_templates.go
+//
+//	// gen_templates.go
+//	func main() {
+//		b, err := wedge.BundleTemplates("templates", "*.html", "forms/*.html")
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		if _, err := b.Parse(nil); err != nil {
+//			log.Fatal(err)
+//		}
+//		f, _ := os.Create("templates_bundle.go")
+//		defer f.Close()
+//		if err := b.WriteGo(f, "main", "templateBundle"); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// Its form field templates can then be given to forms.LoadBundle with
+// b.Sub("forms/").
+type TemplateBundle map[string]string
+
+// BundleTemplates reads the files under root matching any of patterns, as
+// for filepath.Glob, into a TemplateBundle. Each template is named after
+// its path relative to root, always with forward slashes.
+func BundleTemplates(root string, patterns ...string) (TemplateBundle, error) {
+	return BundleFS(os.DirFS(root), patterns...)
+}
+
+// BundleFS is BundleTemplates for an fs.FS, such as one from go:embed.
+func BundleFS(fsys fs.FS, patterns ...string) (TemplateBundle, error) {
+	b := make(TemplateBundle)
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, filepath.ToSlash(pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			b[name] = string(data)
+		}
+	}
+	return b, nil
+}
+
+// names returns the names in the bundle in order, so that anything made
+// from it is the same on every build.
+func (b TemplateBundle) names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sub returns the templates whose names start with prefix, with the prefix
+// removed from their names.
+func (b TemplateBundle) Sub(prefix string) TemplateBundle {
+	sub := make(TemplateBundle)
+	for name, text := range b {
+		if strings.HasPrefix(name, prefix) {
+			sub[strings.TrimPrefix(name, prefix)] = text
+		}
+	}
+	return sub
+}
+
+// Parse parses every template in the bundle into one set, so they can
+// refer to each other by name. funcs must be given before parsing, as
+// templates using unknown functions don't parse. Running it as part of the
+// build catches broken templates before they're deployed.
+func (b TemplateBundle) Parse(funcs template.FuncMap) (*template.Template, error) {
+	t := template.New("").Funcs(funcs)
+	for _, name := range b.names() {
+		if _, err := t.New(name).Parse(b[name]); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// WriteGo writes the bundle as a Go source file in package pkg, declaring
+// it as the variable name.
+func (b TemplateBundle) WriteGo(w io.Writer, pkg, name string) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by wedge.TemplateBundle.WriteGo. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\nimport \"wedge\"\n\n", pkg)
+	fmt.Fprintf(buf, "var %s = wedge.TemplateBundle{\n", name)
+	for _, n := range b.names() {
+		fmt.Fprintf(buf, "%q: %q,\n", n, b[n])
+	}
+	fmt.Fprintf(buf, "}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// TemplateRegistry renders the templates of a bundle, any of which may be
+// overridden while the server runs.
+type TemplateRegistry struct {
+	sync.RWMutex
+	bundle    TemplateBundle
+	overrides TemplateBundle
+	funcs     template.FuncMap
+	parsed    *template.Template
+}
+
+// NewTemplateRegistry parses the templates of b, failing if any of them
+// don't parse.
+func NewTemplateRegistry(b TemplateBundle, funcs template.FuncMap) (*TemplateRegistry, error) {
+	parsed, err := b.Parse(funcs)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateRegistry{
+		bundle:    b,
+		overrides: make(TemplateBundle),
+		funcs:     funcs,
+		parsed:    parsed,
+	}, nil
+}
+
+// Override replaces, or adds, the template called name with text. The
+// registry is unchanged if the result doesn't parse.
+func (r *TemplateRegistry) Override(name, text string) error {
+	r.Lock()
+	defer r.Unlock()
+	merged := r.merged()
+	merged[name] = text
+	parsed, err := merged.Parse(r.funcs)
+	if err != nil {
+		return err
+	}
+	r.overrides[name] = text
+	r.parsed = parsed
+	return nil
+}
+
+// Reset removes the override of name, going back to the bundle's template.
+func (r *TemplateRegistry) Reset(name string) error {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.overrides[name]; !ok {
+		return nil
+	}
+	merged := r.merged()
+	delete(merged, name)
+	if text, ok := r.bundle[name]; ok {
+		merged[name] = text
+	}
+	parsed, err := merged.Parse(r.funcs)
+	if err != nil {
+		return err
+	}
+	delete(r.overrides, name)
+	r.parsed = parsed
+	return nil
+}
+
+// merged returns the bundle with the overrides applied.
+func (r *TemplateRegistry) merged() TemplateBundle {
+	merged := make(TemplateBundle, len(r.bundle)+len(r.overrides))
+	for name, text := range r.bundle {
+		merged[name] = text
+	}
+	for name, text := range r.overrides {
+		merged[name] = text
+	}
+	return merged
+}
+
+// Lookup returns the template called name, or nil.
+func (r *TemplateRegistry) Lookup(name string) *template.Template {
+	r.RLock()
+	defer r.RUnlock()
+	return r.parsed.Lookup(name)
+}
+
+// Render executes the template called name with data.
+func (r *TemplateRegistry) Render(name string, data interface{}) (string, error) {
+	r.RLock()
+	parsed := r.parsed
+	r.RUnlock()
+	buf := new(bytes.Buffer)
+	if err := parsed.ExecuteTemplate(buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
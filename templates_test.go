@@ -0,0 +1,57 @@
+package wedge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateBundle(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "forms"), 0755)
+	os.WriteFile(filepath.Join(dir, "page.html"), []byte(`<h1>{{.}}</h1>{{template "footer.html"}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "footer.html"), []byte(`<footer>f</footer>`), 0644)
+	os.WriteFile(filepath.Join(dir, "forms", "text.html"), []byte(`<input name="{{.Name}}">`), 0644)
+
+	b, err := BundleTemplates(dir, "*.html", "forms/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 3 {
+		t.Fatalf("got %d templates, want 3", len(b))
+	}
+	if sub := b.Sub("forms/"); len(sub) != 1 || sub["text.html"] == "" {
+		t.Errorf("Sub: got %v", sub)
+	}
+
+	src := new(bytes.Buffer)
+	if err := b.WriteGo(src, "main", "bundle"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(src.String(), `"page.html":`) || !strings.Contains(src.String(), "var bundle = wedge.TemplateBundle{") {
+		t.Errorf("WriteGo: got\n%s", src)
+	}
+
+	r, err := NewTemplateRegistry(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Render("page.html", "hi"); got != "<h1>hi</h1><footer>f</footer>" {
+		t.Errorf("Render: got %q", got)
+	}
+	if err := r.Override("footer.html", "{{"); err == nil {
+		t.Error("Override accepted a template which doesn't parse")
+	}
+	if err := r.Override("footer.html", "<footer>new</footer>"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Render("page.html", "hi"); got != "<h1>hi</h1><footer>new</footer>" {
+		t.Errorf("Render after Override: got %q", got)
+	}
+	r.Reset("footer.html")
+	if got, _ := r.Render("page.html", "hi"); got != "<h1>hi</h1><footer>f</footer>" {
+		t.Errorf("Render after Reset: got %q", got)
+	}
+}
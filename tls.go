@@ -0,0 +1,61 @@
+package wedge
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+)
+
+// SetTLSConfig sets the TLS configuration RunTLS and StartTLS serve with,
+// such as the minimum version and cipher suites. Without one, TLS 1.2 is
+// the oldest version accepted. Configurations which provide certificates
+// themselves, such as with GetCertificate, don't need the certificate and
+// key files.
+//
+// Example:
+//
+//	App.SetTLSConfig(&tls.Config{
+//		MinVersion:       tls.VersionTLS13,
+//		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+//	})
+//	App.RunTLS("/etc/ssl/site.crt", "/etc/ssl/site.key")
+func (App *AppServer) SetTLSConfig(config *tls.Config) {
+	App.tlsConfig = config
+}
+
+// RunTLS is Run over HTTPS, with the certificate and key in the PEM files
+// certFile and keyFile.
+func (App *AppServer) RunTLS(certFile, keyFile string) {
+	ctx, stop := SignalContext(context.Background())
+	defer stop()
+	err := App.run(ctx, func() error {
+		return App.StartTLS(certFile, keyFile)
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// StartTLS is Start over HTTPS, see RunTLS. Errors loading the
+// certificate are returned straight away, along with those binding the
+// port.
+func (App *AppServer) StartTLS(certFile, keyFile string) error {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if App.tlsConfig != nil {
+		config = App.tlsConfig.Clone()
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	listener, err := net.Listen("tcp", ":"+App.port)
+	if err != nil {
+		return err
+	}
+	App.serve(listener, config)
+	return nil
+}
@@ -0,0 +1,97 @@
+package wedge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestStartTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeCert(t, dir)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	App := NewAppServer(port, 30)
+	App.SetVerbosity(Quiet)
+	App.AddURLs(URL("^/$", "Index", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "secure", http.StatusOK
+	}, HTML))
+	if err := App.StartTLS(certFile, filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("StartTLS with a missing key returned nil")
+	}
+	App.SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS13})
+	if err := App.StartTLS(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	defer App.Shutdown(context.Background())
+
+	get := func(max uint16) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, MaxVersion: max},
+		}}
+		return client.Get("https://127.0.0.1:" + port + "/")
+	}
+	resp, err := get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secure" || resp.TLS == nil || resp.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("got %q over %+v", body, resp.TLS)
+	}
+	if _, err := get(tls.VersionTLS12); err == nil {
+		t.Error("a TLS 1.2 client was accepted")
+	}
+}
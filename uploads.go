@@ -0,0 +1,360 @@
+package wedge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUploadExpiry is how long a partial upload is kept without any
+// progress, if Uploads has no Expiry of its own.
+var DefaultUploadExpiry = 24 * time.Hour
+
+// UploadSweep is how often Uploads are swept of expired uploads once
+// their route has been used.
+var UploadSweep = 10 * time.Minute
+
+// Upload is the state of a resumable upload.
+type Upload struct {
+	ID string `json:"id"`
+	// Size is the length of the whole file, and Offset how much of it
+	// has been received.
+	Size     int64  `json:"size"`
+	Offset   int64  `json:"offset"`
+	Complete bool   `json:"complete"`
+	Name     string `json:"name,omitempty"`
+	// Key and URL are where a complete upload is in the Uploads'
+	// Storage, if it has one.
+	Key string `json:"key,omitempty"`
+	URL string `json:"url,omitempty"`
+	// Path is where the file is kept on disk, which is only final once
+	// the upload is Complete, and is empty once it's moved to Storage.
+	Path    string    `json:"-"`
+	Updated time.Time `json:"-"`
+	// writing is set while a chunk is written or the upload finished,
+	// which happens outside the Uploads' lock.
+	writing bool
+}
+
+// Uploads receives files in chunks which can be resumed after a dropped
+// connection, for large files over flaky networks. It's served below a
+// prefix by the route from its Route method, which answers:
+//
+//	POST   prefix      starts an upload of Upload-Length bytes, named by
+//	                   the optional Upload-Name header, answering 201 with
+//	                   its Location
+//	PUT    prefix/id   appends the body, a chunk described by a
+//	                   Content-Range such as "bytes 0-1048575/10485760"
+//	GET    prefix/id   reports the progress, also in an Upload-Offset header
+//	DELETE prefix/id   cancels the upload
+//
+// Each successful response is the Upload as JSON. Errors are answered as
+// any other view's are, so clients which accept JSON get an APIError. A
+// chunk which doesn't start where the last one ended is refused with a
+// 409, so a client which lost track asks for the progress and carries on
+// from the offset it gets back.
+type Uploads struct {
+	// Dir is where uploads are kept while they're received and, without
+	// a Storage, once they're complete.
+	Dir string
+	// Storage is where complete uploads are moved to, under their ID and
+	// the extension of their name.
+	Storage Storage
+	// MaxSize refuses uploads larger than it, if it's set.
+	MaxSize int64
+	// Expiry is how long a partial upload is kept without any progress.
+	Expiry time.Duration
+	// OnComplete is called once the whole file has been received. An
+	// error is reported to the client and the file is removed.
+	OnComplete func(u *Upload, req *http.Request) error
+
+	mu      sync.Mutex
+	uploads map[string]*Upload
+	sweeper sync.Once
+}
+
+var contentRange = regexp.MustCompile(`^bytes ([0-9]+)-([0-9]+)/([0-9]+)$`)
+
+// Route returns the route serving the uploads below prefix, e.g.
+// "/uploads/".
+func (u *Uploads) Route(prefix string) *Rule {
+	prefix = strings.TrimRight(prefix, "/")
+	re := "^" + regexp.QuoteMeta(prefix) + "/?([0-9a-f]*)$"
+	return Route(re, func(w http.ResponseWriter, req *http.Request) (string, int) {
+		id := strings.Trim(strings.TrimPrefix(req.URL.Path, prefix), "/")
+		return u.serve(w, req, id)
+	}, Name("Uploads"), ContentType(HTML))
+}
+
+func (u *Uploads) serve(w http.ResponseWriter, req *http.Request, id string) (string, int) {
+	u.sweeper.Do(func() { go u.sweep() })
+	if id == "" {
+		if req.Method != "POST" {
+			return "", http.StatusNotFound
+		}
+		return u.create(w, req)
+	}
+
+	u.mu.Lock()
+	up, ok := u.uploads[id]
+	u.mu.Unlock()
+	if !ok {
+		return "", http.StatusNotFound
+	}
+	switch req.Method {
+	case "GET", "HEAD":
+		return u.progress(w, http.StatusOK, up)
+	case "PUT", "PATCH":
+		return u.append(w, req, up)
+	case "DELETE":
+		u.remove(up)
+		return u.progress(w, http.StatusOK, up)
+	}
+	w.Header().Set("Allow", "GET, HEAD, PUT, PATCH, DELETE")
+	return "method not allowed", http.StatusMethodNotAllowed
+}
+
+func (u *Uploads) create(w http.ResponseWriter, req *http.Request) (string, int) {
+	size, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return "missing or bad Upload-Length", http.StatusBadRequest
+	}
+	if u.MaxSize > 0 && size > u.MaxSize {
+		return "upload too large", http.StatusRequestEntityTooLarge
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Println("Error creating upload id:", err)
+		return "", http.StatusInternalServerError
+	}
+	id := hex.EncodeToString(b)
+	up := &Upload{
+		ID:      id,
+		Size:    size,
+		Name:    filepath.Base(req.Header.Get("Upload-Name")),
+		Path:    filepath.Join(u.Dir, id+".part"),
+		Updated: time.Now(),
+	}
+	if up.Name == "." || up.Name == string(filepath.Separator) {
+		up.Name = ""
+	}
+	f, err := os.OpenFile(up.Path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Println("Error creating upload:", err)
+		return "", http.StatusInternalServerError
+	}
+	f.Close()
+
+	u.mu.Lock()
+	if u.uploads == nil {
+		u.uploads = make(map[string]*Upload)
+	}
+	u.uploads[id] = up
+	up.writing = size == 0
+	u.mu.Unlock()
+	if size == 0 {
+		if err := u.finish(up, req); err != nil {
+			return err.Error(), http.StatusUnprocessableEntity
+		}
+	}
+	w.Header().Set("Location", strings.TrimRight(Link(req, req.URL.Path), "/")+"/"+id)
+	return u.progress(w, http.StatusCreated, up)
+}
+
+func (u *Uploads) append(w http.ResponseWriter, req *http.Request, up *Upload) (string, int) {
+	m := contentRange.FindStringSubmatch(req.Header.Get("Content-Range"))
+	if m == nil {
+		return "missing or bad Content-Range", http.StatusBadRequest
+	}
+	start, _ := strconv.ParseInt(m[1], 10, 64)
+	end, _ := strconv.ParseInt(m[2], 10, 64)
+	total, _ := strconv.ParseInt(m[3], 10, 64)
+	if total != up.Size || end < start || end >= total {
+		return "range doesn't fit the upload", http.StatusRequestedRangeNotSatisfiable
+	}
+
+	// a chunk claims its upload while it's written, so chunks for the
+	// same upload are written one at a time without holding the lock
+	// every other upload needs.
+	u.mu.Lock()
+	if _, ok := u.uploads[up.ID]; !ok {
+		u.mu.Unlock()
+		return "", http.StatusNotFound
+	}
+	if start != up.Offset || up.writing {
+		offset, writing := up.Offset, up.writing
+		u.mu.Unlock()
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		if writing {
+			return "another chunk is being written", http.StatusConflict
+		}
+		return "chunk doesn't start at the upload's offset", http.StatusConflict
+	}
+	up.writing = true
+	path := up.Path
+	u.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		u.release(up)
+		log.Println("Error opening upload:", err)
+		return "", http.StatusInternalServerError
+	}
+	want := end - start + 1
+	n, err := io.Copy(f, io.LimitReader(req.Body, want))
+	f.Close()
+
+	u.mu.Lock()
+	up.Offset += n
+	up.Updated = time.Now()
+	_, ok := u.uploads[up.ID]
+	complete := ok && err == nil && n == want && up.Offset == up.Size
+	up.writing = complete
+	offset := up.Offset
+	u.mu.Unlock()
+	if !ok {
+		// cancelled while it was written.
+		return "", http.StatusNotFound
+	}
+	if err != nil || n != want {
+		// keep what arrived, the client resumes from the new offset.
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		return fmt.Sprintf("chunk ended after %d of %d bytes", n, want), http.StatusBadRequest
+	}
+	if complete {
+		if err := u.finish(up, req); err != nil {
+			return err.Error(), http.StatusUnprocessableEntity
+		}
+	}
+	return u.progress(w, http.StatusOK, up)
+}
+
+// progress answers with a copy of up, taken under the lock, and its
+// offset in the Upload-Offset header.
+func (u *Uploads) progress(w http.ResponseWriter, code int, up *Upload) (string, int) {
+	u.mu.Lock()
+	copied := *up
+	u.mu.Unlock()
+	b, err := json.Marshal(copied)
+	if err != nil {
+		log.Println("Error encoding upload:", err)
+		return "", http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(copied.Offset, 10))
+	return string(b), code
+}
+
+// release unclaims an upload claimed by a chunk.
+func (u *Uploads) release(up *Upload) {
+	u.mu.Lock()
+	up.writing = false
+	up.Updated = time.Now()
+	u.mu.Unlock()
+}
+
+// finish moves a received upload to its final path, or into the
+// Storage, and hands it to OnComplete. It's called with the upload
+// claimed and without the lock, as a Storage may take a while.
+func (u *Uploads) finish(up *Upload, req *http.Request) error {
+	defer u.release(up)
+	final := strings.TrimSuffix(up.Path, ".part")
+	var key, url string
+	if u.Storage != nil {
+		var err error
+		if key, url, err = u.store(up); err != nil {
+			log.Println("Error storing upload:", err)
+			return err
+		}
+		final = ""
+	} else if err := os.Rename(up.Path, final); err != nil {
+		log.Println("Error completing upload:", err)
+		return err
+	}
+	u.mu.Lock()
+	up.Path, up.Key, up.URL = final, key, url
+	up.Complete = true
+	u.mu.Unlock()
+	if u.OnComplete != nil {
+		if err := u.OnComplete(up, req); err != nil {
+			if key != "" {
+				u.Storage.Delete(key)
+			} else {
+				os.Remove(final)
+			}
+			u.mu.Lock()
+			delete(u.uploads, up.ID)
+			u.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// store puts a received upload into the Storage, removing it from Dir,
+// and returns its key and URL.
+func (u *Uploads) store(up *Upload) (string, string, error) {
+	f, err := os.Open(up.Path)
+	if err != nil {
+		return "", "", err
+	}
+	key := up.ID + strings.ToLower(filepath.Ext(up.Name))
+	err = u.Storage.Put(key, f)
+	f.Close()
+	if err != nil {
+		return "", "", err
+	}
+	os.Remove(up.Path)
+	return key, u.Storage.URL(key), nil
+}
+
+func (u *Uploads) remove(up *Upload) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.uploads, up.ID)
+	if !up.Complete {
+		os.Remove(up.Path)
+	}
+}
+
+// sweep runs Sweep every UploadSweep.
+func (u *Uploads) sweep() {
+	tick := time.NewTicker(UploadSweep)
+	defer tick.Stop()
+	for range tick.C {
+		u.Sweep()
+	}
+}
+
+// Sweep removes partial uploads which haven't progressed within the
+// Expiry, and forgets complete ones which are as old, leaving their files
+// to OnComplete. It's run every UploadSweep once the route has been
+// used, and can also be run by hand.
+func (u *Uploads) Sweep() {
+	expiry := u.Expiry
+	if expiry <= 0 {
+		expiry = DefaultUploadExpiry
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id, up := range u.uploads {
+		if up.writing || time.Since(up.Updated) <= expiry {
+			continue
+		}
+		if !up.Complete {
+			os.Remove(up.Path)
+		}
+		delete(u.uploads, id)
+	}
+}
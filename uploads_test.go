@@ -0,0 +1,161 @@
+package wedge
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploads(t *testing.T) {
+	var done *Upload
+	uploads := &Uploads{Dir: t.TempDir(), MaxSize: 100, OnComplete: func(u *Upload, req *http.Request) error {
+		done = u
+		return nil
+	}}
+	App := NewAppServer("0", 30)
+	App.AddURLs(uploads.Route("/uploads/"))
+
+	do := func(method, path, body string, headers map[string]string) (*httptest.ResponseRecorder, Upload) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		var up Upload
+		json.Unmarshal(w.Body.Bytes(), &up)
+		return w, up
+	}
+
+	if w, _ := do("POST", "/uploads/", "", map[string]string{"Upload-Length": "1000"}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("upload over MaxSize: got status %d", w.Code)
+	}
+	w, up := do("POST", "/uploads/", "", map[string]string{"Upload-Length": "11", "Upload-Name": "../hello.txt"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if location != "/uploads/"+up.ID || up.Name != "hello.txt" {
+		t.Errorf("create: got Location %q and name %q", location, up.Name)
+	}
+
+	if w, _ := do("PUT", location, "world", map[string]string{"Content-Range": "bytes 6-10/11"}); w.Code != http.StatusConflict {
+		t.Errorf("chunk past the offset: got status %d", w.Code)
+	}
+	// errors are APIErrors for clients which accept JSON.
+	w, _ = do("PUT", location, "world", map[string]string{"Content-Range": "bytes 6-10/11", "Accept": "application/json"})
+	var failed struct{ Error APIError }
+	if err := json.Unmarshal(w.Body.Bytes(), &failed); err != nil || failed.Error.Code != "conflict" ||
+		failed.Error.Message != "chunk doesn't start at the upload's offset" {
+		t.Errorf("chunk past the offset: got %s", w.Body)
+	}
+	if w, up := do("PUT", location, "hello ", map[string]string{"Content-Range": "bytes 0-5/11"}); w.Code != http.StatusOK || up.Offset != 6 {
+		t.Errorf("first chunk: got status %d and offset %d", w.Code, up.Offset)
+	}
+	if w, up := do("GET", location, "", nil); w.Header().Get("Upload-Offset") != "6" || up.Complete {
+		t.Errorf("progress: got offset %q, complete %v", w.Header().Get("Upload-Offset"), up.Complete)
+	}
+	if w, up := do("PUT", location, "world", map[string]string{"Content-Range": "bytes 6-10/11"}); w.Code != http.StatusOK || !up.Complete {
+		t.Errorf("last chunk: got status %d, complete %v", w.Code, up.Complete)
+	}
+	if done == nil {
+		t.Fatal("OnComplete wasn't called")
+	}
+	if data, _ := os.ReadFile(done.Path); string(data) != "hello world" {
+		t.Errorf("got file %q", data)
+	}
+
+	uploads.Expiry = time.Nanosecond
+	_, stale := do("POST", "/uploads/", "", map[string]string{"Upload-Length": "5"})
+	time.Sleep(time.Millisecond)
+	uploads.Sweep()
+	if w, _ := do("GET", "/uploads/"+stale.ID, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expired upload: got status %d", w.Code)
+	}
+	if _, err := os.Stat(done.Path); err != nil {
+		t.Errorf("sweeping removed a complete upload: %v", err)
+	}
+}
+
+func TestUploadsStorage(t *testing.T) {
+	store := &DiskStorage{Dir: t.TempDir(), BaseURL: "/media/"}
+	uploads := &Uploads{Dir: t.TempDir(), Storage: store}
+	App := NewAppServer("0", 30)
+	App.AddURLs(uploads.Route("/uploads/"))
+
+	req := httptest.NewRequest("POST", "/uploads/", nil)
+	req.Header.Set("Upload-Length", "5")
+	req.Header.Set("Upload-Name", "Notes.TXT")
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	var up Upload
+	json.Unmarshal(w.Body.Bytes(), &up)
+
+	req = httptest.NewRequest("PUT", "/uploads/"+up.ID, strings.NewReader("hello"))
+	req.Header.Set("Content-Range", "bytes 0-4/5")
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &up)
+	if !up.Complete || up.Key != up.ID+".txt" || up.URL != "/media/"+up.ID+".txt" {
+		t.Fatalf("got %+v", up)
+	}
+	r, err := store.Get(up.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, _ := ioutil.ReadAll(r); string(data) != "hello" {
+		t.Errorf("got %q in storage", data)
+	}
+	if parts, _ := os.ReadDir(uploads.Dir); len(parts) != 0 {
+		t.Errorf("%d files were left in Dir", len(parts))
+	}
+}
+
+func TestUploadsConcurrent(t *testing.T) {
+	uploads := &Uploads{Dir: t.TempDir()}
+	App := NewAppServer("0", 30)
+	App.AddURLs(uploads.Route("/uploads/"))
+	create := func() Upload {
+		req := httptest.NewRequest("POST", "/uploads/", nil)
+		req.Header.Set("Upload-Length", "5")
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		var up Upload
+		json.Unmarshal(w.Body.Bytes(), &up)
+		return up
+	}
+	put := func(id string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/uploads/"+id, body)
+		req.Header.Set("Content-Range", "bytes 0-4/5")
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		return w
+	}
+	slow, other := create(), create()
+
+	// a chunk which is slow to arrive holds up neither other uploads nor
+	// a Sweep, only other chunks for its own upload.
+	r, pw := io.Pipe()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- put(slow.ID, r) }()
+	pw.Write([]byte("he"))
+	if w := put(slow.ID, strings.NewReader("hello")); w.Code != http.StatusConflict {
+		t.Errorf("second chunk at once: got status %d", w.Code)
+	}
+	if w := put(other.ID, strings.NewReader("hello")); w.Code != http.StatusOK {
+		t.Errorf("another upload: got status %d", w.Code)
+	}
+	uploads.Sweep()
+	pw.Write([]byte("llo"))
+	pw.Close()
+	if w := <-done; w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("slow chunk: got status %d and offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+}
@@ -2,15 +2,17 @@ package wedge
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 )
 
-// Basic URL struct which holds a match, a name and a handler function
+// Rule is a route which holds a match, a name and a handler function.
+// Rules are created with URL, Route and the other constructors, and
+// can be inspected with their accessor methods.
 //
 // match:
 //     Match is a *regexp.Regexp which we will use to check incoming
@@ -20,56 +22,152 @@ import (
 // handler:
 //     Handler is a wedge.view function which we will use against any
 //     requests that match `match`.
-type url struct {
-	match          *regexp.Regexp
+type Rule struct {
+	match          *matcher
 	name           string
 	handler        view
 	viewtype       handlertype
 	rawre          string
 	cache_duration time.Duration
-	timeout        chan bool
+	converters     map[string]converter
+	accepts        []string
+	vary           []string
+	cache_tags     []string
+	static_dirs    []string
+	methods        []string
+	meta           map[string]interface{}
+	preload        []string
+	preconnect     []string
+	early_hints    bool
+	coalesce       *coalescer
+	cache_set      bool
+	adaptive       *adaptive
+	headers        http.Header
+	shared_cache   bool
+	cors           *cors
+	strip_cookies  bool
+	middleware     []Middleware
+	static_cache   []staticCache
+	checksums      bool
+	stream         bool
+	direct         bool
+	deadline       time.Duration
+	tags           []string
+	offers         []offer
 }
 
-func (u *url) String() string {
+func (u *Rule) String() string {
 	return fmt.Sprintf(
 		"{\n  URL: %s\n  Handler: %s\n}", u.match, u.name,
 	)
 }
 
-// Unexported method which forms as the base method to return *url values
+// Unexported method which forms as the base method to return *Rule values
 //
 // We chose to do it like this because we can have specialized methods
 // which have a simply API but fill in certain blanks for this. And the
 // makeurl method can have a relatively clunky API since the work will
 // be done under the hood.
-func makeurl(re, name string, v view, t handlertype, duration time.Duration) *url {
-	match := regexp.MustCompile(re)
-	timeoutchan := make(chan bool)
+func makeurl(re, name string, v view, t handlertype, duration time.Duration) *Rule {
+	if err := checkPattern(re); err != nil {
+		panic(err)
+	}
+	match := newMatcher(re)
+
+	u := &Rule{
+		match:    match,
+		name:     name,
+		handler:  v,
+		viewtype: t,
+		rawre:    re,
+	}
+	// named groups in the pattern are passed to the view as strings,
+	// see Params.
+	for _, name := range match.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if u.converters == nil {
+			u.converters = make(map[string]converter)
+		}
+		u.converters[name] = converter{convert: asString}
+	}
+	u.setCache(duration)
+	return u
+}
 
-	// Initialize the channel and seed with a value
-	// so the first request will put the response
-	// into memory
+// forever is the cache duration used for routes which never expire.
+const forever = 30 * 12 * 30 * time.Hour
+
+// setCache sets how long responses from the route are cached for, in
+// multiples of TIMEOUT. Zero disables caching and a negative duration
+// caches forever.
+func (u *Rule) setCache(duration time.Duration) {
 	if duration < 0 {
-		duration = 30 * 12 * 30 * time.Hour
+		duration = forever
 	}
-	if duration > 0 {
-		go func() {
-			timeoutchan <- true
-		}()
+	u.cache_duration = duration
+	u.cache_set = true
+}
+
+// cacheExpiry returns when a response from the route cached at now goes
+// stale, which is never, the zero Time, if it's cached forever.
+func (u *Rule) cacheExpiry(now time.Time) time.Time {
+	if u.cache_duration == forever {
+		return time.Time{}
 	}
+	return now.Add(u.cache_duration * TIMEOUT)
+}
+
+// Accepts restricts the request Content-Types which the route will
+// handle. Requests which carry a body of any other type are answered
+// with a 415 Unsupported Media Type before the view is called.
+//
+// Example:
+//     wedge.URL("^/api/posts/$", "Posts", Posts, wedge.JSON).Accepts("application/json")
+func (u *Rule) Accepts(ctypes ...string) *Rule {
+	u.accepts = append(u.accepts, ctypes...)
+	return u
+}
 
-	return &url{
-		match:          match,
-		name:           name,
-		handler:        v,
-		viewtype:       t,
-		rawre:          re,
-		cache_duration: duration,
-		timeout:        timeoutchan,
+// accepted checks whether the Content-Type of req is one which the route
+// has declared it accepts. Requests without a body are always accepted.
+func (u *Rule) accepted(req *http.Request) bool {
+	if len(u.accepts) == 0 || req.ContentLength == 0 {
+		return true
 	}
+	ctype, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, accept := range u.accepts {
+		if strings.EqualFold(ctype, accept) {
+			return true
+		}
+	}
+	return false
+}
+
+// Vary marks the response as depending on the given request headers. For
+// cached routes the values of the headers form part of the cache key, so
+// each variant is cached separately.
+func (u *Rule) Vary(headers ...string) *Rule {
+	u.vary = append(u.vary, headers...)
+	return u
+}
+
+// CacheTags tags every cached response from the route, so that they can
+// all be evicted with App.InvalidateTag when the content they depend on
+// changes.
+//
+// Example:
+//     wedge.CacheURL("^/posts/", "Posts", Posts, wedge.HTML, -1).CacheTags("posts")
+func (u *Rule) CacheTags(tags ...string) *Rule {
+	u.cache_tags = append(u.cache_tags, tags...)
+	return u
 }
 
-// URL is a function which returns a *url value.
+// URL is a function which returns a *Rule value.
 // re:
 //     re is a string which will be compiled to a *regexp.Regexp
 //     and will panic if the regular expression cannot be compiled
@@ -78,16 +176,26 @@ func makeurl(re, name string, v view, t handlertype, duration time.Duration) *ur
 // handler:
 //     Handler is a wedge.view function which we will use against any
 //     requests that match `match`.
-func URL(re, name string, v view, t handlertype) *url {
-	return makeurl(re, name, v, t, 0)
+//
+// If StrictURLs is set the pattern will be anchored, see RawURL.
+//
+// Named groups in the pattern are available to the view via Params, as
+// strings.
+//
+// Example:
+//     wedge.URL(`^/users/(?P<id>\d+)/$`, "User", User, wedge.HTML)
+//
+//     id := wedge.Params(req)["id"].(string)
+func URL(re, name string, v view, t handlertype) *Rule {
+	return Route(re, v, Name(name), ContentType(t))
 }
 
-// Download is a function which returns a *url value.
+// Download is a function which returns a *Rule value.
 //
 // This function simply gives access to the correct content header types
 // so a file is downloaded instead of displayed.
-func Download(re, name string, v view) *url {
-	return makeurl(re, name, v, DOWNLOAD, 0)
+func Download(re, name string, v view) *Rule {
+	return Route(re, v, Name(name), ContentType(DOWNLOAD))
 }
 
 // StaticFiles is a not so light wrapper around the URL function
@@ -100,8 +208,8 @@ func Download(re, name string, v view) *url {
 //
 // This function will return a file in a string format ready to be sent
 // across the wire.
-func StaticFiles(as string, paths ...string) *url {
-	return makeurl(as, "Static File", func(w http.ResponseWriter, req *http.Request) (string, int) {
+func StaticFiles(as string, paths ...string) *Rule {
+	u := makeurl(as, "Static File", func(w http.ResponseWriter, req *http.Request) (string, int) {
 		filename := req.URL.Path[len(as):]
 		for _, path := range paths {
 			// Prevent Directory Traversal Attacks
@@ -116,17 +224,23 @@ func StaticFiles(as string, paths ...string) *url {
 		}
 		return "", http.StatusNotFound
 	}, STATIC, -1)
+	u.static_dirs = paths
+	return u
 }
 
 // CacheURL returns a URL which has caching enabled for time.Duration d.
-func CacheURL(re, name string, v view, t handlertype, d time.Duration) *url {
-	return makeurl(re, name, v, t, d)
+// Its responses get an ETag and Last-Modified header, and GET requests
+// which already have them are answered with a 304 Not Modified.
+func CacheURL(re, name string, v view, t handlertype, d time.Duration) *Rule {
+	return Route(re, v, Name(name), ContentType(t), Cache(d*TIMEOUT))
 }
 
 // Favicon takes a path to some file which you want to be returned when
 // a request comes through for ^/favicon.ico$. By default this will cache
 // for TIMEOUT * 10.
-func Favicon(path string) *url {
+//
+// Sites with more icons than a favicon can serve them all with Icons.
+func Favicon(path string) *Rule {
 	file, err := os.Open(path)
 	if err != nil {
 		panic(err)
@@ -144,15 +258,15 @@ func Favicon(path string) *url {
 }
 
 // Redirect is a simple method of allowing paths to be redirected to other URLs.
-func Redirect(path, to string, code int) *url {
-	return makeurl(path, fmt.Sprintf("Redirecting %s => %s", path, to),
+func Redirect(path, to string, code int) *Rule {
+	return makeurl(strictPattern(path), fmt.Sprintf("Redirecting %s => %s", path, to),
 		func(w http.ResponseWriter, req *http.Request) (string, int) {
 			return to, code
 		}, REDIRECT, 0)
 }
 
 // Returns data as the robots.txt file
-func Robots(data string) *url {
+func Robots(data string) *Rule {
 	return makeurl("^/robots.txt$", "Attack of the robots...robots.txt",
 		func(w http.ResponseWriter, req *http.Request) (string, int) {
 			return data, http.StatusOK
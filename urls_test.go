@@ -0,0 +1,46 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccepts(t *testing.T) {
+	App := NewAppServer("0", 0)
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "ok", http.StatusOK
+	}
+	App.AddURLs(
+		URL("^/api/$", "API", view, HTML).Accepts("application/json", "application/xml"),
+		URL("^/any/$", "Any", view, HTML),
+	)
+	for _, test := range []struct {
+		method, path, ctype, body string
+		want                      int
+		header                    string
+	}{
+		{"POST", "/api/", "application/json", "{}", 200, ""},
+		{"POST", "/api/", "Application/JSON; charset=utf-8", "{}", 200, ""},
+		{"POST", "/api/", "text/plain", "hi", 415, "Accept-Post"},
+		{"PATCH", "/api/", "text/plain", "hi", 415, "Accept-Patch"},
+		{"POST", "/api/", "not a type", "hi", 415, "Accept-Post"},
+		// requests without a body have no type to check.
+		{"POST", "/api/", "", "", 200, ""},
+		{"POST", "/any/", "text/plain", "hi", 200, ""},
+	} {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.ctype != "" {
+			req.Header.Set("Content-Type", test.ctype)
+		}
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, req)
+		if w.Code != test.want {
+			t.Errorf("%s %s as %q: got %d, want %d", test.method, test.path, test.ctype, w.Code, test.want)
+		}
+		if test.header != "" && w.Header().Get(test.header) != "application/json, application/xml" {
+			t.Errorf("%s %s as %q: got %s %q", test.method, test.path, test.ctype, test.header, w.Header().Get(test.header))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package wedge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ViewFunc is a view which writes its own response to w, as an
+// http.Handler does, and returns an error if it couldn't. ctx is the
+// request's context, which is done when the client goes away or the
+// route's Deadline passes, so it can be handed on to databases and
+// other calls which should stop with the request.
+//
+// Errors are answered with the AppServer's 500 handler, apart from
+// ErrNotFound, which is answered with its 404 handler, and any error
+// once the client has gone away, which is counted as aborted. An error
+// returned once the response has been started can only be logged.
+type ViewFunc func(ctx context.Context, w http.ResponseWriter, req *http.Request) error
+
+// ErrNotFound is returned by a ViewFunc to answer with the 404 handler.
+var ErrNotFound = errors.New("wedge: not found")
+
+// Handle is the form of Route for a ViewFunc. As the view writes its own
+// response, the server never caches it and concurrent requests aren't
+// coalesced, whatever the options given.
+//
+// Example:
+//
+//	wedge.Handle(`^/orders/(?P<id>[0-9]+)/$`, func(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+//		order, err := db.Order(ctx, wedge.Params(req)["id"].(string))
+//		if err == sql.ErrNoRows {
+//			return wedge.ErrNotFound
+//		} else if err != nil {
+//			return err
+//		}
+//		return json.NewEncoder(w).Encode(order)
+//	}, wedge.Name("Order"), wedge.Deadline(2*time.Second))
+func Handle(re string, f ViewFunc, opts ...Option) *Rule {
+	u := Route(re, f.View(), opts...)
+	u.direct = true
+	u.cache_duration, u.cache_set = 0, true
+	u.adaptive, u.coalesce = nil, nil
+	return u
+}
+
+// startedWriter notes whether a response has been started.
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (s *startedWriter) WriteHeader(status int) {
+	s.started = true
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *startedWriter) Write(b []byte) (int, error) {
+	s.started = true
+	return s.ResponseWriter.Write(b)
+}
+
+// View adapts f to the signature of the views taken by Route and
+// Middleware. Its response is written by f, so routes other than those
+// made by Handle send their own response for the route's type after it.
+func (f ViewFunc) View() View {
+	return func(w http.ResponseWriter, req *http.Request) (string, int) {
+		sw := &startedWriter{ResponseWriter: w}
+		err := f(req.Context(), WrapWriter(w, sw), req)
+		switch {
+		case err == nil:
+			return "", http.StatusOK
+		case clientGone(req):
+			return "", StatusClientClosed
+		case sw.started:
+			log.Println("Error after the response was started:", req.URL.Path, err)
+			return "", http.StatusOK
+		case errors.Is(err, ErrNotFound):
+			return "", http.StatusNotFound
+		}
+		log.Println("Error in view:", req.URL.Path, err)
+		return "", http.StatusInternalServerError
+	}
+}
+
+// FromView adapts a view to a ViewFunc, so handlers written with the
+// older signature can be used where a ViewFunc is expected. A 404 or 500
+// from v is returned as an error, for the AppServer to answer, and any
+// other response is written as it is.
+func FromView(v View) ViewFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+		resp, status := v(w, req.WithContext(ctx))
+		switch status {
+		case http.StatusNotFound:
+			return ErrNotFound
+		case http.StatusInternalServerError:
+			return errors.New("wedge: view failed")
+		case http.StatusOK:
+		default:
+			w.WriteHeader(status)
+		}
+		_, err := io.WriteString(w, resp)
+		return err
+	}
+}
+
+// Deadline gives the request's context a deadline of d after the route
+// matched, which views can pass on to the calls they make so slow ones
+// are abandoned.
+func (u *Rule) Deadline(d time.Duration) *Rule {
+	u.deadline = d
+	return u
+}
+
+// Deadline is the Option form of Rule.Deadline.
+func Deadline(d time.Duration) Option {
+	return func(u *Rule) {
+		u.Deadline(d)
+	}
+}
@@ -0,0 +1,70 @@
+package wedge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandle(t *testing.T) {
+	App := NewAppServer("0", 30)
+	App.Handler500(func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "custom 500", http.StatusInternalServerError
+	})
+	App.AddURLs(
+		Handle(`^/orders/(?P<id>[0-9]+)/$`, func(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+			switch Params(req)["id"] {
+			case "0":
+				return ErrNotFound
+			case "1":
+				return errors.New("database is down")
+			case "2":
+				io.WriteString(w, "partial")
+				return errors.New("lost the connection")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, err := fmt.Fprintf(w, `{"id":%s}`, Params(req)["id"])
+			return err
+		}, Cache(-1)),
+		Handle("^/slow/$", func(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		}, Deadline(10*time.Millisecond)),
+		Route("^/legacy/$", FromView(func(w http.ResponseWriter, req *http.Request) (string, int) {
+			return "legacy", http.StatusOK
+		}).View()),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for path, want := range map[string]struct {
+		code int
+		body string
+	}{
+		"/orders/42/": {200, `{"id":42}`},
+		"/orders/0/":  {404, "404 page not found\n"},
+		"/orders/1/":  {500, "custom 500"},
+		"/orders/2/":  {200, "partial"},
+		"/slow/":      {500, "custom 500"},
+		"/legacy/":    {200, "legacy"},
+	} {
+		// the second request shows the response wasn't cached.
+		for i := 0; i < 2; i++ {
+			if w := get(path); w.Code != want.code || w.Body.String() != want.body {
+				t.Errorf("%s: got %d %q, want %d %q", path, w.Code, w.Body, want.code, want.body)
+			}
+		}
+	}
+}
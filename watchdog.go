@@ -0,0 +1,218 @@
+package wedge
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Watchdog is a set of limits on the health of the process, which are
+// checked periodically by EnableWatchdog. Limits which are zero aren't
+// checked.
+type Watchdog struct {
+	// Interval is how often the process is checked, every ten seconds if
+	// it isn't set.
+	Interval      time.Duration
+	MaxGoroutines int
+	// MaxHeap is the most bytes of heap in use.
+	MaxHeap uint64
+	// MaxLatency is the most the 95th percentile of response times over
+	// an Interval may be.
+	MaxLatency time.Duration
+	// DumpStacks logs the stack of every goroutine when a limit is
+	// crossed.
+	DumpStacks bool
+	// Maintenance puts the AppServer into maintenance mode when a limit
+	// is crossed. It has to be taken out again with SetMaintenance.
+	Maintenance bool
+	// OnAlert is called when a limit is crossed, after it's logged.
+	OnAlert func(Alert)
+}
+
+// Alert describes a limit of a Watchdog being crossed.
+type Alert struct {
+	// Kind is which limit was crossed: "goroutines", "heap" or "latency".
+	Kind  string
+	Value string
+	Limit string
+	Time  time.Time
+}
+
+func (a Alert) String() string {
+	return fmt.Sprintf("%s at %s, over the limit of %s", a.Kind, a.Value, a.Limit)
+}
+
+// watchdog is a Watchdog watching an AppServer.
+type watchdog struct {
+	Watchdog
+	app  *AppServer
+	stop chan struct{}
+
+	sync.Mutex
+	latencies []time.Duration
+	// over records the limits which are currently crossed, so that each
+	// is only alerted on once until it recovers.
+	over map[string]bool
+}
+
+// maxLatencySamples caps how many response times are kept per Interval.
+const maxLatencySamples = 4096
+
+// sample is the state of the process at a check.
+type sample struct {
+	goroutines int
+	heap       uint64
+	p95        time.Duration
+}
+
+// EnableWatchdog starts checking the process against the limits of w,
+// logging a warning when one is crossed and again once it recovers. It
+// returns a function which stops the checks.
+//
+// Example:
+//
+//	stop := App.EnableWatchdog(wedge.Watchdog{
+//		MaxGoroutines: 10000,
+//		MaxHeap:       512 << 20,
+//		MaxLatency:    2 * time.Second,
+//		DumpStacks:    true,
+//	})
+//	defer stop()
+func (App *AppServer) EnableWatchdog(w Watchdog) (stop func()) {
+	if w.Interval <= 0 {
+		w.Interval = 10 * time.Second
+	}
+	dog := &watchdog{Watchdog: w, app: App, stop: make(chan struct{}), over: make(map[string]bool)}
+	App.watchdog = dog
+	go dog.run()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(dog.stop)
+		})
+	}
+}
+
+func (d *watchdog) run() {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-d.app.quit:
+			return
+		case <-ticker.C:
+			d.check(d.sample())
+		}
+	}
+}
+
+// observe records the response time of a request which started at start.
+func (d *watchdog) observe(start time.Time) {
+	elapsed := time.Since(start)
+	d.Lock()
+	defer d.Unlock()
+	if len(d.latencies) < maxLatencySamples {
+		d.latencies = append(d.latencies, elapsed)
+	}
+}
+
+// sample measures the process, and the response times since the last
+// sample.
+func (d *watchdog) sample() sample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s := sample{goroutines: runtime.NumGoroutine(), heap: mem.HeapAlloc}
+
+	d.Lock()
+	latencies := d.latencies
+	d.latencies = nil
+	d.Unlock()
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		s.p95 = latencies[len(latencies)*95/100]
+	}
+	return s
+}
+
+// check compares s against the limits.
+func (d *watchdog) check(s sample) {
+	d.limit("goroutines", d.MaxGoroutines > 0 && s.goroutines > d.MaxGoroutines,
+		fmt.Sprint(s.goroutines), fmt.Sprint(d.MaxGoroutines))
+	d.limit("heap", d.MaxHeap > 0 && s.heap > d.MaxHeap,
+		fmt.Sprintf("%d bytes", s.heap), fmt.Sprintf("%d bytes", d.MaxHeap))
+	d.limit("latency", d.MaxLatency > 0 && s.p95 > d.MaxLatency,
+		s.p95.String(), d.MaxLatency.String())
+}
+
+// limit alerts when the limit called kind is first crossed, and logs when
+// it recovers.
+func (d *watchdog) limit(kind string, crossed bool, value, limit string) {
+	d.Lock()
+	was := d.over[kind]
+	d.over[kind] = crossed
+	d.Unlock()
+	if !crossed {
+		if was {
+			log.Println("Watchdog:", kind, "recovered at", value)
+		}
+		return
+	}
+	if was {
+		return
+	}
+	alert := Alert{Kind: kind, Value: value, Limit: limit, Time: time.Now()}
+	log.Println("Watchdog:", alert)
+	if d.DumpStacks {
+		buf := make([]byte, 1<<20)
+		n := runtime.Stack(buf, true)
+		log.Printf("Watchdog: goroutine stacks\n%s", buf[:n])
+	}
+	if d.Maintenance {
+		d.app.SetMaintenance(true)
+	}
+	if d.OnAlert != nil {
+		d.OnAlert(alert)
+	}
+}
+
+// MaintenanceRetryAfter is how long clients are told to wait, in the
+// Retry-After header, while the AppServer is in maintenance mode.
+var MaintenanceRetryAfter = 2 * time.Minute
+
+// SetMaintenance turns maintenance mode on or off. In maintenance mode
+// every request is answered with a 503, rendered from the error template
+// for 503 if there is one.
+func (App *AppServer) SetMaintenance(on bool) {
+	var v int32
+	if on {
+		v = 1
+		log.Println("Maintenance mode on")
+	} else {
+		log.Println("Maintenance mode off")
+	}
+	atomic.StoreInt32(&App.offline, v)
+}
+
+// Maintenance reports whether the AppServer is in maintenance mode.
+func (App *AppServer) Maintenance() bool {
+	return atomic.LoadInt32(&App.offline) == 1
+}
+
+// handle503req answers a request while in maintenance mode.
+func (App *AppServer) handle503req(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(MaintenanceRetryAfter/time.Second)))
+	if App.sendAPIError(w, req, http.StatusServiceUnavailable, "") {
+		return
+	}
+	if App.renderError(w, req, http.StatusServiceUnavailable) {
+		return
+	}
+	http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+}
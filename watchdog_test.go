@@ -0,0 +1,51 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	App := NewAppServer("0", 30)
+	var alerts []Alert
+	stop := App.EnableWatchdog(Watchdog{
+		Interval:      time.Hour,
+		MaxGoroutines: 100,
+		MaxLatency:    time.Second,
+		Maintenance:   true,
+		OnAlert: func(a Alert) {
+			alerts = append(alerts, a)
+		},
+	})
+	defer stop()
+	defer App.SetMaintenance(false)
+
+	d := App.watchdog
+	d.check(sample{goroutines: 10})
+	d.check(sample{goroutines: 200, p95: 2 * time.Second})
+	d.check(sample{goroutines: 300, p95: 2 * time.Second})
+	if len(alerts) != 2 || alerts[0].Kind != "goroutines" || alerts[1].Kind != "latency" {
+		t.Fatalf("got alerts %v, want goroutines then latency once each", alerts)
+	}
+	d.check(sample{goroutines: 10})
+	d.check(sample{goroutines: 200})
+	if len(alerts) != 3 {
+		t.Errorf("limit crossed again after recovering: got %d alerts, want 3", len(alerts))
+	}
+
+	if !App.Maintenance() {
+		t.Fatal("alert didn't turn on maintenance mode")
+	}
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("in maintenance: got status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	d.observe(time.Now().Add(-3 * time.Second))
+	if s := d.sample(); s.p95 < 3*time.Second || s.goroutines == 0 || s.heap == 0 {
+		t.Errorf("got sample %+v", s)
+	}
+}
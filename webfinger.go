@@ -0,0 +1,134 @@
+package wedge
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Media types of WebFinger and ActivityPub documents.
+const (
+	JRDType      = "application/jrd+json"
+	ActivityType = "application/activity+json"
+	// activityLDType is the JSON-LD form of ActivityType, which
+	// ActivityPub clients may ask for instead.
+	activityLDType  = "application/ld+json"
+	activityProfile = "https://www.w3.org/ns/activitystreams"
+)
+
+// JRD is a JSON Resource Descriptor, the document WebFinger returns for a
+// resource such as "acct:alice@example.com".
+type JRD struct {
+	Subject    string             `json:"subject"`
+	Aliases    []string           `json:"aliases,omitempty"`
+	Properties map[string]*string `json:"properties,omitempty"`
+	Links      []JRDLink          `json:"links,omitempty"`
+}
+
+// JRDLink is a link from a JRD to something about its subject, such as
+// their ActivityPub actor or profile page.
+type JRDLink struct {
+	Rel        string             `json:"rel"`
+	Type       string             `json:"type,omitempty"`
+	Href       string             `json:"href,omitempty"`
+	Template   string             `json:"template,omitempty"`
+	Titles     map[string]string  `json:"titles,omitempty"`
+	Properties map[string]*string `json:"properties,omitempty"`
+}
+
+// Webfinger serves /.well-known/webfinger, answering with the JRD which
+// lookup returns for the requested resource. If the request names any
+// rels, only the links with those rels are returned. Any site may query
+// it, as RFC 7033 asks.
+//
+// Example:
+//
+//	App.AddURLs(wedge.Webfinger(func(resource string) (*wedge.JRD, bool) {
+//		user, ok := users[strings.TrimPrefix(resource, "acct:")]
+//		if !ok {
+//			return nil, false
+//		}
+//		return &wedge.JRD{
+//			Subject: resource,
+//			Links: []wedge.JRDLink{
+//				{Rel: "self", Type: wedge.ActivityType, Href: user.ActorURL},
+//			},
+//		}, true
+//	}))
+func Webfinger(lookup func(resource string) (*JRD, bool)) *Rule {
+	return WellKnown("webfinger", func(w http.ResponseWriter, req *http.Request) (string, int) {
+		query := req.URL.Query()
+		resource := query.Get("resource")
+		// RFC 7033 asks for a 400 without a resource, which wedge
+		// can't send, so it's treated as a resource which isn't found.
+		if resource == "" {
+			return "", http.StatusNotFound
+		}
+		jrd, ok := lookup(resource)
+		if !ok {
+			return "", http.StatusNotFound
+		}
+		if rels := query["rel"]; len(rels) > 0 {
+			filtered := *jrd
+			filtered.Links = nil
+			for _, link := range jrd.Links {
+				for _, rel := range rels {
+					if link.Rel == rel {
+						filtered.Links = append(filtered.Links, link)
+						break
+					}
+				}
+			}
+			jrd = &filtered
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return writeJSON(w, JRDType, jrd)
+	})
+}
+
+// WantsActivity reports whether req asks for an ActivityPub document
+// rather than, say, an HTML page, so that one URL can serve both people
+// and fediverse servers.
+func WantsActivity(req *http.Request) bool {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediatype, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if mediatype == ActivityType ||
+			(mediatype == activityLDType && params["profile"] == activityProfile) {
+			return true
+		}
+	}
+	return false
+}
+
+// Activity answers with v encoded as an ActivityPub document. v is
+// encoded as it is, so it should include its "@context".
+//
+// Example:
+//
+//	func Profile(w http.ResponseWriter, req *http.Request) (string, int) {
+//		if wedge.WantsActivity(req) {
+//			return wedge.Activity(w, actor)
+//		}
+//		return renderProfile(actor), http.StatusOK
+//	}
+func Activity(w http.ResponseWriter, v interface{}) (string, int) {
+	w.Header().Add("Vary", "Accept")
+	return writeJSON(w, ActivityType, v)
+}
+
+// writeJSON encodes v as the response of an HTML route, which is sent as
+// it is, with ctype as its Content-Type.
+func writeJSON(w http.ResponseWriter, ctype string, v interface{}) (string, int) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Error encoding", ctype, err)
+		return "", http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", ctype)
+	return string(b), http.StatusOK
+}
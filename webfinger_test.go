@@ -0,0 +1,64 @@
+package wedge
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebfinger(t *testing.T) {
+	App := NewAppServer("8080", 30)
+	App.AddURLs(Webfinger(func(resource string) (*JRD, bool) {
+		if resource != "acct:alice@example.com" {
+			return nil, false
+		}
+		return &JRD{
+			Subject: resource,
+			Links: []JRDLink{
+				{Rel: "self", Type: ActivityType, Href: "https://example.com/alice"},
+				{Rel: "http://webfinger.net/rel/profile-page", Href: "https://example.com/@alice"},
+			},
+		}, true
+	}))
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET",
+		"/.well-known/webfinger?resource=acct:alice@example.com&rel=self", nil))
+	if ctype := w.Header().Get("Content-Type"); ctype != JRDType {
+		t.Errorf("got Content-Type %q", ctype)
+	}
+	var jrd JRD
+	if err := json.Unmarshal(w.Body.Bytes(), &jrd); err != nil {
+		t.Fatal(err)
+	}
+	if len(jrd.Links) != 1 || jrd.Links[0].Rel != "self" {
+		t.Errorf("links weren't filtered by rel: %+v", jrd.Links)
+	}
+
+	for _, path := range []string{
+		"/.well-known/webfinger?resource=acct:bob@example.com",
+		"/.well-known/webfinger",
+	} {
+		w := httptest.NewRecorder()
+		App.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != 404 {
+			t.Errorf("%s: got %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestWantsActivity(t *testing.T) {
+	tests := map[string]bool{
+		"application/activity+json": true,
+		`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`: true,
+		"application/ld+json":               false,
+		"text/html, application/json;q=0.9": false,
+	}
+	for accept, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := WantsActivity(req); got != want {
+			t.Errorf("%q: got %v, want %v", accept, got, want)
+		}
+	}
+}
@@ -17,6 +17,9 @@ const (
 	ICON
 	REDIRECT
 	DOWNLOAD
+	IMAGE
+	FEED
+	NDJSON
 )
 
 const (
@@ -24,7 +27,7 @@ const (
 )
 
 var (
-	routes  []*url
+	routes  []*Rule
 	TIMEOUT = time.Second
 )
 
@@ -34,6 +37,10 @@ type handlertype int
 // Handler functions should match this signature
 type view func(http.ResponseWriter, *http.Request) (string, int)
 
+// View is the type of handler functions, for code which passes them
+// around, such as Middleware.
+type View = view
+
 // BasicReplace takes a string and a map[string]string which it uses
 // to replace any instances of a key by the value under it.
 func BasicReplace(template string, replacement_map map[string]string) string {
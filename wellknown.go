@@ -0,0 +1,47 @@
+package wedge
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// wellKnownMeta is the Meta key under which routes created by WellKnown,
+// and ACMEChallenge, record the name they serve.
+const wellKnownMeta = "well-known"
+
+// wellKnownName is what RFC 8615 allows as a well-known URI suffix, going
+// by the names in the IANA registry.
+var wellKnownName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// WellKnown returns a route serving v at /.well-known/name, for the URIs
+// in the IANA well-known registry such as "webfinger", "change-password"
+// or "assetlinks.json". It panics if name isn't a valid suffix, in the
+// same way URL panics for an invalid pattern.
+//
+// Example:
+//
+//	App.AddURLs(wedge.WellKnown("change-password", func(w http.ResponseWriter, req *http.Request) (string, int) {
+//		return "/account/password/", http.StatusSeeOther
+//	}))
+func WellKnown(name string, v view, opts ...Option) *Rule {
+	if !wellKnownName.MatchString(name) {
+		panic(fmt.Sprintf("wedge: invalid well-known URI name %q", name))
+	}
+	u := Route("^/\\.well-known/"+regexp.QuoteMeta(name)+"$", v,
+		append([]Option{Name("Well-known " + name)}, opts...)...)
+	return u.SetMeta(wellKnownMeta, name)
+}
+
+// WellKnownURIs lists the names served under /.well-known/ by the routes
+// added to the AppServer.
+func (App *AppServer) WellKnownURIs() []string {
+	var names []string
+	for _, route := range App.routes {
+		if name, ok := route.Meta(wellKnownMeta).(string); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,47 @@
+package wedge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWellKnown(t *testing.T) {
+	view := func(w http.ResponseWriter, req *http.Request) (string, int) {
+		return "{}", http.StatusOK
+	}
+	App := NewAppServer("8080", 30)
+	App.AddURLs(
+		WellKnown("assetlinks.json", view),
+		WellKnown("change-password", view),
+		ACMEChallenge("."),
+	)
+
+	w := httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/assetlinks.json", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	App.ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/assetlinksXjson", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("the dot in the name matched anything: got %d", w.Code)
+	}
+
+	want := []string{"acme-challenge", "assetlinks.json", "change-password"}
+	if got := App.WellKnownURIs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, name := range []string{"", "../etc", "a/b", "Upper"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q didn't panic", name)
+				}
+			}()
+			WellKnown(name, view)
+		}()
+	}
+}
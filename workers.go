@@ -0,0 +1,165 @@
+package wedge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// ErrNoWorkers is returned by Submit when EnableWorkers hasn't been called.
+var ErrNoWorkers = errors.New("wedge: worker pool has not been enabled")
+
+// Task is a unit of work which can be handed to the worker pool.
+type Task func() (interface{}, error)
+
+type taskResult struct {
+	value interface{}
+	err   error
+}
+
+type task struct {
+	fn     Task
+	result chan taskResult
+}
+
+// workerPool is a fixed number of goroutines reading tasks from a queue.
+type workerPool struct {
+	tasks     chan task
+	quit      <-chan struct{}
+	size      int
+	busy      int64
+	submitted int64
+	completed int64
+	cancelled int64
+}
+
+// WorkerStats is a snapshot of the activity of the worker pool.
+type WorkerStats struct {
+	Size      int
+	Busy      int64
+	Queued    int
+	Submitted int64
+	Completed int64
+	Cancelled int64
+}
+
+// EnableWorkers starts a pool of n goroutines for CPU heavy work, such as
+// resizing images or crunching reports. Up to queue tasks may wait for a
+// free worker before Submit blocks. Views hand work to the pool with Submit
+// instead of starting their own goroutines, so the amount of work being
+// done at any one time stays bounded. The pool can only be enabled once,
+// and later calls are ignored.
+func (App *AppServer) EnableWorkers(n, queue int) {
+	if App.workers != nil {
+		log.Println("Worker pool is already enabled, ignoring EnableWorkers")
+		return
+	}
+	pool := &workerPool{
+		tasks: make(chan task, queue),
+		quit:  App.quit,
+		size:  n,
+	}
+	for i := 0; i < n; i++ {
+		go pool.work()
+	}
+	App.workers = pool
+}
+
+func (p *workerPool) work() {
+	for {
+		var t task
+		select {
+		case t = <-p.tasks:
+		case <-p.quit:
+			return
+		}
+		atomic.AddInt64(&p.busy, 1)
+		value, err := t.fn()
+		atomic.AddInt64(&p.busy, -1)
+		atomic.AddInt64(&p.completed, 1)
+		// result is buffered so this never blocks, even if the
+		// submitter has given up waiting.
+		t.result <- taskResult{value, err}
+	}
+}
+
+// Submit queues fn on the worker pool and waits for its result. If ctx is
+// done before fn has been picked up or has finished, Submit returns
+// ctx.Err() straight away. A task which has already started will run to
+// completion, but its result is discarded.
+//
+// Example:
+//
+//	thumb, err := App.Submit(req.Context(), func() (interface{}, error) {
+//		return resize(img, 200, 200)
+//	})
+func (App *AppServer) Submit(ctx context.Context, fn Task) (interface{}, error) {
+	pool := App.workers
+	if pool == nil {
+		return nil, ErrNoWorkers
+	}
+	t := task{fn, make(chan taskResult, 1)}
+	atomic.AddInt64(&pool.submitted, 1)
+	// select picks at random when the queue has room, so check first
+	// that the caller hasn't already given up.
+	if err := ctx.Err(); err != nil {
+		atomic.AddInt64(&pool.cancelled, 1)
+		return nil, err
+	}
+
+	select {
+	case pool.tasks <- t:
+	case <-ctx.Done():
+		atomic.AddInt64(&pool.cancelled, 1)
+		return nil, ctx.Err()
+	case <-pool.quit:
+		return nil, ErrShutdown
+	}
+
+	select {
+	case res := <-t.result:
+		return res.value, res.err
+	case <-ctx.Done():
+		atomic.AddInt64(&pool.cancelled, 1)
+		return nil, ctx.Err()
+	case <-pool.quit:
+		// the task may have been picked up just before the workers
+		// stopped.
+		select {
+		case res := <-t.result:
+			return res.value, res.err
+		default:
+			return nil, ErrShutdown
+		}
+	}
+}
+
+// WorkerStats returns the current statistics for the worker pool. The
+// zero value is returned if the pool hasn't been enabled.
+func (App *AppServer) WorkerStats() WorkerStats {
+	pool := App.workers
+	if pool == nil {
+		return WorkerStats{}
+	}
+	return WorkerStats{
+		Size:      pool.size,
+		Busy:      atomic.LoadInt64(&pool.busy),
+		Queued:    len(pool.tasks),
+		Submitted: atomic.LoadInt64(&pool.submitted),
+		Completed: atomic.LoadInt64(&pool.completed),
+		Cancelled: atomic.LoadInt64(&pool.cancelled),
+	}
+}
+
+// html renders the statistics as a table for the statistics page.
+func (s WorkerStats) html() string {
+	return fmt.Sprintf(`<table border="2">
+					 <tr><th>Workers</th><th>Busy</th><th>Queued</th>
+					 <th>Submitted</th><th>Completed</th><th>Cancelled</th></tr>
+					 <tr><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td></tr>
+					 </table>`,
+		s.Size, s.Busy, s.Queued, s.Submitted, s.Completed, s.Cancelled,
+	)
+}
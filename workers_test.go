@@ -0,0 +1,92 @@
+package wedge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkers(t *testing.T) {
+	App := NewAppServer("0", 30)
+	defer App.Close()
+	if _, err := App.Submit(context.Background(), nil); err != ErrNoWorkers {
+		t.Errorf("before EnableWorkers: got %v", err)
+	}
+	App.EnableWorkers(1, 1)
+	// a second pool isn't started alongside the first.
+	App.EnableWorkers(4, 4)
+	if size := App.WorkerStats().Size; size != 1 {
+		t.Errorf("got %d workers", size)
+	}
+
+	failed := errors.New("failed")
+	value, err := App.Submit(context.Background(), func() (interface{}, error) {
+		return 42, failed
+	})
+	if value != 42 || err != failed {
+		t.Errorf("got %v and %v", value, err)
+	}
+
+	// a cancelled context never queues the task.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	for i := 0; i < 10; i++ {
+		if _, err := App.Submit(ctx, func() (interface{}, error) {
+			ran = true
+			return nil, nil
+		}); err != context.Canceled {
+			t.Errorf("cancelled: got %v", err)
+		}
+	}
+	if stats := App.WorkerStats(); ran || stats.Queued != 0 || stats.Cancelled != 10 {
+		t.Errorf("cancelled: got ran %v and %+v", ran, stats)
+	}
+}
+
+func TestWorkersQueueFull(t *testing.T) {
+	App := NewAppServer("0", 30)
+	defer App.Close()
+	App.EnableWorkers(1, 1)
+	release := make(chan bool)
+	started := make(chan bool)
+	block := func() (interface{}, error) {
+		started <- true
+		<-release
+		return "done", nil
+	}
+
+	results := make(chan interface{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			value, _ := App.Submit(context.Background(), block)
+			results <- value
+		}()
+	}
+	// one task runs while the other waits in the queue.
+	<-started
+	for App.WorkerStats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := App.Submit(ctx, block); err != context.DeadlineExceeded {
+		t.Errorf("queue full: got %v", err)
+	}
+	if stats := App.WorkerStats(); stats.Busy != 1 || stats.Queued != 1 || stats.Cancelled != 1 {
+		t.Errorf("got %+v", stats)
+	}
+
+	close(release)
+	<-started
+	for i := 0; i < 2; i++ {
+		if value := <-results; value != "done" {
+			t.Errorf("got %v", value)
+		}
+	}
+	if stats := App.WorkerStats(); stats.Submitted != 3 || stats.Completed != 2 {
+		t.Errorf("got %+v", stats)
+	}
+}
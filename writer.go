@@ -0,0 +1,202 @@
+package wedge
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// WrapWriter returns a ResponseWriter which sends Header, Write and
+// WriteHeader to wrapper, for middleware which needs to see or change
+// the response, while keeping the optional interfaces of w.
+//
+// Wrapping a ResponseWriter in a struct hides any http.Flusher,
+// http.Hijacker, io.ReaderFrom or http.Pusher it implements, which breaks
+// streaming, websockets and the like further down. The value returned
+// here implements exactly the ones w does. Each is handled by wrapper if
+// it implements it too, and by w otherwise, apart from ReadFrom which
+// copies through wrapper's Write so the middleware still sees the body.
+//
+// Example:
+//
+//	type statusWriter struct {
+//		http.ResponseWriter
+//		status int
+//	}
+//
+//	func (s *statusWriter) WriteHeader(status int) {
+//		s.status = status
+//		s.ResponseWriter.WriteHeader(status)
+//	}
+//
+//	w = wedge.WrapWriter(w, &statusWriter{ResponseWriter: w})
+func WrapWriter(w, wrapper http.ResponseWriter) http.ResponseWriter {
+	x := &wrapped{wrapper, w}
+	caps := 0
+	if _, ok := w.(http.Flusher); ok {
+		caps |= canFlush
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		caps |= canHijack
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		caps |= canReadFrom
+	}
+	if _, ok := w.(http.Pusher); ok {
+		caps |= canPush
+	}
+
+	// each combination needs its own type, as the method set of a type
+	// is fixed when it's compiled.
+	switch caps {
+	case canFlush:
+		return struct {
+			*wrapped
+			flusher
+		}{x, flusher{x}}
+	case canHijack:
+		return struct {
+			*wrapped
+			hijacker
+		}{x, hijacker{x}}
+	case canFlush | canHijack:
+		return struct {
+			*wrapped
+			flusher
+			hijacker
+		}{x, flusher{x}, hijacker{x}}
+	case canReadFrom:
+		return struct {
+			*wrapped
+			readerFrom
+		}{x, readerFrom{x}}
+	case canFlush | canReadFrom:
+		return struct {
+			*wrapped
+			flusher
+			readerFrom
+		}{x, flusher{x}, readerFrom{x}}
+	case canHijack | canReadFrom:
+		return struct {
+			*wrapped
+			hijacker
+			readerFrom
+		}{x, hijacker{x}, readerFrom{x}}
+	case canFlush | canHijack | canReadFrom:
+		return struct {
+			*wrapped
+			flusher
+			hijacker
+			readerFrom
+		}{x, flusher{x}, hijacker{x}, readerFrom{x}}
+	case canPush:
+		return struct {
+			*wrapped
+			pusher
+		}{x, pusher{x}}
+	case canFlush | canPush:
+		return struct {
+			*wrapped
+			flusher
+			pusher
+		}{x, flusher{x}, pusher{x}}
+	case canHijack | canPush:
+		return struct {
+			*wrapped
+			hijacker
+			pusher
+		}{x, hijacker{x}, pusher{x}}
+	case canFlush | canHijack | canPush:
+		return struct {
+			*wrapped
+			flusher
+			hijacker
+			pusher
+		}{x, flusher{x}, hijacker{x}, pusher{x}}
+	case canReadFrom | canPush:
+		return struct {
+			*wrapped
+			readerFrom
+			pusher
+		}{x, readerFrom{x}, pusher{x}}
+	case canFlush | canReadFrom | canPush:
+		return struct {
+			*wrapped
+			flusher
+			readerFrom
+			pusher
+		}{x, flusher{x}, readerFrom{x}, pusher{x}}
+	case canHijack | canReadFrom | canPush:
+		return struct {
+			*wrapped
+			hijacker
+			readerFrom
+			pusher
+		}{x, hijacker{x}, readerFrom{x}, pusher{x}}
+	case canFlush | canHijack | canReadFrom | canPush:
+		return struct {
+			*wrapped
+			flusher
+			hijacker
+			readerFrom
+			pusher
+		}{x, flusher{x}, hijacker{x}, readerFrom{x}, pusher{x}}
+	}
+	return x
+}
+
+const (
+	canFlush = 1 << iota
+	canHijack
+	canReadFrom
+	canPush
+)
+
+// wrapped holds the wrapper and the ResponseWriter it wraps.
+type wrapped struct {
+	http.ResponseWriter
+	orig http.ResponseWriter
+}
+
+// Unwrap returns the original ResponseWriter, for http.ResponseController.
+func (x *wrapped) Unwrap() http.ResponseWriter {
+	return x.orig
+}
+
+type flusher struct{ x *wrapped }
+
+func (f flusher) Flush() {
+	if fl, ok := f.x.ResponseWriter.(http.Flusher); ok {
+		fl.Flush()
+		return
+	}
+	f.x.orig.(http.Flusher).Flush()
+}
+
+type hijacker struct{ x *wrapped }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := h.x.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return h.x.orig.(http.Hijacker).Hijack()
+}
+
+type readerFrom struct{ x *wrapped }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	if rf, ok := r.x.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(r.x.ResponseWriter, src)
+}
+
+type pusher struct{ x *wrapped }
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	if ps, ok := p.x.ResponseWriter.(http.Pusher); ok {
+		return ps.Push(target, opts)
+	}
+	return p.x.orig.(http.Pusher).Push(target, opts)
+}
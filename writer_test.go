@@ -0,0 +1,72 @@
+package wedge
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fullWriter implements every optional interface.
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (f *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	f.hijacked = true
+	return nil, nil, nil
+}
+
+func (f *fullWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(f.ResponseRecorder, r)
+}
+
+func (f *fullWriter) Push(target string, opts *http.PushOptions) error {
+	return nil
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	c.written += len(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func TestWrapWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := WrapWriter(rec, &countingWriter{ResponseWriter: rec})
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("Flusher was lost")
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		t.Error("Hijacker was added")
+	}
+
+	full := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	counter := &countingWriter{ResponseWriter: full}
+	w = WrapWriter(full, counter)
+	_, flush := w.(http.Flusher)
+	_, hijack := w.(http.Hijacker)
+	_, readFrom := w.(io.ReaderFrom)
+	_, push := w.(http.Pusher)
+	if !flush || !hijack || !readFrom || !push {
+		t.Errorf("interfaces were lost: Flusher %v, Hijacker %v, ReaderFrom %v, Pusher %v",
+			flush, hijack, readFrom, push)
+	}
+
+	w.(http.Hijacker).Hijack()
+	if !full.hijacked {
+		t.Error("Hijack didn't reach the original writer")
+	}
+	io.Copy(w, strings.NewReader("hello"))
+	if counter.written != 5 || full.Body.String() != "hello" {
+		t.Errorf("ReadFrom bypassed the wrapper: counted %d, wrote %q", counter.written, full.Body)
+	}
+}